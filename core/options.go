@@ -21,6 +21,7 @@ type Options struct {
 	CapletsPath   string
 	Script        string
 	PcapBufSize   int
+	Firewall      string
 }
 
 func ParseOptions() (Options, error) {
@@ -42,6 +43,7 @@ func ParseOptions() (Options, error) {
 	flag.StringVar(&o.CapletsPath, "caplets-path", "", "Specify an alternative base path for caplets.")
 	flag.StringVar(&o.Script, "script", "", "Load a session script.")
 	flag.IntVar(&o.PcapBufSize, "pcap-buf-size", -1, "PCAP buffer size, leave to 0 for the default value.")
+	flag.StringVar(&o.Firewall, "firewall", "auto", "Firewall backend to use on Linux, one of auto, iptables or nftables.")
 
 	flag.Parse()
 
@@ -1,5 +1,11 @@
 package firewall
 
+const (
+	BackendAuto     = "auto"
+	BackendIPTables = "iptables"
+	BackendNFTables = "nftables"
+)
+
 type FirewallManager interface {
 	IsForwardingEnabled() bool
 	EnableForwarding(enabled bool) error
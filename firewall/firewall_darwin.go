@@ -27,7 +27,9 @@ type PfFirewall struct {
 	enabled    bool
 }
 
-func Make(iface *network.Endpoint) FirewallManager {
+// Make returns the platform firewall manager, the backend argument is only
+// meaningful on Linux and is ignored here.
+func Make(iface *network.Endpoint, backend string) FirewallManager {
 	firewall := &PfFirewall{
 		iface:      iface,
 		filename:   pfFilePath,
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/bettercap/bettercap/v2/core"
+	"github.com/bettercap/bettercap/v2/log"
 	"github.com/bettercap/bettercap/v2/network"
 
 	"github.com/evilsocket/islazy/fs"
@@ -25,7 +26,34 @@ const (
 	IPV6ForwardingFile = "/proc/sys/net/ipv6/conf/all/forwarding"
 )
 
-func Make(iface *network.Endpoint) FirewallManager {
+// Make returns the firewall manager for the requested backend, either
+// iptables or nftables. With BackendAuto iptables is used if its binary
+// is available and nftables is used otherwise.
+func Make(iface *network.Endpoint, backend string) FirewallManager {
+	if backend == BackendAuto || backend == "" {
+		if core.HasBinary("iptables") {
+			backend = BackendIPTables
+		} else {
+			backend = BackendNFTables
+		}
+	}
+
+	if backend == BackendNFTables {
+		if firewall, err := MakeNFTables(iface); err != nil {
+			log.Warning("could not initialize nftables, falling back to iptables: %v", err)
+		} else {
+			log.Debug("using nftables firewall backend")
+			return firewall
+		}
+	} else if backend != BackendIPTables {
+		log.Warning("unknown firewall backend '%s', using iptables", backend)
+	}
+
+	log.Debug("using iptables firewall backend")
+	return MakeIPTables(iface)
+}
+
+func MakeIPTables(iface *network.Endpoint) FirewallManager {
 	firewall := &LinuxFirewall{
 		iface:        iface,
 		forwarding:   false,
@@ -0,0 +1,308 @@
+package firewall
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/bettercap/bettercap/v2/network"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	NFTablesTableName = "bettercap"
+	NFTablesChainName = "prerouting"
+)
+
+// NFTablesFirewall implements the FirewallManager interface by talking
+// netlink directly to nf_tables, all rules live in a dedicated table
+// (one per address family) so that restoring is a single table delete.
+type NFTablesFirewall struct {
+	sync.Mutex
+
+	iface        *network.Endpoint
+	forwarding   bool
+	restore      bool
+	conn         *nftables.Conn
+	tables       map[nftables.TableFamily]*nftables.Table
+	chains       map[nftables.TableFamily]*nftables.Chain
+	redirections map[string]*nftables.Rule
+}
+
+func MakeNFTables(iface *network.Endpoint) (*NFTablesFirewall, error) {
+	conn, err := nftables.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// make sure nf_tables is actually supported by this kernel
+	if _, err = conn.ListTables(); err != nil {
+		return nil, err
+	}
+
+	firewall := &NFTablesFirewall{
+		iface:        iface,
+		conn:         conn,
+		tables:       make(map[nftables.TableFamily]*nftables.Table),
+		chains:       make(map[nftables.TableFamily]*nftables.Chain),
+		redirections: make(map[string]*nftables.Rule),
+	}
+
+	firewall.forwarding = firewall.IsForwardingEnabled()
+
+	return firewall, nil
+}
+
+func (f *NFTablesFirewall) IsForwardingEnabled() bool {
+	return LinuxFirewall{}.IsForwardingEnabled()
+}
+
+func (f *NFTablesFirewall) EnableForwarding(enabled bool) error {
+	if err := (LinuxFirewall{}).EnableForwarding(enabled); err != nil {
+		return err
+	}
+
+	f.restore = true
+	return nil
+}
+
+func nftFamilyOf(r *Redirection) nftables.TableFamily {
+	if strings.Count(r.DstAddress, ":") < 2 {
+		return nftables.TableFamilyIPv4
+	}
+	return nftables.TableFamilyIPv6
+}
+
+func nftIfname(name string) []byte {
+	b := make([]byte, unix.IFNAMSIZ)
+	copy(b, name+"\x00")
+	return b
+}
+
+func nftIP(family nftables.TableFamily, address string) ([]byte, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("'%s' is not a valid ip address", address)
+	}
+
+	if family == nftables.TableFamilyIPv4 {
+		if ip = ip.To4(); ip == nil {
+			return nil, fmt.Errorf("'%s' is not a valid ipv4 address", address)
+		}
+		return ip, nil
+	}
+
+	return ip.To16(), nil
+}
+
+// nftDestination loads the destination address of the packet in register 1.
+func nftDestination(family nftables.TableFamily) *expr.Payload {
+	if family == nftables.TableFamilyIPv4 {
+		return &expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 16, Len: 4}
+	}
+	return &expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 24, Len: 16}
+}
+
+func (f *NFTablesFirewall) getExpressions(r *Redirection, family nftables.TableFamily) ([]expr.Any, error) {
+	proto := byte(unix.IPPROTO_TCP)
+	switch strings.ToLower(r.Protocol) {
+	case "tcp":
+	case "udp":
+		proto = unix.IPPROTO_UDP
+	default:
+		return nil, fmt.Errorf("protocol '%s' not supported", r.Protocol)
+	}
+
+	exprs := []expr.Any{
+		// iifname <interface>
+		&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: nftIfname(r.Interface)},
+		// meta l4proto <protocol>
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+	}
+
+	// ip daddr <address>
+	if r.SrcAddress != "" {
+		addr, err := nftIP(family, r.SrcAddress)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs,
+			nftDestination(family),
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: addr})
+	}
+
+	// th dport <port>
+	exprs = append(exprs,
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: binaryutil.BigEndian.PutUint16(uint16(r.SrcPort))})
+
+	// exclude traffic destined to the proxy machine itself (prevents redirect loops)
+	if r.ExcludeAddress != "" {
+		addr, err := nftIP(family, r.ExcludeAddress)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs,
+			nftDestination(family),
+			&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: addr})
+	}
+
+	// dnat to <address>:<port>
+	dst, err := nftIP(family, r.DstAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs = append(exprs,
+		&expr.Immediate{Register: 1, Data: dst},
+		&expr.Immediate{Register: 2, Data: binaryutil.BigEndian.PutUint16(uint16(r.DstPort))},
+		&expr.NAT{
+			Type:        expr.NATTypeDestNAT,
+			Family:      uint32(family),
+			RegAddrMin:  1,
+			RegProtoMin: 2,
+			Specified:   true,
+		})
+
+	return exprs, nil
+}
+
+// getChain returns the nat prerouting chain for the given family, creating
+// the table and the chain if needed. Leftovers of a previous session that
+// didn't shut down cleanly are removed in the same transaction.
+func (f *NFTablesFirewall) getChain(family nftables.TableFamily) *nftables.Chain {
+	if chain, found := f.chains[family]; found {
+		return chain
+	}
+
+	table := &nftables.Table{Name: NFTablesTableName, Family: family}
+
+	f.conn.AddTable(table)
+	f.conn.DelTable(table)
+	f.conn.AddTable(table)
+
+	chain := f.conn.AddChain(&nftables.Chain{
+		Name:     NFTablesChainName,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookPrerouting,
+		Priority: nftables.ChainPriorityNATDest,
+	})
+
+	f.tables[family] = table
+	f.chains[family] = chain
+
+	return chain
+}
+
+func (f *NFTablesFirewall) EnableRedirection(r *Redirection, enabled bool) error {
+	f.Lock()
+	defer f.Unlock()
+
+	rkey := r.String()
+	rule, found := f.redirections[rkey]
+
+	if enabled {
+		if found {
+			return fmt.Errorf("Redirection '%s' already enabled.", rkey)
+		}
+
+		family := nftFamilyOf(r)
+		exprs, err := f.getExpressions(r, family)
+		if err != nil {
+			return err
+		}
+
+		_, existed := f.chains[family]
+		chain := f.getChain(family)
+		rule = f.conn.AddRule(&nftables.Rule{
+			Table:    chain.Table,
+			Chain:    chain,
+			Exprs:    exprs,
+			UserData: []byte(rkey),
+		})
+
+		// table, chain and rule are committed as a single transaction
+		if err := f.conn.Flush(); err != nil {
+			if !existed {
+				delete(f.tables, family)
+				delete(f.chains, family)
+			}
+			return err
+		}
+
+		f.redirections[rkey] = rule
+	} else {
+		if !found {
+			return nil
+		}
+
+		// rules returned by AddRule have no handle, get the one assigned by the kernel
+		installed, err := f.installedRule(rule)
+		if err != nil {
+			return err
+		} else if installed != nil {
+			if err := f.conn.DelRule(installed); err != nil {
+				return err
+			} else if err := f.conn.Flush(); err != nil {
+				return err
+			}
+		}
+
+		delete(f.redirections, rkey)
+	}
+
+	return nil
+}
+
+// installedRule returns the rule of the kernel with the same user data of
+// rule, or nil if it's not there anymore.
+func (f *NFTablesFirewall) installedRule(rule *nftables.Rule) (*nftables.Rule, error) {
+	rules, err := f.conn.GetRules(rule.Table, rule.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, installed := range rules {
+		if bytes.Equal(installed.UserData, rule.UserData) {
+			// the parsed rule only has the table name
+			installed.Table = rule.Table
+			installed.Chain = rule.Chain
+			return installed, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *NFTablesFirewall) Restore() {
+	f.Lock()
+	defer f.Unlock()
+
+	if len(f.tables) > 0 {
+		for _, table := range f.tables {
+			f.conn.DelTable(table)
+		}
+
+		if err := f.conn.Flush(); err != nil {
+			fmt.Printf("%s", err)
+		}
+
+		f.tables = make(map[nftables.TableFamily]*nftables.Table)
+		f.chains = make(map[nftables.TableFamily]*nftables.Chain)
+		f.redirections = make(map[string]*nftables.Rule)
+	}
+
+	if f.restore {
+		if err := f.EnableForwarding(f.forwarding); err != nil {
+			fmt.Printf("%s", err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package firewall
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+)
+
+func TestNFTIfname(t *testing.T) {
+	name := nftIfname("eth0")
+	if len(name) != 16 {
+		t.Fatalf("expected 16 bytes, got %d", len(name))
+	}
+	if !bytes.Equal(name[:5], []byte("eth0\x00")) {
+		t.Errorf("unexpected interface name encoding %v", name)
+	}
+}
+
+func TestNFTIP(t *testing.T) {
+	if ip, err := nftIP(nftables.TableFamilyIPv4, "192.168.1.1"); err != nil {
+		t.Fatal(err)
+	} else if len(ip) != 4 {
+		t.Errorf("expected 4 bytes, got %d", len(ip))
+	}
+
+	if ip, err := nftIP(nftables.TableFamilyIPv6, "fe80::1"); err != nil {
+		t.Fatal(err)
+	} else if len(ip) != 16 {
+		t.Errorf("expected 16 bytes, got %d", len(ip))
+	}
+
+	if _, err := nftIP(nftables.TableFamilyIPv4, "fe80::1"); err == nil {
+		t.Error("expected error for ipv6 address in ipv4 family")
+	}
+
+	if _, err := nftIP(nftables.TableFamilyIPv4, "not an ip"); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+func TestNFTFamilyOf(t *testing.T) {
+	if f := nftFamilyOf(NewRedirection("eth0", "tcp", 80, "192.168.1.2", 8080)); f != nftables.TableFamilyIPv4 {
+		t.Errorf("expected ipv4 family, got %v", f)
+	}
+	if f := nftFamilyOf(NewRedirection("eth0", "tcp", 80, "fe80::2", 8080)); f != nftables.TableFamilyIPv6 {
+		t.Errorf("expected ipv6 family, got %v", f)
+	}
+}
+
+func TestNFTGetExpressions(t *testing.T) {
+	f := &NFTablesFirewall{}
+
+	r := NewRedirection("eth0", "tcp", 80, "192.168.1.2", 8080)
+	exprs, err := f.getExpressions(r, nftables.TableFamilyIPv4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// iifname, l4proto, dport, two immediates and the nat
+	if len(exprs) != 9 {
+		t.Fatalf("expected 9 expressions, got %d", len(exprs))
+	}
+
+	nat, ok := exprs[len(exprs)-1].(*expr.NAT)
+	if !ok {
+		t.Fatalf("expected last expression to be a NAT, got %T", exprs[len(exprs)-1])
+	} else if nat.Type != expr.NATTypeDestNAT {
+		t.Errorf("expected dnat, got %v", nat.Type)
+	}
+
+	port := exprs[len(exprs)-2].(*expr.Immediate)
+	if !bytes.Equal(port.Data, []byte{0x1f, 0x90}) {
+		t.Errorf("unexpected destination port %v", port.Data)
+	}
+
+	r.SrcAddress = "10.0.0.1"
+	r.ExcludeAddress = "192.168.1.2"
+	if exprs, err = f.getExpressions(r, nftables.TableFamilyIPv4); err != nil {
+		t.Fatal(err)
+	} else if len(exprs) != 13 {
+		t.Fatalf("expected 13 expressions, got %d", len(exprs))
+	}
+
+	found := false
+	for _, e := range exprs {
+		if cmp, ok := e.(*expr.Cmp); ok && cmp.Op == expr.CmpOpNeq {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected exclusion comparison")
+	}
+
+	r.Protocol = "icmp"
+	if _, err = f.getExpressions(r, nftables.TableFamilyIPv4); err == nil {
+		t.Error("expected error for unsupported protocol")
+	}
+}
+
+// fakeNFTables is a minimal nf_tables kernel keeping the rules added to the
+// bettercap chain, with the handles the real one would assign.
+type fakeNFTables struct {
+	rules      map[uint64][]byte
+	nextHandle uint64
+}
+
+func nftMsgType(msg uint16) netlink.HeaderType {
+	return netlink.HeaderType((unix.NFNL_SUBSYS_NFTABLES << 8) | msg)
+}
+
+func (k *fakeNFTables) dial(req []netlink.Message) ([]netlink.Message, error) {
+	ack := []netlink.Message{{Header: netlink.Header{Type: netlink.Error}, Data: make([]byte, 4)}}
+
+	for _, msg := range req {
+		switch msg.Header.Type {
+		case nftMsgType(unix.NFT_MSG_GETRULE):
+			reply := make([]netlink.Message, 0)
+			for handle, userData := range k.rules {
+				reply = append(reply, netlink.Message{
+					Header: netlink.Header{Type: nftMsgType(unix.NFT_MSG_NEWRULE)},
+					Data: append([]byte{unix.NFPROTO_IPV4, 0, 0, 0}, nltest.MustMarshalAttributes([]netlink.Attribute{
+						{Type: unix.NFTA_RULE_TABLE, Data: []byte(NFTablesTableName + "\x00")},
+						{Type: unix.NFTA_RULE_CHAIN, Data: []byte(NFTablesChainName + "\x00")},
+						{Type: unix.NFTA_RULE_HANDLE, Data: binaryutil.BigEndian.PutUint64(handle)},
+						{Type: unix.NFTA_RULE_USERDATA, Data: userData},
+					})...),
+				})
+			}
+			return reply, nil
+
+		case nftMsgType(unix.NFT_MSG_NEWRULE), nftMsgType(unix.NFT_MSG_DELRULE):
+			ad, err := netlink.NewAttributeDecoder(msg.Data[4:])
+			if err != nil {
+				return nil, err
+			}
+			ad.ByteOrder = binary.BigEndian
+
+			var handle uint64
+			var userData []byte
+			for ad.Next() {
+				switch ad.Type() {
+				case unix.NFTA_RULE_HANDLE:
+					handle = ad.Uint64()
+				case unix.NFTA_RULE_USERDATA:
+					userData = ad.Bytes()
+				}
+			}
+
+			if msg.Header.Type == nftMsgType(unix.NFT_MSG_NEWRULE) {
+				k.nextHandle++
+				k.rules[k.nextHandle] = userData
+			} else if _, found := k.rules[handle]; !found {
+				return nltest.Error(int(unix.ENOENT), []netlink.Message{msg})
+			} else {
+				delete(k.rules, handle)
+			}
+		}
+	}
+
+	return ack, nil
+}
+
+func TestNFTEnableDisableRedirection(t *testing.T) {
+	kernel := &fakeNFTables{rules: make(map[uint64][]byte)}
+	conn, err := nftables.New(nftables.WithTestDial(kernel.dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &NFTablesFirewall{
+		conn:         conn,
+		tables:       make(map[nftables.TableFamily]*nftables.Table),
+		chains:       make(map[nftables.TableFamily]*nftables.Chain),
+		redirections: make(map[string]*nftables.Rule),
+	}
+
+	r := NewRedirection("eth0", "tcp", 80, "192.168.1.2", 8080)
+	if err := f.EnableRedirection(r, true); err != nil {
+		t.Fatal(err)
+	} else if len(kernel.rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(kernel.rules))
+	}
+
+	if err := f.EnableRedirection(r, false); err != nil {
+		t.Fatal(err)
+	} else if len(kernel.rules) != 0 {
+		t.Fatalf("expected the rule to be deleted, got %d", len(kernel.rules))
+	} else if len(f.redirections) != 0 {
+		t.Fatalf("unexpected redirections %v", f.redirections)
+	}
+
+	// off and on again doesn't leave stale rules behind
+	if err := f.EnableRedirection(r, true); err != nil {
+		t.Fatal(err)
+	} else if err := f.EnableRedirection(r, false); err != nil {
+		t.Fatal(err)
+	} else if len(kernel.rules) != 0 {
+		t.Fatalf("expected no rules, got %d", len(kernel.rules))
+	}
+}
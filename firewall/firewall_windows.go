@@ -14,7 +14,9 @@ type WindowsFirewall struct {
 	redirections map[string]*Redirection
 }
 
-func Make(iface *network.Endpoint) FirewallManager {
+// Make returns the platform firewall manager, the backend argument is only
+// meaningful on Linux and is ignored here.
+func Make(iface *network.Endpoint, backend string) FirewallManager {
 	firewall := &WindowsFirewall{
 		iface:        iface,
 		forwarding:   false,
@@ -18,6 +18,7 @@ require (
 	github.com/gobwas/glob v0.0.0-20181002190808-e7a84e9525fe
 	github.com/google/go-github v17.0.0+incompatible
 	github.com/google/gousb v1.1.3
+	github.com/google/nftables v0.3.0
 	github.com/gopacket/gopacket v1.6.1
 	github.com/gorilla/mux v1.8.1
 	github.com/gorilla/websocket v1.5.3
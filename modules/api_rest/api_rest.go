@@ -246,6 +246,7 @@ func (mod *RestAPI) Configure() error {
 	router.HandleFunc("/api/session/ble/{mac}", mod.sessionRoute)
 	router.HandleFunc("/api/session/hid", mod.sessionRoute)
 	router.HandleFunc("/api/session/hid/{mac}", mod.sessionRoute)
+	router.HandleFunc("/api/session/credentials", mod.sessionRoute)
 	router.HandleFunc("/api/session/env", mod.sessionRoute)
 	router.HandleFunc("/api/session/gateway", mod.sessionRoute)
 	router.HandleFunc("/api/session/interface", mod.sessionRoute)
@@ -159,6 +159,10 @@ func (mod *RestAPI) showHID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (mod *RestAPI) showCredentials(w http.ResponseWriter, r *http.Request) {
+	mod.toJSON(w, mod.Session.Credentials)
+}
+
 func (mod *RestAPI) showEnv(w http.ResponseWriter, r *http.Request) {
 	mod.toJSON(w, mod.Session.Env)
 }
@@ -339,6 +343,9 @@ func (mod *RestAPI) sessionRoute(w http.ResponseWriter, r *http.Request) {
 	case path == "/api/session":
 		mod.showSession(w, r)
 
+	case path == "/api/session/credentials":
+		mod.showCredentials(w, r)
+
 	case path == "/api/session/env":
 		mod.showEnv(w, r)
 
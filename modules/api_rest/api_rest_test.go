@@ -809,3 +809,31 @@ func TestToJSON(t *testing.T) {
 		t.Error("JSON response doesn't match expected data")
 	}
 }
+
+func TestShowCredentials(t *testing.T) {
+	s := createMockSession(t)
+	mod := NewRestAPI(s)
+
+	s.Credentials.Clear()
+	defer s.Credentials.Clear()
+
+	s.Credentials.Add(session.Credential{
+		Protocol:    "smb",
+		Module:      "smb.server",
+		Host:        "10.0.0.1",
+		User:        "CORP\\alice",
+		Type:        session.CredentialHash,
+		Secret:      "alice::CORP:1122334455667788:aa:bb",
+		HashcatMode: 5600,
+	})
+
+	recorder := httptest.NewRecorder()
+	mod.showCredentials(recorder, httptest.NewRequest(http.MethodGet, "/api/session/credentials", nil))
+
+	var creds []session.Credential
+	if err := json.Unmarshal(recorder.Body.Bytes(), &creds); err != nil {
+		t.Fatalf("invalid json: %v (%s)", err, recorder.Body.String())
+	} else if len(creds) != 1 || creds[0].User != "CORP\\alice" || creds[0].HashcatMode != 5600 {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+}
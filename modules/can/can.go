@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/bettercap/bettercap/v2/session"
 	"github.com/hashicorp/go-bexpr"
@@ -21,6 +22,11 @@ type CANModule struct {
 	filterExpr *bexpr.Evaluator
 	dbc        *DBC
 	obd2       *OBD2
+	uds        *UDS
+	recorder   *canRecorder
+	replay     *canReplay
+	lastFrames *lastFrames
+	analyzer   *canAnalyzer
 	conn       net.Conn
 	recv       *socketcan.Receiver
 	send       *socketcan.Transmitter
@@ -32,6 +38,11 @@ func NewCanModule(s *session.Session) *CANModule {
 		filter:        "",
 		dbc:           &DBC{},
 		obd2:          &OBD2{},
+		uds:           NewUDS(),
+		recorder:      &canRecorder{},
+		replay:        &canReplay{},
+		lastFrames:    newLastFrames(),
+		analyzer:      newCanAnalyzer(),
 		filterExpr:    nil,
 		transport:     "can",
 		deviceName:    "can0",
@@ -67,6 +78,15 @@ func NewCanModule(s *session.Session) *CANModule {
 		"false",
 		"Enable built in OBD2 PID parsing."))
 
+	mod.AddParam(session.NewBoolParameter("can.parse.uds",
+		"false",
+		"Enable ISO-TP reassembly and UDS diagnostic decoding, reported as can.uds events."))
+
+	mod.AddParam(session.NewStringParameter("can.isotp.ids",
+		"700-7ff,18da0000-18daffff",
+		"",
+		"Comma separated list of hexadecimal arbitration IDs or FROM-TO ranges carrying ISO-TP traffic, empty for all."))
+
 	mod.AddHandler(session.NewModuleHandler("can.recon on", "",
 		"Start CAN-bus discovery.",
 		func(args []string) error {
@@ -92,6 +112,104 @@ func NewCanModule(s *session.Session) *CANModule {
 			return mod.Show()
 		}))
 
+	mod.AddParam(session.NewBoolParameter("can.record.injected",
+		"false",
+		"If true, can.record will also save the frames sent by can.inject, can.fuzz and can.dump.inject."))
+
+	mod.AddHandler(session.NewModuleHandler("can.record off", "",
+		"Stop recording CAN traffic.",
+		func(args []string) error {
+			return mod.stopRecording()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("can.record FILE", `can\.record (.+)`,
+		"Record the received CAN frames matching can.filter to FILE in candump -l log format.",
+		func(args []string) error {
+			return mod.startRecording(args[0])
+		}))
+
+	mod.AddParam(session.NewStringParameter("can.replay.speed",
+		"1.0",
+		`^\d+(\.\d+)?$`,
+		"Speed factor of can.replay relative to the original timing of the frames, 0 to send them as fast as possible."))
+
+	mod.AddParam(session.NewStringParameter("can.replay.from",
+		"",
+		"",
+		"If set, can.replay will skip the frames of the first seconds of the file."))
+
+	mod.AddParam(session.NewStringParameter("can.replay.to",
+		"",
+		"",
+		"If set, can.replay will stop at this number of seconds from the beginning of the file."))
+
+	mod.AddParam(session.NewStringParameter("can.replay.ids",
+		"",
+		"",
+		"Comma separated list of hexadecimal IDs or FROM-TO ranges of the frames to replay, empty for all."))
+
+	mod.AddParam(session.NewStringParameter("can.replay.skip",
+		"",
+		"",
+		"Comma separated list of hexadecimal IDs or FROM-TO ranges of the frames not to replay."))
+
+	mod.AddParam(session.NewStringParameter("can.replay.rewrite",
+		"",
+		"",
+		"Comma separated list of ID[=NEW_ID][#DATA] rules to rewrite the replayed frames, DATA is an hexadecimal payload with '..' for the bytes to keep, for instance 244=245#..ff."))
+
+	mod.AddHandler(session.NewModuleHandler("can.replay off", "",
+		"Stop a running can.replay.",
+		func(args []string) error {
+			return mod.stopReplay()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("can.replay FILE", `can\.replay (.+)`,
+		"Inject the frames of a candump log FILE with their original timing, or the one set by can.replay.speed, selected and rewritten by the can.replay.* parameters.",
+		func(args []string) error {
+			return mod.startReplay(args[0])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("can.analyze on", "",
+		"Start collecting per ID rates, DLCs, byte entropy and bit flips of the received frames matching can.filter, useful to find signals without a DBC.",
+		func(args []string) error {
+			mod.analyzer.Enable(true)
+			mod.Info("analysis started")
+			return nil
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("can.analyze off", "",
+		"Stop collecting frame statistics, the collected ones are kept.",
+		func(args []string) error {
+			mod.analyzer.Enable(false)
+			mod.Info("analysis stopped")
+			return nil
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("can.analyze.show", "",
+		"Show the frame statistics with a bit flips heatmap, highlighting the bits changed during the last action window.",
+		func(args []string) error {
+			return mod.showAnalysis()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("can.analyze.mark off", "",
+		"End the current action window.",
+		func(args []string) error {
+			return mod.markAction("")
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("can.analyze.mark NAME", `can\.analyze\.mark (.+)`,
+		"Start an action window with the given NAME, perform the action (for instance press the brake pedal) and then end it with can.analyze.mark off.",
+		func(args []string) error {
+			return mod.markAction(args[0])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("can.analyze.save FILE", `can\.analyze\.save (.+)`,
+		"Save the frame statistics and action windows to FILE as JSON.",
+		func(args []string) error {
+			return mod.saveAnalysis(args[0])
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("can.dbc.load NAME", "can.dbc.load (.+)",
 		"Load a DBC file from the list of available ones or from disk.",
 		func(args []string) error {
@@ -107,6 +225,18 @@ func NewCanModule(s *session.Session) *CANModule {
 			return mod.Inject(args[0])
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("can.inject.signal MESSAGE SIGNAL=VALUE", `(?i)^can\.inject\.signal\s+([^\s]+)\s+(.+)$`,
+		"Encode the SIGNAL=VALUE physical values (space separated, value descriptions are accepted too) into the MESSAGE name or ID of the loaded DBC and inject it, the other signals are taken from the last frame seen with the same ID.",
+		func(args []string) error {
+			if !mod.Running() {
+				return errors.New("can module not running")
+			} else if values, err := parseSignalValues(strings.Fields(args[1])); err != nil {
+				return err
+			} else {
+				return mod.InjectSignals(args[0], values)
+			}
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("can.fuzz ID_OR_NODE_NAME OPTIONAL_SIZE", `(?i)^can\.fuzz\s+([^\s]+)\s*(\d*)$`,
 		"If an hexadecimal frame ID is specified, create a randomized version of it and inject it. If a node name is specified, a random message for the given node will be instead used.",
 		func(args []string) error {
@@ -117,6 +247,8 @@ func NewCanModule(s *session.Session) *CANModule {
 			return mod.Fuzz(args[0], args[1])
 		}))
 
+	jsModule = mod
+
 	return mod
 }
 
@@ -0,0 +1,441 @@
+package can
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/tui"
+	"go.einride.tech/can"
+)
+
+const (
+	// bits of a classic CAN payload, numbered like in DBC files: bit 0 is the
+	// least significant bit of the first byte
+	analyzeBits = can.MaxDataLength * 8
+	// how many times more often than usual a bit must flip during an action
+	// window to be reported as changed by it
+	analyzeChangeFactor = 4.0
+)
+
+type bitFlips [analyzeBits]uint64
+
+// count adds the bits changed from prev to next.
+func (f *bitFlips) count(prev, next can.Data) {
+	for i := 0; i < can.MaxDataLength; i++ {
+		for diff := prev[i] ^ next[i]; diff != 0; diff &= diff - 1 {
+			f[i*8+bits.TrailingZeros8(diff)]++
+		}
+	}
+}
+
+type idAnalysis struct {
+	id        uint32
+	frames    uint64
+	firstSeen time.Time
+	lastSeen  time.Time
+	dlcs      map[uint8]uint64
+	maxDLC    uint8
+	values    [can.MaxDataLength][256]uint64
+	flips     bitFlips
+	last      can.Data
+}
+
+// entropy returns the Shannon entropy in bits of the values seen in each byte.
+func (a *idAnalysis) entropy() []float64 {
+	entropy := make([]float64, a.maxDLC)
+	for i := range entropy {
+		total := uint64(0)
+		for _, count := range a.values[i] {
+			total += count
+		}
+		for _, count := range a.values[i] {
+			if count > 0 {
+				p := float64(count) / float64(total)
+				entropy[i] -= p * math.Log2(p)
+			}
+		}
+		entropy[i] = math.Abs(entropy[i])
+	}
+	return entropy
+}
+
+// rate returns the frames per second.
+func (a *idAnalysis) rate() float64 {
+	if elapsed := a.lastSeen.Sub(a.firstSeen).Seconds(); a.frames > 1 && elapsed > 0 {
+		return float64(a.frames-1) / elapsed
+	}
+	return 0
+}
+
+type windowAnalysis struct {
+	frames uint64
+	flips  bitFlips
+}
+
+// actionWindow is a time range marked by the user while performing an action
+// on the vehicle, such as pressing the brake pedal.
+type actionWindow struct {
+	name  string
+	start time.Time
+	end   time.Time
+	ids   map[uint32]*windowAnalysis
+}
+
+type canAnalyzer struct {
+	sync.Mutex
+
+	enabled bool
+	ids     map[uint32]*idAnalysis
+	windows []*actionWindow
+	active  *actionWindow
+}
+
+func newCanAnalyzer() *canAnalyzer {
+	return &canAnalyzer{
+		ids:     make(map[uint32]*idAnalysis),
+		windows: make([]*actionWindow, 0),
+	}
+}
+
+func (an *canAnalyzer) Enabled() bool {
+	an.Lock()
+	defer an.Unlock()
+	return an.enabled
+}
+
+// Enable starts or stops the analysis, starting it resets the statistics.
+func (an *canAnalyzer) Enable(enable bool) {
+	an.Lock()
+	defer an.Unlock()
+	if enable && !an.enabled {
+		an.ids = make(map[uint32]*idAnalysis)
+		an.windows = make([]*actionWindow, 0)
+		an.active = nil
+	}
+	an.enabled = enable
+}
+
+func (an *canAnalyzer) Feed(frame can.Frame, now time.Time) {
+	if frame.IsRemote {
+		return
+	}
+
+	an.Lock()
+	defer an.Unlock()
+
+	if !an.enabled {
+		return
+	}
+
+	stats, found := an.ids[frame.ID]
+	if !found {
+		stats = &idAnalysis{
+			id:        frame.ID,
+			firstSeen: now,
+			dlcs:      make(map[uint8]uint64),
+		}
+		an.ids[frame.ID] = stats
+	} else {
+		stats.flips.count(stats.last, frame.Data)
+		if an.active != nil {
+			window, found := an.active.ids[frame.ID]
+			if !found {
+				window = &windowAnalysis{}
+				an.active.ids[frame.ID] = window
+			}
+			window.frames++
+			window.flips.count(stats.last, frame.Data)
+		}
+	}
+
+	stats.frames++
+	stats.lastSeen = now
+	stats.dlcs[frame.Length]++
+	if frame.Length > stats.maxDLC {
+		stats.maxDLC = frame.Length
+	}
+	for i := uint8(0); i < frame.Length; i++ {
+		stats.values[i][frame.Data[i]]++
+	}
+	stats.last = frame.Data
+}
+
+// Mark starts an action window with the given name, or ends the active one
+// if name is empty.
+func (an *canAnalyzer) Mark(name string, now time.Time) (*actionWindow, error) {
+	an.Lock()
+	defer an.Unlock()
+
+	if !an.enabled {
+		return nil, errors.New("can.analyze is not running")
+	}
+
+	if name == "" {
+		if an.active == nil {
+			return nil, errors.New("no action window to end")
+		}
+		window := an.active
+		window.end = now
+		an.active = nil
+		return window, nil
+	} else if an.active != nil {
+		return nil, fmt.Errorf("action window '%s' still open, use can.analyze.mark off first", an.active.name)
+	}
+
+	an.active = &actionWindow{
+		name:  name,
+		start: now,
+		ids:   make(map[uint32]*windowAnalysis),
+	}
+	an.windows = append(an.windows, an.active)
+	return an.active, nil
+}
+
+// changedBits returns the bits of an ID flipping during the window at least
+// analyzeChangeFactor times more often than outside of any window.
+func (an *canAnalyzer) changedBits(window *actionWindow, stats *idAnalysis) []int {
+	changed := make([]int, 0)
+
+	inWindow, found := window.ids[stats.id]
+	if !found || inWindow.frames == 0 {
+		return changed
+	}
+
+	// baseline is everything outside the action windows
+	baseFrames := stats.frames - 1
+	baseFlips := stats.flips
+	for _, w := range an.windows {
+		if other, found := w.ids[stats.id]; found {
+			baseFrames -= other.frames
+			for i := range baseFlips {
+				baseFlips[i] -= other.flips[i]
+			}
+		}
+	}
+
+	for i := 0; i < analyzeBits; i++ {
+		if inWindow.flips[i] == 0 {
+			continue
+		}
+
+		windowRate := float64(inWindow.flips[i]) / float64(inWindow.frames)
+		if baseFrames == 0 || baseFlips[i] == 0 {
+			changed = append(changed, i)
+		} else if baseRate := float64(baseFlips[i]) / float64(baseFrames); windowRate >= baseRate*analyzeChangeFactor {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+func (an *canAnalyzer) sortedIDs() []*idAnalysis {
+	list := make([]*idAnalysis, 0, len(an.ids))
+	for _, stats := range an.ids {
+		list = append(list, stats)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].id < list[j].id
+	})
+	return list
+}
+
+type AnalyzeWindow struct {
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end,omitempty"`
+	Frames   uint64    `json:"frames"`
+	BitFlips []uint64  `json:"bit_flips"`
+	Changed  []int     `json:"changed_bits"`
+}
+
+type AnalyzeID struct {
+	ID        string           `json:"id"`
+	Frames    uint64           `json:"frames"`
+	Rate      float64          `json:"rate"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+	DLCs      map[uint8]uint64 `json:"dlcs"`
+	Entropy   []float64        `json:"entropy"`
+	BitFlips  []uint64         `json:"bit_flips"`
+	Windows   []AnalyzeWindow  `json:"windows,omitempty"`
+}
+
+// Report returns the statistics of every ID sorted by ID.
+func (an *canAnalyzer) Report() []AnalyzeID {
+	an.Lock()
+	defer an.Unlock()
+
+	report := make([]AnalyzeID, 0, len(an.ids))
+	for _, stats := range an.sortedIDs() {
+		entry := AnalyzeID{
+			ID:        fmt.Sprintf("%X", stats.id),
+			Frames:    stats.frames,
+			Rate:      stats.rate(),
+			FirstSeen: stats.firstSeen,
+			LastSeen:  stats.lastSeen,
+			DLCs:      make(map[uint8]uint64),
+			Entropy:   stats.entropy(),
+			BitFlips:  append([]uint64(nil), stats.flips[:stats.maxDLC*8]...),
+		}
+		for dlc, count := range stats.dlcs {
+			entry.DLCs[dlc] = count
+		}
+
+		for _, window := range an.windows {
+			if inWindow, found := window.ids[stats.id]; found {
+				entry.Windows = append(entry.Windows, AnalyzeWindow{
+					Name:     window.name,
+					Start:    window.start,
+					End:      window.end,
+					Frames:   inWindow.frames,
+					BitFlips: append([]uint64(nil), inWindow.flips[:stats.maxDLC*8]...),
+					Changed:  an.changedBits(window, stats),
+				})
+			}
+		}
+
+		report = append(report, entry)
+	}
+	return report
+}
+
+func (mod *CANModule) saveAnalysis(fileName string) error {
+	fileName, err := fs.Expand(fileName)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(mod.analyzer.Report(), "", "  ")
+	if err != nil {
+		return err
+	} else if err = os.WriteFile(fileName, raw, 0644); err != nil {
+		return err
+	}
+
+	mod.Info("analysis saved to %s", fileName)
+	return nil
+}
+
+func (mod *CANModule) markAction(name string) error {
+	window, err := mod.analyzer.Mark(name, time.Now())
+	if err != nil {
+		return err
+	} else if name == "" {
+		mod.Info("action window '%s' ended after %s", window.name, window.end.Sub(window.start).Round(time.Millisecond))
+	} else {
+		mod.Info("action window '%s' started, perform the action now and then use can.analyze.mark off", name)
+	}
+	return nil
+}
+
+// heatmapBit returns a shade of how often a bit flips.
+func heatmapBit(flips uint64, frames uint64) string {
+	if flips == 0 || frames < 2 {
+		return "·"
+	}
+	ratio := float64(flips) / float64(frames-1)
+	switch {
+	case ratio < 0.05:
+		return "░"
+	case ratio < 0.25:
+		return "▒"
+	case ratio < 0.75:
+		return "▓"
+	}
+	return "█"
+}
+
+// heatmap renders the bit flips of each byte from bit 7 to bit 0, the bits
+// changed by the action window are highlighted.
+func heatmap(stats *idAnalysis, changed []int) string {
+	highlight := make(map[int]bool)
+	for _, bit := range changed {
+		highlight[bit] = true
+	}
+
+	bytes := make([]string, 0, stats.maxDLC)
+	for i := 0; i < int(stats.maxDLC); i++ {
+		var sb strings.Builder
+		for b := 7; b >= 0; b-- {
+			bit := i*8 + b
+			if shade := heatmapBit(stats.flips[bit], stats.frames); highlight[bit] {
+				sb.WriteString(tui.Red(shade))
+			} else {
+				sb.WriteString(shade)
+			}
+		}
+		bytes = append(bytes, sb.String())
+	}
+	return strings.Join(bytes, " ")
+}
+
+func (mod *CANModule) showAnalysis() error {
+	an := mod.analyzer
+	an.Lock()
+	defer an.Unlock()
+
+	if len(an.ids) == 0 {
+		mod.Info("no frames analyzed yet, use can.analyze on")
+		return nil
+	}
+
+	// highlight the changes of the last action window
+	var window *actionWindow
+	if len(an.windows) > 0 {
+		window = an.windows[len(an.windows)-1]
+	}
+
+	rows := make([][]string, 0, len(an.ids))
+	for _, stats := range an.sortedIDs() {
+		changed := []int{}
+		if window != nil {
+			changed = an.changedBits(window, stats)
+		}
+
+		dlcs := make([]string, 0, len(stats.dlcs))
+		for dlc := range stats.dlcs {
+			dlcs = append(dlcs, fmt.Sprintf("%d", dlc))
+		}
+		sort.Strings(dlcs)
+
+		entropy := make([]string, 0, stats.maxDLC)
+		for _, e := range stats.entropy() {
+			entropy = append(entropy, fmt.Sprintf("%.1f", e))
+		}
+
+		id := fmt.Sprintf("%03X", stats.id)
+		if len(changed) > 0 {
+			id = tui.Bold(id)
+		}
+
+		rows = append(rows, []string{
+			id,
+			fmt.Sprintf("%d", stats.frames),
+			fmt.Sprintf("%.1f Hz", stats.rate()),
+			strings.Join(dlcs, ","),
+			strings.Join(entropy, " "),
+			heatmap(stats, changed),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, []string{"ID", "Frames", "Rate", "DLC", "Entropy", "Bit Flips"}, rows)
+
+	if window != nil {
+		status := "active"
+		if !window.end.IsZero() {
+			status = window.end.Sub(window.start).Round(time.Millisecond).String()
+		}
+		mod.Printf("bits changed during the action window '%s' (%s) are highlighted.\n\n", window.name, status)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package can
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyzerStats(t *testing.T) {
+	an := newCanAnalyzer()
+	now := time.Unix(1700623093, 0)
+
+	an.Feed(testFrame(0x100, 0x00, 0x00), now)
+	if len(an.ids) != 0 {
+		t.Fatal("frames analyzed while disabled")
+	}
+
+	an.Enable(true)
+	for i := 0; i < 11; i++ {
+		// byte 0 counts, bit 1 of byte 1 toggles
+		an.Feed(testFrame(0x100, byte(i), byte(i%2)<<1), now.Add(time.Duration(i)*100*time.Millisecond))
+	}
+	an.Feed(testFrame(0x200, 0xff), now)
+
+	report := an.Report()
+	if len(report) != 2 || report[0].ID != "100" || report[1].ID != "200" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	stats := report[0]
+	if stats.Frames != 11 || math.Abs(stats.Rate-10) > 0.001 {
+		t.Errorf("unexpected frames %d and rate %f", stats.Frames, stats.Rate)
+	} else if !reflect.DeepEqual(stats.DLCs, map[uint8]uint64{2: 11}) {
+		t.Errorf("unexpected DLCs %v", stats.DLCs)
+	} else if math.Abs(stats.Entropy[0]-math.Log2(11)) > 0.001 || math.Abs(stats.Entropy[1]-0.994) > 0.001 {
+		t.Errorf("unexpected entropy %v", stats.Entropy)
+	} else if len(stats.BitFlips) != 16 || stats.BitFlips[0] != 10 || stats.BitFlips[1] != 5 || stats.BitFlips[9] != 10 || stats.BitFlips[8] != 0 {
+		t.Errorf("unexpected bit flips %v", stats.BitFlips)
+	}
+
+	// enabling again starts from scratch
+	an.Enable(true)
+	if len(an.Report()) != 2 {
+		t.Error("stats reset while already enabled")
+	}
+	an.Enable(false)
+	an.Enable(true)
+	if len(an.Report()) != 0 {
+		t.Error("stats not reset")
+	}
+}
+
+func TestAnalyzerActionWindow(t *testing.T) {
+	an := newCanAnalyzer()
+	now := time.Unix(1700623093, 0)
+
+	if _, err := an.Mark("brake", now); err == nil {
+		t.Fatal("expected an error while disabled")
+	}
+
+	an.Enable(true)
+	if _, err := an.Mark("", now); err == nil {
+		t.Fatal("expected an error without an action window")
+	}
+
+	// a noisy counter in byte 0 and a quiet brake bit in byte 1
+	counter := byte(0)
+	feed := func(brake byte, n int) {
+		for i := 0; i < n; i++ {
+			an.Feed(testFrame(0x1a0, counter, brake), now)
+			counter++
+		}
+	}
+
+	feed(0, 50)
+	if _, err := an.Mark("brake", now); err != nil {
+		t.Fatal(err)
+	} else if _, err = an.Mark("again", now); err == nil {
+		t.Fatal("expected an error with an open window")
+	}
+	feed(0x10, 5)
+	feed(0, 5)
+	if window, err := an.Mark("", now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	} else if window.name != "brake" {
+		t.Fatalf("unexpected window %+v", window)
+	}
+	feed(0, 50)
+
+	report := an.Report()
+	if len(report) != 1 || len(report[0].Windows) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	window := report[0].Windows[0]
+	if window.Name != "brake" || window.Frames != 10 {
+		t.Errorf("unexpected window %+v", window)
+	} else if !reflect.DeepEqual(window.Changed, []int{12}) {
+		t.Errorf("expected only bit 12 to change, got %v", window.Changed)
+	}
+}
+
+func TestAnalyzeSave(t *testing.T) {
+	mod := NewCanModule(createMockSession(t))
+	if err := mod.markAction("brake"); err == nil {
+		t.Error("expected an error while not analyzing")
+	}
+
+	mod.analyzer.Enable(true)
+	mod.onFrame(testFrame(0x100, 0x01))
+	mod.onFrame(testFrame(0x100, 0x02))
+
+	if err := mod.showAnalysis(); err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "analysis.json")
+	if err := mod.saveAnalysis(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report []AnalyzeID
+	if err := json.Unmarshal(raw, &report); err != nil {
+		t.Fatal(err)
+	} else if len(report) != 1 || report[0].ID != "100" || report[0].Frames != 2 || report[0].BitFlips[0] != 1 || report[0].BitFlips[1] != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
@@ -3,6 +3,7 @@ package can
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/evilsocket/islazy/str"
@@ -132,6 +133,29 @@ func (dbc *DBC) MessageById(frameID uint32) *descriptor.Message {
 	return nil
 }
 
+// MessageByName returns the message with the given name, matched case
+// insensitively if there's no exact match.
+func (dbc *DBC) MessageByName(name string) *descriptor.Message {
+	dbc.RLock()
+	defer dbc.RUnlock()
+
+	if dbc.db == nil {
+		return nil
+	}
+
+	for _, msg := range dbc.db.Messages {
+		if msg.Name == name {
+			return msg
+		}
+	}
+	for _, msg := range dbc.db.Messages {
+		if strings.EqualFold(msg.Name, name) {
+			return msg
+		}
+	}
+	return nil
+}
+
 func (dbc *DBC) Messages() []*descriptor.Message {
 	dbc.RLock()
 	defer dbc.RUnlock()
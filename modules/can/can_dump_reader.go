@@ -2,7 +2,6 @@ package can
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"os"
 	"regexp"
@@ -42,12 +41,11 @@ func parseTimeval(timeval string) (time.Time, error) {
 	return time.Unix(seconds, microseconds*1000), nil
 }
 
-func (mod *CANModule) startDumpReader() error {
-	mod.Info("loading CAN dump from %s ...", mod.dumpName)
-
-	file, err := os.Open(mod.dumpName)
+// loadDump parses a candump -l log file.
+func (mod *CANModule) loadDump(fileName string) ([]dumpEntry, error) {
+	file, err := os.Open(fileName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
@@ -71,6 +69,17 @@ func (mod *CANModule) startDumpReader() error {
 	}
 
 	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (mod *CANModule) startDumpReader() error {
+	mod.Info("loading CAN dump from %s ...", mod.dumpName)
+
+	entries, err := mod.loadDump(mod.dumpName)
+	if err != nil {
 		return err
 	}
 
@@ -90,7 +99,7 @@ func (mod *CANModule) startDumpReader() error {
 			}
 
 			if mod.dumpInject {
-				if err := mod.send.TransmitFrame(context.Background(), frame); err != nil {
+				if err := mod.transmit(frame); err != nil {
 					mod.Error("could not send CAN frame: %v", err)
 				}
 			} else {
@@ -1,7 +1,6 @@
 package can
 
 import (
-	"context"
 	"fmt"
 	"math/rand"
 	"strconv"
@@ -100,7 +99,7 @@ func (mod *CANModule) Fuzz(id string, optSize string) error {
 	} else {
 		mod.Info("injecting %s of CAN frame %d ...",
 			humanize.Bytes(uint64(frame.Length)), frame.ID)
-		if err := mod.send.TransmitFrame(context.Background(), *frame); err != nil {
+		if err := mod.transmit(*frame); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,65 @@
+package can
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/evilsocket/islazy/str"
+)
+
+// idRange is an inclusive range of CAN arbitration IDs.
+type idRange struct {
+	From uint32
+	To   uint32
+}
+
+func parseID(value string) (uint32, error) {
+	value = str.Trim(value)
+	// candump style IDs are hexadecimal even without the 0x prefix
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	id, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CAN ID '%s'", value)
+	}
+	return uint32(id), nil
+}
+
+// parseIDRanges parses a comma separated list of hexadecimal CAN IDs and
+// FROM-TO ranges, such as "7df,7e0-7ef".
+func parseIDRanges(value string) ([]idRange, error) {
+	ranges := make([]idRange, 0)
+	for _, token := range str.Comma(value) {
+		parts := strings.SplitN(token, "-", 2)
+		from, err := parseID(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		to := from
+		if len(parts) == 2 {
+			if to, err = parseID(parts[1]); err != nil {
+				return nil, err
+			} else if to < from {
+				return nil, fmt.Errorf("invalid CAN ID range '%s'", token)
+			}
+		}
+
+		ranges = append(ranges, idRange{From: from, To: to})
+	}
+	return ranges, nil
+}
+
+// matchIDRanges returns true if id is in any of the ranges, or if there are
+// no ranges at all.
+func matchIDRanges(ranges []idRange, id uint32) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+	for _, r := range ranges {
+		if id >= r.From && id <= r.To {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,19 @@ import (
 	"go.einride.tech/can"
 )
 
+// transmit sends a frame to the bus and records it if it matches can.filter.
+func (mod *CANModule) transmit(frame can.Frame) error {
+	if err := mod.send.TransmitFrame(context.Background(), frame); err != nil {
+		return err
+	}
+
+	mod.lastFrames.Set(frame)
+	if !mod.isFilteredOut(frame, NewCanMessage(frame)) {
+		mod.record(frame, true)
+	}
+	return nil
+}
+
 func (mod *CANModule) Inject(expr string) (err error) {
 	frame := can.Frame{}
 	if err := frame.UnmarshalString(expr); err != nil {
@@ -16,7 +29,7 @@ func (mod *CANModule) Inject(expr string) (err error) {
 	mod.Info("injecting %s of CAN frame %d ...",
 		humanize.Bytes(uint64(frame.Length)), frame.ID)
 
-	if err := mod.send.TransmitFrame(context.Background(), frame); err != nil {
+	if err := mod.transmit(frame); err != nil {
 		return err
 	}
 
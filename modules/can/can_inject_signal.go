@@ -0,0 +1,225 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+	"github.com/evilsocket/islazy/str"
+	"go.einride.tech/can"
+	"go.einride.tech/can/pkg/descriptor"
+)
+
+// lastFrames keeps the last frame seen or sent for each ID, used to fill the
+// signals not set by can.inject.signal.
+type lastFrames struct {
+	sync.RWMutex
+
+	frames map[uint32]can.Frame
+}
+
+func newLastFrames() *lastFrames {
+	return &lastFrames{
+		frames: make(map[uint32]can.Frame),
+	}
+}
+
+func (l *lastFrames) Set(frame can.Frame) {
+	l.Lock()
+	defer l.Unlock()
+	l.frames[frame.ID] = frame
+}
+
+func (l *lastFrames) Get(id uint32) (can.Frame, bool) {
+	l.RLock()
+	defer l.RUnlock()
+	frame, found := l.frames[id]
+	return frame, found
+}
+
+func findSignal(message *descriptor.Message, name string) *descriptor.Signal {
+	for _, signal := range message.Signals {
+		if signal.Name == name {
+			return signal
+		}
+	}
+	for _, signal := range message.Signals {
+		if strings.EqualFold(signal.Name, name) {
+			return signal
+		}
+	}
+	return nil
+}
+
+func signalNames(message *descriptor.Message) string {
+	names := make([]string, 0, len(message.Signals))
+	for _, signal := range message.Signals {
+		names = append(names, signal.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parsePhysical parses the value of a signal as a physical number, as one of
+// its value descriptions or as a boolean.
+func parsePhysical(signal *descriptor.Signal, value string) (float64, bool, error) {
+	if physical, err := strconv.ParseFloat(value, 64); err == nil {
+		return physical, false, nil
+	}
+
+	for _, desc := range signal.ValueDescriptions {
+		if strings.EqualFold(desc.Description, value) {
+			// value descriptions are raw values
+			return float64(desc.Value), true, nil
+		}
+	}
+
+	switch strings.ToLower(value) {
+	case "true", "on":
+		return 1, true, nil
+	case "false", "off":
+		return 0, true, nil
+	}
+
+	return 0, false, fmt.Errorf("invalid value '%s' for signal %s", value, signal.Name)
+}
+
+// marshalSignal encodes the physical (or raw) value of a signal into data
+// through its scale, offset, size and endianness.
+func marshalSignal(signal *descriptor.Signal, data *can.Data, value float64, isRaw bool) error {
+	if !isRaw && (signal.Min != 0 || signal.Max != 0) && (value < signal.Min || value > signal.Max) {
+		return fmt.Errorf("value %v of signal %s is out of range [%v, %v]", value, signal.Name, signal.Min, signal.Max)
+	}
+
+	raw := value
+	if !isRaw {
+		if signal.Scale == 0 {
+			return fmt.Errorf("signal %s has a zero scale", signal.Name)
+		}
+		raw = (value - signal.Offset) / signal.Scale
+	}
+
+	switch {
+	case signal.Length == 1:
+		signal.MarshalBool(data, raw != 0)
+	case signal.IsFloat:
+		signal.MarshalFloat(data, raw)
+	case signal.IsSigned:
+		rounded := math.Round(raw)
+		if rounded < float64(signal.MinSigned()) || rounded > float64(signal.MaxSigned()) {
+			return fmt.Errorf("value %v of signal %s doesn't fit in %d bits", value, signal.Name, signal.Length)
+		}
+		signal.MarshalSigned(data, int64(rounded))
+	default:
+		rounded := math.Round(raw)
+		if rounded < 0 || rounded > float64(signal.MaxUnsigned()) {
+			return fmt.Errorf("value %v of signal %s doesn't fit in %d bits", value, signal.Name, signal.Length)
+		}
+		signal.MarshalUnsigned(data, uint64(rounded))
+	}
+
+	return nil
+}
+
+// EncodeSignals builds a frame of the DBC message with the given name or
+// hexadecimal ID, setting the given signals to their physical values. The
+// other signals keep the values of the last frame seen with the same ID, or
+// their defaults.
+func (mod *CANModule) EncodeSignals(messageName string, values map[string]string) (can.Frame, error) {
+	frame := can.Frame{}
+
+	if !mod.dbc.Loaded() {
+		return frame, errors.New("no DBC loaded, use can.dbc.load first")
+	}
+
+	message := mod.dbc.MessageByName(messageName)
+	if message == nil {
+		if id, err := parseID(messageName); err == nil {
+			message = mod.dbc.MessageById(id)
+		}
+	}
+	if message == nil {
+		return frame, fmt.Errorf("message %s not found in DBC, available messages: %s", messageName, strings.Join(mod.dbc.AvailableMessages(), ", "))
+	}
+
+	if last, found := mod.lastFrames.Get(message.ID); found {
+		frame.Data = last.Data
+	} else {
+		for _, signal := range message.Signals {
+			if signal.DefaultValue != 0 {
+				if err := marshalSignal(signal, &frame.Data, float64(signal.DefaultValue), true); err != nil {
+					return frame, err
+				}
+			}
+		}
+	}
+
+	frame.ID = message.ID
+	frame.IsExtended = message.IsExtended
+	frame.Length = message.Length
+
+	// sorted so that an explicit multiplexer always wins over the implicit one
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	multiplexerSet := false
+	for _, name := range names {
+		signal := findSignal(message, name)
+		if signal == nil {
+			return frame, fmt.Errorf("signal %s not found in message %s, available signals: %s", name, message.Name, signalNames(message))
+		}
+		multiplexerSet = multiplexerSet || signal.IsMultiplexer
+	}
+
+	for _, name := range names {
+		signal := findSignal(message, name)
+		if value, isRaw, err := parsePhysical(signal, values[name]); err != nil {
+			return frame, err
+		} else if err = marshalSignal(signal, &frame.Data, value, isRaw); err != nil {
+			return frame, err
+		}
+
+		// select the multiplexed signal if the multiplexer wasn't set
+		if signal.IsMultiplexed && !multiplexerSet {
+			if mux, found := message.MultiplexerSignal(); found {
+				if err := marshalSignal(mux, &frame.Data, float64(signal.MultiplexerValue), true); err != nil {
+					return frame, err
+				}
+			}
+		}
+	}
+
+	return frame, nil
+}
+
+// parseSignalValues parses a list of SIGNAL=VALUE expressions.
+func parseSignalValues(exprs []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, expr := range exprs {
+		parts := strings.SplitN(expr, "=", 2)
+		if len(parts) != 2 || str.Trim(parts[0]) == "" || str.Trim(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid signal expression '%s', expected SIGNAL=VALUE", expr)
+		}
+		values[str.Trim(parts[0])] = str.Trim(parts[1])
+	}
+	return values, nil
+}
+
+func (mod *CANModule) InjectSignals(messageName string, values map[string]string) error {
+	frame, err := mod.EncodeSignals(messageName, values)
+	if err != nil {
+		return err
+	}
+
+	mod.Info("injecting %s of CAN frame %s ...", humanize.Bytes(uint64(frame.Length)), frame.String())
+
+	return mod.transmit(frame)
+}
@@ -0,0 +1,100 @@
+package can
+
+import (
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/network"
+)
+
+const testSignalsDBC = `VERSION ""
+
+BU_: ECU
+
+BO_ 256 Speed: 8 ECU
+ SG_ VehicleSpeed : 0|16@1+ (0.01,0) [0|655.35] "km/h" Vector__XXX
+ SG_ Gear : 16|4@1+ (1,0) [0|15] "" Vector__XXX
+ SG_ Temp : 24|8@1- (1,-40) [-40|100] "C" Vector__XXX
+ SG_ Brake : 32|1@1+ (1,0) [0|1] "" Vector__XXX
+ SG_ RPM : 47|16@0+ (0.25,0) [0|16383.75] "rpm" Vector__XXX
+
+VAL_ 256 Gear 0 "P" 1 "R" 2 "N" 3 "D" ;
+`
+
+func TestEncodeSignals(t *testing.T) {
+	s := createMockSession(t)
+	if s.CAN == nil {
+		s.CAN = network.NewCAN(nil, nil, nil)
+	}
+	mod := NewCanModule(s)
+	if _, err := mod.EncodeSignals("Speed", map[string]string{"VehicleSpeed": "80"}); err == nil {
+		t.Fatal("expected an error without a DBC")
+	}
+
+	if err := mod.dbc.LoadData(mod, "test.dbc", []byte(testSignalsDBC)); err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := mod.EncodeSignals("speed", map[string]string{
+		"VehicleSpeed": "80",
+		"gear":         "D",
+		"Temp":         "20",
+		"Brake":        "true",
+		"RPM":          "3000",
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if frame.String() != "100#401F033C012EE000" {
+		t.Fatalf("unexpected frame %s", frame)
+	}
+
+	msg := NewCanMessage(frame)
+	if !mod.dbc.Parse(mod, &msg) {
+		t.Fatal("frame not parsed back")
+	}
+	for name, expected := range map[string]string{"VehicleSpeed": "8000 km/h", "Gear": "3", "Temp": "60 C", "Brake": "true", "RPM": "12000 rpm"} {
+		if msg.Signals[name] != expected {
+			t.Errorf("expected raw %s=%s, got %s", name, expected, msg.Signals[name])
+		}
+	}
+
+	// the other signals come from the last frame
+	mod.onFrame(frame)
+	if frame, err = mod.EncodeSignals("100", map[string]string{"Gear": "1"}); err != nil {
+		t.Fatal(err)
+	} else if frame.String() != "100#401F013C012EE000" {
+		t.Errorf("unexpected frame %s", frame)
+	}
+
+	for _, values := range []map[string]string{
+		{"VehicleSpeed": "1000"},
+		{"Temp": "-50"},
+		{"Gear": "X"},
+		{"Nope": "1"},
+	} {
+		if _, err := mod.EncodeSignals("Speed", values); err == nil {
+			t.Errorf("expected an error for %v", values)
+		}
+	}
+	if _, err := mod.EncodeSignals("Nope", map[string]string{"Gear": "1"}); err == nil {
+		t.Error("expected an error for an unknown message")
+	}
+
+	if encoded := (canPackage{}).EncodeSignal("Speed", map[string]interface{}{"Gear": 2}); encoded != "100#401F023C012EE000" {
+		t.Errorf("unexpected JS encoding %s", encoded)
+	}
+}
+
+func TestParseSignalValues(t *testing.T) {
+	values, err := parseSignalValues([]string{"VehicleSpeed=80", "Gear=D"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(values) != 2 || values["VehicleSpeed"] != "80" || values["Gear"] != "D" {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	for _, invalid := range []string{"Gear", "=1", "Gear="} {
+		if _, err := parseSignalValues([]string{invalid}); err == nil {
+			t.Errorf("expected an error for '%s'", invalid)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package can
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// https://en.wikipedia.org/wiki/ISO_15765-2
+
+const (
+	isotpSingleFrame      = 0x0
+	isotpFirstFrame       = 0x1
+	isotpConsecutiveFrame = 0x2
+	isotpFlowControl      = 0x3
+
+	// largest payload announced by a first frame with the 32 bits length
+	// escape we're willing to reassemble
+	isotpMaxSize = 1024 * 1024
+)
+
+// ISOTPTimeout is the maximum time between two consecutive frames (N_Cr).
+var ISOTPTimeout = time.Duration(1) * time.Second
+
+// ISOTPPacket is a payload reassembled from one or more ISO-TP frames.
+type ISOTPPacket struct {
+	// arbitration ID of the sender
+	ID uint32
+	// arbitration ID of the receiver if known, learned from its flow control
+	// frames or from the standard diagnostic addressing
+	Peer uint32
+	Data []byte
+}
+
+type isotpKey struct {
+	id   uint32
+	peer uint32
+}
+
+type isotpTransfer struct {
+	size    int
+	data    []byte
+	next    uint8
+	pending bool
+	updated time.Time
+}
+
+// ISOTP reassembles the ISO-TP transfers of every pair of arbitration IDs.
+type ISOTP struct {
+	sync.Mutex
+
+	transfers map[isotpKey]*isotpTransfer
+	pairs     map[uint32]uint32
+}
+
+func NewISOTP() *ISOTP {
+	return &ISOTP{
+		transfers: make(map[isotpKey]*isotpTransfer),
+		pairs:     make(map[uint32]uint32),
+	}
+}
+
+// isotpDefaultPeer returns the arbitration ID paired with id by the standard
+// diagnostic addressing (ISO 15765-4), or 0.
+func isotpDefaultPeer(id uint32) uint32 {
+	switch {
+	case id >= OBD2ECUResponseMinID && id <= OBD2ECUResponseMinID+7:
+		return id + 8
+	case id >= OBD2ECUResponseMinID+8 && id <= OBD2ECUResponseMaxID:
+		return id - 8
+	case id&0xffff0000 == 0x18da0000:
+		// normal fixed addressing, 0x18DA<target><source>
+		return 0x18da0000 | (id&0xff)<<8 | (id>>8)&0xff
+	}
+	return 0
+}
+
+func (tp *ISOTP) peerOf(id uint32) uint32 {
+	if peer, found := tp.pairs[id]; found {
+		return peer
+	}
+	return isotpDefaultPeer(id)
+}
+
+// Feed processes a frame received at the given time and returns the
+// reassembled packet if the frame completes a transfer.
+func (tp *ISOTP) Feed(frame can.Frame, now time.Time) (*ISOTPPacket, bool) {
+	if frame.IsRemote || frame.Length == 0 {
+		return nil, false
+	}
+
+	tp.Lock()
+	defer tp.Unlock()
+
+	data := frame.Data[:frame.Length]
+	key := isotpKey{id: frame.ID, peer: tp.peerOf(frame.ID)}
+
+	switch data[0] >> 4 {
+	case isotpSingleFrame:
+		size := int(data[0] & 0x0f)
+		if size == 0 || size > len(data)-1 {
+			return nil, false
+		}
+		delete(tp.transfers, key)
+		return &ISOTPPacket{
+			ID:   key.id,
+			Peer: key.peer,
+			Data: append([]byte(nil), data[1:1+size]...),
+		}, true
+
+	case isotpFirstFrame:
+		if len(data) < can.MaxDataLength {
+			return nil, false
+		}
+
+		size := int(data[0]&0x0f)<<8 | int(data[1])
+		payload := data[2:]
+		if size == 0 {
+			size = int(binary.BigEndian.Uint32(data[2:6]))
+			payload = data[6:]
+		}
+		// anything shorter would have been sent as a single frame
+		if size < can.MaxDataLength || size > isotpMaxSize {
+			return nil, false
+		}
+
+		tp.transfers[key] = &isotpTransfer{
+			size:    size,
+			data:    append(make([]byte, 0, min(size, 4095)), payload...),
+			next:    1,
+			pending: true,
+			updated: now,
+		}
+
+	case isotpConsecutiveFrame:
+		transfer, found := tp.transfers[key]
+		if !found {
+			return nil, false
+		} else if now.Sub(transfer.updated) > ISOTPTimeout || data[0]&0x0f != transfer.next {
+			// timed out or lost a frame
+			delete(tp.transfers, key)
+			return nil, false
+		}
+
+		transfer.data = append(transfer.data, data[1:]...)
+		transfer.next = (transfer.next + 1) & 0x0f
+		transfer.updated = now
+
+		if len(transfer.data) >= transfer.size {
+			delete(tp.transfers, key)
+			return &ISOTPPacket{
+				ID:   key.id,
+				Peer: key.peer,
+				Data: transfer.data[:transfer.size],
+			}, true
+		}
+
+	case isotpFlowControl:
+		// the receiver of the latest transfer waiting for a flow control is
+		// the one sending it, pair their IDs
+		var waiting *isotpTransfer
+		var waitingKey isotpKey
+		for k, transfer := range tp.transfers {
+			if transfer.pending && (waiting == nil || transfer.updated.After(waiting.updated)) {
+				waiting, waitingKey = transfer, k
+			}
+		}
+
+		if waiting != nil {
+			waiting.pending = false
+			if waitingKey.peer != frame.ID {
+				delete(tp.transfers, waitingKey)
+				tp.pairs[waitingKey.id] = frame.ID
+				tp.pairs[frame.ID] = waitingKey.id
+				waitingKey.peer = frame.ID
+				tp.transfers[waitingKey] = waiting
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Clear removes the pending transfers and the learned pairs.
+func (tp *ISOTP) Clear() {
+	tp.Lock()
+	defer tp.Unlock()
+	tp.transfers = make(map[isotpKey]*isotpTransfer)
+	tp.pairs = make(map[uint32]uint32)
+}
@@ -0,0 +1,59 @@
+package can
+
+import (
+	"fmt"
+
+	"github.com/bettercap/bettercap/v2/log"
+	"github.com/evilsocket/islazy/plugin"
+)
+
+// the module instance used by the JS API
+var jsModule = (*CANModule)(nil)
+
+func init() {
+	plugin.Defines["can"] = canPackage{}
+}
+
+type canPackage struct{}
+
+func jsSignalValues(signals map[string]interface{}) map[string]string {
+	values := make(map[string]string)
+	for name, value := range signals {
+		values[name] = fmt.Sprintf("%v", value)
+	}
+	return values
+}
+
+// EncodeSignal returns the ID#DATA expression of the DBC message with the given
+// signals set to their physical values, or an empty string.
+func (c canPackage) EncodeSignal(message string, signals map[string]interface{}) string {
+	if jsModule == nil {
+		log.Error("can.EncodeSignal: can module not loaded")
+		return ""
+	}
+
+	frame, err := jsModule.EncodeSignals(message, jsSignalValues(signals))
+	if err != nil {
+		log.Error("can.EncodeSignal: %v", err)
+		return ""
+	}
+	return frame.String()
+}
+
+// InjectSignal encodes and injects the DBC message with the given signals set
+// to their physical values, like can.inject.signal.
+func (c canPackage) InjectSignal(message string, signals map[string]interface{}) bool {
+	if jsModule == nil {
+		log.Error("can.InjectSignal: can module not loaded")
+		return false
+	} else if !jsModule.Running() {
+		log.Error("can.InjectSignal: can module not running")
+		return false
+	}
+
+	if err := jsModule.InjectSignals(message, jsSignalValues(signals)); err != nil {
+		log.Error("can.InjectSignal: %v", err)
+		return false
+	}
+	return true
+}
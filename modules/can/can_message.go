@@ -10,6 +10,8 @@ type Message struct {
 	Frame can.Frame
 	// parsed as OBD2
 	OBD2 *OBD2Message
+	// the last frame of an ISO-TP transfer decoded as UDS
+	UDS *UDSMessage
 	// parsed from DBC
 	Name    string
 	Source  *network.CANDevice
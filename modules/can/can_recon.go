@@ -2,6 +2,7 @@ package can
 
 import (
 	"errors"
+	"time"
 
 	"github.com/bettercap/bettercap/v2/session"
 	"github.com/evilsocket/islazy/tui"
@@ -13,6 +14,8 @@ import (
 func (mod *CANModule) Configure() error {
 	var err error
 	var parseOBD bool
+	var parseUDS bool
+	var isotpIDs string
 
 	if mod.Running() {
 		return session.ErrAlreadyStarted(mod.Name())
@@ -24,6 +27,10 @@ func (mod *CANModule) Configure() error {
 		return err
 	} else if err, parseOBD = mod.BoolParam("can.parse.obd2"); err != nil {
 		return err
+	} else if err, parseUDS = mod.BoolParam("can.parse.uds"); err != nil {
+		return err
+	} else if err, isotpIDs = mod.StringParam("can.isotp.ids"); err != nil {
+		return err
 	} else if err, mod.transport = mod.StringParam("can.transport"); err != nil {
 		return err
 	} else if mod.transport != "can" && mod.transport != "udp" {
@@ -34,6 +41,12 @@ func (mod *CANModule) Configure() error {
 
 	mod.obd2.Enable(parseOBD)
 
+	if ids, err := parseIDRanges(isotpIDs); err != nil {
+		return err
+	} else {
+		mod.uds.Enable(parseUDS, ids)
+	}
+
 	if mod.filter != "" {
 		if mod.filterExpr, err = bexpr.CreateEvaluator(mod.filter); err != nil {
 			return err
@@ -76,14 +89,24 @@ func (mod *CANModule) isFilteredOut(frame can.Frame, msg Message) bool {
 func (mod *CANModule) onFrame(frame can.Frame) {
 	msg := NewCanMessage(frame)
 
+	mod.lastFrames.Set(frame)
+
 	// try to parse with DBC if we have any
 	if !mod.dbc.Parse(mod, &msg) {
 		// not parsed, if enabled try ODB2
 		mod.obd2.Parse(mod, &msg)
 	}
 
+	// every frame is needed to reassemble ISO-TP transfers
+	mod.uds.Parse(mod, &msg)
+
 	if !mod.isFilteredOut(frame, msg) {
+		mod.record(frame, false)
+		mod.analyzer.Feed(frame, time.Now())
 		mod.Session.Events.Add("can.message", msg)
+		if msg.UDS != nil {
+			mod.Session.Events.Add("can.uds", *msg.UDS)
+		}
 	}
 }
 
@@ -110,6 +133,8 @@ func (mod *CANModule) Stop() error {
 	mod.SetPrompt(session.DefaultPrompt)
 
 	return mod.SetRunning(false, func() {
+		// stop injecting before closing the connection
+		mod.stopReplay()
 		if mod.conn != nil {
 			mod.recv.Close()
 			mod.conn.Close()
@@ -118,5 +143,7 @@ func (mod *CANModule) Stop() error {
 			mod.send = nil
 			mod.filter = ""
 		}
+		// finalize the candump log if recording
+		mod.stopRecording()
 	})
 }
@@ -0,0 +1,118 @@
+package can
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/islazy/fs"
+	"go.einride.tech/can"
+)
+
+// how often the recorded frames are flushed to disk
+const recordFlushInterval = time.Duration(1) * time.Second
+
+type canRecorder struct {
+	sync.Mutex
+
+	file     *os.File
+	writer   *bufio.Writer
+	device   string
+	injected bool
+	frames   uint64
+	flushed  time.Time
+}
+
+// formatDumpLine returns a frame in the candump -l log format parsed by the
+// dump reader, such as "(1700623093.260875) can0 7E0#0322128C00000000".
+func formatDumpLine(t time.Time, device string, frame can.Frame) string {
+	return fmt.Sprintf("(%d.%06d) %s %s", t.Unix(), t.Nanosecond()/1000, device, frame.String())
+}
+
+func (mod *CANModule) startRecording(fileName string) error {
+	var err error
+	var device string
+	var injected bool
+
+	if err, device = mod.StringParam("can.device"); err != nil {
+		return err
+	} else if err, injected = mod.BoolParam("can.record.injected"); err != nil {
+		return err
+	} else if fileName, err = fs.Expand(fileName); err != nil {
+		return err
+	}
+
+	mod.recorder.Lock()
+	defer mod.recorder.Unlock()
+
+	if mod.recorder.file != nil {
+		return fmt.Errorf("already recording to %s, use can.record off first", mod.recorder.file.Name())
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	mod.recorder.file = file
+	mod.recorder.writer = bufio.NewWriter(file)
+	mod.recorder.device = device
+	mod.recorder.injected = injected
+	mod.recorder.frames = 0
+	mod.recorder.flushed = time.Now()
+
+	mod.Info("recording CAN traffic of %s to %s ...", device, fileName)
+	return nil
+}
+
+func (mod *CANModule) stopRecording() error {
+	mod.recorder.Lock()
+	defer mod.recorder.Unlock()
+
+	if mod.recorder.file == nil {
+		return errors.New("not recording")
+	}
+
+	file := mod.recorder.file
+	frames := mod.recorder.frames
+	mod.recorder.file = nil
+
+	err := mod.recorder.writer.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	mod.Info("%d frames saved to %s", frames, file.Name())
+	return nil
+}
+
+// record writes a frame to the candump log if recording, injected frames are
+// only saved if can.record.injected was true when the recording started.
+func (mod *CANModule) record(frame can.Frame, injected bool) {
+	mod.recorder.Lock()
+	defer mod.recorder.Unlock()
+
+	if mod.recorder.file == nil || (injected && !mod.recorder.injected) {
+		return
+	}
+
+	now := time.Now()
+	if _, err := fmt.Fprintln(mod.recorder.writer, formatDumpLine(now, mod.recorder.device, frame)); err != nil {
+		mod.Error("could not record CAN frame: %v", err)
+		return
+	}
+	mod.recorder.frames++
+
+	if now.Sub(mod.recorder.flushed) >= recordFlushInterval {
+		if err := mod.recorder.writer.Flush(); err != nil {
+			mod.Error("could not flush %s: %v", mod.recorder.file.Name(), err)
+		}
+		mod.recorder.flushed = now
+	}
+}
@@ -0,0 +1,68 @@
+package can
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-bexpr"
+	"go.einride.tech/can"
+)
+
+func TestFormatDumpLine(t *testing.T) {
+	frame := testFrame(0x7e0, 0x03, 0x22, 0x12, 0x8c, 0x00, 0x00, 0x00, 0x00)
+	line := formatDumpLine(time.Unix(1700623093, 260875000), "can0", frame)
+	if line != "(1700623093.260875) can0 7E0#0322128C00000000" {
+		t.Fatalf("unexpected line '%s'", line)
+	}
+
+	// must be readable by the dump reader
+	if m := dumpLineParser.FindStringSubmatch(line); len(m) != 4 {
+		t.Fatalf("can't parse '%s'", line)
+	} else if parsed := (can.Frame{}); parsed.UnmarshalString(m[3]) != nil {
+		t.Fatalf("can't unmarshal '%s'", m[3])
+	}
+}
+
+func TestRecord(t *testing.T) {
+	s := createMockSession(t)
+	mod := NewCanModule(s)
+	fileName := filepath.Join(t.TempDir(), "drive.log")
+
+	if err := mod.stopRecording(); err == nil {
+		t.Fatal("expected an error when not recording")
+	} else if err = mod.startRecording(fileName); err != nil {
+		t.Fatal(err)
+	} else if err = mod.startRecording(fileName); err == nil {
+		t.Fatal("expected an error while already recording")
+	}
+
+	mod.filter = "frame.ID != 256"
+	mod.filterExpr, _ = bexpr.CreateEvaluator(mod.filter)
+
+	mod.onFrame(testFrame(0x7e0, 0x02, 0x10, 0x03))
+	mod.onFrame(testFrame(0x100, 0xaa))
+	mod.onFrame(testFrame(0x18daf110, 0x02, 0x3e, 0x00))
+	// can.record.injected is false
+	mod.record(testFrame(0x123, 0xbb), true)
+
+	if err := mod.stopRecording(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	for i, expected := range []string{"can0 7E0#021003", "can0 18DAF110#023E00"} {
+		if !strings.HasSuffix(lines[i], expected) {
+			t.Errorf("unexpected line '%s'", lines[i])
+		}
+	}
+}
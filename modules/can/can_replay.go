@@ -0,0 +1,307 @@
+package can
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/str"
+	"go.einride.tech/can"
+)
+
+// replayRule rewrites the ID and/or the payload of the frames with a given ID,
+// its expression is ID[=NEW_ID][#DATA] where DATA is an hexadecimal payload
+// with '..' for the bytes to keep, for instance 244=245#..ff sends the frames
+// of 0x244 as 0x245 and with 0xff as their second byte.
+type replayRule struct {
+	id    uint32
+	newID uint32
+	hasID bool
+	data  []byte
+	mask  []bool
+}
+
+func parseReplayRule(expr string) (rule replayRule, err error) {
+	parts := strings.SplitN(expr, "#", 2)
+	ids := strings.SplitN(parts[0], "=", 2)
+
+	if rule.id, err = parseID(ids[0]); err != nil {
+		return
+	} else if len(ids) == 2 {
+		if rule.newID, err = parseID(ids[1]); err != nil {
+			return
+		}
+		rule.hasID = true
+	}
+
+	if len(parts) == 2 {
+		pattern := parts[1]
+		if len(pattern) == 0 || len(pattern)%2 != 0 || len(pattern)/2 > can.MaxDataLength {
+			return rule, fmt.Errorf("invalid payload '%s' in rule '%s'", pattern, expr)
+		}
+		for i := 0; i < len(pattern); i += 2 {
+			if pattern[i:i+2] == ".." {
+				rule.data = append(rule.data, 0)
+				rule.mask = append(rule.mask, false)
+			} else if b, err := hex.DecodeString(pattern[i : i+2]); err != nil {
+				return rule, fmt.Errorf("invalid payload '%s' in rule '%s'", pattern, expr)
+			} else {
+				rule.data = append(rule.data, b[0])
+				rule.mask = append(rule.mask, true)
+			}
+		}
+	} else if !rule.hasID {
+		return rule, fmt.Errorf("rule '%s' doesn't rewrite anything", expr)
+	}
+
+	return
+}
+
+func parseReplayRules(value string) ([]replayRule, error) {
+	rules := make([]replayRule, 0)
+	for _, expr := range str.Comma(value) {
+		if rule, err := parseReplayRule(expr); err != nil {
+			return nil, err
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (rule replayRule) apply(frame *can.Frame) {
+	if rule.hasID {
+		frame.ID = rule.newID
+		frame.IsExtended = rule.newID > can.MaxID
+	}
+
+	for i, keep := range rule.mask {
+		if keep {
+			frame.Data[i] = rule.data[i]
+		}
+	}
+	// the payload can make the frame longer
+	if size := uint8(len(rule.data)); size > frame.Length && !frame.IsRemote {
+		frame.Length = size
+	}
+}
+
+type replayFrame struct {
+	// time since the first replayed frame
+	offset time.Duration
+	frame  can.Frame
+}
+
+type replayConfig struct {
+	// 0 to send the frames as fast as possible
+	speed float64
+	// time window relative to the first frame of the dump, to is 0 for the
+	// end of the dump
+	from  time.Duration
+	to    time.Duration
+	ids   []idRange
+	skip  []idRange
+	rules []replayRule
+}
+
+func parseReplaySeconds(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid number of seconds '%s'", value)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (mod *CANModule) parseReplayConfig() (*replayConfig, error) {
+	var err error
+	var speed, from, to, ids, skip, rewrite string
+
+	conf := &replayConfig{}
+	if err, speed = mod.StringParam("can.replay.speed"); err != nil {
+		return nil, err
+	} else if conf.speed, err = strconv.ParseFloat(speed, 64); err != nil || conf.speed < 0 {
+		return nil, fmt.Errorf("invalid replay speed '%s'", speed)
+	} else if err, from = mod.StringParam("can.replay.from"); err != nil {
+		return nil, err
+	} else if conf.from, err = parseReplaySeconds(from); err != nil {
+		return nil, err
+	} else if err, to = mod.StringParam("can.replay.to"); err != nil {
+		return nil, err
+	} else if conf.to, err = parseReplaySeconds(to); err != nil {
+		return nil, err
+	} else if conf.to > 0 && conf.to <= conf.from {
+		return nil, errors.New("can.replay.to must be greater than can.replay.from")
+	} else if err, ids = mod.StringParam("can.replay.ids"); err != nil {
+		return nil, err
+	} else if conf.ids, err = parseIDRanges(ids); err != nil {
+		return nil, err
+	} else if err, skip = mod.StringParam("can.replay.skip"); err != nil {
+		return nil, err
+	} else if conf.skip, err = parseIDRanges(skip); err != nil {
+		return nil, err
+	} else if err, rewrite = mod.StringParam("can.replay.rewrite"); err != nil {
+		return nil, err
+	} else if conf.rules, err = parseReplayRules(rewrite); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// selectFrames returns the frames of the dump in the time window and matching
+// the ID filters, rewritten by the rules, with their delay from the first one
+// already scaled by the speed factor.
+func (conf *replayConfig) selectFrames(mod *CANModule, entries []dumpEntry) []replayFrame {
+	frames := make([]replayFrame, 0)
+	if len(entries) == 0 {
+		return frames
+	}
+
+	start := entries[0].Time.Add(conf.from)
+	for _, entry := range entries {
+		elapsed := entry.Time.Sub(entries[0].Time)
+		if elapsed < conf.from {
+			continue
+		} else if conf.to > 0 && elapsed > conf.to {
+			break
+		}
+
+		frame := can.Frame{}
+		if err := frame.UnmarshalString(entry.Frame); err != nil {
+			mod.Warning("could not unmarshal CAN frame: %v", err)
+			continue
+		} else if !matchIDRanges(conf.ids, frame.ID) || (len(conf.skip) > 0 && matchIDRanges(conf.skip, frame.ID)) {
+			continue
+		}
+
+		for _, rule := range conf.rules {
+			if rule.id == frame.ID {
+				rule.apply(&frame)
+				break
+			}
+		}
+
+		offset := time.Duration(0)
+		if conf.speed > 0 {
+			offset = time.Duration(float64(entry.Time.Sub(start)) / conf.speed)
+		}
+
+		frames = append(frames, replayFrame{offset: offset, frame: frame})
+	}
+
+	return frames
+}
+
+type canReplay struct {
+	sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (mod *CANModule) startReplay(fileName string) error {
+	if !mod.Running() {
+		return errors.New("can module not running")
+	}
+
+	conf, err := mod.parseReplayConfig()
+	if err != nil {
+		return err
+	} else if fileName, err = fs.Expand(fileName); err != nil {
+		return err
+	}
+
+	entries, err := mod.loadDump(fileName)
+	if err != nil {
+		return err
+	}
+
+	frames := conf.selectFrames(mod, entries)
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames of %s to replay with the current filters", fileName)
+	}
+
+	mod.replay.Lock()
+	defer mod.replay.Unlock()
+
+	if mod.replay.stop != nil {
+		return errors.New("already replaying, use can.replay off first")
+	}
+
+	mod.replay.stop = make(chan struct{})
+	mod.replay.done = make(chan struct{})
+
+	mod.Info("replaying %d of %d frames from %s (%s) ...", len(frames), len(entries), fileName,
+		frames[len(frames)-1].offset.Round(time.Millisecond))
+
+	go mod.replayFrames(frames, mod.replay.stop, mod.replay.done)
+
+	return nil
+}
+
+func (mod *CANModule) replayFrames(frames []replayFrame, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	total := len(frames)
+	progress := 0
+	started := time.Now()
+
+	for i, frame := range frames {
+		if wait := time.Until(started.Add(frame.offset)); wait > 0 {
+			select {
+			case <-stop:
+				mod.Info("replay stopped after %d of %d frames", i, total)
+				return
+			case <-time.After(wait):
+			}
+		} else {
+			select {
+			case <-stop:
+				mod.Info("replay stopped after %d of %d frames", i, total)
+				return
+			default:
+			}
+		}
+
+		if err := mod.transmit(frame.frame); err != nil {
+			mod.Error("could not send CAN frame: %v", err)
+		}
+
+		if percent := (i + 1) * 100 / total; percent/10 > progress/10 && percent < 100 {
+			progress = percent
+			mod.Info("replayed %d%% (%d of %d frames) ...", percent, i+1, total)
+		}
+	}
+
+	mod.Info("replayed %d frames in %s", total, time.Since(started).Round(time.Millisecond))
+
+	// let another replay start without can.replay off
+	mod.replay.Lock()
+	defer mod.replay.Unlock()
+	if mod.replay.stop == stop {
+		mod.replay.stop = nil
+	}
+}
+
+func (mod *CANModule) stopReplay() error {
+	mod.replay.Lock()
+	stop, done := mod.replay.stop, mod.replay.done
+	mod.replay.stop = nil
+	mod.replay.Unlock()
+
+	if stop == nil {
+		return errors.New("not replaying")
+	}
+
+	close(stop)
+	<-done
+	return nil
+}
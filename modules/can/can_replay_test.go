@@ -0,0 +1,148 @@
+package can
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.einride.tech/can"
+	"go.einride.tech/can/pkg/socketcan"
+)
+
+func TestParseReplayRules(t *testing.T) {
+	rules, err := parseReplayRules("244=245#..ff, 7e0=18daf110, 100#0102")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(rules) != 3 {
+		t.Fatalf("expected 3 rules, got %+v", rules)
+	}
+
+	frame := testFrame(0x244, 0xaa, 0xbb, 0xcc)
+	rules[0].apply(&frame)
+	if frame.String() != "245#AAFFCC" {
+		t.Errorf("unexpected frame %s", frame)
+	}
+
+	frame = testFrame(0x7e0, 0x01)
+	rules[1].apply(&frame)
+	if frame.String() != "18DAF110#01" {
+		t.Errorf("unexpected frame %s", frame)
+	}
+
+	frame = testFrame(0x100)
+	rules[2].apply(&frame)
+	if frame.String() != "100#0102" {
+		t.Errorf("unexpected frame %s", frame)
+	}
+
+	for _, invalid := range []string{"244", "244#f", "244#zz", "xyz=1", "1=xyz", "1#001122334455667788"} {
+		if _, err := parseReplayRules(invalid); err == nil {
+			t.Errorf("expected an error for '%s'", invalid)
+		}
+	}
+}
+
+func testReplayEntries() []dumpEntry {
+	start := time.Unix(1700623093, 0)
+	entries := make([]dumpEntry, 0)
+	for i, frame := range []string{"100#01", "200#02", "244#0300", "300#04", "100#05", "244#0600"} {
+		entries = append(entries, dumpEntry{
+			Time:   start.Add(time.Duration(i) * time.Second),
+			Device: "can0",
+			Frame:  frame,
+		})
+	}
+	return entries
+}
+
+func TestReplaySelectFrames(t *testing.T) {
+	mod := NewCanModule(createMockSession(t))
+	rules, _ := parseReplayRules("244=245#..ff")
+
+	conf := &replayConfig{
+		speed: 2,
+		from:  1 * time.Second,
+		to:    5 * time.Second,
+		skip:  []idRange{{0x300, 0x300}},
+		rules: rules,
+	}
+
+	frames := conf.selectFrames(mod, testReplayEntries())
+	got := make([]string, 0)
+	for _, f := range frames {
+		got = append(got, f.frame.String()+"@"+f.offset.String())
+	}
+	if strings.Join(got, " ") != "200#02@0s 245#03FF@500ms 100#05@1.5s 245#06FF@2s" {
+		t.Errorf("unexpected frames %v", got)
+	}
+
+	conf = &replayConfig{ids: []idRange{{0x100, 0x100}}}
+	frames = conf.selectFrames(mod, testReplayEntries())
+	if len(frames) != 2 || frames[1].offset != 0 {
+		t.Errorf("unexpected frames %+v", frames)
+	}
+}
+
+func TestReplayFrames(t *testing.T) {
+	mod := NewCanModule(createMockSession(t))
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	mod.send = socketcan.NewTransmitter(local)
+
+	received := make(chan can.Frame, 16)
+	go func() {
+		recv := socketcan.NewReceiver(remote)
+		for recv.Receive() {
+			received <- recv.Frame()
+		}
+	}()
+
+	conf := &replayConfig{}
+	frames := conf.selectFrames(mod, testReplayEntries())
+
+	mod.replay.stop = make(chan struct{})
+	mod.replay.done = make(chan struct{})
+	go mod.replayFrames(frames, mod.replay.stop, mod.replay.done)
+
+	for i := range frames {
+		select {
+		case frame := <-received:
+			if frame != frames[i].frame {
+				t.Fatalf("expected %s, got %s", frames[i].frame, frame)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	<-mod.replay.done
+	if err := mod.stopReplay(); err == nil {
+		t.Error("expected an error after the replay completed")
+	}
+
+	// stopping a slow replay
+	conf.speed = 0.001
+	frames = conf.selectFrames(mod, testReplayEntries())
+	mod.replay.stop = make(chan struct{})
+	mod.replay.done = make(chan struct{})
+	go mod.replayFrames(frames, mod.replay.stop, mod.replay.done)
+
+	<-received
+	if err := mod.stopReplay(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartReplayNotRunning(t *testing.T) {
+	mod := NewCanModule(createMockSession(t))
+	fileName := filepath.Join(t.TempDir(), "dump.log")
+	os.WriteFile(fileName, []byte("(1700623093.260875) can0 7E0#0322128C00000000\n"), 0644)
+
+	if err := mod.startReplay(fileName); err == nil {
+		t.Error("expected an error when not running")
+	}
+}
@@ -81,8 +81,19 @@ func TestNewCanModule(t *testing.T) {
 		"can.recon off",
 		"can.clear",
 		"can.show",
+		"can.record off",
+		"can.record FILE",
+		"can.replay off",
+		"can.replay FILE",
+		"can.analyze on",
+		"can.analyze off",
+		"can.analyze.show",
+		"can.analyze.mark off",
+		"can.analyze.mark NAME",
+		"can.analyze.save FILE",
 		"can.dbc.load NAME",
 		"can.inject FRAME_EXPRESSION",
+		"can.inject.signal MESSAGE SIGNAL=VALUE",
 		"can.fuzz ID_OR_NODE_NAME OPTIONAL_SIZE",
 	}
 
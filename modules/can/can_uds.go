@@ -0,0 +1,55 @@
+package can
+
+import (
+	"sync"
+	"time"
+)
+
+type UDS struct {
+	sync.RWMutex
+
+	enabled bool
+	ids     []idRange
+	isotp   *ISOTP
+}
+
+func NewUDS() *UDS {
+	return &UDS{
+		isotp: NewISOTP(),
+	}
+}
+
+func (uds *UDS) Enabled() bool {
+	uds.RLock()
+	defer uds.RUnlock()
+	return uds.enabled
+}
+
+// Enable enables or disables the reassembly of the ISO-TP transfers of the
+// given arbitration IDs, all of them if empty, and their UDS decoding.
+func (uds *UDS) Enable(enable bool, ids []idRange) {
+	uds.Lock()
+	defer uds.Unlock()
+	uds.enabled = enable
+	uds.ids = ids
+	uds.isotp.Clear()
+}
+
+func (uds *UDS) Parse(mod *CANModule, msg *Message) bool {
+	uds.RLock()
+	defer uds.RUnlock()
+
+	if !uds.enabled || !matchIDRanges(uds.ids, msg.Frame.ID) {
+		return false
+	}
+
+	if packet, complete := uds.isotp.Feed(msg.Frame, time.Now()); complete {
+		if udsMessage, ok := ParseUDS(packet); ok {
+			msg.UDS = udsMessage
+			return true
+		}
+		mod.Debug("ISO-TP packet from 0x%x is not UDS: %x", packet.ID, packet.Data)
+	}
+
+	return false
+}
@@ -0,0 +1,418 @@
+package can
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// https://en.wikipedia.org/wiki/Unified_Diagnostic_Services
+
+const udsNegativeResponse = 0x7f
+
+type UDSService uint8
+
+var udsServices = map[UDSService]string{
+	0x10: "DiagnosticSessionControl",
+	0x11: "ECUReset",
+	0x14: "ClearDiagnosticInformation",
+	0x19: "ReadDTCInformation",
+	0x22: "ReadDataByIdentifier",
+	0x23: "ReadMemoryByAddress",
+	0x24: "ReadScalingDataByIdentifier",
+	0x27: "SecurityAccess",
+	0x28: "CommunicationControl",
+	0x29: "Authentication",
+	0x2A: "ReadDataByPeriodicIdentifier",
+	0x2C: "DynamicallyDefineDataIdentifier",
+	0x2E: "WriteDataByIdentifier",
+	0x2F: "InputOutputControlByIdentifier",
+	0x31: "RoutineControl",
+	0x34: "RequestDownload",
+	0x35: "RequestUpload",
+	0x36: "TransferData",
+	0x37: "RequestTransferExit",
+	0x38: "RequestFileTransfer",
+	0x3D: "WriteMemoryByAddress",
+	0x3E: "TesterPresent",
+	0x83: "AccessTimingParameter",
+	0x84: "SecuredDataTransmission",
+	0x85: "ControlDTCSetting",
+	0x86: "ResponseOnEvent",
+	0x87: "LinkControl",
+}
+
+func (s UDSService) Known() bool {
+	_, found := udsServices[s]
+	return found
+}
+
+func (s UDSService) String() string {
+	if name, found := udsServices[s]; found {
+		return name
+	}
+	return fmt.Sprintf("service 0x%x", uint8(s))
+}
+
+func (s UDSService) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// hasSubFunction returns true if the first parameter of the service is a
+// sub-function, whose bit 7 asks the server to suppress the positive response.
+func (s UDSService) hasSubFunction() bool {
+	switch s {
+	case 0x10, 0x11, 0x19, 0x27, 0x28, 0x29, 0x31, 0x3E, 0x83, 0x85, 0x86, 0x87:
+		return true
+	}
+	return false
+}
+
+type UDSNRC uint8
+
+var udsNRCs = map[UDSNRC]string{
+	0x10: "generalReject",
+	0x11: "serviceNotSupported",
+	0x12: "subFunctionNotSupported",
+	0x13: "incorrectMessageLengthOrInvalidFormat",
+	0x14: "responseTooLong",
+	0x21: "busyRepeatRequest",
+	0x22: "conditionsNotCorrect",
+	0x24: "requestSequenceError",
+	0x25: "noResponseFromSubnetComponent",
+	0x26: "failurePreventsExecutionOfRequestedAction",
+	0x31: "requestOutOfRange",
+	0x33: "securityAccessDenied",
+	0x34: "authenticationRequired",
+	0x35: "invalidKey",
+	0x36: "exceedNumberOfAttempts",
+	0x37: "requiredTimeDelayNotExpired",
+	0x70: "uploadDownloadNotAccepted",
+	0x71: "transferDataSuspended",
+	0x72: "generalProgrammingFailure",
+	0x73: "wrongBlockSequenceCounter",
+	0x78: "requestCorrectlyReceivedResponsePending",
+	0x7E: "subFunctionNotSupportedInActiveSession",
+	0x7F: "serviceNotSupportedInActiveSession",
+	0x81: "rpmTooHigh",
+	0x82: "rpmTooLow",
+	0x83: "engineIsRunning",
+	0x84: "engineIsNotRunning",
+	0x88: "vehicleSpeedTooHigh",
+	0x89: "vehicleSpeedTooLow",
+	0x92: "voltageTooHigh",
+	0x93: "voltageTooLow",
+}
+
+func (c UDSNRC) String() string {
+	if name, found := udsNRCs[c]; found {
+		return name
+	}
+	return fmt.Sprintf("nrc 0x%x", uint8(c))
+}
+
+func (c UDSNRC) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// well known data identifiers of ISO 14229-1 annex C
+var udsDIDs = map[uint16]string{
+	0xF186: "ActiveDiagnosticSession",
+	0xF187: "SparePartNumber",
+	0xF188: "ECUSoftwareNumber",
+	0xF189: "ECUSoftwareVersion",
+	0xF18A: "SystemSupplierIdentifier",
+	0xF18B: "ECUManufacturingDate",
+	0xF18C: "ECUSerialNumber",
+	0xF190: "VIN",
+	0xF191: "ECUHardwareNumber",
+	0xF192: "SystemSupplierECUHardwareNumber",
+	0xF193: "SystemSupplierECUHardwareVersion",
+	0xF194: "SystemSupplierECUSoftwareNumber",
+	0xF195: "SystemSupplierECUSoftwareVersion",
+	0xF197: "SystemName",
+	0xF19E: "ODXFile",
+}
+
+func udsDIDName(did uint16) string {
+	if name, found := udsDIDs[did]; found {
+		return fmt.Sprintf("%04X (%s)", did, name)
+	}
+	return fmt.Sprintf("%04X", did)
+}
+
+type UDSMessageType uint8
+
+const (
+	UDSMessageTypeRequest UDSMessageType = iota
+	UDSMessageTypeResponse
+	UDSMessageTypeNegativeResponse
+)
+
+func (t UDSMessageType) String() string {
+	switch t {
+	case UDSMessageTypeRequest:
+		return "request"
+	case UDSMessageTypeResponse:
+		return "response"
+	}
+	return "negative response"
+}
+
+func (t UDSMessageType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+type UDSMessage struct {
+	// arbitration IDs of the sender and of the receiver, if known
+	ID   uint32         `json:"id"`
+	Peer uint32         `json:"peer,omitempty"`
+	Type UDSMessageType `json:"type"`
+
+	Service          UDSService `json:"service"`
+	SubFunction      uint8      `json:"sub_function,omitempty"`
+	SuppressResponse bool       `json:"suppress_response,omitempty"`
+	NRC              UDSNRC     `json:"nrc,omitempty"`
+	// data identifiers, or the routine identifier of RoutineControl
+	DIDs []uint16 `json:"dids,omitempty"`
+	// memory address and size of the transfer and memory services, Size is
+	// the maximum block length in RequestDownload and RequestUpload responses
+	Address uint64 `json:"address,omitempty"`
+	Size    uint64 `json:"size,omitempty"`
+	// block sequence counter of TransferData
+	Block uint8  `json:"block,omitempty"`
+	Data  []byte `json:"data,omitempty"`
+}
+
+// readSized reads the big endian integer of the given size at the beginning of
+// data and returns it with the rest of the buffer.
+func readSized(data []byte, size int) (uint64, []byte, bool) {
+	if size > 8 || len(data) < size {
+		return 0, nil, false
+	}
+	value := uint64(0)
+	for _, b := range data[:size] {
+		value = value<<8 | uint64(b)
+	}
+	return value, data[size:], true
+}
+
+// parseAddressAndSize parses an addressAndLengthFormatIdentifier followed by
+// the memory address and size it describes.
+func (msg *UDSMessage) parseAddressAndSize(data []byte) ([]byte, bool) {
+	if len(data) < 1 {
+		return nil, false
+	}
+
+	ok := false
+	format := data[0]
+	if msg.Address, data, ok = readSized(data[1:], int(format&0x0f)); !ok {
+		return nil, false
+	} else if msg.Size, data, ok = readSized(data, int(format>>4)); !ok {
+		return nil, false
+	}
+	return data, true
+}
+
+// ParseUDS decodes a reassembled ISO-TP packet as a UDS request, positive or
+// negative response.
+func ParseUDS(packet *ISOTPPacket) (*UDSMessage, bool) {
+	data := packet.Data
+	if len(data) == 0 {
+		return nil, false
+	}
+
+	msg := &UDSMessage{
+		ID:   packet.ID,
+		Peer: packet.Peer,
+	}
+
+	sid := data[0]
+	if sid == udsNegativeResponse {
+		if len(data) != 3 || !UDSService(data[1]).Known() {
+			return nil, false
+		}
+		msg.Type = UDSMessageTypeNegativeResponse
+		msg.Service = UDSService(data[1])
+		msg.NRC = UDSNRC(data[2])
+		return msg, true
+	} else if UDSService(sid).Known() {
+		msg.Type = UDSMessageTypeRequest
+		msg.Service = UDSService(sid)
+	} else if sid >= 0x40 && UDSService(sid-0x40).Known() {
+		msg.Type = UDSMessageTypeResponse
+		msg.Service = UDSService(sid - 0x40)
+	} else {
+		return nil, false
+	}
+
+	ok := true
+	data = data[1:]
+	isRequest := msg.Type == UDSMessageTypeRequest
+
+	if msg.Service.hasSubFunction() {
+		if len(data) < 1 {
+			return nil, false
+		}
+		msg.SubFunction = data[0] & 0x7f
+		msg.SuppressResponse = isRequest && data[0]&0x80 != 0
+		data = data[1:]
+	}
+
+	switch msg.Service {
+	case 0x22:
+		if isRequest {
+			// one or more identifiers
+			if len(data) == 0 || len(data)%2 != 0 {
+				return nil, false
+			}
+			for ; len(data) > 0; data = data[2:] {
+				msg.DIDs = append(msg.DIDs, binary.BigEndian.Uint16(data))
+			}
+			break
+		}
+		// the length of each record depends on the identifier, only the
+		// first one can be decoded
+		fallthrough
+
+	case 0x24, 0x2E, 0x2F, 0x31:
+		if len(data) < 2 {
+			return nil, false
+		}
+		msg.DIDs = []uint16{binary.BigEndian.Uint16(data)}
+		data = data[2:]
+
+	case 0x23, 0x3D:
+		if isRequest || msg.Service == 0x3D {
+			data, ok = msg.parseAddressAndSize(data)
+		}
+
+	case 0x34, 0x35:
+		if isRequest {
+			// skip the dataFormatIdentifier
+			if len(data) < 1 {
+				return nil, false
+			}
+			data, ok = msg.parseAddressAndSize(data[1:])
+		} else if len(data) > 0 {
+			msg.Size, data, ok = readSized(data[1:], int(data[0]>>4))
+		}
+
+	case 0x36:
+		if len(data) < 1 {
+			return nil, false
+		}
+		msg.Block = data[0]
+		data = data[1:]
+	}
+
+	if !ok {
+		return nil, false
+	} else if len(data) > 0 {
+		msg.Data = append([]byte(nil), data...)
+	}
+
+	return msg, true
+}
+
+// SubFunctionName returns the name of the sub-function of the message, or an
+// empty string if its service doesn't have any.
+func (msg *UDSMessage) SubFunctionName() string {
+	if !msg.Service.hasSubFunction() {
+		return ""
+	}
+
+	sub := msg.SubFunction
+	switch msg.Service {
+	case 0x10:
+		switch sub {
+		case 0x01:
+			return "defaultSession"
+		case 0x02:
+			return "programmingSession"
+		case 0x03:
+			return "extendedDiagnosticSession"
+		case 0x04:
+			return "safetySystemDiagnosticSession"
+		}
+	case 0x11:
+		switch sub {
+		case 0x01:
+			return "hardReset"
+		case 0x02:
+			return "keyOffOnReset"
+		case 0x03:
+			return "softReset"
+		}
+	case 0x27:
+		if sub%2 == 1 {
+			return fmt.Sprintf("requestSeed(level %d)", (sub+1)/2)
+		} else if sub > 0 {
+			return fmt.Sprintf("sendKey(level %d)", sub/2)
+		}
+	case 0x28:
+		switch sub {
+		case 0x00:
+			return "enableRxAndTx"
+		case 0x01:
+			return "enableRxAndDisableTx"
+		case 0x02:
+			return "disableRxAndEnableTx"
+		case 0x03:
+			return "disableRxAndTx"
+		}
+	case 0x31:
+		switch sub {
+		case 0x01:
+			return "startRoutine"
+		case 0x02:
+			return "stopRoutine"
+		case 0x03:
+			return "requestRoutineResults"
+		}
+	case 0x3E:
+		if sub == 0 {
+			return "zeroSubFunction"
+		}
+	case 0x85:
+		switch sub {
+		case 0x01:
+			return "on"
+		case 0x02:
+			return "off"
+		}
+	}
+
+	return fmt.Sprintf("0x%02x", sub)
+}
+
+func (msg *UDSMessage) String() string {
+	parts := []string{msg.Service.String()}
+
+	if msg.Type == UDSMessageTypeNegativeResponse {
+		return fmt.Sprintf("%s : %s (0x%02x)", parts[0], msg.NRC, uint8(msg.NRC))
+	}
+
+	if sub := msg.SubFunctionName(); sub != "" {
+		parts = append(parts, sub)
+	}
+	for _, did := range msg.DIDs {
+		parts = append(parts, udsDIDName(did))
+	}
+	if msg.Address > 0 {
+		parts = append(parts, fmt.Sprintf("address=0x%x", msg.Address))
+	}
+	if msg.Size > 0 {
+		parts = append(parts, fmt.Sprintf("size=%d", msg.Size))
+	}
+	if msg.Service == 0x36 {
+		parts = append(parts, fmt.Sprintf("block=%d", msg.Block))
+	}
+
+	desc := strings.Join(parts, " ")
+	if len(msg.Data) > 0 {
+		desc += " : " + hex.EncodeToString(msg.Data)
+	}
+	return desc
+}
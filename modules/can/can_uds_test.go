@@ -0,0 +1,171 @@
+package can
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+func testFrame(id uint32, data ...byte) can.Frame {
+	frame := can.Frame{ID: id, Length: uint8(len(data)), IsExtended: id > can.MaxID}
+	copy(frame.Data[:], data)
+	return frame
+}
+
+func TestParseIDRanges(t *testing.T) {
+	ranges, err := parseIDRanges("7df, 0x7e0-7EF,18daf110")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []idRange{{0x7df, 0x7df}, {0x7e0, 0x7ef}, {0x18daf110, 0x18daf110}}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("unexpected ranges %+v", ranges)
+	}
+
+	for id, match := range map[uint32]bool{0x7df: true, 0x7e8: true, 0x7f0: false, 0x18daf110: true, 0x100: false} {
+		if matchIDRanges(ranges, id) != match {
+			t.Errorf("unexpected match of 0x%x", id)
+		}
+	}
+	if !matchIDRanges(nil, 0x123) {
+		t.Error("no ranges should match everything")
+	}
+
+	for _, invalid := range []string{"xyz", "7ef-7e0", "7e0-"} {
+		if _, err := parseIDRanges(invalid); err == nil {
+			t.Errorf("expected an error for '%s'", invalid)
+		}
+	}
+}
+
+func TestISOTPReassembly(t *testing.T) {
+	tp := NewISOTP()
+	now := time.Now()
+	vin := []byte("WAUZZZ8V0KA000001")
+	payload := append([]byte{0x62, 0xf1, 0x90}, vin...)
+
+	frames := []can.Frame{
+		testFrame(0x7e8, append([]byte{0x10, byte(len(payload))}, payload[:6]...)...),
+		testFrame(0x7e0, 0x30, 0x00, 0x00),
+		testFrame(0x7e8, append([]byte{0x21}, payload[6:13]...)...),
+		testFrame(0x7e8, append([]byte{0x22}, payload[13:]...)...),
+	}
+
+	for i, frame := range frames {
+		packet, complete := tp.Feed(frame, now)
+		if i < len(frames)-1 {
+			if complete {
+				t.Fatalf("unexpected packet after frame %d: %+v", i, packet)
+			}
+			continue
+		} else if !complete {
+			t.Fatal("expected a complete packet")
+		} else if packet.ID != 0x7e8 || packet.Peer != 0x7e0 || !bytes.Equal(packet.Data, payload) {
+			t.Fatalf("unexpected packet %+v", packet)
+		}
+	}
+
+	// a lost consecutive frame aborts the transfer
+	tp.Feed(frames[0], now)
+	tp.Feed(frames[1], now)
+	if _, complete := tp.Feed(frames[3], now); complete {
+		t.Fatal("unexpected packet with a missing frame")
+	} else if _, complete = tp.Feed(frames[2], now); complete {
+		t.Fatal("unexpected packet of an aborted transfer")
+	}
+
+	// and so does a timeout
+	tp.Feed(frames[0], now)
+	tp.Feed(frames[2], now)
+	if _, complete := tp.Feed(frames[3], now.Add(2*ISOTPTimeout)); complete {
+		t.Fatal("unexpected packet after the timeout")
+	}
+
+	// non standard IDs are paired by their flow control
+	tp.Feed(testFrame(0x6f1, 0x10, 0x08, 1, 2, 3, 4, 5, 6), now)
+	tp.Feed(testFrame(0x612, 0x30, 0x00, 0x00), now)
+	if packet, complete := tp.Feed(testFrame(0x6f1, 0x21, 7, 8), now); !complete || packet.Peer != 0x612 {
+		t.Fatalf("unexpected packet %+v", packet)
+	} else if packet, complete = tp.Feed(testFrame(0x612, 0x02, 0x50, 0x03), now); !complete || packet.Peer != 0x6f1 {
+		t.Fatalf("unexpected packet %+v", packet)
+	}
+
+	// normal fixed addressing
+	if packet, complete := tp.Feed(testFrame(0x18daf110, 0x02, 0x10, 0x03), now); !complete || packet.Peer != 0x18da10f1 {
+		t.Fatalf("unexpected packet %+v", packet)
+	}
+}
+
+func TestParseUDS(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte{0x10, 0x03}, "DiagnosticSessionControl extendedDiagnosticSession"},
+		{[]byte{0x50, 0x03, 0x00, 0x32, 0x01, 0xf4}, "DiagnosticSessionControl extendedDiagnosticSession : 003201f4"},
+		{[]byte{0x22, 0xf1, 0x90, 0xf1, 0x8c}, "ReadDataByIdentifier F190 (VIN) F18C (ECUSerialNumber)"},
+		{[]byte{0x62, 0xf1, 0x90, 0x57, 0x41}, "ReadDataByIdentifier F190 (VIN) : 5741"},
+		{[]byte{0x27, 0x01}, "SecurityAccess requestSeed(level 1)"},
+		{[]byte{0x27, 0x02, 0xca, 0xfe}, "SecurityAccess sendKey(level 1) : cafe"},
+		{[]byte{0x7f, 0x27, 0x35}, "SecurityAccess : invalidKey (0x35)"},
+		{[]byte{0x34, 0x00, 0x44, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x02, 0x00}, "RequestDownload address=0x1000 size=512"},
+		{[]byte{0x74, 0x20, 0x0f, 0xff}, "RequestDownload size=4095"},
+		{[]byte{0x36, 0x01, 0xaa, 0xbb}, "TransferData block=1 : aabb"},
+		{[]byte{0x37}, "RequestTransferExit"},
+		{[]byte{0x31, 0x01, 0xff, 0x00}, "RoutineControl startRoutine FF00"},
+	}
+
+	for _, test := range tests {
+		if msg, ok := ParseUDS(&ISOTPPacket{ID: 0x7e0, Data: test.data}); !ok {
+			t.Errorf("%x not parsed", test.data)
+		} else if desc := msg.String(); desc != test.expected {
+			t.Errorf("%x: expected '%s', got '%s'", test.data, test.expected, desc)
+		}
+	}
+
+	if msg, ok := ParseUDS(&ISOTPPacket{Data: []byte{0x3e, 0x80}}); !ok || !msg.SuppressResponse || msg.Type != UDSMessageTypeRequest {
+		t.Errorf("unexpected tester present %+v", msg)
+	}
+
+	// OBD-II and broken messages
+	for _, data := range [][]byte{{0x01, 0x0c}, {0x41, 0x0c, 0x1a, 0xf8}, {0x7f, 0x27}, {0x22, 0xf1}, {0x34, 0x00, 0x44, 0x00}} {
+		if msg, ok := ParseUDS(&ISOTPPacket{Data: data}); ok {
+			t.Errorf("%x unexpectedly parsed as %+v", data, msg)
+		}
+	}
+}
+
+func TestUDSEvents(t *testing.T) {
+	s := createMockSession(t)
+	s.Events.Clear()
+	mod := NewCanModule(s)
+	mod.uds.Enable(true, []idRange{{0x700, 0x7ff}})
+
+	for _, frame := range []can.Frame{
+		testFrame(0x7e0, 0x02, 0x27, 0x01),
+		testFrame(0x7e8, 0x03, 0x7f, 0x27, 0x37),
+		// out of the ISO-TP ranges
+		testFrame(0x100, 0x02, 0x27, 0x01),
+	} {
+		mod.onFrame(frame)
+	}
+
+	events := make([]UDSMessage, 0)
+	for _, e := range s.Events.Sorted() {
+		if e.Tag == "can.uds" {
+			events = append(events, e.Data.(UDSMessage))
+		}
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	} else if events[0].Service != 0x27 || events[0].Peer != 0x7e8 || events[0].SubFunction != 1 {
+		t.Errorf("unexpected request %+v", events[0])
+	} else if events[1].Type != UDSMessageTypeNegativeResponse || events[1].NRC.String() != "requiredTimeDelayNotExpired" {
+		t.Errorf("unexpected response %+v", events[1])
+	}
+}
@@ -0,0 +1,180 @@
+package creds
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/tui"
+)
+
+type CredsModule struct {
+	session.SessionModule
+}
+
+func NewCredsModule(s *session.Session) *CredsModule {
+	mod := &CredsModule{
+		SessionModule: session.NewSessionModule("creds", s),
+	}
+
+	mod.AddHandler(session.NewModuleHandler("creds.show", "",
+		"Show the credentials captured so far by every module.",
+		func(args []string) error {
+			return mod.Show()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("creds.save FORMAT FILE", `creds\.save (json|csv|hashcat) (.+)`,
+		"Save the captured credentials to FILE as json, csv or hashcat (if hashes of different modes were captured, one file per mode is created with the mode appended to the FILE name).",
+		func(args []string) error {
+			return mod.Save(args[0], args[1])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("creds.clear", "",
+		"Clear the captured credentials.",
+		func(args []string) error {
+			mod.Session.Credentials.Clear()
+			return nil
+		}))
+
+	return mod
+}
+
+func (mod *CredsModule) Name() string {
+	return "creds"
+}
+
+func (mod *CredsModule) Description() string {
+	return "A module to list and export the credentials and hashes captured by the other modules."
+}
+
+func (mod *CredsModule) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *CredsModule) Configure() error {
+	return nil
+}
+
+func (mod *CredsModule) Stop() error {
+	return nil
+}
+
+func (mod *CredsModule) Start() error {
+	return nil
+}
+
+// abbreviate long hashes so that the table fits the terminal
+func secretView(cred session.Credential) string {
+	secret := cred.Secret
+	if len(secret) > 64 {
+		secret = secret[:61] + "..."
+	}
+
+	if cred.Type == session.CredentialPassword {
+		return tui.Red(secret)
+	}
+	return tui.Yellow(secret)
+}
+
+func (mod *CredsModule) Show() error {
+	list := mod.Session.Credentials.List()
+	if len(list) == 0 {
+		mod.Info("no credentials captured yet")
+		return nil
+	}
+
+	colNames := []string{
+		"Seen",
+		"Module",
+		"Protocol",
+		"Client",
+		"Host",
+		"User",
+		"Type",
+		"Secret",
+		"#",
+	}
+	rows := [][]string{}
+
+	for _, cred := range list {
+		rows = append(rows, []string{
+			humanize.Time(cred.LastSeen),
+			tui.Dim(cred.Module),
+			tui.Green(cred.Protocol),
+			cred.Client,
+			tui.Bold(cred.Host),
+			tui.Bold(cred.User),
+			cred.Type,
+			secretView(cred),
+			tui.Dim(fmt.Sprintf("%d", cred.Seen)),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+
+	return nil
+}
+
+func (mod *CredsModule) Save(format string, fileName string) error {
+	fileName, err := fs.Expand(fileName)
+	if err != nil {
+		return err
+	}
+
+	if format == "hashcat" {
+		return mod.saveHashcat(fileName)
+	}
+
+	fp, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	if format == "csv" {
+		err = mod.Session.Credentials.ExportCSV(fp)
+	} else {
+		err = mod.Session.Credentials.ExportJSON(fp)
+	}
+
+	if err == nil {
+		mod.Info("saved %d credentials to %s", mod.Session.Credentials.Len(), fileName)
+	}
+	return err
+}
+
+func (mod *CredsModule) saveHashcat(fileName string) error {
+	modes := mod.Session.Credentials.HashcatModes()
+	if len(modes) == 0 {
+		return fmt.Errorf("no hashes captured yet")
+	}
+
+	for _, hashMode := range modes {
+		modeFileName := fileName
+		if len(modes) > 1 {
+			ext := filepath.Ext(fileName)
+			modeFileName = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(fileName, ext), hashMode, ext)
+		}
+
+		fp, err := os.OpenFile(modeFileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+
+		num, err := mod.Session.Credentials.ExportHashcat(fp, hashMode)
+		fp.Close()
+		if err != nil {
+			return err
+		}
+
+		mod.Info("saved %d hashes to %s (hashcat -m %d)", num, modeFileName, hashMode)
+	}
+
+	return nil
+}
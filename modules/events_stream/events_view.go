@@ -93,6 +93,23 @@ func (mod *EventsStream) viewSynScanEvent(output io.Writer, e session.Event) {
 		tui.Bold(se.Address))
 }
 
+func (mod *EventsStream) viewCredentialEvent(output io.Writer, e session.Event) {
+	cred := e.Data.(session.Credential)
+	user := ""
+	if cred.User != "" {
+		user = tui.Bold(cred.User) + " @ "
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] new %s %s for %s%s captured by %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Yellow(cred.Protocol),
+		cred.Type,
+		user,
+		tui.Bold(cred.Host),
+		tui.Dim(cred.Module))
+}
+
 func (mod *EventsStream) viewUpdateEvent(output io.Writer, e session.Event) {
 	update := e.Data.(*github.RepositoryRelease)
 
@@ -130,6 +147,8 @@ func (mod *EventsStream) Render(output io.Writer, e session.Event) {
 		mod.viewSnifferEvent(output, e)
 	} else if e.Tag == "syn.scan" {
 		mod.viewSynScanEvent(output, e)
+	} else if e.Tag == "creds.new" {
+		mod.viewCredentialEvent(output, e)
 	} else if e.Tag == "update.available" {
 		mod.viewUpdateEvent(output, e)
 	} else if e.Tag == "gateway.change" {
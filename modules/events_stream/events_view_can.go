@@ -77,9 +77,33 @@ func (mod *EventsStream) viewCANOBDMessage(output io.Writer, e session.Event) {
 
 }
 
+func (mod *EventsStream) viewCANUDSMessage(output io.Writer, e session.Event) {
+	msg := e.Data.(can.UDSMessage)
+
+	peer := ""
+	if msg.Peer != 0 {
+		peer = fmt.Sprintf(" > <0x%x>", msg.Peer)
+	}
+
+	what := tui.Yellow(msg.Type.String())
+	if msg.Type == can.UDSMessageTypeNegativeResponse {
+		what = tui.Red(msg.Type.String())
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] <0x%x>%s %s : %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		msg.ID,
+		tui.Dim(peer),
+		what,
+		msg.String())
+}
+
 func (mod *EventsStream) viewCANEvent(output io.Writer, e session.Event) {
 	if e.Tag == "can.device.new" {
 		mod.viewCANDeviceNew(output, e)
+	} else if e.Tag == "can.uds" {
+		mod.viewCANUDSMessage(output, e)
 	} else if e.Tag == "can.message" {
 		msg := e.Data.(can.Message)
 		if msg.OBD2 != nil {
@@ -69,6 +69,28 @@ func (mod *EventsStream) viewWiFiClientProbeEvent(output io.Writer, e session.Ev
 		tui.Yellow(rssi))
 }
 
+func (mod *EventsStream) viewWiFiClientLuredEvent(output io.Writer, e session.Event) {
+	lured := e.Data.(wifi.LuredEvent)
+	desc := ""
+	if lured.Alias != "" {
+		desc = fmt.Sprintf(" (%s)", lured.Alias)
+	} else if lured.Vendor != "" {
+		desc = fmt.Sprintf(" (%s)", lured.Vendor)
+	}
+	rssi := ""
+	if lured.RSSI != 0 {
+		rssi = fmt.Sprintf(" (%d dBm)", lured.RSSI)
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] station %s%s is connecting to the fake access point as %s%s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		lured.Addr,
+		tui.Dim(desc),
+		tui.Red(lured.SSID),
+		tui.Yellow(rssi))
+}
+
 func (mod *EventsStream) viewWiFiHandshakeEvent(output io.Writer, e session.Event) {
 	hand := e.Data.(wifi.HandshakeEvent)
 
@@ -99,6 +121,33 @@ func (mod *EventsStream) viewWiFiHandshakeEvent(output io.Writer, e session.Even
 		hand.File)
 }
 
+func (mod *EventsStream) viewWiFiEAPEvent(output io.Writer, e session.Event) {
+	eap := e.Data.(wifi.EAPEvent)
+
+	to := eap.AP
+	if eap.ESSID != "" {
+		to = fmt.Sprintf("%s (%s)", tui.Bold(eap.ESSID), tui.Dim(eap.AP))
+	}
+
+	what := make([]string, 0)
+	if eap.Identity != "" {
+		what = append(what, fmt.Sprintf("identity %s", tui.Bold(eap.Identity)))
+	}
+	if len(eap.Methods) > 0 {
+		what = append(what, fmt.Sprintf("methods %s", strings.Join(eap.Methods, ", ")))
+	}
+	if eap.Hash != "" {
+		what = append(what, fmt.Sprintf("%s hash %s (hashcat -m %d)", eap.Method, tui.Red(eap.Hash), eap.HashcatMode))
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] station %s -> %s EAP %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		eap.Station,
+		to,
+		strings.Join(what, ", "))
+}
+
 func (mod *EventsStream) viewWiFiClientEvent(output io.Writer, e session.Event) {
 	ce := e.Data.(wifi.ClientEvent)
 
@@ -144,6 +193,18 @@ func (mod *EventsStream) viewWiFiBruteforceEvent(output io.Writer, e session.Eve
 		success.Elapsed)
 }
 
+func (mod *EventsStream) viewWiFiCrackEvent(output io.Writer, e session.Event) {
+	success := e.Data.(wifi.CrackSuccess)
+	fmt.Fprintf(output, "[%s] [%s] essid='%s' ap=%s type=%s password='%s' found_in=%v\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(tui.Bold(e.Tag)),
+		tui.Bold(success.ESSID),
+		success.AP,
+		success.Type,
+		tui.Red(success.Password),
+		success.Elapsed)
+}
+
 func (mod *EventsStream) viewWiFiEvent(output io.Writer, e session.Event) {
 	if strings.HasPrefix(e.Tag, "wifi.ap.") {
 		mod.viewWiFiApEvent(output, e)
@@ -151,12 +212,18 @@ func (mod *EventsStream) viewWiFiEvent(output io.Writer, e session.Event) {
 		mod.viewWiFiDeauthEvent(output, e)
 	} else if e.Tag == "wifi.client.probe" {
 		mod.viewWiFiClientProbeEvent(output, e)
+	} else if e.Tag == "wifi.client.eap" {
+		mod.viewWiFiEAPEvent(output, e)
+	} else if e.Tag == "wifi.client.lured" {
+		mod.viewWiFiClientLuredEvent(output, e)
 	} else if e.Tag == "wifi.client.handshake" {
 		mod.viewWiFiHandshakeEvent(output, e)
 	} else if e.Tag == "wifi.client.new" || e.Tag == "wifi.client.lost" {
 		mod.viewWiFiClientEvent(output, e)
 	} else if e.Tag == "wifi.bruteforce.success" {
 		mod.viewWiFiBruteforceEvent(output, e)
+	} else if e.Tag == "wifi.crack.success" {
+		mod.viewWiFiCrackEvent(output, e)
 	} else {
 		fmt.Fprintf(output, "[%s] [%s] %#v\n", e.Time.Format(mod.timeFormat), tui.Green(e.Tag), e)
 	}
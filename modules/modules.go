@@ -8,7 +8,9 @@ import (
 	"github.com/bettercap/bettercap/v2/modules/c2"
 	"github.com/bettercap/bettercap/v2/modules/can"
 	"github.com/bettercap/bettercap/v2/modules/caplets"
+	"github.com/bettercap/bettercap/v2/modules/creds"
 	"github.com/bettercap/bettercap/v2/modules/dhcp6_spoof"
+	"github.com/bettercap/bettercap/v2/modules/dhcp_spoof"
 	"github.com/bettercap/bettercap/v2/modules/dns_proxy"
 	"github.com/bettercap/bettercap/v2/modules/dns_spoof"
 	"github.com/bettercap/bettercap/v2/modules/events_stream"
@@ -21,11 +23,13 @@ import (
 	"github.com/bettercap/bettercap/v2/modules/https_server"
 	"github.com/bettercap/bettercap/v2/modules/mac_changer"
 	"github.com/bettercap/bettercap/v2/modules/mysql_server"
+	"github.com/bettercap/bettercap/v2/modules/name_spoof"
 	"github.com/bettercap/bettercap/v2/modules/ndp_spoof"
 	"github.com/bettercap/bettercap/v2/modules/net_probe"
 	"github.com/bettercap/bettercap/v2/modules/net_recon"
 	"github.com/bettercap/bettercap/v2/modules/net_sniff"
 	"github.com/bettercap/bettercap/v2/modules/packet_proxy"
+	"github.com/bettercap/bettercap/v2/modules/smb_server"
 	"github.com/bettercap/bettercap/v2/modules/ssh_proxy"
 	"github.com/bettercap/bettercap/v2/modules/syn_scan"
 	"github.com/bettercap/bettercap/v2/modules/tcp_proxy"
@@ -46,6 +50,7 @@ func LoadModules(sess *session.Session) {
 	sess.Register(ble.NewBLERecon(sess))
 	sess.Register(can.NewCanModule(sess))
 	sess.Register(dhcp6_spoof.NewDHCP6Spoofer(sess))
+	sess.Register(dhcp_spoof.NewDHCPSpoofer(sess))
 	sess.Register(net_recon.NewDiscovery(sess))
 	sess.Register(dns_proxy.NewDnsProxy(sess))
 	sess.Register(dns_spoof.NewDNSSpoofer(sess))
@@ -58,10 +63,12 @@ func LoadModules(sess *session.Session) {
 	sess.Register(https_server.NewHttpsServer(sess))
 	sess.Register(mac_changer.NewMacChanger(sess))
 	sess.Register(mysql_server.NewMySQLServer(sess))
+	sess.Register(name_spoof.NewNameSpoofer(sess))
 	sess.Register(zerogod.NewZeroGod(sess))
 	sess.Register(net_sniff.NewSniffer(sess))
 	sess.Register(packet_proxy.NewPacketProxy(sess))
 	sess.Register(net_probe.NewProber(sess))
+	sess.Register(smb_server.NewSMBServer(sess))
 	sess.Register(syn_scan.NewSynScanner(sess))
 	sess.Register(ssh_proxy.NewSSHProxy(sess))
 	sess.Register(tcp_proxy.NewTcpProxy(sess))
@@ -73,6 +80,7 @@ func LoadModules(sess *session.Session) {
 	sess.Register(ndp_spoof.NewNDPSpoofer(sess))
 
 	sess.Register(caplets.NewCapletsModule(sess))
+	sess.Register(creds.NewCredsModule(sess))
 	sess.Register(update.NewUpdateModule(sess))
 	sess.Register(ui.NewUIModule(sess))
 }
@@ -141,6 +141,10 @@ func (mod *MySQLServer) Start() error {
 				mod.Info("can use LOAD DATA LOCAL: %s", loadData)
 				mod.Info("login request username: %s", tui.Bold(username))
 
+				if user, response, ok := packets.MySQLParseLogin(readBuffer[:read]); ok {
+					mod.addCredential(clientAddress, user, response)
+				}
+
 				if _, err := conn.Write(packets.MySQLFirstResponseOK); err != nil {
 					mod.Warning("error while writing server first response ok: %s", err)
 					continue
@@ -180,6 +184,27 @@ func (mod *MySQLServer) Start() error {
 	})
 }
 
+// addCredential stores the mysql_native_password response of a client,
+// an empty response means the client has no password.
+func (mod *MySQLServer) addCredential(client string, user string, response []byte) {
+	cred := session.Credential{
+		Protocol: "mysql",
+		Module:   mod.Name(),
+		Client:   client,
+		Host:     mod.address.IP.String(),
+		User:     user,
+		Type:     session.CredentialPassword,
+	}
+
+	if len(response) > 0 {
+		cred.Type = session.CredentialHash
+		cred.Secret = packets.MySQLHashcatString(packets.MySQLGreetingSalt(), response)
+		cred.HashcatMode = 11200
+	}
+
+	mod.Session.Credentials.Add(cred)
+}
+
 func (mod *MySQLServer) Stop() error {
 	return mod.SetRunning(false, func() {
 		defer mod.listener.Close()
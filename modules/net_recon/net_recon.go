@@ -1,6 +1,7 @@
 package net_recon
 
 import (
+	"sync"
 	"time"
 
 	"github.com/bettercap/bettercap/v2/modules/utils"
@@ -12,13 +13,29 @@ import (
 type Discovery struct {
 	session.SessionModule
 	selector *utils.ViewSelector
+
+	dhcpEnabled  bool
+	dhcpDatabase string
+	dhcpLock     *sync.Mutex
+	dhcpPending  map[string]map[string]string
 }
 
 func NewDiscovery(s *session.Session) *Discovery {
 	mod := &Discovery{
 		SessionModule: session.NewSessionModule("net.recon", s),
+		dhcpLock:      &sync.Mutex{},
+		dhcpPending:   make(map[string]map[string]string),
 	}
 
+	mod.AddParam(session.NewBoolParameter("net.recon.dhcp",
+		"true",
+		"If true, DHCPv4 requests will be passively captured in order to collect host names and fingerprint the operating system of the clients."))
+
+	mod.AddParam(session.NewStringParameter("net.recon.dhcp.fingerprints",
+		"",
+		"",
+		"Optional JSON file with additional DHCP fingerprints to match, as a list of {\"fingerprint\", \"vendor\", \"device\"} objects."))
+
 	mod.AddHandler(session.NewModuleHandler("net.recon on", "",
 		"Start network hosts discovery.",
 		func(args []string) error {
@@ -96,9 +113,16 @@ func (mod *Discovery) runDiff(cache network.ArpTable) {
 	for ip, mac := range cache {
 		mod.Session.Lan.AddIfNew(ip, mac)
 	}
+
+	mod.applyPendingDHCP()
 }
 
-func (mod *Discovery) Configure() error {
+func (mod *Discovery) Configure() (err error) {
+	if err, mod.dhcpEnabled = mod.BoolParam("net.recon.dhcp"); err != nil {
+		return err
+	} else if err, mod.dhcpDatabase = mod.StringParam("net.recon.dhcp.fingerprints"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -108,6 +132,12 @@ func (mod *Discovery) Start() error {
 	}
 
 	return mod.SetRunning(true, func() {
+		if mod.dhcpEnabled {
+			if err := mod.startDHCPListener(); err != nil {
+				mod.Warning("could not start the dhcp listener: %v", err)
+			}
+		}
+
 		every := time.Duration(1) * time.Second
 		iface := mod.Session.Interface.Name()
 		for mod.Running() {
@@ -0,0 +1,193 @@
+package net_recon
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/network"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcap"
+)
+
+// DHCPInfo is what a DHCPv4 client tells about itself.
+type DHCPInfo struct {
+	MAC         net.HardwareAddr
+	IP          net.IP
+	Hostname    string
+	Vendor      string
+	Fingerprint string
+	Device      string
+}
+
+// Meta returns the endpoint metadata for this client.
+func (i *DHCPInfo) Meta() map[string]string {
+	meta := make(map[string]string)
+	if i.Hostname != "" {
+		meta["dhcp:hostname"] = i.Hostname
+	}
+	if i.Vendor != "" {
+		meta["dhcp:vendor"] = i.Vendor
+	}
+	if i.Fingerprint != "" {
+		meta["dhcp:fingerprint"] = i.Fingerprint
+	}
+	if i.Device != "" {
+		meta["dhcp:os"] = i.Device
+	}
+	return meta
+}
+
+// ParseDHCPRequest extracts the client information from a DHCPv4 DISCOVER,
+// REQUEST or INFORM packet, it returns nil for any other packet.
+func ParseDHCPRequest(dhcp *layers.DHCPv4) *DHCPInfo {
+	if dhcp.Operation != layers.DHCPOpRequest || len(dhcp.ClientHWAddr) != 6 {
+		return nil
+	}
+
+	info := &DHCPInfo{MAC: dhcp.ClientHWAddr}
+	if !dhcp.ClientIP.IsUnspecified() {
+		info.IP = dhcp.ClientIP
+	}
+
+	msgType := layers.DHCPMsgTypeUnspecified
+	for _, opt := range dhcp.Options {
+		switch opt.Type {
+		case layers.DHCPOptMessageType:
+			if len(opt.Data) == 1 {
+				msgType = layers.DHCPMsgType(opt.Data[0])
+			}
+		case layers.DHCPOptHostname:
+			info.Hostname = strings.TrimRight(string(opt.Data), "\x00")
+		case layers.DHCPOptClassID:
+			info.Vendor = strings.TrimRight(string(opt.Data), "\x00")
+		case layers.DHCPOptRequestIP:
+			if len(opt.Data) == 4 && info.IP == nil {
+				info.IP = net.IP(opt.Data)
+			}
+		case layers.DHCPOptParamsRequest:
+			params := make([]string, len(opt.Data))
+			for i, p := range opt.Data {
+				params[i] = strconv.Itoa(int(p))
+			}
+			info.Fingerprint = strings.Join(params, ",")
+		}
+	}
+
+	switch msgType {
+	case layers.DHCPMsgTypeDiscover, layers.DHCPMsgTypeRequest, layers.DHCPMsgTypeInform:
+	default:
+		return nil
+	}
+
+	info.Device = MatchDHCPFingerprint(info.Fingerprint, info.Vendor)
+
+	return info
+}
+
+// onDHCP updates the endpoint of the client, if we don't know it yet its
+// metadata is kept until it shows up.
+func (mod *Discovery) onDHCP(info *DHCPInfo) {
+	mac := network.NormalizeMac(info.MAC.String())
+	meta := info.Meta()
+	if len(meta) == 0 {
+		return
+	}
+
+	mod.Debug("dhcp request from %s: %v", mac, meta)
+
+	endpoint, found := mod.Session.Lan.Get(mac)
+	if !found && info.IP != nil && mod.Session.Interface.Net.Contains(info.IP) {
+		mod.Session.Lan.AddIfNew(info.IP.String(), mac)
+		endpoint, found = mod.Session.Lan.Get(mac)
+	}
+
+	if found {
+		endpoint.OnMeta(meta)
+		return
+	}
+
+	mod.dhcpLock.Lock()
+	defer mod.dhcpLock.Unlock()
+
+	if pending, found := mod.dhcpPending[mac]; found {
+		for k, v := range meta {
+			pending[k] = v
+		}
+	} else {
+		mod.dhcpPending[mac] = meta
+	}
+}
+
+// applyPendingDHCP sets the metadata collected for hosts that were not known yet.
+func (mod *Discovery) applyPendingDHCP() {
+	if mod.dhcpPending == nil {
+		return
+	}
+
+	mod.dhcpLock.Lock()
+	defer mod.dhcpLock.Unlock()
+
+	for mac, meta := range mod.dhcpPending {
+		if endpoint, found := mod.Session.Lan.Get(mac); found {
+			endpoint.OnMeta(meta)
+			delete(mod.dhcpPending, mac)
+		}
+	}
+}
+
+func (mod *Discovery) dhcpListener(handle *pcap.Handle) {
+	defer handle.Close()
+
+	mod.Debug("dhcp listener started")
+
+	src := gopacket.NewPacketSource(handle, handle.LinkType())
+	for mod.Running() {
+		pkt, err := src.NextPacket()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
+			mod.Debug("dhcp listener: %v", err)
+			continue
+		}
+
+		if layer := pkt.Layer(layers.LayerTypeDHCPv4); layer != nil {
+			if info := ParseDHCPRequest(layer.(*layers.DHCPv4)); info != nil {
+				mod.onDHCP(info)
+			}
+		}
+	}
+
+	mod.Debug("dhcp listener stopped")
+}
+
+// startDHCPListener opens a capture for DHCPv4 client packets.
+func (mod *Discovery) startDHCPListener() error {
+	if filename := mod.dhcpDatabase; filename != "" {
+		if num, err := LoadDHCPFingerprints(filename); err != nil {
+			return err
+		} else {
+			mod.Info("loaded %d dhcp fingerprints from %s", num, filename)
+		}
+	} else {
+		resetDHCPFingerprints()
+	}
+
+	handle, err := network.CaptureWithTimeout(mod.Session.Interface.Name(), 500*time.Millisecond)
+	if err != nil {
+		return err
+	} else if err = handle.SetBPFFilter("udp and src port 68 and dst port 67"); err != nil {
+		handle.Close()
+		return err
+	}
+
+	go mod.dhcpListener(handle)
+
+	return nil
+}
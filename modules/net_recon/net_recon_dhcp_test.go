@@ -0,0 +1,176 @@
+package net_recon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/evilsocket/islazy/data"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func testDHCPRequest(msgType layers.DHCPMsgType, mac string, opts ...layers.DHCPOption) *layers.DHCPv4 {
+	hw, _ := net.ParseMAC(mac)
+	options := layers.DHCPOptions{
+		layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(msgType)}),
+	}
+	return &layers.DHCPv4{
+		Operation:    layers.DHCPOpRequest,
+		HardwareType: layers.LinkTypeEthernet,
+		ClientHWAddr: hw,
+		ClientIP:     net.IPv4zero,
+		Options:      append(options, opts...),
+	}
+}
+
+func TestParseDHCPRequest(t *testing.T) {
+	dhcp := testDHCPRequest(layers.DHCPMsgTypeRequest, "aa:aa:aa:aa:aa:01",
+		layers.NewDHCPOption(layers.DHCPOptHostname, []byte("DESKTOP-1234")),
+		layers.NewDHCPOption(layers.DHCPOptClassID, []byte("MSFT 5.0")),
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 50}),
+		layers.NewDHCPOption(layers.DHCPOptParamsRequest, []byte{1, 3, 6, 15, 31, 33, 43, 44, 46, 47, 119, 121, 249, 252}))
+
+	info := ParseDHCPRequest(dhcp)
+	if info == nil {
+		t.Fatal("expected dhcp info")
+	}
+
+	if info.Hostname != "DESKTOP-1234" || info.Vendor != "MSFT 5.0" || !info.IP.Equal(net.IPv4(192, 168, 1, 50)) {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info.Fingerprint != "1,3,6,15,31,33,43,44,46,47,119,121,249,252" || info.Device != "Windows 10/11" {
+		t.Errorf("unexpected fingerprint %+v", info)
+	}
+
+	meta := info.Meta()
+	if meta["dhcp:hostname"] != "DESKTOP-1234" || meta["dhcp:os"] != "Windows 10/11" {
+		t.Errorf("unexpected meta %v", meta)
+	}
+
+	// server replies are not parsed
+	dhcp.Operation = layers.DHCPOpReply
+	if ParseDHCPRequest(dhcp) != nil {
+		t.Error("expected reply to be ignored")
+	}
+
+	if ParseDHCPRequest(testDHCPRequest(layers.DHCPMsgTypeRelease, "aa:aa:aa:aa:aa:01")) != nil {
+		t.Error("expected release to be ignored")
+	}
+}
+
+func TestMatchDHCPFingerprint(t *testing.T) {
+	tests := []struct {
+		fingerprint string
+		vendor      string
+		expected    string
+	}{
+		{"1,121,3,6,15,119,252", "", "iOS"},
+		{"1,3,6,15,26,28,51,58,59,43,114", "android-dhcp-10", "Android 10+"},
+		// unknown parameters, vendor class only
+		{"1,2,3", "android-dhcp-14", "Android"},
+		{"1,2,3", "MSFT 5.0", "Windows"},
+		// windows fingerprint with a different vendor class
+		{"1,3,6,15,31,33,43,44,46,47,119,121,249,252", "", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := MatchDHCPFingerprint(tt.fingerprint, tt.vendor); got != tt.expected {
+			t.Errorf("%s/%s: expected '%s', got '%s'", tt.fingerprint, tt.vendor, tt.expected, got)
+		}
+	}
+}
+
+func TestLoadDHCPFingerprints(t *testing.T) {
+	defer resetDHCPFingerprints()
+
+	builtin := len(dhcpFingerprints)
+	filename := filepath.Join(t.TempDir(), "fingerprints.json")
+	if err := os.WriteFile(filename, []byte(`[{"fingerprint": "1,3,6", "device": "Custom Device"}, {"vendor": "MSFT", "device": "Custom Windows"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// loading again, like at every net.recon on, doesn't add duplicates
+	for i := 0; i < 2; i++ {
+		if num, err := LoadDHCPFingerprints(filename); err != nil || num != 2 {
+			t.Fatalf("unexpected result %d %v", num, err)
+		} else if len(userDHCPFingerprints) != 2 || len(dhcpFingerprints) != builtin {
+			t.Fatalf("unexpected databases sizes %d/%d", len(userDHCPFingerprints), len(dhcpFingerprints))
+		}
+	}
+
+	if got := MatchDHCPFingerprint("1,3,6", ""); got != "Custom Device" {
+		t.Errorf("expected custom device, got '%s'", got)
+	}
+	// the user database wins over better built-in matches
+	if got := MatchDHCPFingerprint("1,3,6,15,31,33,43,44,46,47,119,121,249,252", "MSFT 5.0"); got != "Custom Windows" {
+		t.Errorf("expected custom windows, got '%s'", got)
+	}
+
+	resetDHCPFingerprints()
+	if got := MatchDHCPFingerprint("1,3,6", ""); got != "" {
+		t.Errorf("expected no match after reset, got '%s'", got)
+	}
+
+	if _, err := LoadDHCPFingerprints(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestOnDHCP(t *testing.T) {
+	sess := createMockSession()
+	aliases, _ := data.NewUnsortedKV("", 0)
+	sess.Lan = network.NewLAN(sess.Interface, sess.Gateway, aliases, func(e *network.Endpoint) {}, func(e *network.Endpoint) {})
+
+	mod := NewDiscovery(sess)
+
+	// known host
+	sess.Lan.AddIfNew("192.168.1.10", "aa:aa:aa:aa:aa:01")
+	mod.onDHCP(ParseDHCPRequest(testDHCPRequest(layers.DHCPMsgTypeRequest, "aa:aa:aa:aa:aa:01",
+		layers.NewDHCPOption(layers.DHCPOptHostname, []byte("iphone")),
+		layers.NewDHCPOption(layers.DHCPOptParamsRequest, []byte{1, 121, 3, 6, 15, 119, 252}))))
+
+	if e, found := sess.Lan.Get("aa:aa:aa:aa:aa:01"); !found {
+		t.Fatal("endpoint not found")
+	} else if e.Hostname != "iphone" || e.Meta.Get("dhcp:os") != "iOS" {
+		t.Errorf("unexpected endpoint %+v", e)
+	}
+
+	// host with a requested address
+	mod.onDHCP(ParseDHCPRequest(testDHCPRequest(layers.DHCPMsgTypeRequest, "aa:aa:aa:aa:aa:02",
+		layers.NewDHCPOption(layers.DHCPOptHostname, []byte("laptop")),
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 20}))))
+
+	if e, found := sess.Lan.Get("aa:aa:aa:aa:aa:02"); !found {
+		t.Fatal("endpoint not added")
+	} else if e.IpAddress != "192.168.1.20" || e.Hostname != "laptop" {
+		t.Errorf("unexpected endpoint %+v", e)
+	}
+
+	// unknown host without address, applied once it's found by the ARP diff
+	mod.onDHCP(ParseDHCPRequest(testDHCPRequest(layers.DHCPMsgTypeDiscover, "aa:aa:aa:aa:aa:03",
+		layers.NewDHCPOption(layers.DHCPOptHostname, []byte("printer")))))
+
+	if _, found := sess.Lan.Get("aa:aa:aa:aa:aa:03"); found {
+		t.Fatal("endpoint should not be known yet")
+	}
+
+	mod.runDiff(network.ArpTable{
+		"192.168.1.10":           "aa:aa:aa:aa:aa:01",
+		"192.168.1.20":           "aa:aa:aa:aa:aa:02",
+		"192.168.1.30":           "aa:aa:aa:aa:aa:03",
+		sess.Interface.IpAddress: sess.Interface.HwAddress,
+		sess.Gateway.IpAddress:   sess.Gateway.HwAddress,
+	})
+
+	if e, found := sess.Lan.Get("aa:aa:aa:aa:aa:03"); !found {
+		t.Fatal("endpoint not added")
+	} else if e.Hostname != "printer" {
+		t.Errorf("pending metadata not applied %+v", e)
+	}
+	if len(mod.dhcpPending) != 0 {
+		t.Errorf("expected no pending metadata, got %v", mod.dhcpPending)
+	}
+}
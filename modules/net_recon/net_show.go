@@ -82,11 +82,22 @@ func (mod *Discovery) getRow(e *network.Endpoint, withMeta bool) [][]string {
 		seen = tui.Dim(seen)
 	}
 
+	vendor := tui.Dim(e.Vendor)
+	if e.Meta == nil {
+		// not discovered through the LAN
+	} else if os, ok := e.Meta.Get("dhcp:os").(string); ok && os != "" {
+		if e.Vendor != "" {
+			vendor = fmt.Sprintf("%s (%s)", vendor, os)
+		} else {
+			vendor = os
+		}
+	}
+
 	row := []string{
 		addr,
 		mac,
 		name,
-		tui.Dim(e.Vendor),
+		vendor,
 		humanize.Bytes(traffic.Sent),
 		humanize.Bytes(traffic.Received),
 		seen,
@@ -8,11 +8,14 @@ import (
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/fs"
 )
 
 type Sniffer struct {
 	session.SessionModule
 	Stats         *SnifferStats
+	Streams       *StreamAssembler
 	Ctx           *SnifferContext
 	pktSourceChan chan gopacket.Packet
 
@@ -64,6 +67,23 @@ func NewSniffer(s *session.Session) *Sniffer {
 		"",
 		"Interface to sniff on."))
 
+	mod.AddParam(session.NewIntParameter("net.sniff.streams.timeout",
+		fmt.Sprintf("%d", DefaultStreamTimeout),
+		"Number of seconds after which an idle TCP flow is considered closed by the reassembler."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.streams.buffer",
+		fmt.Sprintf("%d", DefaultStreamBuffer),
+		"Maximum number of out of order pages (1900 bytes each) the TCP reassembler will buffer before dropping data."))
+
+	mod.AddParam(session.NewStringParameter("net.sniff.carve",
+		"",
+		"",
+		"If set, files transferred over HTTP, FTP and SMB2 will be extracted to this folder and named after their SHA256."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.carve.max_size",
+		fmt.Sprintf("%d", DefaultCarveMaxSize),
+		"Maximum size in bytes of the files to extract."))
+
 	mod.AddHandler(session.NewModuleHandler("net.sniff stats", "",
 		"Print sniffer session configuration and statistics.",
 		func(args []string) error {
@@ -73,7 +93,7 @@ func NewSniffer(s *session.Session) *Sniffer {
 
 			mod.Ctx.Log(mod.Session)
 
-			return mod.Stats.Print()
+			return mod.Stats.Print(mod.Streams.Stats())
 		}))
 
 	mod.AddHandler(session.NewModuleHandler("net.sniff on", "",
@@ -88,6 +108,12 @@ func NewSniffer(s *session.Session) *Sniffer {
 			return mod.Stop()
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("net.sniff.replay FILE", `net\.sniff\.replay (.+)`,
+		"Parse every packet of a pcap or pcapng file as if it was sniffed live, without starting the sniffer.",
+		func(args []string) error {
+			return mod.Replay(args[0])
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("net.fuzz on", "",
 		"Enable fuzzing for every sniffed packet containing the specified layers.",
 		func(args []string) error {
@@ -152,11 +178,59 @@ func (mod Sniffer) isLocalPacket(packet gopacket.Packet) bool {
 }
 
 func (mod *Sniffer) onPacketMatched(pkt gopacket.Packet) {
-	if mainParser(pkt, mod.Ctx.Verbose) {
+	if mainParser(pkt, mod.Ctx.Verbose, mod.Streams) {
 		mod.Stats.NumDumped++
 	}
 }
 
+func (mod *Sniffer) Replay(filename string) error {
+	filename, err := fs.Expand(filename)
+	if err != nil {
+		return err
+	}
+
+	err, verbose := mod.BoolParam("net.sniff.verbose")
+	if err != nil {
+		return err
+	}
+
+	err, timeout := mod.IntParam("net.sniff.streams.timeout")
+	if err != nil {
+		return err
+	}
+
+	err, buffer := mod.IntParam("net.sniff.streams.buffer")
+	if err != nil {
+		return err
+	}
+
+	streams := NewStreamAssembler(time.Duration(timeout)*time.Second, buffer)
+
+	if err, carve := mod.StringParam("net.sniff.carve"); err != nil {
+		return err
+	} else if carve != "" {
+		err, maxSize := mod.IntParam("net.sniff.carve.max_size")
+		if err != nil {
+			return err
+		} else if err = streams.EnableCarving(carve, maxSize); err != nil {
+			return err
+		}
+	}
+
+	parsed := 0
+	num, err := ReplayFile(filename, verbose, streams, func(pkt gopacket.Packet, ok bool) {
+		if ok {
+			parsed++
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	mod.Info("replayed %d packets from %s (%d parsed)", num, filename, parsed)
+	return nil
+}
+
 func (mod *Sniffer) Configure() error {
 	var err error
 
@@ -180,6 +254,12 @@ func (mod *Sniffer) Start() error {
 
 	return mod.SetRunning(true, func() {
 		mod.Stats = NewSnifferStats()
+		mod.Streams = NewStreamAssembler(mod.Ctx.StreamTimeout, mod.Ctx.StreamBuffer)
+		if mod.Ctx.CarvePath != "" {
+			if err := mod.Streams.EnableCarving(mod.Ctx.CarvePath, mod.Ctx.CarveMaxSize); err != nil {
+				mod.Error("could not enable file carving: %v", err)
+			}
+		}
 
 		src := gopacket.NewPacketSource(mod.Ctx.Handle, mod.Ctx.Handle.LinkType())
 		mod.pktSourceChan = src.Packets()
@@ -224,6 +304,7 @@ func (mod *Sniffer) Start() error {
 			}
 		}
 
+		mod.Streams.Close()
 		mod.pktSourceChan = nil
 	})
 }
@@ -0,0 +1,257 @@
+package net_sniff
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/log"
+
+	"github.com/dustin/go-humanize"
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/tui"
+)
+
+const DefaultCarveMaxSize = 50 * 1024 * 1024
+
+// CarvedFile is the metadata of a file extracted from the traffic.
+type CarvedFile struct {
+	Protocol    string `json:"protocol"`
+	Source      string `json:"from"`
+	Destination string `json:"to"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+	Path        string `json:"path"`
+}
+
+// fileCarver writes transferred files to a folder, naming them after their SHA256.
+type fileCarver struct {
+	sync.Mutex
+
+	path    string
+	maxSize int
+	ftp     *ftpDataTracker
+}
+
+func newFileCarver(path string, maxSize int) (*fileCarver, error) {
+	path, err := fs.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fs.Exists(path) {
+		if err = os.MkdirAll(path, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	if maxSize <= 0 {
+		maxSize = DefaultCarveMaxSize
+	}
+
+	return &fileCarver{
+		path:    path,
+		maxSize: maxSize,
+		ftp:     newFTPDataTracker(),
+	}, nil
+}
+
+func (c *fileCarver) save(t time.Time, proto string, src, dst net.IP, name string, ctype string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	hash := sha256.Sum256(data)
+	file := CarvedFile{
+		Protocol:    proto,
+		Source:      src.String(),
+		Destination: dst.String(),
+		Name:        name,
+		ContentType: ctype,
+		Size:        len(data),
+		SHA256:      hex.EncodeToString(hash[:]),
+	}
+
+	file.Path = filepath.Join(c.path, file.SHA256)
+
+	c.Lock()
+	if !fs.Exists(file.Path) {
+		if err := os.WriteFile(file.Path, data, 0644); err != nil {
+			c.Unlock()
+			log.Error("could not save carved file %s: %v", file.Path, err)
+			return
+		}
+	}
+	c.Unlock()
+
+	NewSnifferEvent(
+		t,
+		"file",
+		src.String(),
+		dst.String(),
+		file,
+		"%s %s > %s - %s %s %s (%s)",
+		tui.Wrap(tui.BACKGREEN+tui.FOREBLACK, "file"),
+		vIP(src),
+		vIP(dst),
+		tui.Dim(proto),
+		tui.Yellow(name),
+		tui.Dim(humanize.Bytes(uint64(len(data)))),
+		tui.Dim(ctype),
+	).Push()
+}
+
+// EnableCarving makes the stream parsers write transferred files to path,
+// files bigger than maxSize bytes are ignored.
+func (a *StreamAssembler) EnableCarving(path string, maxSize int) error {
+	carver, err := newFileCarver(path, maxSize)
+	if err != nil {
+		return err
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	a.carver = carver
+	return nil
+}
+
+const (
+	bodyPlain = iota
+	bodyChunkSize
+	bodyChunkData
+	bodyChunkEnd
+	bodyTrailer
+)
+
+// bodyReader incrementally reads a message body framed either by a length,
+// by the chunked transfer encoding or by the end of the connection.
+type bodyReader struct {
+	state int
+	// bytes left in the body or in the current chunk, -1 if until close
+	remaining int64
+	data      []byte
+	max       int
+	// set when the body exceeded the maximum size and has been discarded
+	overflow bool
+}
+
+func newBodyReader(length int64, chunked bool, max int) *bodyReader {
+	b := &bodyReader{
+		state:     bodyPlain,
+		remaining: length,
+		max:       max,
+	}
+	if chunked {
+		b.state = bodyChunkSize
+	}
+	return b
+}
+
+func (b *bodyReader) write(data []byte) {
+	if b.overflow {
+		return
+	} else if len(b.data)+len(data) > b.max {
+		b.overflow = true
+		b.data = nil
+		return
+	}
+	b.data = append(b.data, data...)
+}
+
+// bodyLine returns the length of the first line of buf including its terminator.
+func bodyLine(buf []byte) int {
+	if idx := bytes.IndexByte(buf, '\n'); idx != -1 {
+		return idx + 1
+	}
+	return -1
+}
+
+// feed consumes as much as possible of buf and returns the number of bytes
+// used and true once the body is complete.
+func (b *bodyReader) feed(buf []byte) (int, bool) {
+	used := 0
+	for {
+		left := buf[used:]
+		switch b.state {
+		case bodyPlain:
+			if b.remaining < 0 {
+				b.write(left)
+				return len(buf), false
+			}
+			n := int64(len(left))
+			if n > b.remaining {
+				n = b.remaining
+			}
+			b.write(left[:n])
+			b.remaining -= n
+			return used + int(n), b.remaining == 0
+
+		case bodyChunkSize:
+			n := bodyLine(left)
+			if n == -1 {
+				return used, false
+			}
+			line := strings.TrimSpace(string(left[:n]))
+			if idx := strings.IndexByte(line, ';'); idx != -1 {
+				line = line[:idx]
+			}
+			size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+			if err != nil || size < 0 {
+				// broken framing, give up on this body
+				b.overflow = true
+				b.data = nil
+				return len(buf), true
+			}
+			used += n
+			if size == 0 {
+				b.state = bodyTrailer
+			} else {
+				b.remaining = size
+				b.state = bodyChunkData
+			}
+
+		case bodyChunkData:
+			n := int64(len(left))
+			if n == 0 {
+				return used, false
+			}
+			if n > b.remaining {
+				n = b.remaining
+			}
+			b.write(left[:n])
+			b.remaining -= n
+			used += int(n)
+			if b.remaining == 0 {
+				b.state = bodyChunkEnd
+			}
+
+		case bodyChunkEnd:
+			n := bodyLine(left)
+			if n == -1 {
+				return used, false
+			}
+			used += n
+			b.state = bodyChunkSize
+
+		case bodyTrailer:
+			n := bodyLine(left)
+			if n == -1 {
+				return used, false
+			}
+			used += n
+			if strings.TrimSpace(string(left[:n])) == "" {
+				return used, true
+			}
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package net_sniff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// replayCarving replays the frames with file carving enabled and returns
+// the carved files.
+func replayCarving(t *testing.T, frames ...[]byte) []CarvedFile {
+	t.Helper()
+
+	setupTestSession()
+
+	streams := NewStreamAssembler(DefaultStreamTimeout*time.Second, DefaultStreamBuffer)
+	if err := streams.EnableCarving(t.TempDir(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := CollectEvents(testWritePcap(t, frames...), false, streams)
+	if err != nil {
+		t.Fatalf("could not replay capture: %v", err)
+	}
+
+	files := make([]CarvedFile, 0)
+	for _, e := range eventsOf(events, "file") {
+		files = append(files, e.Data.(CarvedFile))
+	}
+	return files
+}
+
+func expectCarved(t *testing.T, file CarvedFile, proto string, name string, data []byte) {
+	t.Helper()
+
+	hash := sha256.Sum256(data)
+	if file.Protocol != proto || file.Name != name || file.Size != len(data) {
+		t.Errorf("unexpected file %+v", file)
+	}
+	if file.SHA256 != hex.EncodeToString(hash[:]) || filepath.Base(file.Path) != file.SHA256 {
+		t.Errorf("unexpected hash or path %+v", file)
+	}
+	if raw, err := os.ReadFile(file.Path); err != nil {
+		t.Error(err)
+	} else if !bytes.Equal(raw, data) {
+		t.Errorf("unexpected contents of %s", file.Path)
+	}
+}
+
+func TestCarveHTTPContentLength(t *testing.T) {
+	body := "hello from the server"
+	files := replayCarving(t, mailConversation(t, 80, 42000,
+		"C:GET /files/hello.txt HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"S:HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n",
+		fmt.Sprintf("S:Content-Length: %d\r\n\r\n%s", len(body), body[:5]),
+		"S:"+body[5:],
+		"C:HEAD /other HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"S:HTTP/1.1 200 OK\r\nContent-Length: 1000\r\n\r\n",
+		"C:GET /download?id=1 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"S:HTTP/1.1 200 OK\r\nContent-Disposition: attachment; filename=\"report.pdf\"\r\nContent-Length: 3\r\n\r\nPDF",
+	)...)
+
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %+v", files)
+	}
+	expectCarved(t, files[0], "http", "hello.txt", []byte(body))
+	expectCarved(t, files[1], "http", "report.pdf", []byte("PDF"))
+	if files[0].Source != testServerIP.String() || files[0].ContentType != "text/plain" {
+		t.Errorf("unexpected file %+v", files[0])
+	}
+}
+
+func TestCarveHTTPChunkedGzip(t *testing.T) {
+	data := bytes.Repeat([]byte("compressed content "), 50)
+	compressed := bytes.Buffer{}
+	w := gzip.NewWriter(&compressed)
+	w.Write(data)
+	w.Close()
+
+	raw := compressed.Bytes()
+	half := len(raw) / 2
+	chunked := fmt.Sprintf("%x\r\n%s\r\n%x;ext=1\r\n%s\r\n0\r\n\r\n", half, raw[:half], len(raw)-half, raw[half:])
+
+	files := replayCarving(t, mailConversation(t, 8080, 42001,
+		"C:GET /data.bin HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"S:HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nContent-Encoding: gzip\r\n\r\n",
+		"S:"+chunked[:7],
+		"S:"+chunked[7:],
+	)...)
+
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %+v", files)
+	}
+	expectCarved(t, files[0], "http", "data.bin", data)
+}
+
+func TestCarveHTTPGzipBomb(t *testing.T) {
+	setupTestSession()
+
+	compressed := bytes.Buffer{}
+	w := gzip.NewWriter(&compressed)
+	w.Write(make([]byte, 1024*1024))
+	w.Close()
+	raw := compressed.Bytes()
+
+	streams := NewStreamAssembler(DefaultStreamTimeout*time.Second, DefaultStreamBuffer)
+	if err := streams.EnableCarving(t.TempDir(), 4096); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := CollectEvents(testWritePcap(t, mailConversation(t, 80, 42011,
+		"C:GET /bomb.bin HTTP/1.1\r\n\r\n",
+		fmt.Sprintf("S:HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\nContent-Length: %d\r\n\r\n%s", len(raw), raw),
+	)...), false, streams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// too big once uncompressed, saved as is
+	files := eventsOf(events, "file")
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %+v", files)
+	}
+	expectCarved(t, files[0].Data.(CarvedFile), "http", "bomb.bin", raw)
+}
+
+func TestCarveHTTPUntilClose(t *testing.T) {
+	files := replayCarving(t, mailConversation(t, 80, 42002,
+		"C:GET / HTTP/1.0\r\n\r\n",
+		"S:HTTP/1.0 200 OK\r\n\r\n<html>",
+		"S:</html>",
+	)...)
+
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %+v", files)
+	}
+	expectCarved(t, files[0], "http", "index", []byte("<html></html>"))
+}
+
+func TestCarveHTTPIgnoresOtherProtocols(t *testing.T) {
+	files := replayCarving(t, mailConversation(t, 80, 42003,
+		"C:\x16\x03\x01garbage\r\n\r\n",
+		"S:HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\nabc",
+	)...)
+
+	if len(files) != 0 {
+		t.Errorf("expected no files, got %+v", files)
+	}
+}
+
+func TestCarveFTPPassive(t *testing.T) {
+	content := []byte("ftp file contents")
+	frames := mailConversation(t, 21, 42004,
+		"S:220 ready\r\n",
+		"C:PASV\r\n",
+		"S:227 Entering Passive Mode (10,0,0,3,195,80).\r\n",
+		"C:RETR /pub/notes.txt\r\n",
+	)
+	frames = append(frames, mailConversation(t, 50000, 42005,
+		"S:"+string(content[:4]),
+		"S:"+string(content[4:]),
+	)...)
+	frames = append(frames, mailConversation(t, 21, 42004, "S:226 done\r\n")...)
+
+	files := replayCarving(t, frames...)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %+v", files)
+	}
+	expectCarved(t, files[0], "ftp", "notes.txt", content)
+	if files[0].Source != testServerIP.String() {
+		t.Errorf("unexpected source %s", files[0].Source)
+	}
+}
+
+func TestCarveFTPExtendedActive(t *testing.T) {
+	content := []byte("uploaded")
+	frames := mailConversation(t, 21, 42006,
+		"C:EPRT |1|10.0.0.2|42007|\r\n",
+		"C:STOR up.bin\r\n",
+	)
+	// the server connects back to the client, which sends the file
+	frames = append(frames, testTCPPacket(t, true, 42007, 20, 1, content))
+
+	files := replayCarving(t, frames...)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %+v", files)
+	}
+	expectCarved(t, files[0], "ftp", "up.bin", content)
+}
+
+// smb2Packet builds a direct TCP SMB2 message with the given header fields and body.
+func smb2Packet(command uint16, response bool, msgID uint64, body []byte) string {
+	hdr := make([]byte, smb2HeaderSize)
+	copy(hdr, smb2Magic)
+	binary.LittleEndian.PutUint16(hdr[4:], smb2HeaderSize)
+	binary.LittleEndian.PutUint16(hdr[12:], command)
+	if response {
+		binary.LittleEndian.PutUint32(hdr[16:], smb2FlagResponse)
+	}
+	binary.LittleEndian.PutUint64(hdr[24:], msgID)
+
+	msg := append(hdr, body...)
+	frame := make([]byte, 4)
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	return string(append(frame, msg...))
+}
+
+func TestCarveSMB2Read(t *testing.T) {
+	id := bytes.Repeat([]byte{0xab}, 16)
+	content := []byte("secret document")
+
+	name := utf16.Encode([]rune(`share\docs\secret.txt`))
+	rawName := make([]byte, len(name)*2)
+	for i, u := range name {
+		binary.LittleEndian.PutUint16(rawName[i*2:], u)
+	}
+
+	createReq := make([]byte, 56)
+	binary.LittleEndian.PutUint16(createReq[44:], smb2HeaderSize+56)
+	binary.LittleEndian.PutUint16(createReq[46:], uint16(len(rawName)))
+	createReq = append(createReq, rawName...)
+
+	createRes := make([]byte, 88)
+	copy(createRes[64:], id)
+
+	readReq := func(offset uint64) []byte {
+		body := make([]byte, 48)
+		binary.LittleEndian.PutUint32(body[4:], 8)
+		binary.LittleEndian.PutUint64(body[8:], offset)
+		copy(body[16:], id)
+		return body
+	}
+	readRes := func(data []byte) []byte {
+		body := make([]byte, 16)
+		body[2] = smb2HeaderSize + 16
+		binary.LittleEndian.PutUint32(body[4:], uint32(len(data)))
+		return append(body, data...)
+	}
+
+	closeReq := make([]byte, 24)
+	copy(closeReq[8:], id)
+
+	files := replayCarving(t, mailConversation(t, 445, 42008,
+		"C:"+smb2Packet(smb2Create, false, 1, createReq),
+		"S:"+smb2Packet(smb2Create, true, 1, createRes),
+		"C:"+smb2Packet(smb2Read, false, 2, readReq(0))+smb2Packet(smb2Read, false, 3, readReq(8)),
+		"S:"+smb2Packet(smb2Read, true, 3, readRes(content[8:])),
+		"S:"+smb2Packet(smb2Read, true, 2, readRes(content[:8])),
+		"C:"+smb2Packet(smb2Close, false, 4, closeReq),
+	)...)
+
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %+v", files)
+	}
+	expectCarved(t, files[0], "smb", "secret.txt", content)
+}
+
+func TestCarveSMB2Write(t *testing.T) {
+	id := bytes.Repeat([]byte{0x01}, 16)
+	content := []byte("written over smb")
+
+	write := make([]byte, 48)
+	binary.LittleEndian.PutUint16(write[2:], smb2HeaderSize+48)
+	binary.LittleEndian.PutUint32(write[4:], uint32(len(content)))
+	copy(write[16:], id)
+	write = append(write, content...)
+
+	files := replayCarving(t, mailConversation(t, 445, 42009,
+		"C:"+smb2Packet(smb2Write, false, 1, write),
+	)...)
+
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %+v", files)
+	}
+	expectCarved(t, files[0], "smb", hex.EncodeToString(id), content)
+	if files[0].Source != testClientIP.String() {
+		t.Errorf("unexpected source %s", files[0].Source)
+	}
+}
+
+func TestSMBFileWriteBounds(t *testing.T) {
+	file := &smbFile{}
+	file.write(math.MaxUint64-8, []byte("0123456789abcdef"), 1024)
+	if !file.overflow || file.data != nil {
+		t.Fatalf("expected a wrapping offset to overflow, got %+v", file)
+	}
+
+	file = &smbFile{}
+	file.write(4, []byte("data"), 8)
+	if file.overflow || string(file.data) != "\x00\x00\x00\x00data" {
+		t.Fatalf("unexpected file %+v", file)
+	} else if file.write(5, []byte("data"), 8); !file.overflow {
+		t.Error("expected a write past the max size to overflow")
+	}
+
+	h := &smbCarveHandler{files: make(map[smb2FileID]*smbFile)}
+	for i := 0; i < smb2MaxFiles+8; i++ {
+		h.file(smb2FileID{byte(i)}).write(0, []byte("x"), 8)
+	}
+	if len(h.files) != smb2MaxFiles {
+		t.Errorf("expected %d tracked files, got %d", smb2MaxFiles, len(h.files))
+	} else if extra := h.file(smb2FileID{byte(smb2MaxFiles)}); !extra.overflow {
+		t.Error("expected untracked files to be discarded")
+	}
+}
+
+func TestCarveMaxSize(t *testing.T) {
+	setupTestSession()
+
+	streams := NewStreamAssembler(DefaultStreamTimeout*time.Second, DefaultStreamBuffer)
+	if err := streams.EnableCarving(t.TempDir(), 4); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := CollectEvents(testWritePcap(t, mailConversation(t, 80, 42010,
+		"C:GET /big HTTP/1.1\r\n\r\n",
+		"S:HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\n0123456789",
+		"C:GET /small HTTP/1.1\r\n\r\n",
+		"S:HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\n012",
+	)...), false, streams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := eventsOf(events, "file")
+	if len(files) != 1 || files[0].Data.(CarvedFile).Name != "small" {
+		t.Errorf("expected only the small file, got %+v", files)
+	}
+}
+
+func TestBodyReaderChunked(t *testing.T) {
+	b := newBodyReader(-1, true, 1024)
+	input := []byte("4\r\nWiki\r\n5\r\npedia\r\n0\r\nX-Trailer: 1\r\n\r\nNEXT")
+
+	used, done := 0, false
+	// feed one byte at a time
+	for i := 1; i <= len(input) && !done; i++ {
+		var n int
+		n, done = b.feed(input[used:i])
+		used += n
+	}
+
+	if !done || string(b.data) != "Wikipedia" || string(input[used:]) != "NEXT" {
+		t.Errorf("unexpected result done=%v data='%s' left='%s'", done, b.data, input[used:])
+	}
+}
@@ -16,17 +16,21 @@ import (
 )
 
 type SnifferContext struct {
-	Handle       *pcap.Handle
-	Interface    string
-	Source       string
-	DumpLocal    bool
-	Verbose      bool
-	Filter       string
-	Expression   string
-	Compiled     *regexp.Regexp
-	Output       string
-	OutputFile   *os.File
-	OutputWriter *pcapgo.NgWriter
+	Handle        *pcap.Handle
+	Interface     string
+	Source        string
+	DumpLocal     bool
+	Verbose       bool
+	Filter        string
+	Expression    string
+	Compiled      *regexp.Regexp
+	Output        string
+	OutputFile    *os.File
+	OutputWriter  *pcapgo.NgWriter
+	StreamTimeout time.Duration
+	StreamBuffer  int
+	CarvePath     string
+	CarveMaxSize  int
 }
 
 func (mod *Sniffer) GetContext() (error, *SnifferContext) {
@@ -87,6 +91,24 @@ func (mod *Sniffer) GetContext() (error, *SnifferContext) {
 		}
 	}
 
+	timeout := 0
+	if err, timeout = mod.IntParam("net.sniff.streams.timeout"); err != nil {
+		return err, ctx
+	}
+	ctx.StreamTimeout = time.Duration(timeout) * time.Second
+
+	if err, ctx.StreamBuffer = mod.IntParam("net.sniff.streams.buffer"); err != nil {
+		return err, ctx
+	}
+
+	if err, ctx.CarvePath = mod.StringParam("net.sniff.carve"); err != nil {
+		return err, ctx
+	}
+
+	if err, ctx.CarveMaxSize = mod.IntParam("net.sniff.carve.max_size"); err != nil {
+		return err, ctx
+	}
+
 	if err, ctx.Output = mod.StringParam("net.sniff.output"); err != nil {
 		return err, ctx
 	} else if ctx.Output != "" {
@@ -105,17 +127,21 @@ func (mod *Sniffer) GetContext() (error, *SnifferContext) {
 
 func NewSnifferContext() *SnifferContext {
 	return &SnifferContext{
-		Handle:       nil,
-		Interface:    "",
-		Source:       "",
-		DumpLocal:    false,
-		Verbose:      false,
-		Filter:       "",
-		Expression:   "",
-		Compiled:     nil,
-		Output:       "",
-		OutputFile:   nil,
-		OutputWriter: nil,
+		Handle:        nil,
+		Interface:     "",
+		Source:        "",
+		DumpLocal:     false,
+		Verbose:       false,
+		Filter:        "",
+		Expression:    "",
+		Compiled:      nil,
+		Output:        "",
+		OutputFile:    nil,
+		OutputWriter:  nil,
+		StreamTimeout: DefaultStreamTimeout * time.Second,
+		StreamBuffer:  DefaultStreamBuffer,
+		CarvePath:     "",
+		CarveMaxSize:  DefaultCarveMaxSize,
 	}
 }
 
@@ -135,6 +161,9 @@ func (c *SnifferContext) Log(sess *session.Session) {
 	log.Info("BPF Filter         : '%s'", tui.Yellow(c.Filter))
 	log.Info("Regular expression : '%s'", tui.Yellow(c.Expression))
 	log.Info("File output        : '%s'", tui.Yellow(c.Output))
+	log.Info("Streams timeout    : %s", c.StreamTimeout)
+	log.Info("Streams buffer     : %d pages", c.StreamBuffer)
+	log.Info("Carve files to     : '%s'", tui.Yellow(c.CarvePath))
 }
 
 func (c *SnifferContext) Close() {
@@ -30,6 +30,28 @@ func NewSnifferEvent(t time.Time, proto string, src string, dst string, data int
 }
 
 func (e SnifferEvent) Push() {
+	sinkLock.RLock()
+	defer sinkLock.RUnlock()
+
+	if sink != nil {
+		sink(e)
+		return
+	}
+
 	session.I.Events.Add("net.sniff."+e.Protocol, e)
 	session.I.Refresh()
 }
+
+// pushCredential stores a credential found by a parser in the session vault.
+func pushCredential(cred session.Credential) {
+	sinkLock.RLock()
+	defer sinkLock.RUnlock()
+
+	// collected events don't end up in the session
+	if sink != nil || session.I == nil || session.I.Credentials == nil {
+		return
+	}
+
+	cred.Module = "net.sniff"
+	session.I.Credentials.Add(cred)
+}
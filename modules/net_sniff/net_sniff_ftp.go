@@ -1,8 +1,17 @@
 package net_sniff
 
 import (
+	"fmt"
 	"net"
+	"net/http"
+	"path"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -13,8 +22,34 @@ import (
 
 var (
 	ftpRe = regexp.MustCompile(`^(USER|PASS) (.+)[\n\r]+$`)
+
+	// USER commands waiting for their PASS, by control connection
+	ftpUsers     = make(map[string]string)
+	ftpUsersLock = sync.Mutex{}
 )
 
+// max number of USER commands waiting for a PASS
+const ftpMaxPendingUsers = 1024
+
+// ftpCredential pairs USER and PASS commands sent on the same connection,
+// returning the user name once the password is sent.
+func ftpCredential(conn string, what string, arg string) (string, bool) {
+	ftpUsersLock.Lock()
+	defer ftpUsersLock.Unlock()
+
+	if what == "USER" {
+		if len(ftpUsers) >= ftpMaxPendingUsers {
+			ftpUsers = make(map[string]string)
+		}
+		ftpUsers[conn] = arg
+		return "", false
+	}
+
+	user, found := ftpUsers[conn]
+	delete(ftpUsers, conn)
+	return user, found
+}
+
 func ftpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
 	data := string(tcp.Payload)
 
@@ -36,8 +71,211 @@ func ftpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *la
 			tui.Yellow(cred),
 		).Push()
 
+		conn := fmt.Sprintf("%s:%d>%s:%d", srcIP, tcp.SrcPort, dstIP, tcp.DstPort)
+		if user, ok := ftpCredential(conn, what, cred); ok {
+			pushCredential(session.Credential{
+				Protocol: "ftp",
+				Client:   srcIP.String(),
+				Host:     dstIP.String(),
+				User:     user,
+				Type:     session.CredentialPassword,
+				Secret:   cred,
+				LastSeen: pkt.Metadata().Timestamp,
+			})
+		}
+
 		return true
 	}
 
 	return false
 }
+
+var (
+	ftpPasvRe = regexp.MustCompile(`\((\d+),(\d+),(\d+),(\d+),(\d+),(\d+)\)`)
+	ftpEpsvRe = regexp.MustCompile(`\(\|\|\|(\d+)\|\)`)
+)
+
+// ftpTransfer is a data connection announced on a control connection.
+type ftpTransfer struct {
+	name string
+}
+
+// ftpDataTracker maps the endpoints announced by PASV, EPSV, PORT and EPRT
+// to the transfers that will use them.
+type ftpDataTracker struct {
+	expected map[string]*ftpTransfer
+}
+
+func newFTPDataTracker() *ftpDataTracker {
+	return &ftpDataTracker{
+		expected: make(map[string]*ftpTransfer),
+	}
+}
+
+func ftpEndpoint(ip net.IP, port int) string {
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
+
+func (t *ftpDataTracker) expect(ip net.IP, port int) *ftpTransfer {
+	transfer := &ftpTransfer{}
+	t.expected[ftpEndpoint(ip, port)] = transfer
+	return transfer
+}
+
+func (t *ftpDataTracker) match(ip net.IP, port layers.TCPPort) *ftpTransfer {
+	key := ftpEndpoint(ip, int(port))
+	if transfer, found := t.expected[key]; found {
+		delete(t.expected, key)
+		return transfer
+	}
+	return nil
+}
+
+// ftpControlHandler follows a FTP control connection looking for data transfers.
+type ftpControlHandler struct {
+	stream  *TCPStream
+	tracker *ftpDataTracker
+	client  []byte
+	server  []byte
+	last    *ftpTransfer
+	owned   []string
+}
+
+func ftpControlParser(stream *TCPStream) StreamHandler {
+	if stream.owner.carver == nil || stream.ServerPort != 21 {
+		return nil
+	}
+	return &ftpControlHandler{
+		stream:  stream,
+		tracker: stream.owner.carver.ftp,
+	}
+}
+
+func (h *ftpControlHandler) expect(ip net.IP, port int) {
+	h.last = h.tracker.expect(ip, port)
+	h.owned = append(h.owned, ftpEndpoint(ip, port))
+}
+
+func (h *ftpControlHandler) OnData(chunk StreamChunk) {
+	if chunk.FromClient {
+		for _, line := range readLines(&h.client, chunk.Data) {
+			cmd, args := mailCommand(line)
+			switch cmd {
+			case "PORT":
+				if len(args) > 0 {
+					if m := ftpPasvRe.FindStringSubmatch("(" + args[0] + ")"); m != nil {
+						h.onAddress(m[1:])
+					}
+				}
+			case "EPRT":
+				// EPRT |proto|address|port|
+				if len(args) > 0 {
+					if parts := strings.Split(args[0], "|"); len(parts) == 5 {
+						if ip := net.ParseIP(parts[2]); ip != nil {
+							if port, err := strconv.Atoi(parts[3]); err == nil {
+								h.expect(ip, port)
+							}
+						}
+					}
+				}
+			case "RETR", "STOR", "STOU", "APPE":
+				if h.last != nil && len(args) > 0 {
+					h.last.name = path.Base(strings.Join(args, " "))
+				}
+			}
+		}
+	} else {
+		for _, line := range readLines(&h.server, chunk.Data) {
+			if strings.HasPrefix(line, "227 ") {
+				if m := ftpPasvRe.FindStringSubmatch(line); m != nil {
+					h.onAddress(m[1:])
+				}
+			} else if strings.HasPrefix(line, "229 ") {
+				if m := ftpEpsvRe.FindStringSubmatch(line); m != nil {
+					if port, err := strconv.Atoi(m[1]); err == nil {
+						h.expect(h.stream.ServerIP, port)
+					}
+				}
+			}
+		}
+	}
+}
+
+// onAddress handles the h1,h2,h3,h4,p1,p2 address format of PASV and PORT.
+func (h *ftpControlHandler) onAddress(fields []string) {
+	nums := make([]int, len(fields))
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n > 255 {
+			return
+		}
+		nums[i] = n
+	}
+	ip := net.IPv4(byte(nums[0]), byte(nums[1]), byte(nums[2]), byte(nums[3]))
+	h.expect(ip, nums[4]<<8|nums[5])
+}
+
+func (h *ftpControlHandler) OnClose() {
+	for _, key := range h.owned {
+		delete(h.tracker.expected, key)
+	}
+}
+
+// ftpDataHandler collects the contents of a FTP data connection.
+type ftpDataHandler struct {
+	stream   *TCPStream
+	carver   *fileCarver
+	transfer *ftpTransfer
+	started  time.Time
+	client   *bodyReader
+	server   *bodyReader
+}
+
+func ftpDataParser(stream *TCPStream) StreamHandler {
+	if stream.owner.carver == nil {
+		return nil
+	}
+
+	tracker := stream.owner.carver.ftp
+	transfer := tracker.match(stream.ServerIP, stream.ServerPort)
+	if transfer == nil {
+		if transfer = tracker.match(stream.ClientIP, stream.ClientPort); transfer == nil {
+			return nil
+		}
+	}
+
+	max := stream.owner.carver.maxSize
+	stream.Claim()
+	return &ftpDataHandler{
+		stream:   stream,
+		carver:   stream.owner.carver,
+		transfer: transfer,
+		client:   newBodyReader(-1, false, max),
+		server:   newBodyReader(-1, false, max),
+	}
+}
+
+func (h *ftpDataHandler) OnData(chunk StreamChunk) {
+	if h.started.IsZero() {
+		h.started = chunk.Time
+	}
+	if chunk.FromClient {
+		h.client.feed(chunk.Data)
+	} else {
+		h.server.feed(chunk.Data)
+	}
+}
+
+func (h *ftpDataHandler) OnClose() {
+	name := h.transfer.name
+	if name == "" {
+		name = "?"
+	}
+
+	// data only flows in one direction
+	if len(h.server.data) > 0 && !h.server.overflow {
+		h.carver.save(h.started, "ftp", h.stream.ServerIP, h.stream.ClientIP, name, http.DetectContentType(h.server.data), h.server.data)
+	} else if len(h.client.data) > 0 && !h.client.overflow {
+		h.carver.save(h.started, "ftp", h.stream.ClientIP, h.stream.ServerIP, name, http.DetectContentType(h.client.data), h.client.data)
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io"
+	"mime"
 	"net"
 	"net/http"
+	"path"
 	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -138,6 +143,16 @@ func httpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *l
 				tui.Bold("PASS"),
 				tui.Red(pass),
 			).Push()
+
+			pushCredential(session.Credential{
+				Protocol: "http",
+				Client:   srcIP.String(),
+				Host:     req.Host,
+				User:     user,
+				Type:     session.CredentialPassword,
+				Secret:   pass,
+				LastSeen: pkt.Metadata().Timestamp,
+			})
 		} else {
 			NewSnifferEvent(
 				pkt.Metadata().Timestamp,
@@ -178,3 +193,219 @@ func httpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *l
 
 	return false
 }
+
+const httpMaxHeaders = 64 * 1024
+
+var httpHeadersEnd = []byte("\r\n\r\n")
+
+// httpCarveHandler follows a HTTP/1.x connection and carves response bodies.
+type httpCarveHandler struct {
+	stream *TCPStream
+	carver *fileCarver
+	dead   bool
+
+	client      []byte
+	requests    []*http.Request
+	requestBody *bodyReader
+
+	server       []byte
+	response     *http.Response
+	request      *http.Request
+	responseTime time.Time
+	responseBody *bodyReader
+}
+
+func httpCarveParser(stream *TCPStream) StreamHandler {
+	if stream.owner.carver == nil {
+		return nil
+	}
+	return &httpCarveHandler{
+		stream: stream,
+		carver: stream.owner.carver,
+	}
+}
+
+// isHTTPStart returns true if data looks like the beginning of a request or a response.
+func isHTTPStart(data []byte, fromClient bool) bool {
+	if !fromClient {
+		return len(data) < 5 || bytes.HasPrefix(data, []byte("HTTP/"))
+	}
+
+	for i, c := range data {
+		if c == ' ' {
+			return i >= 3
+		} else if c < 'A' || c > 'Z' || i > 10 {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *httpCarveHandler) OnData(chunk StreamChunk) {
+	if h.dead {
+		return
+	} else if chunk.Skipped > 0 {
+		// framing is lost
+		h.dead = true
+		return
+	}
+
+	if chunk.FromClient {
+		if len(h.client) == 0 && h.requestBody == nil && !isHTTPStart(chunk.Data, true) {
+			h.dead = true
+			return
+		}
+		h.client = append(h.client, chunk.Data...)
+		h.onClientData()
+	} else {
+		if len(h.server) == 0 && h.responseBody == nil && !isHTTPStart(chunk.Data, false) {
+			h.dead = true
+			return
+		}
+		h.server = append(h.server, chunk.Data...)
+		h.onServerData(chunk.Time)
+	}
+}
+
+// readHeaders returns the length of the headers block in buf or -1 if incomplete.
+func (h *httpCarveHandler) readHeaders(buf []byte) int {
+	if idx := bytes.Index(buf, httpHeadersEnd); idx != -1 {
+		return idx + len(httpHeadersEnd)
+	} else if len(buf) > httpMaxHeaders {
+		h.dead = true
+	}
+	return -1
+}
+
+func (h *httpCarveHandler) onClientData() {
+	for !h.dead && len(h.client) > 0 {
+		if h.requestBody != nil {
+			used, done := h.requestBody.feed(h.client)
+			h.client = h.client[used:]
+			if !done {
+				return
+			}
+			h.requestBody = nil
+			continue
+		}
+
+		size := h.readHeaders(h.client)
+		if size == -1 {
+			return
+		}
+
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(h.client[:size])))
+		h.client = h.client[size:]
+		if err != nil {
+			h.dead = true
+			return
+		}
+
+		h.requests = append(h.requests, req)
+		// request bodies are skipped
+		if chunked := len(req.TransferEncoding) > 0 && req.TransferEncoding[0] == "chunked"; chunked || req.ContentLength > 0 {
+			h.requestBody = newBodyReader(req.ContentLength, chunked, 0)
+		}
+	}
+}
+
+func (h *httpCarveHandler) onServerData(t time.Time) {
+	for !h.dead && len(h.server) > 0 {
+		if h.responseBody != nil {
+			used, done := h.responseBody.feed(h.server)
+			h.server = h.server[used:]
+			if !done {
+				return
+			}
+			h.save()
+			continue
+		}
+
+		size := h.readHeaders(h.server)
+		if size == -1 {
+			return
+		}
+
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(h.server[:size])), nil)
+		h.server = h.server[size:]
+		if err != nil {
+			h.dead = true
+			return
+		} else if res.StatusCode >= 100 && res.StatusCode < 200 {
+			// informational responses precede the actual one
+			continue
+		}
+
+		var req *http.Request
+		if len(h.requests) > 0 {
+			req = h.requests[0]
+			h.requests = h.requests[1:]
+		}
+
+		if (req != nil && req.Method == "HEAD") || res.StatusCode == 204 || res.StatusCode == 304 {
+			continue
+		}
+
+		chunked := len(res.TransferEncoding) > 0 && res.TransferEncoding[0] == "chunked"
+		if !chunked && res.ContentLength == 0 {
+			continue
+		}
+
+		h.request = req
+		h.response = res
+		h.responseTime = t
+		h.responseBody = newBodyReader(res.ContentLength, chunked, h.carver.maxSize)
+	}
+}
+
+// name returns the name of the carved file from the headers or the URL.
+func (h *httpCarveHandler) name() string {
+	if disp := h.response.Header.Get("Content-Disposition"); disp != "" {
+		if _, params, err := mime.ParseMediaType(disp); err == nil && params["filename"] != "" {
+			return path.Base(params["filename"])
+		}
+	}
+
+	if h.request != nil {
+		if name := path.Base(h.request.URL.Path); name != "/" && name != "." {
+			return name
+		}
+	}
+
+	return "index"
+}
+
+func (h *httpCarveHandler) save() {
+	body, res := h.responseBody, h.response
+	h.responseBody = nil
+
+	if body.overflow || len(body.data) == 0 {
+		return
+	}
+
+	data := body.data
+	if strings.Contains(res.Header.Get("Content-Encoding"), "gzip") {
+		if reader, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+			// the max size applies to the uncompressed body too, bigger ones
+			// are saved compressed
+			limited := io.LimitReader(reader, int64(h.carver.maxSize)+1)
+			if uncompressed, err := io.ReadAll(limited); err == nil && len(uncompressed) <= h.carver.maxSize {
+				data = uncompressed
+			}
+		}
+	}
+
+	ctype := res.Header.Get("Content-Type")
+	if ctype == "" {
+		ctype = http.DetectContentType(data)
+	}
+
+	h.carver.save(h.responseTime, "http", h.stream.ServerIP, h.stream.ClientIP, h.name(), ctype, data)
+}
+
+func (h *httpCarveHandler) OnClose() {
+	// bodies delimited by the end of the connection
+	if !h.dead && h.responseBody != nil && h.responseBody.remaining < 0 && h.responseBody.state == bodyPlain {
+		h.save()
+	}
+}
@@ -0,0 +1,131 @@
+package net_sniff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// a line ending with a synchronizing or non synchronizing literal, RFC 3501 / 7888
+var imapLiteralRe = regexp.MustCompile(`\{(\d+)\+?\}$`)
+
+// imapArguments tokenizes a command line made of atoms and quoted strings.
+func imapArguments(line string) []string {
+	args := make([]string, 0)
+	token := strings.Builder{}
+	quoted, escaped, inToken := false, false, false
+
+	for _, c := range line {
+		switch {
+		case escaped:
+			token.WriteRune(c)
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			inToken = true
+		case !quoted && c == ' ':
+			if inToken {
+				args = append(args, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteRune(c)
+			inToken = true
+		}
+	}
+
+	if inToken {
+		args = append(args, token.String())
+	}
+
+	return args
+}
+
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// imapUnliteral joins lines split by literals, returning false while the
+// command is still incomplete.
+func imapUnliteral(s *mailSession, line string) (string, bool) {
+	if s.literal > 0 {
+		if len(line) < s.literal {
+			s.pending, s.literal = "", 0
+			return "", false
+		}
+		line = s.pending + imapQuote(line[:s.literal]) + line[s.literal:]
+		s.pending, s.literal = "", 0
+	}
+
+	if m := imapLiteralRe.FindStringSubmatchIndex(line); m != nil {
+		if size, err := strconv.Atoi(line[m[2]:m[3]]); err == nil && size > 0 && size < mailMaxLine {
+			s.pending = line[:m[0]]
+			s.literal = size
+			return "", false
+		}
+	}
+
+	return line, true
+}
+
+var imap = &mailProtocol{
+	name:  "imap",
+	color: tui.BACKYELLOW + tui.FOREBLACK,
+	ports: map[layers.TCPPort]bool{
+		143: true,
+	},
+	onClient: func(s *mailSession, line string) *MailAuth {
+		if s.mechanism != "" {
+			return s.saslStep(line)
+		}
+
+		// only LOGIN arguments are worth reassembling when sent as literals
+		if s.pending != "" || strings.Contains(strings.ToUpper(line), " LOGIN ") {
+			complete := false
+			if line, complete = imapUnliteral(s, line); !complete {
+				return nil
+			}
+		}
+
+		// <tag> <command> [arguments]
+		args := imapArguments(line)
+		if len(args) < 2 {
+			return nil
+		}
+
+		switch strings.ToUpper(args[1]) {
+		case "LOGIN":
+			if len(args) == 4 {
+				return &MailAuth{Mechanism: "LOGIN", Username: args[2], Password: args[3]}
+			}
+		case "AUTHENTICATE":
+			if len(args) > 2 {
+				initial := ""
+				if len(args) > 3 {
+					initial = args[3]
+				}
+				return s.saslStart(args[2], initial)
+			}
+		}
+
+		return nil
+	},
+	onServer: func(s *mailSession, line string) {
+		if s.mechanism == "" {
+			return
+		} else if strings.HasPrefix(line, "+ ") {
+			s.saslChallenge(line[2:])
+		} else if !strings.HasPrefix(line, "* ") {
+			// tagged completion of the AUTHENTICATE command
+			s.reset()
+		}
+	},
+}
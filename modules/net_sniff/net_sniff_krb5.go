@@ -5,6 +5,7 @@ import (
 	"net"
 
 	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -37,6 +38,19 @@ func krb5Parser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *l
 			s,
 		).Push()
 
+		if hash, mode, err := req.HashcatString(); err == nil {
+			pushCredential(session.Credential{
+				Protocol:    "krb5",
+				Client:      srcIP.String(),
+				Host:        dstIP.String(),
+				User:        req.User() + "@" + req.ReqBody.Realm,
+				Type:        session.CredentialHash,
+				Secret:      hash,
+				HashcatMode: mode,
+				LastSeen:    pkt.Metadata().Timestamp,
+			})
+		}
+
 		return true
 	}
 
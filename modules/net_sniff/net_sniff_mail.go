@@ -0,0 +1,284 @@
+package net_sniff
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const mailMaxLine = 8192
+
+// MailAuth holds the credentials extracted from a SMTP, POP3 or IMAP session.
+type MailAuth struct {
+	Mechanism string `json:"mechanism"`
+	Username  string `json:"username"`
+	Password  string `json:"password,omitempty"`
+	Challenge string `json:"challenge,omitempty"`
+	Response  string `json:"response,omitempty"`
+}
+
+func (a MailAuth) String() string {
+	if a.Password != "" {
+		return fmt.Sprintf("%s %s %s %s %s", tui.Dim(a.Mechanism), tui.Bold("USER"), tui.Red(a.Username), tui.Bold("PASS"), tui.Red(a.Password))
+	}
+	return fmt.Sprintf("%s %s %s %s %s %s %s", tui.Dim(a.Mechanism), tui.Bold("USER"), tui.Red(a.Username),
+		tui.Bold("CHALLENGE"), tui.Yellow(a.Challenge), tui.Bold("RESPONSE"), tui.Red(a.Response))
+}
+
+// mailSession is the per connection state of a mail protocol exchange.
+type mailSession struct {
+	client    []byte
+	server    []byte
+	greeted   bool
+	mechanism string
+	step      int
+	username  string
+	challenge string
+	timestamp string
+	pending   string
+	literal   int
+}
+
+func (s *mailSession) reset() {
+	s.mechanism = ""
+	s.step = 0
+	s.challenge = ""
+}
+
+// readLines appends data to the buffer and returns all the complete lines,
+// partial ones are kept for the next segment.
+func readLines(buffer *[]byte, data []byte) []string {
+	*buffer = append(*buffer, data...)
+	lines := make([]string, 0)
+	for {
+		idx := bytes.IndexByte(*buffer, '\n')
+		if idx == -1 {
+			break
+		}
+		lines = append(lines, strings.TrimRight(string((*buffer)[:idx]), "\r"))
+		*buffer = (*buffer)[idx+1:]
+	}
+
+	if len(*buffer) > mailMaxLine {
+		*buffer = nil
+	}
+
+	return lines
+}
+
+func mailDecode(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if s == "=" {
+		return "", true
+	}
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", false
+	}
+	return string(raw), true
+}
+
+// saslStart begins a SASL exchange, initial is the optional initial response.
+func (s *mailSession) saslStart(mechanism string, initial string) *MailAuth {
+	s.mechanism = strings.ToUpper(mechanism)
+	s.step = 0
+	s.challenge = ""
+
+	switch s.mechanism {
+	case "PLAIN", "LOGIN", "CRAM-MD5", "XOAUTH2", "OAUTHBEARER":
+	default:
+		s.reset()
+		return nil
+	}
+
+	if initial != "" {
+		return s.saslStep(initial)
+	}
+	return nil
+}
+
+// saslChallenge is called for every server continuation line of the exchange.
+func (s *mailSession) saslChallenge(data string) {
+	if s.mechanism == "CRAM-MD5" {
+		if decoded, ok := mailDecode(data); ok {
+			s.challenge = decoded
+		}
+	}
+}
+
+// saslStep processes a client response, returning the credentials once complete.
+func (s *mailSession) saslStep(line string) *MailAuth {
+	if strings.TrimSpace(line) == "*" {
+		s.reset()
+		return nil
+	}
+
+	decoded, ok := mailDecode(line)
+	if !ok {
+		s.reset()
+		return nil
+	}
+
+	mechanism := s.mechanism
+	switch mechanism {
+	case "PLAIN":
+		s.reset()
+		// authzid \0 authcid \0 passwd
+		if parts := strings.Split(decoded, "\x00"); len(parts) == 3 {
+			return &MailAuth{Mechanism: mechanism, Username: parts[1], Password: parts[2]}
+		}
+
+	case "LOGIN":
+		if s.step == 0 {
+			s.username = decoded
+			s.step++
+		} else {
+			s.reset()
+			return &MailAuth{Mechanism: mechanism, Username: s.username, Password: decoded}
+		}
+
+	case "CRAM-MD5":
+		challenge := s.challenge
+		s.reset()
+		if parts := strings.SplitN(decoded, " ", 2); len(parts) == 2 {
+			return &MailAuth{Mechanism: mechanism, Username: parts[0], Challenge: challenge, Response: parts[1]}
+		}
+
+	case "XOAUTH2", "OAUTHBEARER":
+		s.reset()
+		auth := &MailAuth{Mechanism: mechanism}
+		for _, field := range strings.Split(decoded, "\x01") {
+			if strings.HasPrefix(field, "user=") {
+				auth.Username = field[5:]
+			} else if strings.HasPrefix(field, "auth=") {
+				auth.Password = strings.TrimPrefix(field[5:], "Bearer ")
+			} else if strings.HasPrefix(field, "n,a=") {
+				auth.Username = strings.TrimSuffix(field[4:], ",")
+			}
+		}
+		if auth.Password != "" {
+			return auth
+		}
+	}
+
+	return nil
+}
+
+// Credential converts the authentication to a credential for the session
+// vault, CRAM-MD5 responses are stored in hashcat format.
+func (a MailAuth) Credential() session.Credential {
+	cred := session.Credential{
+		User:   a.Username,
+		Type:   session.CredentialPassword,
+		Secret: a.Password,
+	}
+
+	switch a.Mechanism {
+	case "XOAUTH2", "OAUTHBEARER":
+		cred.Type = session.CredentialToken
+	case "CRAM-MD5":
+		cred.Type = session.CredentialHash
+		cred.Secret = fmt.Sprintf("$cram_md5$%s$%s",
+			base64.StdEncoding.EncodeToString([]byte(a.Challenge)),
+			base64.StdEncoding.EncodeToString([]byte(a.Username+" "+a.Response)))
+		cred.HashcatMode = 16400
+	}
+
+	return cred
+}
+
+// mailProtocol describes how a mail protocol parser handles each line.
+type mailProtocol struct {
+	name  string
+	color string
+	ports map[layers.TCPPort]bool
+	// onClient returns the credentials once an authentication is complete
+	onClient func(s *mailSession, line string) *MailAuth
+	onServer func(s *mailSession, line string)
+}
+
+// mailHandler follows a single mail protocol connection.
+type mailHandler struct {
+	proto   *mailProtocol
+	stream  *TCPStream
+	session mailSession
+}
+
+func (p *mailProtocol) parser(stream *TCPStream) StreamHandler {
+	if !p.ports[stream.ServerPort] {
+		return nil
+	}
+	stream.Claim()
+	return &mailHandler{
+		proto:  p,
+		stream: stream,
+	}
+}
+
+func (h *mailHandler) OnData(chunk StreamChunk) {
+	s := &h.session
+
+	if chunk.FromClient {
+		if chunk.Skipped > 0 {
+			s.client = nil
+		}
+		for _, line := range readLines(&s.client, chunk.Data) {
+			if auth := h.proto.onClient(s, line); auth != nil {
+				h.emit(chunk.Time, auth)
+			}
+		}
+	} else {
+		if chunk.Skipped > 0 {
+			s.server = nil
+		}
+		for _, line := range readLines(&s.server, chunk.Data) {
+			h.proto.onServer(s, line)
+			s.greeted = true
+		}
+	}
+}
+
+func (h *mailHandler) OnClose() {
+	h.session = mailSession{}
+}
+
+func (h *mailHandler) emit(t time.Time, auth *MailAuth) {
+	srcIP, dstIP := h.stream.ClientIP, h.stream.ServerIP
+	NewSnifferEvent(
+		t,
+		h.proto.name,
+		srcIP.String(),
+		dstIP.String(),
+		*auth,
+		"%s %s > %s:%s - %s",
+		tui.Wrap(h.proto.color, h.proto.name),
+		vIP(srcIP),
+		vIP(dstIP),
+		vPort(h.stream.ServerPort),
+		auth.String(),
+	).Push()
+
+	cred := auth.Credential()
+	cred.Protocol = h.proto.name
+	cred.Client = srcIP.String()
+	cred.Host = dstIP.String()
+	cred.LastSeen = t
+	pushCredential(cred)
+}
+
+// mailCommand splits a client line in its upper cased verb and arguments.
+func mailCommand(line string) (string, []string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return strings.ToUpper(fields[0]), fields[1:]
+}
@@ -0,0 +1,225 @@
+package net_sniff
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/session"
+)
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// mailConversation builds the frames of a client/server exchange, lines
+// starting with "C:" are sent by the client and "S:" by the server.
+func mailConversation(t *testing.T, port int, clientPort int, lines ...string) [][]byte {
+	frames := make([][]byte, 0)
+	cseq, sseq := uint32(1), uint32(1)
+	for _, line := range lines {
+		data := []byte(line[2:])
+		if line[:2] == "C:" {
+			frames = append(frames, testTCPPacket(t, true, clientPort, port, cseq, data))
+			cseq += uint32(len(data))
+		} else {
+			frames = append(frames, testTCPPacket(t, false, port, clientPort, sseq, data))
+			sseq += uint32(len(data))
+		}
+	}
+	return frames
+}
+
+func expectMailAuth(t *testing.T, events []SnifferEvent, proto string, expected MailAuth) {
+	t.Helper()
+
+	found := eventsOf(events, proto)
+	if len(found) != 1 {
+		t.Fatalf("expected 1 %s event, got %d: %+v", proto, len(found), events)
+	}
+
+	auth, ok := found[0].Data.(MailAuth)
+	if !ok {
+		t.Fatalf("unexpected data type %T", found[0].Data)
+	} else if auth != expected {
+		t.Errorf("expected %+v, got %+v", expected, auth)
+	}
+}
+
+func TestSMTPAuthPlain(t *testing.T) {
+	events := replayFrames(t, mailConversation(t, 587, 41000,
+		"S:220 mail.example.com ESMTP\r\n",
+		"C:EHLO client\r\n",
+		"S:250-mail.example.com\r\n250 AUTH PLAIN LOGIN\r\n",
+		"C:AUTH PLAIN "+b64("\x00alice\x00wonderland")+"\r\n",
+		"S:235 2.7.0 Authentication successful\r\n",
+	)...)
+
+	expectMailAuth(t, events, "smtp", MailAuth{Mechanism: "PLAIN", Username: "alice", Password: "wonderland"})
+}
+
+func TestSMTPAuthLoginSplitSegments(t *testing.T) {
+	user := b64("bob@example.com")
+	events := replayFrames(t, mailConversation(t, 25, 41001,
+		"S:220 mail.example.com ESMTP\r\n",
+		"C:AUTH LOGIN\r\n",
+		"S:334 VXNlcm5hbWU6\r\n",
+		"C:"+user[:5],
+		"C:"+user[5:]+"\r\n",
+		"S:334 UGFzc3dvcmQ6\r\n",
+		"C:"+b64("hunter2")+"\r\n",
+		"S:235 ok\r\n",
+	)...)
+
+	expectMailAuth(t, events, "smtp", MailAuth{Mechanism: "LOGIN", Username: "bob@example.com", Password: "hunter2"})
+}
+
+func TestSMTPAuthCramMD5(t *testing.T) {
+	challenge := "<1896.697170952@postoffice.example.net>"
+	events := replayFrames(t, mailConversation(t, 25, 41002,
+		"C:AUTH CRAM-MD5\r\n",
+		"S:334 "+b64(challenge)+"\r\n",
+		"C:"+b64("tim b913a602c7eda7a495b4e6e7334d3890")+"\r\n",
+	)...)
+
+	expectMailAuth(t, events, "smtp", MailAuth{
+		Mechanism: "CRAM-MD5",
+		Username:  "tim",
+		Challenge: challenge,
+		Response:  "b913a602c7eda7a495b4e6e7334d3890",
+	})
+}
+
+func TestSMTPAuthCancelled(t *testing.T) {
+	events := replayFrames(t, mailConversation(t, 25, 41003,
+		"C:AUTH LOGIN\r\n",
+		"S:334 VXNlcm5hbWU6\r\n",
+		"C:*\r\n",
+		"S:501 cancelled\r\n",
+		"C:MAIL FROM:<a@b.c>\r\n",
+	)...)
+
+	if found := eventsOf(events, "smtp"); len(found) != 0 {
+		t.Errorf("expected no smtp events, got %+v", found)
+	}
+}
+
+func TestPOP3UserPass(t *testing.T) {
+	events := replayFrames(t, mailConversation(t, 110, 41004,
+		"S:+OK POP3 server ready\r\n",
+		"C:USER carol\r\n",
+		"S:+OK\r\n",
+		"C:PASS letmein\r\n",
+		"S:+OK logged in\r\n",
+	)...)
+
+	expectMailAuth(t, events, "pop3", MailAuth{Mechanism: "USER", Username: "carol", Password: "letmein"})
+	if found := eventsOf(events, "ftp"); len(found) != 0 {
+		t.Errorf("pop3 commands reported as ftp: %+v", found)
+	}
+}
+
+func TestPOP3APOP(t *testing.T) {
+	events := replayFrames(t, mailConversation(t, 110, 41005,
+		"S:+OK POP3 server ready <1896.697170952@dbc.mtview.ca.us>\r\n",
+		"C:APOP mrose c4c9334bac560ecc979e58001b3e22fb\r\n",
+	)...)
+
+	expectMailAuth(t, events, "pop3", MailAuth{
+		Mechanism: "APOP",
+		Username:  "mrose",
+		Challenge: "<1896.697170952@dbc.mtview.ca.us>",
+		Response:  "c4c9334bac560ecc979e58001b3e22fb",
+	})
+}
+
+func TestPOP3AuthPlain(t *testing.T) {
+	events := replayFrames(t, mailConversation(t, 110, 41006,
+		"S:+OK ready\r\n",
+		"C:AUTH PLAIN\r\n",
+		"S:+ \r\n",
+		"C:"+b64("\x00dave\x00pa55")+"\r\n",
+	)...)
+
+	expectMailAuth(t, events, "pop3", MailAuth{Mechanism: "PLAIN", Username: "dave", Password: "pa55"})
+}
+
+func TestIMAPLogin(t *testing.T) {
+	events := replayFrames(t, mailConversation(t, 143, 41007,
+		"S:* OK IMAP4rev1 ready\r\n",
+		"C:a001 LOGIN \"eve@example.com\" \"p\\\"ss word\"\r\n",
+		"S:a001 OK LOGIN completed\r\n",
+	)...)
+
+	expectMailAuth(t, events, "imap", MailAuth{Mechanism: "LOGIN", Username: "eve@example.com", Password: "p\"ss word"})
+}
+
+func TestIMAPLoginLiteral(t *testing.T) {
+	events := replayFrames(t, mailConversation(t, 143, 41008,
+		"C:a002 LOGIN {5}\r\n",
+		"S:+ Ready for literal\r\n",
+		"C:frank {6}\r\n",
+		"S:+ Ready for literal\r\n",
+		"C:s3cret\r\n",
+	)...)
+
+	expectMailAuth(t, events, "imap", MailAuth{Mechanism: "LOGIN", Username: "frank", Password: "s3cret"})
+}
+
+func TestIMAPAuthenticate(t *testing.T) {
+	events := replayFrames(t, mailConversation(t, 143, 41009,
+		"C:a003 AUTHENTICATE PLAIN\r\n",
+		"S:+ \r\n",
+		"C:"+b64("\x00grace\x00hopper")+"\r\n",
+		"S:a003 OK done\r\n",
+		"C:a004 AUTHENTICATE LOGIN "+b64("heidi")+"\r\n",
+		"S:+ UGFzc3dvcmQ6\r\n",
+		"C:"+b64("klum")+"\r\n",
+	)...)
+
+	found := eventsOf(events, "imap")
+	if len(found) != 2 {
+		t.Fatalf("expected 2 imap events, got %+v", events)
+	}
+	if auth := found[0].Data.(MailAuth); auth.Username != "grace" || auth.Password != "hopper" {
+		t.Errorf("unexpected credentials %+v", auth)
+	}
+	if auth := found[1].Data.(MailAuth); auth.Username != "heidi" || auth.Password != "klum" {
+		t.Errorf("unexpected credentials %+v", auth)
+	}
+}
+
+func TestIMAPArguments(t *testing.T) {
+	args := imapArguments(`a1 LOGIN "quoted \"user\"" atom ""`)
+	expected := []string{"a1", "LOGIN", `quoted "user"`, "atom", ""}
+	if len(args) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, args)
+	}
+	for i := range args {
+		if args[i] != expected[i] {
+			t.Errorf("argument %d: expected '%s', got '%s'", i, expected[i], args[i])
+		}
+	}
+}
+
+func TestMailAuthCredential(t *testing.T) {
+	plain := MailAuth{Mechanism: "PLAIN", Username: "alice", Password: "secret"}.Credential()
+	if plain.Type != session.CredentialPassword || plain.User != "alice" || plain.Secret != "secret" {
+		t.Errorf("unexpected credential %+v", plain)
+	}
+
+	token := MailAuth{Mechanism: "XOAUTH2", Username: "alice", Password: "ya29.token"}.Credential()
+	if token.Type != session.CredentialToken {
+		t.Errorf("unexpected credential %+v", token)
+	}
+
+	cram := MailAuth{
+		Mechanism: "CRAM-MD5",
+		Username:  "tim",
+		Challenge: "<1896.697170952@postoffice.reston.mci.net>",
+		Response:  "b913a602c7eda7a495b4e6e7334d3890",
+	}.Credential()
+	exp := "$cram_md5$PDE4OTYuNjk3MTcwOTUyQHBvc3RvZmZpY2UucmVzdG9uLm1jaS5uZXQ+$dGltIGI5MTNhNjAyYzdlZGE3YTQ5NWI0ZTZlNzMzNGQzODkw"
+	if cram.Type != session.CredentialHash || cram.HashcatMode != 16400 || cram.Secret != exp {
+		t.Errorf("unexpected credential %+v", cram)
+	}
+}
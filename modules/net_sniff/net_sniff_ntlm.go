@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -60,6 +61,22 @@ func ntlmParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *l
 						vIP(dstIP),
 						data.LcString(),
 					).Push()
+
+					mode := 5600
+					if data.Type == packets.NtlmV1 {
+						mode = 5500
+					}
+
+					pushCredential(session.Credential{
+						Protocol:    "ntlm",
+						Client:      srcIP.String(),
+						Host:        dstIP.String(),
+						User:        data.Domain + "\\" + data.User,
+						Type:        session.CredentialHash,
+						Secret:      data.HashcatString(),
+						HashcatMode: mode,
+						LastSeen:    pkt.Metadata().Timestamp,
+					})
 				})
 			}
 		}
@@ -33,7 +33,13 @@ func onUNK(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose boo
 	}
 }
 
-func mainParser(pkt gopacket.Packet, verbose bool) bool {
+// ParsePacket passes a packet decoded outside of the sniffer, such as the
+// decrypted payload of a 802.11 data frame, through the sniffer parsers.
+func ParsePacket(pkt gopacket.Packet, verbose bool, streams *StreamAssembler) bool {
+	return mainParser(pkt, verbose, streams)
+}
+
+func mainParser(pkt gopacket.Packet, verbose bool, streams *StreamAssembler) bool {
 	defer func() {
 		if err := recover(); err != nil {
 			log.Warning("error while parsing packet: %v", err)
@@ -75,9 +81,9 @@ func mainParser(pkt gopacket.Packet, verbose bool) bool {
 		}
 
 		if tlayer.LayerType() == layers.LayerTypeTCP {
-			onTCP(srcIP, dstIP, basePayload, pkt, verbose)
+			onTCP(srcIP, dstIP, basePayload, pkt, verbose, streams)
 		} else if tlayer.LayerType() == layers.LayerTypeUDP {
-			onUDP(srcIP, dstIP, basePayload, pkt, verbose)
+			onUDP(srcIP, dstIP, basePayload, pkt, verbose, streams)
 		} else {
 			onUNK(srcIP, dstIP, basePayload, pkt, verbose)
 		}
@@ -0,0 +1,64 @@
+package net_sniff
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// the APOP timestamp in the server greeting, RFC 1939
+var apopTimestampRe = regexp.MustCompile(`<[^<>]+@[^<>]+>`)
+
+var pop3 = &mailProtocol{
+	name:  "pop3",
+	color: tui.BACKYELLOW + tui.FOREBLACK,
+	ports: map[layers.TCPPort]bool{
+		110: true,
+	},
+	onClient: func(s *mailSession, line string) *MailAuth {
+		if s.mechanism != "" {
+			return s.saslStep(line)
+		}
+
+		verb, args := mailCommand(line)
+		switch verb {
+		case "USER":
+			if len(args) > 0 {
+				s.username = strings.TrimSpace(line[5:])
+				return nil
+			}
+		case "PASS":
+			if s.username != "" && len(line) > 5 {
+				return &MailAuth{Mechanism: "USER", Username: s.username, Password: line[5:]}
+			}
+		case "APOP":
+			if len(args) == 2 {
+				return &MailAuth{Mechanism: "APOP", Username: args[0], Challenge: s.timestamp, Response: args[1]}
+			}
+		case "AUTH":
+			if len(args) > 0 {
+				initial := ""
+				if len(args) > 1 {
+					initial = args[1]
+				}
+				return s.saslStart(args[0], initial)
+			}
+		}
+
+		return nil
+	},
+	onServer: func(s *mailSession, line string) {
+		if !s.greeted {
+			if strings.HasPrefix(line, "+OK") {
+				s.timestamp = apopTimestampRe.FindString(line)
+			}
+		} else if s.mechanism != "" && strings.HasPrefix(line, "+ ") {
+			s.saslChallenge(line[2:])
+		} else if s.mechanism != "" && strings.HasPrefix(line, "-ERR") {
+			s.reset()
+		}
+	},
+}
@@ -0,0 +1,446 @@
+package net_sniff
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	quicVersion1 = 0x00000001
+	quicVersion2 = 0x6b3343cf
+	// RFC 9000 section 14.1
+	quicMinInitialSize = 1200
+	// ClientHellos spread over more Initials than this are ignored
+	quicMaxCrypto = 16 * 1024
+	// incomplete handshakes are forgotten after this long
+	quicInitialTimeout = 30 * time.Second
+	quicMaxTracked     = 1024
+)
+
+var (
+	quicSaltV1 = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
+	quicSaltV2 = []byte{0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93, 0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9}
+
+	errQUICNotInitial = errors.New("not a QUIC Initial packet")
+)
+
+// QUICInitial is the information extracted from the Initial packets of a QUIC connection.
+type QUICInitial struct {
+	Version string `json:"version"`
+	DCID    string `json:"dcid"`
+	TLSFingerprint
+}
+
+// quicKeys are the keys protecting the Initial packets sent by one peer.
+type quicKeys struct {
+	aead cipher.AEAD
+	iv   []byte
+	hp   cipher.Block
+}
+
+// hkdfExpandLabel implements HKDF-Expand-Label of RFC 8446 with an empty context.
+func hkdfExpandLabel(secret []byte, label string, length int) ([]byte, error) {
+	label = "tls13 " + label
+	info := make([]byte, 0, 4+len(label))
+	info = binary.BigEndian.AppendUint16(info, uint16(length))
+	info = append(info, byte(len(label)))
+	info = append(info, label...)
+	info = append(info, 0)
+	return hkdf.Expand(sha256.New, secret, string(info), length)
+}
+
+// quicClientKeys derives the keys of the client Initial packets from the
+// destination connection id chosen by the client.
+func quicClientKeys(version uint32, dcid []byte) (*quicKeys, error) {
+	salt, prefix := quicSaltV1, "quic "
+	if version == quicVersion2 {
+		salt, prefix = quicSaltV2, "quicv2 "
+	}
+
+	initial, err := hkdf.Extract(sha256.New, dcid, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := hkdfExpandLabel(initial, "client in", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &quicKeys{}
+	key, err := hkdfExpandLabel(secret, prefix+"key", 16)
+	if err != nil {
+		return nil, err
+	} else if keys.iv, err = hkdfExpandLabel(secret, prefix+"iv", 12); err != nil {
+		return nil, err
+	}
+
+	hp, err := hkdfExpandLabel(secret, prefix+"hp", 16)
+	if err != nil {
+		return nil, err
+	} else if keys.hp, err = aes.NewCipher(hp); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	} else if keys.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// quicVarint reads a variable length integer, returning its value and size.
+func quicVarint(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	size := 1 << (data[0] >> 6)
+	if len(data) < size {
+		return 0, 0
+	}
+	value := uint64(data[0] & 0x3f)
+	for _, b := range data[1:size] {
+		value = value<<8 | uint64(b)
+	}
+	return value, size
+}
+
+// quicPacket is a decrypted Initial packet.
+type quicPacket struct {
+	version uint32
+	dcid    []byte
+	payload []byte
+}
+
+// parseQUICInitial decrypts the first packet of a datagram if it's a
+// client Initial of a supported version.
+func parseQUICInitial(data []byte) (*quicPacket, error) {
+	// long header with fixed bit
+	if len(data) < 7 || data[0]&0xc0 != 0xc0 {
+		return nil, errQUICNotInitial
+	}
+
+	version := binary.BigEndian.Uint32(data[1:])
+	kind := (data[0] >> 4) & 0x03
+	if !(version == quicVersion1 && kind == 0) && !(version == quicVersion2 && kind == 1) {
+		return nil, errQUICNotInitial
+	}
+
+	offset := 5
+	dcidLen := int(data[offset])
+	offset++
+	if dcidLen > 20 || offset+dcidLen >= len(data) {
+		return nil, errQUICNotInitial
+	}
+	dcid := data[offset : offset+dcidLen]
+	offset += dcidLen
+
+	scidLen := int(data[offset])
+	offset += 1 + scidLen
+	if scidLen > 20 || offset >= len(data) {
+		return nil, errQUICNotInitial
+	}
+
+	tokenLen, n := quicVarint(data[offset:])
+	if n == 0 || uint64(len(data)-offset-n) < tokenLen {
+		return nil, errQUICNotInitial
+	}
+	offset += n + int(tokenLen)
+
+	length, n := quicVarint(data[offset:])
+	if n == 0 || uint64(len(data)-offset-n) < length {
+		return nil, errQUICNotInitial
+	}
+	offset += n
+	pnOffset := offset
+	end := pnOffset + int(length)
+
+	// header protection sample starts 4 bytes after the packet number
+	if pnOffset+4+aes.BlockSize > end {
+		return nil, errQUICNotInitial
+	}
+
+	keys, err := quicClientKeys(version, dcid)
+	if err != nil {
+		return nil, err
+	}
+
+	mask := make([]byte, aes.BlockSize)
+	keys.hp.Encrypt(mask, data[pnOffset+4:pnOffset+4+aes.BlockSize])
+
+	header := make([]byte, pnOffset+4)
+	copy(header, data[:pnOffset+4])
+	header[0] ^= mask[0] & 0x0f
+	pnLen := int(header[0]&0x03) + 1
+	header = header[:pnOffset+pnLen]
+
+	pn := uint64(0)
+	for i := 0; i < pnLen; i++ {
+		header[pnOffset+i] ^= mask[1+i]
+		pn = pn<<8 | uint64(header[pnOffset+i])
+	}
+
+	nonce := make([]byte, len(keys.iv))
+	copy(nonce, keys.iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * i))
+	}
+
+	payload, err := keys.aead.Open(nil, nonce, data[pnOffset+pnLen:end], header)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt QUIC Initial: %v", err)
+	}
+
+	return &quicPacket{
+		version: version,
+		dcid:    dcid,
+		payload: payload,
+	}, nil
+}
+
+// quicRead consumes a variable length integer from data.
+func quicRead(data *[]byte) (uint64, bool) {
+	value, n := quicVarint(*data)
+	*data = (*data)[n:]
+	return value, n > 0
+}
+
+// quicCrypto returns the CRYPTO frames of a decrypted payload by offset.
+func quicCrypto(payload []byte) (map[uint64][]byte, error) {
+	frames := make(map[uint64][]byte)
+	for len(payload) > 0 {
+		kind, ok := quicRead(&payload)
+		if !ok {
+			return nil, errQUICNotInitial
+		}
+
+		switch kind {
+		case 0x00, 0x01:
+			// PADDING, PING
+		case 0x02, 0x03:
+			// ACK: largest, delay, range count and first range followed by
+			// two values per range and, for 0x03, three ECN counts
+			values := make([]uint64, 4)
+			for i := range values {
+				if values[i], ok = quicRead(&payload); !ok {
+					return nil, errQUICNotInitial
+				}
+			}
+			skip := values[2] * 2
+			if kind == 0x03 {
+				skip += 3
+			}
+			for ; skip > 0; skip-- {
+				if _, ok = quicRead(&payload); !ok {
+					return nil, errQUICNotInitial
+				}
+			}
+		case 0x06:
+			offset, ok := quicRead(&payload)
+			if !ok {
+				return nil, errQUICNotInitial
+			}
+			length, ok := quicRead(&payload)
+			if !ok || uint64(len(payload)) < length {
+				return nil, errQUICNotInitial
+			}
+			frames[offset] = payload[:length]
+			payload = payload[length:]
+		default:
+			// CONNECTION_CLOSE or anything else, stop here
+			return frames, nil
+		}
+	}
+	return frames, nil
+}
+
+// quicHandshake collects the CRYPTO frames sent by a client.
+type quicHandshake struct {
+	seen   time.Time
+	frames map[uint64][]byte
+	size   int
+	done   bool
+}
+
+// message returns the ClientHello once all of its fragments are available.
+func (h *quicHandshake) message() []byte {
+	offsets := make([]uint64, 0, len(h.frames))
+	for offset := range h.frames {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	data := make([]byte, 0)
+	for _, offset := range offsets {
+		if offset > uint64(len(data)) {
+			break
+		}
+		frame := h.frames[offset]
+		if end := offset + uint64(len(frame)); end > uint64(len(data)) {
+			data = append(data, frame[uint64(len(data))-offset:]...)
+		}
+	}
+
+	if len(data) >= 4 {
+		size := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		if len(data) >= 4+size {
+			return data[:4+size]
+		}
+	}
+	return nil
+}
+
+// quicTracker keeps the state of the QUIC handshakes being observed.
+type quicTracker struct {
+	sync.Mutex
+	handshakes map[string]*quicHandshake
+}
+
+func newQUICTracker() *quicTracker {
+	return &quicTracker{
+		handshakes: make(map[string]*quicHandshake),
+	}
+}
+
+// feed adds the frames to the handshake with the given key and returns the
+// ClientHello if it's now complete.
+func (t *quicTracker) feed(key string, now time.Time, frames map[uint64][]byte) []byte {
+	t.Lock()
+	defer t.Unlock()
+
+	h, found := t.handshakes[key]
+	if !found {
+		for k, other := range t.handshakes {
+			if now.Sub(other.seen) > quicInitialTimeout {
+				delete(t.handshakes, k)
+			}
+		}
+		if len(t.handshakes) >= quicMaxTracked {
+			t.handshakes = make(map[string]*quicHandshake)
+		}
+		h = &quicHandshake{frames: make(map[uint64][]byte)}
+		t.handshakes[key] = h
+	}
+
+	h.seen = now
+	if h.done {
+		return nil
+	}
+
+	for offset, frame := range frames {
+		if _, found := h.frames[offset]; !found && h.size+len(frame) <= quicMaxCrypto {
+			h.frames[offset] = frame
+			h.size += len(frame)
+		}
+	}
+
+	if message := h.message(); message != nil {
+		h.done = true
+		h.frames = nil
+		return message
+	}
+	return nil
+}
+
+func (t *quicTracker) parser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
+	data := udp.Payload
+	if len(data) < quicMinInitialSize {
+		return false
+	}
+
+	initial, err := parseQUICInitial(data)
+	if err != nil {
+		return false
+	}
+
+	frames, err := quicCrypto(initial.payload)
+	if err != nil || len(frames) == 0 {
+		return false
+	}
+
+	key := fmt.Sprintf("%s:%d/%x", srcIP, udp.SrcPort, initial.dcid)
+	message := t.feed(key, pkt.Metadata().Timestamp, frames)
+	if message == nil || message[0] != tlsClientHello {
+		// either waiting for more fragments or already reported
+		return true
+	}
+
+	hello, err := ParseClientHello(message[4:])
+	if err != nil {
+		return false
+	}
+
+	version := "v1"
+	if initial.version == quicVersion2 {
+		version = "v2"
+	}
+
+	ja3 := hello.JA3()
+	info := QUICInitial{
+		Version: version,
+		DCID:    hex.EncodeToString(initial.dcid),
+		TLSFingerprint: TLSFingerprint{
+			ServerName: hello.ServerName,
+			ALPN:       hello.ALPN,
+			JA3:        ja3,
+			JA3Hash:    md5Hex(ja3),
+			JA4:        hello.JA4('q'),
+		},
+	}
+
+	if endpoint := session.I.Lan.GetByIp(srcIP.String()); endpoint != nil {
+		endpoint.OnMeta(map[string]string{
+			"tls:ja3": info.JA3Hash,
+			"tls:ja4": info.JA4,
+		})
+	}
+
+	domain := hello.ServerName
+	if domain == "" {
+		domain = dstIP.String()
+	}
+	if udp.DstPort != 443 {
+		domain = fmt.Sprintf("%s:%d", domain, udp.DstPort)
+	}
+
+	alpn := ""
+	if len(hello.ALPN) > 0 {
+		alpn = hello.ALPN[0]
+	}
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"quic",
+		srcIP.String(),
+		domain,
+		info,
+		"%s %s > %s %s %s",
+		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, "quic"),
+		vIP(srcIP),
+		tui.Yellow("https://"+domain),
+		tui.Dim(alpn),
+		tui.Dim(info.JA4),
+	).Push()
+
+	return true
+}
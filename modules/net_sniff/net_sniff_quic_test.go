@@ -0,0 +1,157 @@
+package net_sniff
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// quicVarint2 encodes a value as a 2 bytes QUIC variable length integer.
+func quicVarint2(v int) []byte {
+	return []byte{0x40 | byte(v>>8), byte(v)}
+}
+
+// quicCryptoFrame builds a CRYPTO frame.
+func quicCryptoFrame(offset int, data []byte) []byte {
+	frame := []byte{0x06}
+	frame = append(frame, quicVarint2(offset)...)
+	frame = append(frame, quicVarint2(len(data))...)
+	return append(frame, data...)
+}
+
+// quicProtect builds a client Initial packet carrying the given frames.
+func quicProtect(t *testing.T, version uint32, dcid []byte, pn uint16, frames []byte) []byte {
+	t.Helper()
+
+	keys, err := quicClientKeys(version, dcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pad to the minimum Initial size
+	if missing := quicMinInitialSize - len(frames); missing > 0 {
+		frames = append(frames, make([]byte, missing)...)
+	}
+
+	kind := byte(0)
+	if version == quicVersion2 {
+		kind = 1
+	}
+
+	header := []byte{0xc0 | kind<<4 | 0x01}
+	header = binary.BigEndian.AppendUint32(header, version)
+	header = append(header, byte(len(dcid)))
+	header = append(header, dcid...)
+	// empty source connection id and token
+	header = append(header, 0, 0)
+	header = append(header, quicVarint2(2+len(frames)+16)...)
+	pnOffset := len(header)
+	header = binary.BigEndian.AppendUint16(header, pn)
+
+	nonce := make([]byte, len(keys.iv))
+	copy(nonce, keys.iv)
+	nonce[len(nonce)-2] ^= byte(pn >> 8)
+	nonce[len(nonce)-1] ^= byte(pn)
+
+	packet := keys.aead.Seal(append([]byte{}, header...), nonce, frames, header)
+
+	mask := make([]byte, aes.BlockSize)
+	keys.hp.Encrypt(mask, packet[pnOffset+4:pnOffset+4+aes.BlockSize])
+	packet[0] ^= mask[0] & 0x0f
+	packet[pnOffset] ^= mask[1]
+	packet[pnOffset+1] ^= mask[2]
+
+	return packet
+}
+
+func TestQUICInitialKeys(t *testing.T) {
+	// RFC 9001 appendix A.1
+	keys, err := quicClientKeys(quicVersion1, unhex(t, "8394c8f03e515708"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(keys.iv, unhex(t, "fa044b2f42a3fd3b46fb255c")) {
+		t.Errorf("unexpected iv %x", keys.iv)
+	}
+
+	hp, _ := aes.NewCipher(unhex(t, "9f50449e04a0e810283a1e9933adedd2"))
+	expected, got := make([]byte, 16), make([]byte, 16)
+	hp.Encrypt(expected, make([]byte, 16))
+	keys.hp.Encrypt(got, make([]byte, 16))
+	if !bytes.Equal(expected, got) {
+		t.Error("unexpected header protection key")
+	}
+
+	block, _ := aes.NewCipher(unhex(t, "1f369613dd76d5467730efcbe3b1a22d"))
+	aead, _ := cipher.NewGCM(block)
+	nonce := make([]byte, 12)
+	if !bytes.Equal(aead.Seal(nil, nonce, []byte("test"), nil), keys.aead.Seal(nil, nonce, []byte("test"), nil)) {
+		t.Error("unexpected packet protection key")
+	}
+}
+
+func TestQUICInitialDecrypt(t *testing.T) {
+	for _, version := range []uint32{quicVersion1, quicVersion2} {
+		frames := append([]byte{0x01}, quicCryptoFrame(0, []byte("hello"))...)
+		packet := quicProtect(t, version, []byte{1, 2, 3, 4, 5, 6, 7, 8}, 0x1234, frames)
+
+		initial, err := parseQUICInitial(packet)
+		if err != nil {
+			t.Fatalf("version %x: %v", version, err)
+		}
+
+		crypto, err := quicCrypto(initial.payload)
+		if err != nil {
+			t.Fatal(err)
+		} else if string(crypto[0]) != "hello" {
+			t.Errorf("version %x: unexpected crypto frames %v", version, crypto)
+		}
+	}
+
+	if _, err := parseQUICInitial([]byte{0x40, 0x00, 0x01}); err != errQUICNotInitial {
+		t.Errorf("expected short header to be ignored, got %v", err)
+	}
+}
+
+func TestReplayQUIC(t *testing.T) {
+	// ClientHello without the record layer, split in two datagrams received out of order
+	hello := testClientHello()[5:]
+	dcid := []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x11, 0x22, 0x33}
+	half := len(hello) / 2
+
+	events := replayFrames(t,
+		testUDPPacket(t, true, 50000, 443, quicProtect(t, quicVersion1, dcid, 1, quicCryptoFrame(half, hello[half:]))),
+		testUDPPacket(t, true, 50000, 443, quicProtect(t, quicVersion1, dcid, 0, quicCryptoFrame(0, hello[:half]))),
+		// retransmission
+		testUDPPacket(t, true, 50000, 443, quicProtect(t, quicVersion1, dcid, 2, quicCryptoFrame(0, hello))))
+
+	found := eventsOf(events, "quic")
+	if len(found) != 1 {
+		t.Fatalf("expected 1 quic event, got %+v", events)
+	}
+
+	info, ok := found[0].Data.(QUICInitial)
+	if !ok {
+		t.Fatalf("unexpected data type %T", found[0].Data)
+	}
+	if found[0].Destination != "example.com" || info.ServerName != "example.com" || info.Version != "v1" {
+		t.Errorf("unexpected event %+v", found[0])
+	}
+	if len(info.ALPN) != 2 || info.ALPN[0] != "h2" || info.JA4[0] != 'q' || info.DCID != "deadbeef00112233" {
+		t.Errorf("unexpected fingerprint %+v", info)
+	}
+}
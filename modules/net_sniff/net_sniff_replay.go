@@ -0,0 +1,114 @@
+package net_sniff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+var (
+	// when set, parsed events are sent here instead of the session
+	sink     func(SnifferEvent)
+	sinkLock = sync.RWMutex{}
+	// serializes CollectEvents calls
+	collectLock = sync.Mutex{}
+
+	pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+)
+
+type replaySource interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+// openReplaySource opens a pcap or pcapng file without relying on libpcap.
+func openReplaySource(filename string) (*os.File, replaySource, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(fp)
+	magic, err := reader.Peek(4)
+	if err != nil {
+		fp.Close()
+		return nil, nil, fmt.Errorf("could not read %s: %v", filename, err)
+	}
+
+	var source replaySource
+	if bytes.Equal(magic, pcapngMagic) {
+		source, err = pcapgo.NewNgReader(reader, pcapgo.DefaultNgReaderOptions)
+	} else {
+		source, err = pcapgo.NewReader(reader)
+	}
+
+	if err != nil {
+		fp.Close()
+		return nil, nil, fmt.Errorf("could not parse %s: %v", filename, err)
+	}
+
+	return fp, source, nil
+}
+
+// ReplayFile feeds every packet of a pcap or pcapng file through the
+// sniffer parsers, the callback (if any) is invoked for each packet along
+// with the result of the parsing. If streams is nil a reassembler with the
+// default settings is used. It returns the number of packets read.
+func ReplayFile(filename string, verbose bool, streams *StreamAssembler, cb func(pkt gopacket.Packet, parsed bool)) (int, error) {
+	fp, source, err := openReplaySource(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer fp.Close()
+
+	num := 0
+	if streams == nil {
+		streams = NewStreamAssembler(DefaultStreamTimeout*time.Second, DefaultStreamBuffer)
+	}
+	for packet := range gopacket.NewPacketSource(source, source.LinkType()).Packets() {
+		parsed := mainParser(packet, verbose, streams)
+		if cb != nil {
+			cb(packet, parsed)
+		}
+		num++
+	}
+	// flush whatever is left in the reassembler
+	streams.Close()
+
+	return num, nil
+}
+
+// CollectEvents replays a capture file and returns the events emitted by
+// the parsers instead of pushing them to the session, this is meant to
+// regression test parsers against known captures.
+func CollectEvents(filename string, verbose bool, streams *StreamAssembler) ([]SnifferEvent, error) {
+	collectLock.Lock()
+	defer collectLock.Unlock()
+
+	events := make([]SnifferEvent, 0)
+
+	sinkLock.Lock()
+	sink = func(e SnifferEvent) {
+		events = append(events, e)
+	}
+	sinkLock.Unlock()
+
+	defer func() {
+		sinkLock.Lock()
+		sink = nil
+		sinkLock.Unlock()
+	}()
+
+	if _, err := ReplayFile(filename, verbose, streams, nil); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
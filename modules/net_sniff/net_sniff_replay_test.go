@@ -0,0 +1,269 @@
+package net_sniff
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/evilsocket/islazy/data"
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+var (
+	testClientMAC = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	testServerMAC = net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+	testClientIP  = net.IP{10, 0, 0, 2}
+	testServerIP  = net.IP{10, 0, 0, 3}
+)
+
+func setupTestSession() {
+	if session.I != nil {
+		return
+	}
+
+	iface := &network.Endpoint{IpAddress: "10.0.0.100", HwAddress: "aa:bb:cc:dd:ee:ff"}
+	iface.SetIP("10.0.0.100")
+	gateway := &network.Endpoint{IpAddress: "10.0.0.1", HwAddress: "11:22:33:44:55:66"}
+	gateway.SetIP("10.0.0.1")
+
+	aliases, _ := data.NewUnsortedKV("", 0)
+
+	session.I = &session.Session{
+		Interface: iface,
+		Gateway:   gateway,
+		Lan:       network.NewLAN(iface, gateway, aliases, func(e *network.Endpoint) {}, func(e *network.Endpoint) {}),
+		Events:    session.NewEventPool(false, false),
+	}
+}
+
+// testTCPPacket builds an ethernet frame carrying a TCP segment with the given payload.
+func testTCPPacket(t *testing.T, fromClient bool, srcPort, dstPort int, seq uint32, payload []byte) []byte {
+	t.Helper()
+
+	eth := &layers.Ethernet{SrcMAC: testClientMAC, DstMAC: testServerMAC, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: testClientIP, DstIP: testServerIP}
+	if !fromClient {
+		eth.SrcMAC, eth.DstMAC = eth.DstMAC, eth.SrcMAC
+		ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+	}
+	tcp := &layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), Seq: seq, ACK: true, PSH: true, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	return testSerialize(t, eth, ip, tcp, gopacket.Payload(payload))
+}
+
+// testUDPPacket builds an ethernet frame carrying a UDP datagram with the given payload.
+func testUDPPacket(t *testing.T, fromClient bool, srcPort, dstPort int, payload []byte) []byte {
+	t.Helper()
+
+	eth := &layers.Ethernet{SrcMAC: testClientMAC, DstMAC: testServerMAC, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: testClientIP, DstIP: testServerIP}
+	if !fromClient {
+		eth.SrcMAC, eth.DstMAC = eth.DstMAC, eth.SrcMAC
+		ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+	}
+	udp := &layers.UDP{SrcPort: layers.UDPPort(srcPort), DstPort: layers.UDPPort(dstPort)}
+	udp.SetNetworkLayerForChecksum(ip)
+
+	return testSerialize(t, eth, ip, udp, gopacket.Payload(payload))
+}
+
+func testSerialize(t *testing.T, l ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, l...); err != nil {
+		t.Fatalf("could not serialize packet: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testWritePcap writes the given ethernet frames to a temporary pcap file.
+func testWritePcap(t *testing.T, frames ...[]byte) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "capture.pcap")
+	fp, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	w := pcapgo.NewWriter(fp)
+	if err := w.WriteFileHeader(65535, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, frame := range frames {
+		ci := gopacket.CaptureInfo{
+			Timestamp:     ts.Add(time.Duration(i) * time.Millisecond),
+			CaptureLength: len(frame),
+			Length:        len(frame),
+		}
+		if err := w.WritePacket(ci, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return filename
+}
+
+// replayFrames writes the frames to a capture and returns the events the
+// parsers emitted while replaying it.
+func replayFrames(t *testing.T, frames ...[]byte) []SnifferEvent {
+	t.Helper()
+
+	setupTestSession()
+
+	events, err := CollectEvents(testWritePcap(t, frames...), false, nil)
+	if err != nil {
+		t.Fatalf("could not replay capture: %v", err)
+	}
+	return events
+}
+
+// eventsOf filters events by protocol.
+func eventsOf(events []SnifferEvent, proto string) []SnifferEvent {
+	filtered := make([]SnifferEvent, 0)
+	for _, e := range events {
+		if e.Protocol == proto {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+func TestReplayFTP(t *testing.T) {
+	events := replayFrames(t,
+		testTCPPacket(t, true, 40000, 21, 1, []byte("USER admin\r\n")),
+		testTCPPacket(t, true, 40000, 21, 13, []byte("PASS s3cr3t\r\n")))
+
+	ftp := eventsOf(events, "ftp")
+	if len(ftp) != 2 {
+		t.Fatalf("expected 2 ftp events, got %d: %+v", len(ftp), events)
+	}
+	if !strings.Contains(ftp[1].Message, "s3cr3t") {
+		t.Errorf("expected password in message, got %s", ftp[1].Message)
+	}
+	if ftp[0].Source != testClientIP.String() || ftp[0].Destination != testServerIP.String() {
+		t.Errorf("unexpected endpoints %s -> %s", ftp[0].Source, ftp[0].Destination)
+	}
+}
+
+func TestReplayHTTP(t *testing.T) {
+	req := "GET /index.html HTTP/1.1\r\nHost: example.com\r\nUser-Agent: test\r\n\r\n"
+	events := replayFrames(t, testTCPPacket(t, true, 40000, 80, 1, []byte(req)))
+
+	http := eventsOf(events, "http.request")
+	if len(http) != 1 {
+		t.Fatalf("expected 1 http event, got %d: %+v", len(http), events)
+	}
+	if r, ok := http[0].Data.(HTTPRequest); !ok {
+		t.Errorf("unexpected data type %T", http[0].Data)
+	} else if r.Host != "example.com" || r.Method != "GET" {
+		t.Errorf("unexpected request %+v", r)
+	}
+}
+
+func TestReplayDNS(t *testing.T) {
+	dns := &layers.DNS{
+		ID:     1,
+		QR:     true,
+		OpCode: layers.DNSOpCodeQuery,
+		Questions: []layers.DNSQuestion{
+			{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN},
+		},
+		Answers: []layers.DNSResourceRecord{
+			{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 60, IP: net.IP{93, 184, 216, 34}},
+		},
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := dns.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := replayFrames(t, testUDPPacket(t, false, 53, 40000, buf.Bytes()))
+
+	found := eventsOf(events, "dns")
+	if len(found) != 1 {
+		t.Fatalf("expected 1 dns event, got %d: %+v", len(found), events)
+	}
+	if !strings.Contains(found[0].Message, "example.com") || !strings.Contains(found[0].Message, "93.184.216.34") {
+		t.Errorf("unexpected message %s", found[0].Message)
+	}
+}
+
+func TestReplayPcapNG(t *testing.T) {
+	setupTestSession()
+
+	filename := filepath.Join(t.TempDir(), "capture.pcapng")
+	fp, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := pcapgo.NewNgWriter(fp, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := testTCPPacket(t, true, 40000, 21, 1, []byte("USER admin\r\n"))
+	ci := gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: len(frame), Length: len(frame), InterfaceIndex: 0}
+	if err := w.WritePacket(ci, frame); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	fp.Close()
+
+	events, err := CollectEvents(filename, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eventsOf(events, "ftp")) != 1 {
+		t.Errorf("expected 1 ftp event, got %+v", events)
+	}
+}
+
+func TestReplayFileErrors(t *testing.T) {
+	if _, err := ReplayFile(filepath.Join(t.TempDir(), "missing.pcap"), false, nil, nil); err == nil {
+		t.Error("expected error for missing file")
+	}
+
+	garbage := filepath.Join(t.TempDir(), "garbage.pcap")
+	if err := os.WriteFile(garbage, []byte("this is not a capture"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReplayFile(garbage, false, nil, nil); err == nil {
+		t.Error("expected error for invalid file")
+	}
+}
+
+func TestReplayFileCallback(t *testing.T) {
+	setupTestSession()
+
+	filename := testWritePcap(t,
+		testTCPPacket(t, true, 40000, 21, 1, []byte("USER admin\r\n")),
+		testUDPPacket(t, true, 40000, 9999, []byte("hello")))
+
+	seen := 0
+	num, err := ReplayFile(filename, false, nil, func(pkt gopacket.Packet, parsed bool) {
+		seen++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 2 || seen != 2 {
+		t.Errorf("expected 2 packets, got num=%d seen=%d", num, seen)
+	}
+}
@@ -0,0 +1,266 @@
+package net_sniff
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	smb2HeaderSize = 64
+	// bigger than the largest read or write size negotiated in practice
+	smb2MaxMessage = 16 * 1024 * 1024
+	// open files tracked per connection, each one can buffer up to the carving max size
+	smb2MaxFiles = 32
+
+	smb2FlagResponse  = 0x00000001
+	smb2StatusPending = 0x00000103
+
+	smb2Create = 0x0005
+	smb2Close  = 0x0006
+	smb2Read   = 0x0008
+	smb2Write  = 0x0009
+)
+
+var (
+	smb2Magic = []byte{0xfe, 'S', 'M', 'B'}
+)
+
+type smb2FileID [16]byte
+
+// smbFile is a file opened over a SMB2 connection.
+type smbFile struct {
+	name     string
+	upload   bool
+	data     []byte
+	overflow bool
+	updated  time.Time
+}
+
+func (f *smbFile) write(offset uint64, data []byte, max int) {
+	if f.overflow {
+		return
+	} else if offset > uint64(max) || uint64(len(data)) > uint64(max)-offset {
+		// offsets are chosen by the peer, checked this way so they can't wrap around
+		f.overflow = true
+		f.data = nil
+		return
+	}
+
+	end := offset + uint64(len(data))
+	if end > uint64(len(f.data)) {
+		f.data = append(f.data, make([]byte, int(end)-len(f.data))...)
+	}
+	copy(f.data[offset:], data)
+}
+
+type smbRead struct {
+	id     smb2FileID
+	offset uint64
+}
+
+// smbCarveHandler follows a SMB2 connection and carves the files read or written.
+type smbCarveHandler struct {
+	stream *TCPStream
+	carver *fileCarver
+	dead   bool
+	client []byte
+	server []byte
+	// file names of pending CREATE requests by message id
+	creates map[uint64]string
+	// pending READ requests by message id
+	reads map[uint64]smbRead
+	files map[smb2FileID]*smbFile
+}
+
+func smbCarveParser(stream *TCPStream) StreamHandler {
+	if stream.owner.carver == nil || stream.ServerPort != 445 {
+		return nil
+	}
+	return &smbCarveHandler{
+		stream:  stream,
+		carver:  stream.owner.carver,
+		creates: make(map[uint64]string),
+		reads:   make(map[uint64]smbRead),
+		files:   make(map[smb2FileID]*smbFile),
+	}
+}
+
+func (h *smbCarveHandler) OnData(chunk StreamChunk) {
+	if h.dead {
+		return
+	} else if chunk.Skipped > 0 {
+		h.dead = true
+		return
+	}
+
+	buffer := &h.server
+	if chunk.FromClient {
+		buffer = &h.client
+	}
+	*buffer = append(*buffer, chunk.Data...)
+
+	// direct TCP transport: 1 byte zero and 3 bytes of big endian length
+	for len(*buffer) >= 4 {
+		if (*buffer)[0] != 0 {
+			h.dead = true
+			return
+		}
+
+		size := int(binary.BigEndian.Uint32(*buffer))
+		if size > smb2MaxMessage {
+			h.dead = true
+			return
+		} else if len(*buffer) < 4+size {
+			return
+		}
+
+		h.onMessage((*buffer)[4:4+size], chunk.Time)
+		*buffer = (*buffer)[4+size:]
+	}
+}
+
+// onMessage parses a (possibly compounded) SMB2 message, encrypted
+// and SMB1 ones are ignored.
+func (h *smbCarveHandler) onMessage(msg []byte, t time.Time) {
+	for len(msg) >= smb2HeaderSize && string(msg[:4]) == string(smb2Magic) {
+		next := binary.LittleEndian.Uint32(msg[20:])
+		packet := msg
+		if next > 0 && int(next) <= len(msg) {
+			packet = msg[:next]
+		}
+
+		h.onPacket(packet, t)
+
+		if next == 0 || int(next) >= len(msg) {
+			break
+		}
+		msg = msg[next:]
+	}
+}
+
+func (h *smbCarveHandler) onPacket(pkt []byte, t time.Time) {
+	status := binary.LittleEndian.Uint32(pkt[8:])
+	command := binary.LittleEndian.Uint16(pkt[12:])
+	flags := binary.LittleEndian.Uint32(pkt[16:])
+	msgID := binary.LittleEndian.Uint64(pkt[24:])
+	body := pkt[smb2HeaderSize:]
+	response := flags&smb2FlagResponse != 0
+
+	switch command {
+	case smb2Create:
+		if !response && len(body) >= 48 {
+			offset := int(binary.LittleEndian.Uint16(body[44:]))
+			length := int(binary.LittleEndian.Uint16(body[46:]))
+			if offset+length <= len(pkt) {
+				h.creates[msgID] = smbString(pkt[offset : offset+length])
+			}
+		} else if response {
+			name, found := h.creates[msgID]
+			if status == 0 && found && len(body) >= 80 {
+				var id smb2FileID
+				copy(id[:], body[64:80])
+				if _, tracked := h.files[id]; tracked || len(h.files) < smb2MaxFiles {
+					h.files[id] = &smbFile{name: name}
+				}
+			}
+			// STATUS_PENDING is followed by the actual response
+			if status != smb2StatusPending {
+				delete(h.creates, msgID)
+			}
+		}
+
+	case smb2Read:
+		if !response && len(body) >= 32 {
+			read := smbRead{offset: binary.LittleEndian.Uint64(body[8:])}
+			copy(read.id[:], body[16:32])
+			h.reads[msgID] = read
+		} else if response {
+			read, found := h.reads[msgID]
+			if found && status == 0 && len(body) >= 8 {
+				offset := int(body[2])
+				length := int(binary.LittleEndian.Uint32(body[4:]))
+				if offset+length <= len(pkt) {
+					h.file(read.id).write(read.offset, pkt[offset:offset+length], h.carver.maxSize)
+					h.file(read.id).updated = t
+				}
+			}
+			if status != smb2StatusPending {
+				delete(h.reads, msgID)
+			}
+		}
+
+	case smb2Write:
+		if !response && len(body) >= 32 {
+			offset := int(binary.LittleEndian.Uint16(body[2:]))
+			length := int(binary.LittleEndian.Uint32(body[4:]))
+			var id smb2FileID
+			copy(id[:], body[16:32])
+			if offset+length <= len(pkt) {
+				file := h.file(id)
+				file.upload = true
+				file.updated = t
+				file.write(binary.LittleEndian.Uint64(body[8:]), pkt[offset:offset+length], h.carver.maxSize)
+			}
+		}
+
+	case smb2Close:
+		if !response && len(body) >= 24 {
+			var id smb2FileID
+			copy(id[:], body[8:24])
+			if file, found := h.files[id]; found {
+				h.save(file)
+				delete(h.files, id)
+			}
+		}
+	}
+}
+
+// file returns the file with the given id, creating it if its CREATE was missed.
+// Once too many files are open the new ones are not tracked and never saved.
+func (h *smbCarveHandler) file(id smb2FileID) *smbFile {
+	file, found := h.files[id]
+	if !found {
+		file = &smbFile{name: hex.EncodeToString(id[:])}
+		if len(h.files) < smb2MaxFiles {
+			h.files[id] = file
+		} else {
+			file.overflow = true
+		}
+	}
+	return file
+}
+
+func (h *smbCarveHandler) save(file *smbFile) {
+	if file.overflow || len(file.data) == 0 {
+		return
+	}
+
+	name := path.Base(strings.ReplaceAll(file.name, "\\", "/"))
+	ctype := http.DetectContentType(file.data)
+	if file.upload {
+		h.carver.save(file.updated, "smb", h.stream.ClientIP, h.stream.ServerIP, name, ctype, file.data)
+	} else {
+		h.carver.save(file.updated, "smb", h.stream.ServerIP, h.stream.ClientIP, name, ctype, file.data)
+	}
+}
+
+func (h *smbCarveHandler) OnClose() {
+	for id, file := range h.files {
+		h.save(file)
+		delete(h.files, id)
+	}
+}
+
+// smbString decodes an UTF-16LE string.
+func smbString(raw []byte) string {
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(raw[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
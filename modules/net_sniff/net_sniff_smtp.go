@@ -0,0 +1,44 @@
+package net_sniff
+
+import (
+	"strings"
+
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+var smtp = &mailProtocol{
+	name:  "smtp",
+	color: tui.BACKYELLOW + tui.FOREBLACK,
+	ports: map[layers.TCPPort]bool{
+		25:   true,
+		587:  true,
+		2525: true,
+	},
+	onClient: func(s *mailSession, line string) *MailAuth {
+		if s.mechanism != "" {
+			return s.saslStep(line)
+		}
+
+		// AUTH <mechanism> [initial-response]
+		if verb, args := mailCommand(line); verb == "AUTH" && len(args) > 0 {
+			initial := ""
+			if len(args) > 1 {
+				initial = args[1]
+			}
+			return s.saslStart(args[0], initial)
+		}
+
+		return nil
+	},
+	onServer: func(s *mailSession, line string) {
+		// 334 <base64 challenge>
+		if s.mechanism != "" && strings.HasPrefix(line, "334 ") {
+			s.saslChallenge(line[4:])
+		} else if s.mechanism != "" && (strings.HasPrefix(line, "235") || strings.HasPrefix(line, "5")) {
+			// authentication completed or aborted by the server
+			s.reset()
+		}
+	},
+}
@@ -2,48 +2,165 @@ package net_sniff
 
 import (
 	"fmt"
-	"net"
+	"time"
 
-	"regexp"
-
-	"github.com/gopacket/gopacket"
-	"github.com/gopacket/gopacket/layers"
+	"github.com/bettercap/bettercap/v2/session"
 
 	"github.com/evilsocket/islazy/tui"
 )
 
-// poor man's TLS Client Hello with SNI extension parser :P
-var sniRe = regexp.MustCompile("\x00\x00.{4}\x00.{2}([a-z0-9]+([\\-\\.]{1}[a-z0-9]+)*\\.[a-z]{2,6})\x00")
+// handshakes bigger than this are not parsed
+const tlsMaxHandshake = 64 * 1024
+
+// TLSFingerprint is the information extracted from a TLS handshake.
+type TLSFingerprint struct {
+	ServerName string   `json:"server_name"`
+	ALPN       []string `json:"alpn,omitempty"`
+	JA3        string   `json:"ja3"`
+	JA3Hash    string   `json:"ja3_hash"`
+	JA4        string   `json:"ja4"`
+	JA3S       string   `json:"ja3s,omitempty"`
+	JA3SHash   string   `json:"ja3s_hash,omitempty"`
+}
+
+// sniHandler follows a TLS handshake to fingerprint both peers, the event
+// is sent once the ServerHello is seen or the connection is closed.
+type sniHandler struct {
+	stream      *TCPStream
+	client      []byte
+	server      []byte
+	time        time.Time
+	fingerprint *TLSFingerprint
+	done        bool
+}
+
+func sniParser(stream *TCPStream) StreamHandler {
+	return &sniHandler{stream: stream}
+}
 
-func sniParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
-	data := tcp.Payload
-	dataSize := len(data)
+func isTLSHandshake(data []byte) bool {
+	return len(data) >= 3 && data[0] == tlsRecordHandshake && data[1] == 0x03 && data[2] <= 0x04
+}
 
-	if dataSize < 2 || data[0] != 0x16 || data[1] != 0x03 {
-		return false
+// readHandshake buffers data until a full handshake message is available,
+// errTLSTruncated is returned while more data is needed.
+func (h *sniHandler) readHandshake(buffer *[]byte, data []byte) (byte, []byte, error) {
+	*buffer = append(*buffer, data...)
+	kind, message, err := tlsHandshake(*buffer)
+	if err == errTLSTruncated && len(*buffer) >= tlsMaxHandshake {
+		err = fmt.Errorf("handshake bigger than %d bytes", tlsMaxHandshake)
 	}
+	if err != errTLSTruncated {
+		*buffer = nil
+	}
+	return kind, message, err
+}
 
-	m := sniRe.FindSubmatch(data)
-	if len(m) < 2 {
-		return false
+func (h *sniHandler) OnData(chunk StreamChunk) {
+	if h.done {
+		return
+	} else if chunk.Skipped > 0 {
+		h.finish()
+		return
 	}
 
-	domain := string(m[1])
-	if tcp.DstPort != 443 {
-		domain = fmt.Sprintf("%s:%d", domain, tcp.DstPort)
+	if chunk.FromClient && h.fingerprint == nil {
+		if len(h.client) == 0 {
+			if !isTLSHandshake(chunk.Data) {
+				h.done = true
+				return
+			}
+			// encrypted traffic is of no use to the other parsers
+			h.stream.Claim()
+			h.time = chunk.Time
+		}
+
+		kind, message, err := h.readHandshake(&h.client, chunk.Data)
+		if err == errTLSTruncated {
+			return
+		} else if err != nil || kind != tlsClientHello {
+			h.done = true
+			return
+		}
+
+		hello, err := ParseClientHello(message)
+		if err != nil {
+			h.done = true
+			return
+		}
+
+		ja3 := hello.JA3()
+		h.fingerprint = &TLSFingerprint{
+			ServerName: hello.ServerName,
+			ALPN:       hello.ALPN,
+			JA3:        ja3,
+			JA3Hash:    md5Hex(ja3),
+			JA4:        hello.JA4('t'),
+		}
+	} else if !chunk.FromClient && h.fingerprint != nil {
+		kind, message, err := h.readHandshake(&h.server, chunk.Data)
+		if err == errTLSTruncated {
+			return
+		} else if err == nil && kind == tlsServerHello {
+			if hello, err := ParseServerHello(message); err == nil {
+				h.fingerprint.JA3S = hello.JA3S()
+				h.fingerprint.JA3SHash = md5Hex(h.fingerprint.JA3S)
+			}
+		}
+		h.finish()
+	}
+}
+
+func (h *sniHandler) OnClose() {
+	h.finish()
+}
+
+func (h *sniHandler) finish() {
+	if h.done {
+		return
+	}
+	h.done = true
+
+	fp := h.fingerprint
+	if fp == nil {
+		return
+	}
+
+	srcIP, dstIP := h.stream.ClientIP, h.stream.ServerIP
+
+	if endpoint := session.I.Lan.GetByIp(srcIP.String()); endpoint != nil {
+		endpoint.OnMeta(map[string]string{
+			"tls:ja3": fp.JA3Hash,
+			"tls:ja4": fp.JA4,
+		})
+	}
+
+	if fp.JA3SHash != "" {
+		if endpoint := session.I.Lan.GetByIp(dstIP.String()); endpoint != nil {
+			endpoint.OnMeta(map[string]string{
+				"tls:ja3s": fp.JA3SHash,
+			})
+		}
+	}
+
+	domain := fp.ServerName
+	if domain == "" {
+		domain = dstIP.String()
+	}
+	if h.stream.ServerPort != 443 {
+		domain = fmt.Sprintf("%s:%d", domain, h.stream.ServerPort)
 	}
 
 	NewSnifferEvent(
-		pkt.Metadata().Timestamp,
+		h.time,
 		"https",
 		srcIP.String(),
 		domain,
-		nil,
-		"%s %s > %s",
+		*fp,
+		"%s %s > %s %s",
 		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, "sni"),
 		vIP(srcIP),
 		tui.Yellow("https://"+domain),
+		tui.Dim(fp.JA4),
 	).Push()
-
-	return true
 }
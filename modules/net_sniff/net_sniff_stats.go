@@ -28,7 +28,7 @@ func NewSnifferStats() *SnifferStats {
 	}
 }
 
-func (s *SnifferStats) Print() error {
+func (s *SnifferStats) Print(streams StreamStats) error {
 	first := "never"
 	last := "never"
 
@@ -46,6 +46,10 @@ func (s *SnifferStats) Print() error {
 	log.Info("Matched Packets    : %d", s.NumMatched)
 	log.Info("Dumped Packets     : %d", s.NumDumped)
 	log.Info("Wrote Packets      : %d", s.NumWrote)
+	log.Info("Tracked Flows      : %d", streams.FlowsTracked)
+	log.Info("Total Flows        : %d", streams.FlowsTotal)
+	log.Info("Reassembled Bytes  : %d", streams.BytesReassembled)
+	log.Info("Dropped Bytes      : %d", streams.BytesDropped)
 
 	return nil
 }
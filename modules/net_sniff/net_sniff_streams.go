@@ -0,0 +1,257 @@
+package net_sniff
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/log"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/reassembly"
+)
+
+const (
+	DefaultStreamTimeout = 120
+	DefaultStreamBuffer  = 4096
+	// every how often idle flows are closed, in capture time
+	streamFlushInterval = 10 * time.Second
+	// out of order pages (1900 bytes each) kept for a single flow
+	streamPagesPerFlow = 64
+)
+
+// StreamChunk is a piece of reassembled payload of a TCP flow.
+type StreamChunk struct {
+	// true if sent by the client to the server
+	FromClient bool
+	// ordered payload, only valid during the OnData call
+	Data []byte
+	// number of bytes that were lost before this chunk
+	Skipped int
+	// capture time of the first packet of the chunk
+	Time time.Time
+}
+
+// StreamHandler receives the reassembled data of a single TCP flow.
+type StreamHandler interface {
+	OnData(chunk StreamChunk)
+	OnClose()
+}
+
+// StreamParser is given every new TCP flow and returns a handler for it
+// or nil if not interested.
+type StreamParser func(stream *TCPStream) StreamHandler
+
+// TCPStream is a TCP flow being reassembled.
+type TCPStream struct {
+	ClientIP   net.IP
+	ServerIP   net.IP
+	ClientPort layers.TCPPort
+	ServerPort layers.TCPPort
+	Started    time.Time
+
+	key       streamKey
+	claimed   bool
+	clientDir reassembly.TCPFlowDirection
+	handlers  []StreamHandler
+	owner     *StreamAssembler
+}
+
+type streamKey [2]gopacket.Flow
+
+// Claim marks the flow as fully handled by a stream parser, its packets
+// won't be passed to the per packet parsers anymore.
+func (s *TCPStream) Claim() {
+	s.claimed = true
+}
+
+func (s *TCPStream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) bool {
+	// flows are picked up even if we missed the handshake
+	*start = true
+	return true
+}
+
+func (s *TCPStream) ReassembledSG(sg reassembly.ScatterGather, ac reassembly.AssemblerContext) {
+	dir, _, _, skip := sg.Info()
+	length, _ := sg.Lengths()
+
+	if skip > 0 {
+		s.owner.stats.BytesDropped += uint64(skip)
+	} else if skip < 0 {
+		skip = 0
+	}
+
+	if length == 0 && skip == 0 {
+		return
+	}
+
+	s.owner.stats.BytesReassembled += uint64(length)
+
+	chunk := StreamChunk{
+		FromClient: dir == s.clientDir,
+		Data:       sg.Fetch(length),
+		Skipped:    skip,
+		Time:       ac.GetCaptureInfo().Timestamp,
+	}
+
+	for _, handler := range s.handlers {
+		handler.OnData(chunk)
+	}
+}
+
+func (s *TCPStream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	for _, handler := range s.handlers {
+		handler.OnClose()
+	}
+	delete(s.owner.streams, s.key)
+	s.owner.stats.FlowsTracked--
+	// remove the connection from the pool
+	return true
+}
+
+// StreamStats holds the statistics of the reassembly layer.
+type StreamStats struct {
+	FlowsTracked     uint64
+	FlowsTotal       uint64
+	BytesReassembled uint64
+	BytesDropped     uint64
+}
+
+// StreamAssembler reassembles TCP flows and dispatches ordered payloads
+// to the registered stream parsers.
+type StreamAssembler struct {
+	sync.Mutex
+
+	timeout   time.Duration
+	pool      *reassembly.StreamPool
+	assembler *reassembly.Assembler
+	streams   map[streamKey]*TCPStream
+	lastFlush time.Time
+	stats     StreamStats
+	// set when file carving is enabled
+	carver *fileCarver
+	// QUIC Initial packets are reassembled here too
+	quic *quicTracker
+}
+
+type streamFactory struct {
+	owner *StreamAssembler
+}
+
+func (f *streamFactory) New(netFlow, tcpFlow gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+	src, dst := netFlow.Endpoints()
+	sport, dport := tcp.SrcPort, tcp.DstPort
+
+	stream := &TCPStream{
+		ClientIP:   net.IP(src.Raw()),
+		ServerIP:   net.IP(dst.Raw()),
+		ClientPort: sport,
+		ServerPort: dport,
+		Started:    ac.GetCaptureInfo().Timestamp,
+		key:        streamKey{netFlow, tcpFlow},
+		clientDir:  reassembly.TCPDirClientToServer,
+		owner:      f.owner,
+	}
+
+	// the first packet we see is not necessarily sent by the client
+	if (tcp.SYN && tcp.ACK) || (!tcp.SYN && sport < dport) {
+		stream.ClientIP, stream.ServerIP = stream.ServerIP, stream.ClientIP
+		stream.ClientPort, stream.ServerPort = stream.ServerPort, stream.ClientPort
+		stream.clientDir = reassembly.TCPDirServerToClient
+	}
+
+	for _, parser := range streamParsers {
+		if handler := parser(stream); handler != nil {
+			stream.handlers = append(stream.handlers, handler)
+		}
+	}
+
+	f.owner.streams[stream.key] = stream
+	f.owner.stats.FlowsTracked++
+	f.owner.stats.FlowsTotal++
+
+	return stream
+}
+
+// NewStreamAssembler creates a reassembler closing flows idle for more than
+// timeout and buffering at most maxPages out of order pages overall.
+func NewStreamAssembler(timeout time.Duration, maxPages int) *StreamAssembler {
+	a := &StreamAssembler{
+		timeout: timeout,
+		streams: make(map[streamKey]*TCPStream),
+		quic:    newQUICTracker(),
+	}
+
+	a.pool = reassembly.NewStreamPool(&streamFactory{owner: a})
+	a.assembler = reassembly.NewAssembler(a.pool)
+	a.assembler.MaxBufferedPagesTotal = maxPages
+	a.assembler.MaxBufferedPagesPerConnection = streamPagesPerFlow
+
+	return a
+}
+
+type streamContext struct {
+	ci gopacket.CaptureInfo
+}
+
+func (c *streamContext) GetCaptureInfo() gopacket.CaptureInfo {
+	return c.ci
+}
+
+// Feed passes a TCP packet to the reassembler, it returns true if the flow
+// has been claimed by a stream parser.
+func (a *StreamAssembler) Feed(pkt gopacket.Packet, tcp *layers.TCP) bool {
+	nlayer := pkt.NetworkLayer()
+	if nlayer == nil {
+		return false
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	ctx := &streamContext{ci: pkt.Metadata().CaptureInfo}
+	if ctx.ci.Timestamp.IsZero() {
+		ctx.ci.Timestamp = time.Now()
+	}
+
+	netFlow := nlayer.NetworkFlow()
+	a.assembler.AssembleWithContext(netFlow, tcp, ctx)
+
+	claimed := false
+	tcpFlow := tcp.TransportFlow()
+	if stream, found := a.streams[streamKey{netFlow, tcpFlow}]; found {
+		claimed = stream.claimed
+	} else if stream, found := a.streams[streamKey{netFlow.Reverse(), tcpFlow.Reverse()}]; found {
+		claimed = stream.claimed
+	}
+
+	// timeouts are evaluated on capture time so that replayed files behave like live traffic
+	if now := ctx.ci.Timestamp; a.lastFlush.IsZero() {
+		a.lastFlush = now
+	} else if now.Sub(a.lastFlush) >= streamFlushInterval {
+		flushed, closed := a.assembler.FlushCloseOlderThan(now.Add(-a.timeout))
+		if closed > 0 {
+			log.Debug("streams: flushed %d and closed %d idle flows", flushed, closed)
+		}
+		a.lastFlush = now
+	}
+
+	return claimed
+}
+
+// Close flushes and closes all the flows.
+func (a *StreamAssembler) Close() {
+	a.Lock()
+	defer a.Unlock()
+
+	a.assembler.FlushAll()
+}
+
+// Stats returns a snapshot of the reassembly statistics.
+func (a *StreamAssembler) Stats() StreamStats {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.stats
+}
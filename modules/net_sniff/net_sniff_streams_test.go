@@ -0,0 +1,190 @@
+package net_sniff
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+type testStreamHandler struct {
+	stream *TCPStream
+	client bytes.Buffer
+	server bytes.Buffer
+	skip   int
+	closed bool
+}
+
+func (h *testStreamHandler) OnData(chunk StreamChunk) {
+	h.skip += chunk.Skipped
+	if chunk.FromClient {
+		h.client.Write(chunk.Data)
+	} else {
+		h.server.Write(chunk.Data)
+	}
+}
+
+func (h *testStreamHandler) OnClose() {
+	h.closed = true
+}
+
+// withTestStreamParser replaces the stream parsers with one collecting
+// the data of every flow to the given port.
+func withTestStreamParser(t *testing.T, port layers.TCPPort) *[]*testStreamHandler {
+	handlers := make([]*testStreamHandler, 0)
+	saved := streamParsers
+	streamParsers = []StreamParser{
+		func(stream *TCPStream) StreamHandler {
+			if stream.ServerPort != port {
+				return nil
+			}
+			stream.Claim()
+			h := &testStreamHandler{stream: stream}
+			handlers = append(handlers, h)
+			return h
+		},
+	}
+	t.Cleanup(func() {
+		streamParsers = saved
+	})
+	return &handlers
+}
+
+func feedFrames(t *testing.T, a *StreamAssembler, frames ...[]byte) []bool {
+	claimed := make([]bool, 0)
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, frame := range frames {
+		pkt := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default)
+		pkt.Metadata().CaptureInfo = gopacket.CaptureInfo{
+			Timestamp:     ts.Add(time.Duration(i) * time.Millisecond),
+			CaptureLength: len(frame),
+			Length:        len(frame),
+		}
+		tcp, ok := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok {
+			t.Fatalf("frame %d is not tcp", i)
+		}
+		claimed = append(claimed, a.Feed(pkt, tcp))
+	}
+	return claimed
+}
+
+func TestStreamsOutOfOrder(t *testing.T) {
+	handlers := withTestStreamParser(t, 1234)
+	a := NewStreamAssembler(time.Minute, DefaultStreamBuffer)
+
+	claimed := feedFrames(t, a,
+		testTCPPacket(t, true, 40000, 1234, 100, []byte("hello ")),
+		testTCPPacket(t, true, 40000, 1234, 112, []byte("world")),
+		testTCPPacket(t, true, 40000, 1234, 106, []byte("there ")),
+		// retransmission
+		testTCPPacket(t, true, 40000, 1234, 106, []byte("there ")),
+		testTCPPacket(t, false, 1234, 40000, 500, []byte("ok")))
+	a.Close()
+
+	if len(*handlers) != 1 {
+		t.Fatalf("expected 1 stream, got %d", len(*handlers))
+	}
+
+	h := (*handlers)[0]
+	if got := h.client.String(); got != "hello there world" {
+		t.Errorf("unexpected client stream '%s'", got)
+	}
+	if got := h.server.String(); got != "ok" {
+		t.Errorf("unexpected server stream '%s'", got)
+	}
+	if !h.closed {
+		t.Error("expected stream to be closed")
+	}
+	if !h.stream.ClientIP.Equal(testClientIP) || h.stream.ServerPort != 1234 {
+		t.Errorf("unexpected endpoints %s:%d -> %s:%d", h.stream.ClientIP, h.stream.ClientPort, h.stream.ServerIP, h.stream.ServerPort)
+	}
+
+	for i, c := range claimed {
+		if !c {
+			t.Errorf("expected packet %d to be claimed", i)
+		}
+	}
+
+	stats := a.Stats()
+	if stats.FlowsTotal != 1 || stats.FlowsTracked != 0 {
+		t.Errorf("unexpected flow stats %+v", stats)
+	}
+	if stats.BytesReassembled != uint64(len("hello there worldok")) {
+		t.Errorf("unexpected reassembled bytes %+v", stats)
+	}
+}
+
+func TestStreamsDroppedBytes(t *testing.T) {
+	handlers := withTestStreamParser(t, 1234)
+	a := NewStreamAssembler(time.Minute, DefaultStreamBuffer)
+
+	feedFrames(t, a,
+		testTCPPacket(t, true, 40000, 1234, 100, []byte("first")),
+		// 10 bytes never seen
+		testTCPPacket(t, true, 40000, 1234, 115, []byte("second")))
+	a.Close()
+
+	h := (*handlers)[0]
+	if got := h.client.String(); got != "firstsecond" {
+		t.Errorf("unexpected client stream '%s'", got)
+	}
+	if h.skip != 10 {
+		t.Errorf("expected 10 skipped bytes, got %d", h.skip)
+	}
+	if stats := a.Stats(); stats.BytesDropped != 10 {
+		t.Errorf("expected 10 dropped bytes, got %+v", stats)
+	}
+}
+
+func TestStreamsUnclaimed(t *testing.T) {
+	handlers := withTestStreamParser(t, 1234)
+	a := NewStreamAssembler(time.Minute, DefaultStreamBuffer)
+
+	claimed := feedFrames(t, a, testTCPPacket(t, true, 40000, 80, 1, []byte("GET / HTTP/1.1\r\n\r\n")))
+	a.Close()
+
+	if len(*handlers) != 0 {
+		t.Errorf("expected no handlers, got %d", len(*handlers))
+	}
+	if claimed[0] {
+		t.Error("expected packet not to be claimed")
+	}
+	if stats := a.Stats(); stats.FlowsTotal != 1 {
+		t.Errorf("expected flow to be tracked anyway, got %+v", stats)
+	}
+}
+
+func TestStreamsTimeout(t *testing.T) {
+	handlers := withTestStreamParser(t, 1234)
+	a := NewStreamAssembler(5*time.Second, DefaultStreamBuffer)
+
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, frame := range [][]byte{
+		testTCPPacket(t, true, 40000, 1234, 1, []byte("idle")),
+		testTCPPacket(t, true, 40001, 1234, 1, []byte("active")),
+	} {
+		pkt := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default)
+		pkt.Metadata().CaptureInfo = gopacket.CaptureInfo{
+			Timestamp:     ts.Add(time.Duration(i) * time.Minute),
+			CaptureLength: len(frame),
+			Length:        len(frame),
+		}
+		a.Feed(pkt, pkt.Layer(layers.LayerTypeTCP).(*layers.TCP))
+	}
+
+	if len(*handlers) != 2 {
+		t.Fatalf("expected 2 streams, got %d", len(*handlers))
+	}
+	if !(*handlers)[0].closed {
+		t.Error("expected idle stream to be closed")
+	}
+	if (*handlers)[1].closed {
+		t.Error("expected active stream to be open")
+	}
+	if stats := a.Stats(); stats.FlowsTracked != 1 {
+		t.Errorf("expected 1 tracked flow, got %+v", stats)
+	}
+}
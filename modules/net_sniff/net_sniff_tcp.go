@@ -11,18 +11,32 @@ import (
 )
 
 var tcpParsers = []func(net.IP, net.IP, []byte, gopacket.Packet, *layers.TCP) bool{
-	sniParser,
 	ntlmParser,
 	httpParser,
 	ftpParser,
 	teamViewerParser,
 }
 
-func onTCP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool) {
+var streamParsers = []StreamParser{
+	sniParser,
+	smtp.parser,
+	pop3.parser,
+	imap.parser,
+	httpCarveParser,
+	ftpControlParser,
+	ftpDataParser,
+	smbCarveParser,
+}
+
+func onTCP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool, streams *StreamAssembler) {
 	tcp := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
-	for _, parser := range tcpParsers {
-		if parser(srcIP, dstIP, payload, pkt, tcp) {
-			return
+
+	// flows handled by stream parsers are not passed to the per packet ones
+	if streams == nil || !streams.Feed(pkt, tcp) {
+		for _, parser := range tcpParsers {
+			if parser(srcIP, dstIP, payload, pkt, tcp) {
+				return
+			}
 		}
 	}
 
@@ -0,0 +1,365 @@
+package net_sniff
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	tlsRecordHandshake   = 0x16
+	tlsClientHello       = 0x01
+	tlsServerHello       = 0x02
+	tlsExtServerName     = 0x0000
+	tlsExtSupportedGroup = 0x000a
+	tlsExtPointFormats   = 0x000b
+	tlsExtSignatureAlgs  = 0x000d
+	tlsExtALPN           = 0x0010
+	tlsExtSupportedVers  = 0x002b
+)
+
+var errTLSTruncated = errors.New("truncated TLS message")
+
+// TLSHello holds the fields of a ClientHello or ServerHello used for fingerprinting.
+type TLSHello struct {
+	Version           uint16
+	Ciphers           []uint16
+	Extensions        []uint16
+	Groups            []uint16
+	PointFormats      []uint8
+	SignatureAlgs     []uint16
+	SupportedVersions []uint16
+	ServerName        string
+	ALPN              []string
+}
+
+// isGREASE returns true for the reserved values of RFC 8701.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGREASE(values []uint16) []uint16 {
+	filtered := make([]uint16, 0, len(values))
+	for _, v := range values {
+		if !isGREASE(v) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+// tlsReader is a bounds checked reader of TLS structures.
+type tlsReader struct {
+	data []byte
+	err  error
+}
+
+func (r *tlsReader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = errTLSTruncated
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *tlsReader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *tlsReader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *tlsReader) u24() int {
+	if b := r.bytes(3); b != nil {
+		return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	}
+	return 0
+}
+
+// vector returns a reader over a vector prefixed by a size of n bytes.
+func (r *tlsReader) vector(n int) *tlsReader {
+	size := 0
+	switch n {
+	case 1:
+		size = int(r.u8())
+	case 2:
+		size = int(r.u16())
+	case 3:
+		size = r.u24()
+	}
+	return &tlsReader{data: r.bytes(size), err: r.err}
+}
+
+func (r *tlsReader) u16s() []uint16 {
+	values := make([]uint16, 0, len(r.data)/2)
+	for len(r.data) >= 2 {
+		values = append(values, r.u16())
+	}
+	return values
+}
+
+// tlsHandshake returns the first handshake message found in a stream of TLS
+// records, it returns errTLSTruncated if more data is needed.
+func tlsHandshake(data []byte) (byte, []byte, error) {
+	message := make([]byte, 0)
+	for {
+		if len(data) < 5 {
+			return 0, nil, errTLSTruncated
+		} else if data[0] != tlsRecordHandshake || data[1] != 0x03 {
+			return 0, nil, fmt.Errorf("not a TLS handshake record")
+		}
+
+		size := int(binary.BigEndian.Uint16(data[3:]))
+		if len(data) < 5+size {
+			return 0, nil, errTLSTruncated
+		}
+		message = append(message, data[5:5+size]...)
+		data = data[5+size:]
+
+		// handshake messages can be fragmented over several records
+		if len(message) >= 4 {
+			hsSize := int(message[1])<<16 | int(message[2])<<8 | int(message[3])
+			if len(message) >= 4+hsSize {
+				return message[0], message[4 : 4+hsSize], nil
+			}
+		}
+	}
+}
+
+func (h *TLSHello) parseExtensions(r *tlsReader) {
+	extensions := r.vector(2)
+	for len(extensions.data) >= 4 && extensions.err == nil {
+		kind := extensions.u16()
+		ext := extensions.vector(2)
+		h.Extensions = append(h.Extensions, kind)
+
+		switch kind {
+		case tlsExtServerName:
+			names := ext.vector(2)
+			for len(names.data) > 0 && names.err == nil {
+				nameType := names.u8()
+				name := names.vector(2)
+				if nameType == 0 && name.err == nil {
+					h.ServerName = string(name.data)
+				}
+			}
+		case tlsExtSupportedGroup:
+			h.Groups = ext.vector(2).u16s()
+		case tlsExtPointFormats:
+			h.PointFormats = ext.vector(1).data
+		case tlsExtSignatureAlgs:
+			h.SignatureAlgs = ext.vector(2).u16s()
+		case tlsExtALPN:
+			protos := ext.vector(2)
+			for len(protos.data) > 0 && protos.err == nil {
+				if proto := protos.vector(1); proto.err == nil {
+					h.ALPN = append(h.ALPN, string(proto.data))
+				}
+			}
+		case tlsExtSupportedVers:
+			if len(ext.data) == 2 {
+				// ServerHello, selected version
+				h.SupportedVersions = []uint16{ext.u16()}
+			} else {
+				h.SupportedVersions = ext.vector(1).u16s()
+			}
+		}
+	}
+}
+
+// ParseClientHello parses the body of a ClientHello handshake message.
+func ParseClientHello(data []byte) (*TLSHello, error) {
+	r := &tlsReader{data: data}
+	hello := &TLSHello{Version: r.u16()}
+	// random and session id
+	r.bytes(32)
+	r.vector(1)
+	hello.Ciphers = r.vector(2).u16s()
+	// compression methods
+	r.vector(1)
+	if r.err != nil {
+		return nil, r.err
+	} else if len(r.data) > 0 {
+		hello.parseExtensions(r)
+	}
+	return hello, nil
+}
+
+// ParseServerHello parses the body of a ServerHello handshake message.
+func ParseServerHello(data []byte) (*TLSHello, error) {
+	r := &tlsReader{data: data}
+	hello := &TLSHello{Version: r.u16()}
+	r.bytes(32)
+	r.vector(1)
+	hello.Ciphers = []uint16{r.u16()}
+	// compression method
+	r.u8()
+	if r.err != nil {
+		return nil, r.err
+	} else if len(r.data) > 0 {
+		hello.parseExtensions(r)
+	}
+	return hello, nil
+}
+
+func joinDecimal(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(parts, "-")
+}
+
+func joinHex(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%04x", v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func md5Hex(s string) string {
+	hash := md5.Sum([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+func sha256Prefix(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+// JA3 returns the JA3 string of a ClientHello.
+func (h *TLSHello) JA3() string {
+	formats := make([]uint16, len(h.PointFormats))
+	for i, f := range h.PointFormats {
+		formats[i] = uint16(f)
+	}
+
+	return fmt.Sprintf("%d,%s,%s,%s,%s",
+		h.Version,
+		joinDecimal(withoutGREASE(h.Ciphers)),
+		joinDecimal(withoutGREASE(h.Extensions)),
+		joinDecimal(withoutGREASE(h.Groups)),
+		joinDecimal(formats))
+}
+
+// JA3S returns the JA3S string of a ServerHello.
+func (h *TLSHello) JA3S() string {
+	return fmt.Sprintf("%d,%s,%s",
+		h.Version,
+		joinDecimal(h.Ciphers),
+		joinDecimal(withoutGREASE(h.Extensions)))
+}
+
+func ja4Version(v uint16) string {
+	switch v {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0002:
+		return "s2"
+	case 0xfeff:
+		return "d1"
+	case 0xfefd:
+		return "d2"
+	case 0xfefc:
+		return "d3"
+	}
+	return "00"
+}
+
+func isAlnum(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// JA4 returns the JA4 fingerprint of a ClientHello, transport is 't' for
+// TCP or 'q' for QUIC.
+func (h *TLSHello) JA4(transport byte) string {
+	// the highest version offered in supported_versions, if any
+	version := h.Version
+	if versions := withoutGREASE(h.SupportedVersions); len(versions) > 0 {
+		version = versions[0]
+		for _, v := range versions {
+			if v > version {
+				version = v
+			}
+		}
+	}
+
+	sni := 'i'
+	if h.ServerName != "" {
+		sni = 'd'
+	}
+
+	alpn := "00"
+	if len(h.ALPN) > 0 && h.ALPN[0] != "" {
+		first := h.ALPN[0]
+		a, b := first[0], first[len(first)-1]
+		if isAlnum(a) && isAlnum(b) {
+			alpn = string([]byte{a, b})
+		} else {
+			alpn = fmt.Sprintf("%c%c", hex.EncodeToString([]byte{a})[0], hex.EncodeToString([]byte{b})[1])
+		}
+	}
+
+	ciphers := withoutGREASE(h.Ciphers)
+	extensions := withoutGREASE(h.Extensions)
+
+	count := func(n int) int {
+		if n > 99 {
+			return 99
+		}
+		return n
+	}
+
+	sortedCiphers := append([]uint16{}, ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+
+	sortedExts := make([]uint16, 0, len(extensions))
+	for _, e := range extensions {
+		if e != tlsExtServerName && e != tlsExtALPN {
+			sortedExts = append(sortedExts, e)
+		}
+	}
+	sort.Slice(sortedExts, func(i, j int) bool { return sortedExts[i] < sortedExts[j] })
+
+	extString := joinHex(sortedExts)
+	if len(h.SignatureAlgs) > 0 && extString != "" {
+		extString += "_" + joinHex(withoutGREASE(h.SignatureAlgs))
+	}
+
+	return fmt.Sprintf("%c%s%c%02d%02d%s_%s_%s",
+		transport,
+		ja4Version(version),
+		sni,
+		count(len(ciphers)),
+		count(len(extensions)),
+		alpn,
+		sha256Prefix(joinHex(sortedCiphers)),
+		sha256Prefix(extString))
+}
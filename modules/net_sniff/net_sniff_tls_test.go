@@ -0,0 +1,217 @@
+package net_sniff
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/session"
+)
+
+func tlsVector(size int, data []byte) []byte {
+	prefix := make([]byte, 4)
+	binary.BigEndian.PutUint32(prefix, uint32(len(data)))
+	return append(prefix[4-size:], data...)
+}
+
+func tlsU16s(values ...uint16) []byte {
+	data := make([]byte, len(values)*2)
+	for i, v := range values {
+		binary.BigEndian.PutUint16(data[i*2:], v)
+	}
+	return data
+}
+
+func tlsExtension(kind uint16, data []byte) []byte {
+	return append(tlsU16s(kind), tlsVector(2, data)...)
+}
+
+// tlsRecord wraps a handshake message in a handshake record.
+func tlsRecord(kind byte, body []byte) []byte {
+	message := append([]byte{kind}, tlsVector(3, body)...)
+	return append([]byte{tlsRecordHandshake, 0x03, 0x01}, tlsVector(2, message)...)
+}
+
+func testClientHello() []byte {
+	body := tlsU16s(0x0303)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, tlsVector(1, nil)...)
+	body = append(body, tlsVector(2, tlsU16s(0x0a0a, 0x1301, 0xc02b))...)
+	body = append(body, tlsVector(1, []byte{0})...)
+
+	alpn := append(tlsVector(1, []byte("h2")), tlsVector(1, []byte("http/1.1"))...)
+	sni := append([]byte{0}, tlsVector(2, []byte("example.com"))...)
+
+	extensions := tlsExtension(0x1a1a, nil)
+	extensions = append(extensions, tlsExtension(tlsExtServerName, tlsVector(2, sni))...)
+	extensions = append(extensions, tlsExtension(tlsExtSupportedGroup, tlsVector(2, tlsU16s(0x2a2a, 0x001d, 0x0017)))...)
+	extensions = append(extensions, tlsExtension(tlsExtPointFormats, tlsVector(1, []byte{0}))...)
+	extensions = append(extensions, tlsExtension(tlsExtSignatureAlgs, tlsVector(2, tlsU16s(0x0403, 0x0804)))...)
+	extensions = append(extensions, tlsExtension(tlsExtALPN, tlsVector(2, alpn))...)
+	extensions = append(extensions, tlsExtension(tlsExtSupportedVers, tlsVector(1, tlsU16s(0x3a3a, 0x0304, 0x0303)))...)
+
+	return tlsRecord(tlsClientHello, append(body, tlsVector(2, extensions)...))
+}
+
+func testServerHello() []byte {
+	body := tlsU16s(0x0303)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, tlsVector(1, nil)...)
+	body = append(body, tlsU16s(0x1301)...)
+	body = append(body, 0)
+
+	extensions := tlsExtension(tlsExtSupportedVers, tlsU16s(0x0304))
+	extensions = append(extensions, tlsExtension(0x0033, make([]byte, 36))...)
+
+	record := tlsRecord(tlsServerHello, append(body, tlsVector(2, extensions)...))
+	// change cipher spec
+	return append(record, 0x14, 0x03, 0x03, 0x00, 0x01, 0x01)
+}
+
+func TestTLSFingerprints(t *testing.T) {
+	_, message, err := tlsHandshake(testClientHello())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello, err := ParseClientHello(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hello.ServerName != "example.com" || len(hello.ALPN) != 2 || hello.ALPN[1] != "http/1.1" {
+		t.Errorf("unexpected hello %+v", hello)
+	}
+
+	if ja3 := hello.JA3(); ja3 != "771,4865-49195,0-10-11-13-16-43,29-23,0" {
+		t.Errorf("unexpected ja3 %s", ja3)
+	}
+
+	expected := "t13d0206h2_" + sha256Prefix("1301,c02b") + "_" + sha256Prefix("000a,000b,000d,002b_0403,0804")
+	if ja4 := hello.JA4('t'); ja4 != expected {
+		t.Errorf("expected ja4 %s, got %s", expected, ja4)
+	}
+
+	_, message, err = tlsHandshake(testServerHello())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server, err := ParseServerHello(message); err != nil {
+		t.Fatal(err)
+	} else if ja3s := server.JA3S(); ja3s != "771,4865,43-51" {
+		t.Errorf("unexpected ja3s %s", ja3s)
+	}
+}
+
+func TestTLSJA4NoSNINoALPN(t *testing.T) {
+	hello := &TLSHello{Version: 0x0303, Ciphers: []uint16{0x002f}}
+	if ja4 := hello.JA4('q'); ja4 != "q12i010000_"+sha256Prefix("002f")+"_000000000000" {
+		t.Errorf("unexpected ja4 %s", ja4)
+	}
+}
+
+func TestTLSTruncated(t *testing.T) {
+	record := testClientHello()
+	if _, _, err := tlsHandshake(record[:len(record)-1]); err != errTLSTruncated {
+		t.Errorf("expected truncated error, got %v", err)
+	}
+	if _, err := ParseClientHello([]byte{0x03, 0x03, 0x00}); err == nil {
+		t.Error("expected error for short hello")
+	}
+}
+
+func TestReplaySNIFingerprint(t *testing.T) {
+	hello := testClientHello()
+	events := replayFrames(t,
+		// split over two segments
+		testTCPPacket(t, true, 43000, 443, 1, hello[:20]),
+		testTCPPacket(t, true, 43000, 443, 21, hello[20:]),
+		testTCPPacket(t, false, 443, 43000, 1, testServerHello()))
+
+	found := eventsOf(events, "https")
+	if len(found) != 1 {
+		t.Fatalf("expected 1 https event, got %+v", events)
+	}
+
+	fp, ok := found[0].Data.(TLSFingerprint)
+	if !ok {
+		t.Fatalf("unexpected data type %T", found[0].Data)
+	}
+	if found[0].Destination != "example.com" || fp.ServerName != "example.com" {
+		t.Errorf("unexpected event %+v", found[0])
+	}
+	if fp.JA3Hash != md5Hex(fp.JA3) || fp.JA3S != "771,4865,43-51" || fp.JA4 == "" {
+		t.Errorf("unexpected fingerprint %+v", fp)
+	}
+}
+
+func TestReplaySNIWithoutServerHello(t *testing.T) {
+	events := replayFrames(t, testTCPPacket(t, true, 43001, 8443, 1, testClientHello()))
+
+	found := eventsOf(events, "https")
+	if len(found) != 1 {
+		t.Fatalf("expected 1 https event, got %+v", events)
+	}
+	if found[0].Destination != "example.com:8443" || found[0].Data.(TLSFingerprint).JA3S != "" {
+		t.Errorf("unexpected event %+v", found[0])
+	}
+}
+
+func TestReplaySNIMeta(t *testing.T) {
+	setupTestSession()
+	session.I.Lan.AddIfNew(testClientIP.String(), testClientMAC.String())
+
+	replayFrames(t, testTCPPacket(t, true, 43002, 443, 1, testClientHello()))
+
+	endpoint := session.I.Lan.GetByIp(testClientIP.String())
+	if endpoint == nil {
+		t.Fatal("client endpoint not found")
+	}
+	if ja4, found := endpoint.Meta.Get("tls:ja4").(string); !found || ja4 == "" {
+		t.Errorf("expected tls:ja4 meta, got %v", endpoint.Meta)
+	}
+}
+
+// TestTLSRealClientHello checks the parser against the ClientHello of crypto/tls.
+func TestTLSRealClientHello(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		conn := tls.Client(client, &tls.Config{ServerName: "bettercap.org", NextProtos: []string{"h2"}})
+		conn.SetDeadline(time.Now().Add(time.Second))
+		conn.Handshake()
+		client.Close()
+	}()
+
+	buffer := make([]byte, 0)
+	chunk := make([]byte, 4096)
+	server.SetDeadline(time.Now().Add(time.Second))
+	for {
+		n, err := server.Read(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffer = append(buffer, chunk[:n]...)
+		if kind, message, err := tlsHandshake(buffer); err == nil {
+			if kind != tlsClientHello {
+				t.Fatalf("unexpected handshake type %d", kind)
+			}
+			hello, err := ParseClientHello(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hello.ServerName != "bettercap.org" || len(hello.ALPN) != 1 || hello.ALPN[0] != "h2" {
+				t.Errorf("unexpected hello %+v", hello)
+			}
+			if ja4 := hello.JA4('t'); ja4[:4] != "t13d" || ja4[8:10] != "h2" {
+				t.Errorf("unexpected ja4 %s", ja4)
+			}
+			return
+		} else if err != errTLSTruncated {
+			t.Fatal(err)
+		}
+	}
+}
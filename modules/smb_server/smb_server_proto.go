@@ -0,0 +1,286 @@
+package smb_server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/packets"
+)
+
+const (
+	smb2HeaderSize = 64
+
+	smb2Negotiate    = 0x0000
+	smb2SessionSetup = 0x0001
+	smb2Logoff       = 0x0002
+
+	smb2FlagResponse = 0x00000001
+
+	smb2StatusSuccess         = 0x00000000
+	smb2StatusMoreProcessing  = 0xc0000016
+	smb2StatusAccessDenied    = 0xc0000022
+	smb2StatusNotSupported    = 0xc00000bb
+	smb2DialectWildcard       = 0x02ff
+	smb2NegotiateResponseSize = 65
+
+	smb1Negotiate = 0x72
+
+	// largest message we accept from a client
+	maxMessageSize = 0xffff
+)
+
+var (
+	smb1Magic = []byte{0xff, 'S', 'M', 'B'}
+	smb2Magic = []byte{0xfe, 'S', 'M', 'B'}
+
+	oidSPNEGO  = []byte{0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	oidNTLMSSP = []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+
+	errNotSMB = errors.New("not a SMB message")
+)
+
+// dialects we're willing to speak, by preference, 3.1.1 would require
+// negotiate contexts and preauth integrity
+var smb2Dialects = []uint16{0x0210, 0x0202, 0x0302, 0x0300}
+
+// readMessage reads a message framed by the NetBIOS session service.
+func readMessage(reader *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	size := int(binary.BigEndian.Uint32(header) & 0x00ffffff)
+	if header[0] != 0 || size > maxMessageSize {
+		return nil, fmt.Errorf("unexpected netbios frame (type=%d size=%d)", header[0], size)
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(reader, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// frameMessage prepends the NetBIOS session service header to a message.
+func frameMessage(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+}
+
+// asn1Wrap encodes a DER element with the given tag.
+func asn1Wrap(tag byte, content []byte) []byte {
+	size := len(content)
+	switch {
+	case size < 0x80:
+		return append([]byte{tag, byte(size)}, content...)
+	case size <= 0xff:
+		return append([]byte{tag, 0x81, byte(size)}, content...)
+	default:
+		return append([]byte{tag, 0x82, byte(size >> 8), byte(size)}, content...)
+	}
+}
+
+// spnegoInit creates the SPNEGO NegTokenInit advertising NTLMSSP.
+func spnegoInit() []byte {
+	mechTypes := asn1Wrap(0xa0, asn1Wrap(0x30, asn1Wrap(0x06, oidNTLMSSP)))
+	negTokenInit := asn1Wrap(0xa0, asn1Wrap(0x30, mechTypes))
+	return asn1Wrap(0x60, append(asn1Wrap(0x06, oidSPNEGO), negTokenInit...))
+}
+
+// spnegoChallenge wraps a NTLMSSP CHALLENGE in a SPNEGO NegTokenResp.
+func spnegoChallenge(challenge []byte) []byte {
+	// accept-incomplete
+	negState := asn1Wrap(0xa0, []byte{0x0a, 0x01, 0x01})
+	supportedMech := asn1Wrap(0xa1, asn1Wrap(0x06, oidNTLMSSP))
+	responseToken := asn1Wrap(0xa2, asn1Wrap(0x04, challenge))
+
+	body := append(negState, supportedMech...)
+	body = append(body, responseToken...)
+
+	return asn1Wrap(0xa1, asn1Wrap(0x30, body))
+}
+
+// smbConn is the state of a client connection.
+type smbConn struct {
+	server       *SMBServer
+	challenge    []byte
+	challengeMsg []byte
+	sessionID    uint64
+	dialect      uint16
+}
+
+func smb2Header(command uint16, status uint32, messageID uint64, sessionID uint64) []byte {
+	header := make([]byte, smb2HeaderSize)
+	copy(header, smb2Magic)
+	binary.LittleEndian.PutUint16(header[4:], smb2HeaderSize)
+	binary.LittleEndian.PutUint32(header[8:], status)
+	binary.LittleEndian.PutUint16(header[12:], command)
+	// grant one credit
+	binary.LittleEndian.PutUint16(header[14:], 1)
+	binary.LittleEndian.PutUint32(header[16:], smb2FlagResponse)
+	binary.LittleEndian.PutUint64(header[24:], messageID)
+	binary.LittleEndian.PutUint64(header[40:], sessionID)
+	return header
+}
+
+func smb2Error(command uint16, status uint32, messageID uint64, sessionID uint64) []byte {
+	// structure size 9, no error contexts and no data
+	return append(smb2Header(command, status, messageID, sessionID), 9, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+func (c *smbConn) negotiateResponse(messageID uint64) []byte {
+	blob := spnegoInit()
+
+	body := make([]byte, 64)
+	binary.LittleEndian.PutUint16(body[0:], smb2NegotiateResponseSize)
+	// signing enabled but not required
+	binary.LittleEndian.PutUint16(body[2:], 0x01)
+	binary.LittleEndian.PutUint16(body[4:], c.dialect)
+	copy(body[8:24], c.server.guid)
+	binary.LittleEndian.PutUint32(body[28:], 0x10000)
+	binary.LittleEndian.PutUint32(body[32:], 0x10000)
+	binary.LittleEndian.PutUint32(body[36:], 0x10000)
+	binary.LittleEndian.PutUint64(body[40:], packets.FileTime(time.Now()))
+	binary.LittleEndian.PutUint16(body[56:], smb2HeaderSize+64)
+	binary.LittleEndian.PutUint16(body[58:], uint16(len(blob)))
+
+	msg := append(smb2Header(smb2Negotiate, smb2StatusSuccess, messageID, 0), body...)
+	return append(msg, blob...)
+}
+
+// onSMB1 handles the SMB1 NEGOTIATE sent by clients supporting both versions,
+// answering with a SMB2 one to upgrade the connection.
+func (c *smbConn) onSMB1(msg []byte) ([]byte, error) {
+	if len(msg) < 5 {
+		return nil, fmt.Errorf("SMB1 message too short")
+	} else if msg[4] != smb1Negotiate {
+		return nil, fmt.Errorf("unsupported SMB1 command 0x%02x", msg[4])
+	} else if len(msg) < 35 {
+		return nil, fmt.Errorf("SMB1 negotiate request too short")
+	}
+
+	// dialects are 0x02 prefixed null terminated strings
+	dialects := msg[35:]
+	if bytes.Contains(dialects, []byte("SMB 2.???")) {
+		c.dialect = smb2DialectWildcard
+	} else if bytes.Contains(dialects, []byte("SMB 2.002")) {
+		c.dialect = 0x0202
+	} else {
+		return nil, fmt.Errorf("client only supports SMB1")
+	}
+
+	return c.negotiateResponse(0), nil
+}
+
+func (c *smbConn) onNegotiate(messageID uint64, body []byte) ([]byte, error) {
+	if len(body) < 36 {
+		return nil, fmt.Errorf("negotiate request too short")
+	}
+
+	count := int(binary.LittleEndian.Uint16(body[2:]))
+	if len(body) < 36+count*2 {
+		return nil, fmt.Errorf("negotiate request too short")
+	}
+
+	offered := make(map[uint16]bool)
+	for i := 0; i < count; i++ {
+		offered[binary.LittleEndian.Uint16(body[36+i*2:])] = true
+	}
+
+	c.dialect = 0
+	for _, dialect := range smb2Dialects {
+		if offered[dialect] {
+			c.dialect = dialect
+			break
+		}
+	}
+
+	if c.dialect == 0 {
+		return smb2Error(smb2Negotiate, smb2StatusNotSupported, messageID, 0), fmt.Errorf("no supported dialect")
+	}
+
+	return c.negotiateResponse(messageID), nil
+}
+
+// onSessionSetup completes the NTLM exchange, returning the client response
+// once it authenticates.
+func (c *smbConn) onSessionSetup(messageID uint64, msg []byte) ([]byte, *packets.NTLMChallengeResponseParsed) {
+	body := msg[smb2HeaderSize:]
+	if len(body) < 24 {
+		return smb2Error(smb2SessionSetup, smb2StatusAccessDenied, messageID, c.sessionID), nil
+	}
+
+	offset := int(binary.LittleEndian.Uint16(body[12:]))
+	size := int(binary.LittleEndian.Uint16(body[14:]))
+	if offset+size > len(msg) || offset < smb2HeaderSize {
+		return smb2Error(smb2SessionSetup, smb2StatusAccessDenied, messageID, c.sessionID), nil
+	}
+
+	ntlm := packets.NTLMFind(msg[offset : offset+size])
+	switch packets.NTLMMessageType(ntlm) {
+	case packets.NTLM_NEGOTIATE:
+		c.sessionID = c.server.nextSessionID()
+		c.challengeMsg = packets.NewNTLMChallenge(c.challenge, packets.NTLMNegotiateFlags(ntlm), !c.server.downgrade,
+			c.server.domain, c.server.computer)
+		blob := spnegoChallenge(c.challengeMsg)
+
+		reply := smb2Header(smb2SessionSetup, smb2StatusMoreProcessing, messageID, c.sessionID)
+		reply = binary.LittleEndian.AppendUint16(reply, 9)
+		reply = binary.LittleEndian.AppendUint16(reply, 0)
+		reply = binary.LittleEndian.AppendUint16(reply, smb2HeaderSize+8)
+		reply = binary.LittleEndian.AppendUint16(reply, uint16(len(blob)))
+		return append(reply, blob...), nil
+
+	case packets.NTLM_AUTHENTICATE:
+		if c.challengeMsg == nil {
+			break
+		}
+
+		pair := packets.NTLMChallengeResponse{
+			Challenge: base64.StdEncoding.EncodeToString(c.challengeMsg),
+			Response:  base64.StdEncoding.EncodeToString(ntlm),
+		}
+
+		reply := smb2Error(smb2SessionSetup, smb2StatusAccessDenied, messageID, c.sessionID)
+		if parsed, err := pair.Parsed(); err == nil {
+			return reply, &parsed
+		}
+		return reply, nil
+	}
+
+	return smb2Error(smb2SessionSetup, smb2StatusAccessDenied, messageID, c.sessionID), nil
+}
+
+// process handles a client message, returning the reply and the credentials
+// it contained if any.
+func (c *smbConn) process(msg []byte) ([]byte, *packets.NTLMChallengeResponseParsed, error) {
+	if bytes.HasPrefix(msg, smb1Magic) {
+		reply, err := c.onSMB1(msg)
+		return reply, nil, err
+	} else if !bytes.HasPrefix(msg, smb2Magic) || len(msg) < smb2HeaderSize {
+		return nil, nil, errNotSMB
+	}
+
+	command := binary.LittleEndian.Uint16(msg[12:])
+	messageID := binary.LittleEndian.Uint64(msg[24:])
+
+	switch command {
+	case smb2Negotiate:
+		reply, err := c.onNegotiate(messageID, msg[smb2HeaderSize:])
+		return reply, nil, err
+	case smb2SessionSetup:
+		reply, creds := c.onSessionSetup(messageID, msg)
+		return reply, creds, nil
+	case smb2Logoff:
+		reply := append(smb2Header(smb2Logoff, smb2StatusSuccess, messageID, c.sessionID), 4, 0, 0, 0)
+		return reply, nil, nil
+	}
+
+	return smb2Error(command, smb2StatusAccessDenied, messageID, c.sessionID), nil, nil
+}
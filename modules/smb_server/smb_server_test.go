@@ -0,0 +1,182 @@
+package smb_server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+)
+
+func unicode(s string) []byte {
+	raw := []byte{}
+	for _, c := range utf16.Encode([]rune(s)) {
+		raw = binary.LittleEndian.AppendUint16(raw, c)
+	}
+	return raw
+}
+
+func testServer() *SMBServer {
+	env, _ := session.NewEnvironment("")
+	mod := NewSMBServer(&session.Session{Env: env, Events: session.NewEventPool(false, false)})
+	mod.domain = "CORP"
+	mod.computer = "FS01"
+	mod.guid = make([]byte, 16)
+	mod.challenge = []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}
+	return mod
+}
+
+func smb2Request(command uint16, messageID uint64, body []byte) []byte {
+	header := make([]byte, smb2HeaderSize)
+	copy(header, smb2Magic)
+	binary.LittleEndian.PutUint16(header[4:], smb2HeaderSize)
+	binary.LittleEndian.PutUint16(header[12:], command)
+	binary.LittleEndian.PutUint64(header[24:], messageID)
+	return append(header, body...)
+}
+
+func negotiateRequest(dialects ...uint16) []byte {
+	body := make([]byte, 36)
+	binary.LittleEndian.PutUint16(body[0:], 36)
+	binary.LittleEndian.PutUint16(body[2:], uint16(len(dialects)))
+	for _, d := range dialects {
+		body = binary.LittleEndian.AppendUint16(body, d)
+	}
+	return smb2Request(smb2Negotiate, 0, body)
+}
+
+func sessionSetupRequest(messageID uint64, blob []byte) []byte {
+	body := make([]byte, 24)
+	binary.LittleEndian.PutUint16(body[0:], 25)
+	binary.LittleEndian.PutUint16(body[12:], smb2HeaderSize+24)
+	binary.LittleEndian.PutUint16(body[14:], uint16(len(blob)))
+	return smb2Request(smb2SessionSetup, messageID, append(body, blob...))
+}
+
+func ntlmNegotiate() []byte {
+	msg := append([]byte{}, packets.NTLMSignature...)
+	msg = binary.LittleEndian.AppendUint32(msg, packets.NTLM_NEGOTIATE)
+	msg = binary.LittleEndian.AppendUint32(msg, packets.NTLM_NEGOTIATE_UNICODE|packets.NTLM_NEGOTIATE_EXTENDED_SESSIONSEC)
+	return append(msg, make([]byte, 16)...)
+}
+
+func ntlmAuthenticate(domain, user string, nt []byte) []byte {
+	fields := [][]byte{make([]byte, 24), nt, unicode(domain), unicode(user), unicode("WS01"), nil}
+
+	msg := append([]byte{}, packets.NTLMSignature...)
+	msg = binary.LittleEndian.AppendUint32(msg, packets.NTLM_AUTHENTICATE)
+
+	offset := packets.NTLM_TYPE3_DATA_OFFSET
+	payload := []byte{}
+	for _, field := range fields {
+		msg = binary.LittleEndian.AppendUint16(msg, uint16(len(field)))
+		msg = binary.LittleEndian.AppendUint16(msg, uint16(len(field)))
+		msg = binary.LittleEndian.AppendUint32(msg, uint32(offset))
+		offset += len(field)
+		payload = append(payload, field...)
+	}
+	msg = binary.LittleEndian.AppendUint32(msg, 0)
+	return append(msg, payload...)
+}
+
+func status(reply []byte) uint32 {
+	return binary.LittleEndian.Uint32(reply[8:])
+}
+
+func TestNegotiate(t *testing.T) {
+	conn := testServer().newConn()
+
+	reply, _, err := conn.process(negotiateRequest(0x0202, 0x0210, 0x0311))
+	if err != nil {
+		t.Fatal(err)
+	} else if status(reply) != smb2StatusSuccess || binary.LittleEndian.Uint16(reply[smb2HeaderSize+4:]) != 0x0210 {
+		t.Fatalf("unexpected reply %x", reply)
+	}
+
+	offset := binary.LittleEndian.Uint16(reply[smb2HeaderSize+56:])
+	size := binary.LittleEndian.Uint16(reply[smb2HeaderSize+58:])
+	if int(offset)+int(size) != len(reply) || !bytes.Contains(reply[offset:], oidNTLMSSP) {
+		t.Errorf("unexpected security buffer")
+	}
+
+	if reply, _, err := conn.process(negotiateRequest(0x0311)); err == nil || status(reply) != smb2StatusNotSupported {
+		t.Errorf("expected unsupported dialect error")
+	}
+}
+
+func TestSMB1Negotiate(t *testing.T) {
+	conn := testServer().newConn()
+
+	msg := append([]byte{0xff, 'S', 'M', 'B', smb1Negotiate}, make([]byte, 30)...)
+	msg = append(msg, []byte("\x02NT LM 0.12\x00\x02SMB 2.002\x00\x02SMB 2.???\x00")...)
+
+	reply, _, err := conn.process(msg)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.HasPrefix(reply, smb2Magic) || binary.LittleEndian.Uint16(reply[smb2HeaderSize+4:]) != smb2DialectWildcard {
+		t.Errorf("unexpected reply %x", reply)
+	}
+}
+
+func TestSMB1Truncated(t *testing.T) {
+	conn := testServer().newConn()
+
+	for _, msg := range [][]byte{
+		{0xff, 'S', 'M', 'B'},
+		{0xff, 'S', 'M', 'B', smb1Negotiate},
+		{0xff, 'S', 'M', 'B', 0x73},
+	} {
+		if reply, _, err := conn.process(msg); err == nil || reply != nil {
+			t.Errorf("expected an error for %x", msg)
+		}
+	}
+}
+
+func TestSessionSetup(t *testing.T) {
+	conn := testServer().newConn()
+
+	reply, creds, err := conn.process(sessionSetupRequest(1, spnegoInit()[:4:4]))
+	if err != nil || creds != nil || status(reply) != smb2StatusAccessDenied {
+		t.Fatalf("expected access denied without NTLM, got %x", reply)
+	}
+
+	reply, creds, err = conn.process(sessionSetupRequest(2, append(spnegoInit(), ntlmNegotiate()...)))
+	if err != nil || creds != nil || status(reply) != smb2StatusMoreProcessing {
+		t.Fatalf("expected challenge, got %x", reply)
+	}
+
+	challenge := packets.NTLMFind(reply[smb2HeaderSize+8:])
+	if packets.NTLMMessageType(challenge) != packets.NTLM_CHALLENGE ||
+		!bytes.Equal(challenge[packets.NTLM_TYPE2_CHALLENGE_OFFSET:packets.NTLM_TYPE2_CHALLENGE_OFFSET+8], conn.challenge) {
+		t.Fatalf("unexpected challenge %x", challenge)
+	}
+
+	nt := append(bytes.Repeat([]byte{0xaa}, 16), 0x01, 0x01, 0x00, 0x00)
+	reply, creds, err = conn.process(sessionSetupRequest(3, spnegoChallenge(ntlmAuthenticate("CORP", "alice", nt))))
+	if err != nil || status(reply) != smb2StatusAccessDenied {
+		t.Fatalf("expected access denied, got %x", reply)
+	} else if creds == nil {
+		t.Fatal("expected credentials")
+	} else if creds.HashcatString() != "alice::CORP:1122334455667788:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa:01010000" {
+		t.Errorf("unexpected hash %s", creds.HashcatString())
+	}
+}
+
+func TestFraming(t *testing.T) {
+	msg := negotiateRequest(0x0202)
+	framed := frameMessage(msg)
+
+	got, err := readMessage(bufio.NewReader(bytes.NewReader(framed)))
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, msg) {
+		t.Errorf("unexpected message %x", got)
+	}
+
+	if _, err := readMessage(bufio.NewReader(bytes.NewReader([]byte{0x85, 0, 0, 0}))); err == nil {
+		t.Error("expected error for keepalive frame")
+	}
+}
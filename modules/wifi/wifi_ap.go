@@ -27,7 +27,7 @@ func (mod *WiFiModule) parseApConfig() (err error) {
 	} else if err, mod.apConfig.Encryption = mod.BoolParam("wifi.ap.encryption"); err != nil {
 		return
 	}
-	return
+	return mod.parseKarmaConfig()
 }
 
 func (mod *WiFiModule) startAp() error {
@@ -54,6 +54,12 @@ func (mod *WiFiModule) startAp() error {
 			mod.apConfig.Channel,
 			enc)
 
+		if mod.karmaLoud() {
+			mod.Info("karma mode enabled, answering probe requests and beaconing the SSIDs collected from the stations (loud).")
+		} else if mod.karmaEnabled() {
+			mod.Info("karma mode enabled, answering probe requests for the SSIDs the stations are looking for.")
+		}
+
 		for seqn := uint16(0); mod.Running(); seqn++ {
 			mod.writes.Add(1)
 			defer mod.writes.Done()
@@ -64,6 +70,16 @@ func (mod *WiFiModule) startAp() error {
 				mod.injectPacket(pkt)
 			}
 
+			for _, ssid := range mod.karmaBeacons() {
+				conf := mod.apConfig
+				conf.SSID = ssid
+				if err, pkt := packets.NewDot11Beacon(conf, mod.karma.nextSeq()); err != nil {
+					mod.Error("could not create beacon packet: %s", err)
+				} else {
+					mod.injectPacket(pkt)
+				}
+			}
+
 			time.Sleep(100 * time.Millisecond)
 		}
 	}()
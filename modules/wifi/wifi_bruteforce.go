@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/session"
 	"github.com/evilsocket/islazy/async"
 	"github.com/evilsocket/islazy/ops"
 	"github.com/evilsocket/islazy/str"
@@ -158,6 +159,13 @@ func (mod *WiFiModule) bruteforceWorker(arg async.Job) {
 				Target:   job.essid,
 				Password: job.password,
 			})
+			mod.Session.Credentials.Add(session.Credential{
+				Protocol: "wpa",
+				Module:   mod.Name(),
+				Host:     job.essid,
+				Type:     session.CredentialPassword,
+				Secret:   job.password,
+			})
 			if mod.bruteforce.stop_at_first {
 				// stop if stop_at_first==true
 				job.running.Store(false)
@@ -0,0 +1,326 @@
+package wifi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/ops"
+)
+
+var (
+	errCrackRunning    = errors.New("wifi.crack already running")
+	errCrackNotRunning = errors.New("wifi.crack not running")
+)
+
+const crackProgressPeriod = 5 * time.Second
+
+type CrackSuccess struct {
+	File     string        `json:"file"`
+	AP       string        `json:"ap"`
+	Station  string        `json:"station"`
+	ESSID    string        `json:"essid"`
+	Type     string        `json:"type"`
+	Password string        `json:"password"`
+	Elapsed  time.Duration `json:"elapsed"`
+}
+
+// crackNetwork groups the targets sharing the same ESSID, so that the PMK
+// of each candidate is only derived once for all of them.
+type crackNetwork struct {
+	essid   string
+	targets []*crackTarget
+	cracked atomic.Bool
+}
+
+type crackConfig struct {
+	running  atomic.Bool
+	done     atomic.Uint64
+	cracked  atomic.Uint64
+	todo     uint64
+	workers  int
+	file     string
+	wordlist string
+	networks []*crackNetwork
+	started  time.Time
+}
+
+func NewCrackConfig() *crackConfig {
+	return &crackConfig{
+		workers: 0,
+	}
+}
+
+// isCrackCandidate returns true if the passphrase has a valid WPA length.
+func isCrackCandidate(password string) bool {
+	return len(password) >= 8 && len(password) <= 63
+}
+
+func countCrackCandidates(wordlist string) (uint64, error) {
+	fp, err := os.Open(wordlist)
+	if err != nil {
+		return 0, err
+	}
+	defer fp.Close()
+
+	num := uint64(0)
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		if isCrackCandidate(scanner.Text()) {
+			num++
+		}
+	}
+	return num, scanner.Err()
+}
+
+func (crack *crackConfig) setup(mod *WiFiModule, file string, wordlist string) (err error) {
+	if err, crack.workers = mod.IntParam("wifi.crack.workers"); err != nil {
+		return err
+	} else if crack.file, err = fs.Expand(file); err != nil {
+		return err
+	} else if crack.wordlist, err = fs.Expand(wordlist); err != nil {
+		return err
+	}
+
+	if crack.workers <= 0 {
+		crack.workers = runtime.NumCPU()
+	}
+
+	targets, err := loadCrackTargets(crack.file)
+	if err != nil {
+		return err
+	}
+
+	byESSID := make(map[string]*crackNetwork)
+	crack.networks = make([]*crackNetwork, 0)
+	for _, target := range targets {
+		if target.ESSID == "" {
+			mod.Warning("skipping %s of %s <-> %s, ESSID not found in %s", target.Type, target.AP, target.Station, crack.file)
+			continue
+		}
+
+		bss, found := byESSID[target.ESSID]
+		if !found {
+			bss = &crackNetwork{essid: target.ESSID}
+			byESSID[target.ESSID] = bss
+			crack.networks = append(crack.networks, bss)
+		}
+		bss.targets = append(bss.targets, target)
+	}
+
+	if len(crack.networks) == 0 {
+		return fmt.Errorf("no crackable handshakes or PMKIDs found in %s", crack.file)
+	}
+
+	candidates, err := countCrackCandidates(crack.wordlist)
+	if err != nil {
+		return err
+	} else if candidates == 0 {
+		return fmt.Errorf("no valid WPA passphrases (8 to 63 characters) in %s", crack.wordlist)
+	}
+
+	nNetworks := len(crack.networks)
+	mod.Info("loaded %d target%s for %d network%s from %s, %d candidates from %s",
+		len(targets),
+		ops.Ternary(len(targets) > 1, "s", ""),
+		nNetworks,
+		ops.Ternary(nNetworks > 1, "s", ""),
+		crack.file,
+		candidates,
+		crack.wordlist)
+
+	crack.todo = candidates * uint64(nNetworks)
+	crack.done.Store(0)
+	crack.cracked.Store(0)
+	crack.started = time.Now()
+	crack.running.Store(true)
+
+	return nil
+}
+
+// crackTry verifies a candidate against every network not cracked yet.
+func (mod *WiFiModule) crackTry(crack *crackConfig, password string) {
+	for _, bss := range crack.networks {
+		if !crack.running.Load() {
+			return
+		} else if bss.cracked.Load() {
+			crack.done.Add(1)
+			continue
+		}
+
+		pmk := packets.WPAPMK(password, bss.essid)
+		for _, target := range bss.targets {
+			if target.Check(pmk) {
+				if bss.cracked.CompareAndSwap(false, true) {
+					mod.onCrackSuccess(crack, target, password)
+				}
+				break
+			}
+		}
+		crack.done.Add(1)
+	}
+}
+
+func (mod *WiFiModule) onCrackSuccess(crack *crackConfig, target *crackTarget, password string) {
+	elapsed := time.Since(crack.started)
+
+	mod.Info("found password for %s (%s, %s): %s", target.ESSID, target.AP, target.Type, password)
+
+	mod.Session.Events.Add("wifi.crack.success", CrackSuccess{
+		File:     crack.file,
+		AP:       target.AP.String(),
+		Station:  target.Station.String(),
+		ESSID:    target.ESSID,
+		Type:     target.Type,
+		Password: password,
+		Elapsed:  elapsed,
+	})
+
+	mod.Session.Credentials.Add(session.Credential{
+		Protocol: "wpa",
+		Module:   mod.Name(),
+		Host:     target.ESSID,
+		Type:     session.CredentialPassword,
+		Secret:   password,
+	})
+
+	if err := mod.setPSK(target.ESSID, password); err != nil {
+		mod.Debug("could not set the passphrase of %s: %v", target.ESSID, err)
+	}
+
+	if crack.cracked.Add(1) == uint64(len(crack.networks)) {
+		// nothing left to crack
+		crack.running.Store(false)
+	}
+}
+
+func (mod *WiFiModule) showCrackProgress(crack *crackConfig) {
+	done := crack.done.Load()
+	progress := 100.0 * (float64(done) / float64(crack.todo))
+	mod.State.Store("crack.progress", progress)
+
+	if crack.running.Load() {
+		elapsed := time.Since(crack.started).Seconds()
+		rate := 0.0
+		if elapsed > 0 {
+			rate = float64(done) / elapsed
+		}
+		mod.Info("[%.2f%%] tried %d of %d candidates (%.0f/s), %d of %d networks cracked",
+			progress,
+			done,
+			crack.todo,
+			rate,
+			crack.cracked.Load(),
+			len(crack.networks))
+	}
+}
+
+func (mod *WiFiModule) startCrack(file string, wordlist string) error {
+	// every run gets its own state, so that a stopped one can wind down
+	// while a new one is starting
+	if mod.crack.running.Load() {
+		return errCrackRunning
+	}
+
+	crack := NewCrackConfig()
+	if err := crack.setup(mod, file, wordlist); err != nil {
+		return err
+	}
+	mod.crack = crack
+
+	mod.Info("cracking with %d workers ...", crack.workers)
+
+	go func() {
+		passwords := make(chan string, crack.workers*64)
+		wg := sync.WaitGroup{}
+
+		for i := 0; i < crack.workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for password := range passwords {
+					mod.crackTry(crack, password)
+				}
+			}()
+		}
+
+		go func() {
+			defer close(passwords)
+
+			fp, err := os.Open(crack.wordlist)
+			if err != nil {
+				mod.Error("%v", err)
+				crack.running.Store(false)
+				return
+			}
+			defer fp.Close()
+
+			scanner := bufio.NewScanner(fp)
+			for crack.running.Load() && scanner.Scan() {
+				if line := scanner.Text(); isCrackCandidate(line) {
+					passwords <- line
+				}
+			}
+		}()
+
+		finished := make(chan bool)
+		go func() {
+			wg.Wait()
+			close(finished)
+		}()
+
+		ticker := time.NewTicker(crackProgressPeriod)
+		defer ticker.Stop()
+
+	wait:
+		for {
+			select {
+			case <-ticker.C:
+				mod.showCrackProgress(crack)
+			case <-finished:
+				break wait
+			}
+		}
+
+		stopped := !crack.running.Load() && crack.cracked.Load() < uint64(len(crack.networks))
+		crack.running.Store(false)
+		mod.showCrackProgress(crack)
+
+		cracked := crack.cracked.Load()
+		if stopped {
+			mod.Info("cracking stopped after %s, %d of %d networks cracked", time.Since(crack.started), cracked, len(crack.networks))
+		} else {
+			mod.Info("cracking completed in %s, %d of %d networks cracked", time.Since(crack.started), cracked, len(crack.networks))
+		}
+		if cracked < uint64(len(crack.networks)) {
+			for _, bss := range crack.networks {
+				if !bss.cracked.Load() {
+					mod.Info("password for %s not found", bss.essid)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (mod *WiFiModule) stopCrack() error {
+	if !mod.crack.running.Load() {
+		return errCrackNotRunning
+	}
+
+	mod.Info("stopping cracking ...")
+
+	mod.crack.running.Store(false)
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package wifi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/bettercap/bettercap/v2/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+const (
+	crackTypePMKID = "PMKID"
+	crackTypeEAPOL = "EAPOL"
+)
+
+var (
+	pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+	pmkidOUI    = []byte{0x00, 0x0f, 0xac, 0x04}
+)
+
+// crackTarget is the key material of a single AP and station pair that can
+// be used to verify a passphrase offline.
+type crackTarget struct {
+	Type    string
+	AP      net.HardwareAddr
+	Station net.HardwareAddr
+	ESSID   string
+	// PMKID targets
+	PMKID []byte
+	// EAPOL targets, EAPOL is the raw M2 frame with its MIC zeroed
+	ANonce      []byte
+	SNonce      []byte
+	MIC         []byte
+	EAPOL       []byte
+	Version     uint8
+	MessagePair uint8
+}
+
+// Check returns true if the PMK derived from a candidate passphrase matches
+// the key material of the target.
+func (t *crackTarget) Check(pmk []byte) bool {
+	if t.Type == crackTypePMKID {
+		return bytes.Equal(packets.WPAPMKID(pmk, t.AP, t.Station), t.PMKID)
+	}
+	kck := packets.WPAKCK(pmk, t.AP, t.Station, t.ANonce, t.SNonce, t.Version)
+	return bytes.Equal(packets.WPAMIC(kck, t.EAPOL, t.Version), t.MIC)
+}
+
+type captureSource interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// openCapture opens a pcap or pcapng file.
+func openCapture(fileName string) (*os.File, captureSource, error) {
+	fp, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(fp)
+	magic, err := reader.Peek(4)
+	if err != nil {
+		fp.Close()
+		return nil, nil, fmt.Errorf("could not read %s: %v", fileName, err)
+	}
+
+	var source captureSource
+	if bytes.Equal(magic, pcapngMagic) {
+		source, err = pcapgo.NewNgReader(reader, pcapgo.DefaultNgReaderOptions)
+	} else {
+		source, err = pcapgo.NewReader(reader)
+	}
+
+	if err != nil {
+		fp.Close()
+		return nil, nil, fmt.Errorf("could not parse %s: %v", fileName, err)
+	}
+
+	return fp, source, nil
+}
+
+type eapolFrame struct {
+	key *layers.EAPOLKey
+	raw []byte
+}
+
+type eapolPair struct {
+	ap      net.HardwareAddr
+	sta     net.HardwareAddr
+	anonces []*layers.EAPOLKey
+	m3s     []*layers.EAPOLKey
+	m2s     []eapolFrame
+	pmkids  [][]byte
+}
+
+func (p *eapolPair) addPMKID(pmkid []byte) {
+	for _, known := range p.pmkids {
+		if bytes.Equal(known, pmkid) {
+			return
+		}
+	}
+	p.pmkids = append(p.pmkids, pmkid)
+}
+
+// framePMKID returns the PMKID carried by the key data of a M1 frame, if any.
+func framePMKID(packet gopacket.Packet) []byte {
+	for _, layer := range packet.Layers() {
+		if info, ok := layer.(*layers.Dot11InformationElement); ok {
+			if info.ID == layers.Dot11InformationElementIDVendor && len(info.Info) == 16 && bytes.Equal(info.OUI, pmkidOUI) && !allZeros(info.Info) {
+				return info.Info
+			}
+		}
+	}
+	return nil
+}
+
+// handshakeParser collects the key material of EAPOL frames and PMKIDs fed
+// to it, ESSIDs are taken from beacons, probe responses and association
+// requests.
+type handshakeParser struct {
+	essids map[string]string
+	pairs  map[string]*eapolPair
+	sorted []*eapolPair
+}
+
+func newHandshakeParser() *handshakeParser {
+	return &handshakeParser{
+		essids: make(map[string]string),
+		pairs:  make(map[string]*eapolPair),
+		sorted: make([]*eapolPair, 0),
+	}
+}
+
+// Feed parses a 802.11 frame.
+func (p *handshakeParser) Feed(packet gopacket.Packet) {
+	dot11, ok := packet.Layer(layers.LayerTypeDot11).(*layers.Dot11)
+	if !ok {
+		return
+	}
+
+	switch dot11.Type {
+	case layers.Dot11TypeMgmtBeacon, layers.Dot11TypeMgmtProbeResp, layers.Dot11TypeMgmtAssociationReq, layers.Dot11TypeMgmtReassociationReq:
+		if found, ssid := packets.Dot11ParseIDSSID(packet); found && ssid != "<hidden>" {
+			p.essids[dot11.Address3.String()] = ssid
+		}
+		return
+	}
+
+	ok, key, apMac, staMac := packets.Dot11ParseEAPOL(packet, dot11)
+	if !ok || apMac == nil || staMac == nil {
+		return
+	}
+
+	pairKey := apMac.String() + staMac.String()
+	pair, found := p.pairs[pairKey]
+	if !found {
+		pair = &eapolPair{ap: apMac, sta: staMac}
+		p.pairs[pairKey] = pair
+		p.sorted = append(p.sorted, pair)
+	}
+
+	if !key.Install && key.KeyACK && !key.KeyMIC {
+		// M1
+		pair.anonces = append(pair.anonces, key)
+		if pmkid := framePMKID(packet); pmkid != nil {
+			pair.addPMKID(pmkid)
+		}
+	} else if !key.Install && !key.KeyACK && key.KeyMIC && !allZeros(key.Nonce) {
+		// M2
+		if eapol, ok := packet.Layer(layers.LayerTypeEAPOL).(*layers.EAPOL); ok {
+			raw := packets.WPAZeroMIC(append(append([]byte{}, eapol.Contents...), eapol.Payload...))
+			if raw != nil {
+				pair.m2s = append(pair.m2s, eapolFrame{key: key, raw: raw})
+			}
+		}
+	} else if key.Install && key.KeyACK && key.KeyMIC {
+		// M3
+		pair.m3s = append(pair.m3s, key)
+	}
+}
+
+// Targets returns every PMKID and M2 frame that can be verified, in the
+// order they have been captured, the ESSID is left empty if not found.
+func (p *handshakeParser) Targets() []*crackTarget {
+	targets := make([]*crackTarget, 0)
+	for _, pair := range p.sorted {
+		essid := p.essids[pair.ap.String()]
+		for _, pmkid := range pair.pmkids {
+			targets = append(targets, &crackTarget{
+				Type:    crackTypePMKID,
+				AP:      pair.ap,
+				Station: pair.sta,
+				ESSID:   essid,
+				PMKID:   pmkid,
+			})
+		}
+
+		for _, m2 := range pair.m2s {
+			seen := make(map[string]bool)
+			add := func(anonce []byte, messagePair uint8) {
+				if seen[string(anonce)] {
+					return
+				}
+				seen[string(anonce)] = true
+				targets = append(targets, &crackTarget{
+					Type:        crackTypeEAPOL,
+					AP:          pair.ap,
+					Station:     pair.sta,
+					ESSID:       essid,
+					ANonce:      anonce,
+					SNonce:      m2.key.Nonce,
+					MIC:         m2.key.MIC,
+					EAPOL:       m2.raw,
+					Version:     uint8(m2.key.KeyDescriptorVersion),
+					MessagePair: messagePair,
+				})
+			}
+			// M1+M2 where the replay counters match, then M2+M3
+			for _, m1 := range pair.anonces {
+				if m1.ReplayCounter == m2.key.ReplayCounter {
+					add(m1.Nonce, 0)
+				}
+			}
+			for _, m3 := range pair.m3s {
+				if m3.ReplayCounter == m2.key.ReplayCounter+1 {
+					add(m3.Nonce, 2)
+				}
+			}
+			// some access points don't follow the replay counter rules, fall back
+			// to every ANonce and flag the pair as not replay counter checked
+			if len(seen) == 0 {
+				for _, m1 := range pair.anonces {
+					add(m1.Nonce, 0x80)
+				}
+				for _, m3 := range pair.m3s {
+					add(m3.Nonce, 0x82)
+				}
+			}
+		}
+	}
+
+	return targets
+}
+
+// loadCrackTargets parses a capture file and returns its crackable targets.
+func loadCrackTargets(fileName string) ([]*crackTarget, error) {
+	fp, source, err := openCapture(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	parser := newHandshakeParser()
+	for {
+		data, _, err := source.ReadPacketData()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error while reading %s: %v", fileName, err)
+		}
+
+		parser.Feed(gopacket.NewPacket(data, source.LinkType(), gopacket.Default))
+	}
+
+	return parser.Targets(), nil
+}
@@ -0,0 +1,254 @@
+package wifi
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+const (
+	testCrackESSID    = "bettercap-test"
+	testCrackPassword = "correct horse battery"
+)
+
+var (
+	testCrackAP     = []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	testCrackSTA    = []byte{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+	testCrackANonce = bytes.Repeat([]byte{0xaa}, 32)
+	testCrackSNonce = bytes.Repeat([]byte{0xbb}, 32)
+)
+
+func testEAPOLFrame(t *testing.T, fromAP bool, key *layers.EAPOLKey) []byte {
+	t.Helper()
+
+	dot11 := &layers.Dot11{Type: layers.Dot11TypeData}
+	if fromAP {
+		dot11.Flags = layers.Dot11FlagsFromDS
+		dot11.Address1, dot11.Address2, dot11.Address3 = testCrackSTA, testCrackAP, testCrackAP
+	} else {
+		dot11.Flags = layers.Dot11FlagsToDS
+		dot11.Address1, dot11.Address2, dot11.Address3 = testCrackAP, testCrackSTA, testCrackAP
+	}
+
+	key.KeyDescriptorType = layers.EAPOLKeyDescriptorTypeDot11
+	key.KeyType = layers.EAPOLKeyTypePairwise
+	key.KeyDataLength = uint16(len(key.EncryptedKeyData))
+	if key.IV == nil {
+		key.IV = make([]byte, 16)
+	}
+	if key.MIC == nil {
+		key.MIC = make([]byte, 16)
+	}
+
+	err, raw := packets.Serialize(
+		&layers.RadioTap{},
+		dot11,
+		&layers.LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 3},
+		&layers.SNAP{OrganizationalCode: []byte{0, 0, 0}, Type: layers.EthernetTypeEAPOL},
+		&layers.EAPOL{Version: 1, Type: layers.EAPOLTypeKey, Length: uint16(95 + len(key.EncryptedKeyData))},
+		key,
+	)
+	if err != nil {
+		t.Fatalf("could not serialize EAPOL frame: %v", err)
+	}
+	return raw
+}
+
+// testM2 returns a M2 frame signed with the PMK of the test password.
+func testM2(t *testing.T, version layers.EAPOLKeyDescriptorVersion) []byte {
+	key := &layers.EAPOLKey{
+		KeyDescriptorVersion: version,
+		KeyMIC:               true,
+		ReplayCounter:        1,
+		Nonce:                testCrackSNonce,
+	}
+	unsigned := testEAPOLFrame(t, false, key)
+
+	packet := gopacket.NewPacket(unsigned, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+	eapol := packet.Layer(layers.LayerTypeEAPOL).(*layers.EAPOL)
+	raw := append(append([]byte{}, eapol.Contents...), eapol.Payload...)
+
+	pmk := packets.WPAPMK(testCrackPassword, testCrackESSID)
+	kck := packets.WPAKCK(pmk, testCrackAP, testCrackSTA, testCrackANonce, testCrackSNonce, uint8(version))
+	key.MIC = packets.WPAMIC(kck, raw, uint8(version))
+
+	return testEAPOLFrame(t, false, key)
+}
+
+func testBeacon(t *testing.T) []byte {
+	t.Helper()
+	err, beacon := packets.NewDot11Beacon(packets.Dot11ApConfig{
+		SSID:       testCrackESSID,
+		BSSID:      testCrackAP,
+		Channel:    1,
+		Encryption: true,
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return beacon
+}
+
+func writeTestCapture(t *testing.T, withBeacon bool, frames ...[]byte) string {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "handshakes.pcap")
+	fp, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	if withBeacon {
+		frames = append([][]byte{testBeacon(t)}, frames...)
+	}
+
+	writer := pcapgo.NewWriter(fp)
+	if err := writer.WriteFileHeader(65536, layers.LinkTypeIEEE80211Radio); err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range frames {
+		if err := writer.WritePacket(gopacket.CaptureInfo{
+			Timestamp:     time.Now(),
+			CaptureLength: len(frame),
+			Length:        len(frame),
+		}, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fileName
+}
+
+func testM1(t *testing.T, pmkid []byte) []byte {
+	key := &layers.EAPOLKey{
+		KeyDescriptorVersion: layers.EAPOLKeyDescriptorVersionAESHMACSHA1,
+		KeyACK:               true,
+		ReplayCounter:        1,
+		Nonce:                testCrackANonce,
+	}
+	if pmkid != nil {
+		key.EncryptedKeyData = append([]byte{0xdd, 0x14, 0x00, 0x0f, 0xac, 0x04}, pmkid...)
+	}
+	return testEAPOLFrame(t, true, key)
+}
+
+func TestLoadCrackTargets(t *testing.T) {
+	pmk := packets.WPAPMK(testCrackPassword, testCrackESSID)
+	pmkid := packets.WPAPMKID(pmk, testCrackAP, testCrackSTA)
+
+	fileName := writeTestCapture(t, true, testM1(t, pmkid), testM2(t, layers.EAPOLKeyDescriptorVersionAESHMACSHA1))
+	targets, err := loadCrackTargets(fileName)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+
+	for _, target := range targets {
+		if target.ESSID != testCrackESSID {
+			t.Errorf("%s: expected essid %s, got '%s'", target.Type, testCrackESSID, target.ESSID)
+		} else if !bytes.Equal(target.AP, testCrackAP) || !bytes.Equal(target.Station, testCrackSTA) {
+			t.Errorf("%s: unexpected addresses %s <-> %s", target.Type, target.AP, target.Station)
+		} else if !target.Check(pmk) {
+			t.Errorf("%s: the right PMK did not verify", target.Type)
+		} else if target.Check(packets.WPAPMK("wrong password", testCrackESSID)) {
+			t.Errorf("%s: a wrong PMK verified", target.Type)
+		}
+	}
+
+	if targets[0].Type != crackTypePMKID || targets[1].Type != crackTypeEAPOL {
+		t.Errorf("unexpected targets order %s, %s", targets[0].Type, targets[1].Type)
+	} else if targets[1].MessagePair != 0 {
+		t.Errorf("expected message pair 0, got %d", targets[1].MessagePair)
+	}
+}
+
+func TestLoadCrackTargetsVersions(t *testing.T) {
+	pmk := packets.WPAPMK(testCrackPassword, testCrackESSID)
+	for _, version := range []layers.EAPOLKeyDescriptorVersion{
+		layers.EAPOLKeyDescriptorVersionRC4HMACMD5,
+		layers.EAPOLKeyDescriptorVersionAESHMACSHA1,
+		layers.EAPOLKeyDescriptorVersionAES128CMAC,
+	} {
+		fileName := writeTestCapture(t, true, testM1(t, nil), testM2(t, version))
+		if targets, err := loadCrackTargets(fileName); err != nil {
+			t.Fatal(err)
+		} else if len(targets) != 1 {
+			t.Errorf("version %d: expected 1 target, got %d", version, len(targets))
+		} else if !targets[0].Check(pmk) {
+			t.Errorf("version %d: the right PMK did not verify", version)
+		}
+	}
+}
+
+func TestLoadCrackTargetsWithoutESSID(t *testing.T) {
+	fileName := writeTestCapture(t, false, testM1(t, nil), testM2(t, layers.EAPOLKeyDescriptorVersionAESHMACSHA1))
+	targets, err := loadCrackTargets(fileName)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(targets) != 1 || targets[0].ESSID != "" {
+		t.Fatalf("expected 1 target without essid, got %+v", targets)
+	}
+}
+
+func TestWiFiCrack(t *testing.T) {
+	sess := createMockSession()
+	sess.Credentials = session.NewCredentials(nil)
+	mod := NewWiFiModule(sess)
+
+	fileName := writeTestCapture(t, true, testM1(t, nil), testM2(t, layers.EAPOLKeyDescriptorVersionAESHMACSHA1))
+	wordlist := filepath.Join(t.TempDir(), "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte("short\nwrong password\nanother wrong one\n"+testCrackPassword+"\nnever tried\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mod.stopCrack(); err != errCrackNotRunning {
+		t.Errorf("expected %v, got %v", errCrackNotRunning, err)
+	} else if err := mod.startCrack(fileName, wordlist); err != nil {
+		t.Fatal(err)
+	} else if mod.crack.todo != 4 {
+		t.Errorf("expected 4 candidates, got %d", mod.crack.todo)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for mod.crack.running.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if mod.crack.cracked.Load() != 1 {
+		t.Fatal("password not found")
+	}
+
+	creds := sess.Credentials.List()
+	if len(creds) != 1 || creds[0].Host != testCrackESSID || creds[0].Secret != testCrackPassword {
+		t.Errorf("unexpected credentials %+v", creds)
+	} else if psks := mod.decrypter.PSKs(); psks[testCrackESSID] != testCrackPassword {
+		t.Errorf("cracked passphrase not set for decryption: %v", psks)
+	}
+}
+
+func TestWiFiCrackNoTargets(t *testing.T) {
+	mod := NewWiFiModule(createMockSession())
+
+	fileName := writeTestCapture(t, true)
+	wordlist := filepath.Join(t.TempDir(), "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte(testCrackPassword+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mod.startCrack(fileName, wordlist); err == nil {
+		t.Error("expected an error for a capture without handshakes")
+	} else if mod.crack.running.Load() {
+		t.Error("crack should not be running")
+	}
+}
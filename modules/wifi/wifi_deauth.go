@@ -59,11 +59,19 @@ func (mod *WiFiModule) doDeauthAcquired() bool {
 
 func (mod *WiFiModule) deauthFlowsFor(selector wifiSelector) []wifiTarget {
 	toDeauth := make([]wifiTarget, 0)
+	protected := make(map[string]bool)
 	for _, target := range mod.resolveWiFiTargets(selector, true) {
-		if !mod.skipDeauth(target.apSnapshot.HW) && !mod.skipDeauth(target.clientSnapshot.HW) {
-			toDeauth = append(toDeauth, target)
-		} else {
+		if mod.skipDeauth(target.apSnapshot.HW) || mod.skipDeauth(target.clientSnapshot.HW) {
 			mod.Debug("skipping ap:%v client:%v because skip list %v", target.ap, target.client, mod.deauthSkip)
+		} else if target.apSnapshot.PMF == packets.Dot11PMFRequired {
+			// with 802.11w deauthentication frames must be signed, spoofed ones are dropped
+			if !protected[target.apSnapshot.HwAddress] {
+				protected[target.apSnapshot.HwAddress] = true
+				mod.Warning("skipping AP %s (%s), it requires management frame protection (802.11w) so its clients ignore spoofed deauthentication frames",
+					target.apSnapshot.Hostname, target.apSnapshot.HwAddress)
+			}
+		} else {
+			toDeauth = append(toDeauth, target)
 		}
 	}
 	return toDeauth
@@ -101,7 +109,7 @@ func (mod *WiFiModule) startDeauth(target string) error {
 		if selector.all {
 			return nil
 		}
-		return fmt.Errorf("%q is an unknown BSSID, client or ESSID, is in the deauth skip list, or doesn't have detected clients", selector.raw)
+		return fmt.Errorf("%q is an unknown BSSID, client or ESSID, is in the deauth skip list, requires management frame protection, or doesn't have detected clients", selector.raw)
 	}
 
 	mod.writes.Add(1)
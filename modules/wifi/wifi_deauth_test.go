@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
 )
 
 func deauthFlowPairs(flows []wifiTarget) []string {
@@ -86,6 +87,26 @@ func TestDeauthSkipListStillWinsForESSIDTargets(t *testing.T) {
 	requireDeauthFlows(t, mod, "02:00:00:00:02:02")
 }
 
+func TestDeauthSkipsPMFRequiredAccessPoints(t *testing.T) {
+	mod := newWiFiTargetTestModule(t)
+	if ap, found := mod.Session.WiFi.Get("02:00:00:00:00:01"); !found {
+		t.Fatal("AP not found")
+	} else {
+		ap.SetPMF(packets.Dot11PMFRequired)
+	}
+	if ap, found := mod.Session.WiFi.Get("02:00:00:00:00:02"); !found {
+		t.Fatal("AP not found")
+	} else {
+		// capable only, clients without PMF can still be deauthenticated
+		ap.SetPMF(packets.Dot11PMFCapable)
+	}
+
+	requireDeauthFlows(t, mod, "Corp WiFi",
+		"02:00:00:00:00:02/02:00:00:00:02:01",
+		"02:00:00:00:00:02/02:00:00:00:02:02")
+	requireDeauthFlows(t, mod, "02:00:00:00:01:01")
+}
+
 func TestDeauthUnknownOrClientlessESSIDHasNoFlows(t *testing.T) {
 	mod := newWiFiTargetTestModule(t)
 	for _, target := range []string{"Missing", "Empty"} {
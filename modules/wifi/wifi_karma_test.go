@@ -0,0 +1,154 @@
+package wifi
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func TestKarmaAllowDeny(t *testing.T) {
+	k := NewKarmaConfig()
+	if !k.allowed("home") || k.allowed("") {
+		t.Fatal("expected every non empty SSID to be allowed without lists")
+	}
+
+	var err error
+	if k.allow, err = parseKarmaList("home*, office"); err != nil {
+		t.Fatal(err)
+	} else if k.deny, err = parseKarmaList("home-guest"); err != nil {
+		t.Fatal(err)
+	}
+
+	for ssid, expected := range map[string]bool{
+		"home":       true,
+		"home-5G":    true,
+		"office":     true,
+		"home-guest": false,
+		"airport":    false,
+	} {
+		if got := k.allowed(ssid); got != expected {
+			t.Errorf("%q: expected %v, got %v", ssid, expected, got)
+		}
+	}
+
+	if _, err := parseKarmaList("[home"); err == nil {
+		t.Error("expected error for invalid expression")
+	}
+}
+
+func TestKarmaPreferredNetworks(t *testing.T) {
+	sess := createMockSession()
+	mod := NewWiFiModule(sess)
+
+	sta, _ := net.ParseMAC("66:77:88:99:aa:bb")
+	other, _ := net.ParseMAC("66:77:88:99:aa:cc")
+	mod.karmaProbe(nil, sta, "home")
+	mod.karmaProbe(nil, sta, "office")
+	mod.karmaProbe(nil, sta, "")
+	mod.karmaProbe(nil, other, "airport")
+
+	if got := mod.karma.preferred("66:77:88:99:aa:bb"); !reflect.DeepEqual(got, []string{"home", "office"}) {
+		t.Errorf("unexpected preferred networks %v", got)
+	}
+
+	sess.Env.Set("wifi.ap.karma.deny", "office")
+	if err := mod.parseApConfig(); err != nil {
+		t.Fatal(err)
+	} else if got := mod.karma.collected(); !reflect.DeepEqual(got, []string{"airport", "home"}) {
+		t.Errorf("unexpected collected networks %v", got)
+	} else if beacons := mod.karmaBeacons(); beacons != nil {
+		t.Errorf("expected no beacons outside of loud mode, got %v", beacons)
+	}
+
+	sess.Env.Set("wifi.ap.karma", "true")
+	sess.Env.Set("wifi.ap.mana.loud", "true")
+	sess.Env.Set("wifi.ap.ssid", "home")
+	if err := mod.parseApConfig(); err != nil {
+		t.Fatal(err)
+	} else if beacons := mod.karmaBeacons(); !reflect.DeepEqual(beacons, []string{"airport"}) {
+		t.Errorf("unexpected beacons %v", beacons)
+	}
+	// with more SSIDs than wifi.ap.mana.max they are beaconed in turns
+	for _, ssid := range []string{"cafe", "hotel", "station"} {
+		mod.karma.collect("66:77:88:99:aa:bb", ssid)
+	}
+	sess.Env.Set("wifi.ap.mana.max", "3")
+	if err := mod.parseApConfig(); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range [][]string{
+		{"airport", "cafe", "hotel"},
+		{"station", "airport", "cafe"},
+		{"hotel", "station", "airport"},
+	} {
+		if beacons := mod.karmaBeacons(); !reflect.DeepEqual(beacons, expected) {
+			t.Errorf("expected beacons %v, got %v", expected, beacons)
+		}
+	}
+
+	sess.Env.Set("wifi.ap.mana.max", "0")
+	if err := mod.parseApConfig(); err == nil {
+		t.Error("expected an error with wifi.ap.mana.max 0")
+	}
+}
+
+func TestKarmaLure(t *testing.T) {
+	sess := createMockSession()
+	mod := NewWiFiModule(sess)
+
+	sess.Env.Set("wifi.ap.bssid", "00:11:22:33:44:55")
+	sess.Env.Set("wifi.ap.karma", "true")
+	if err := mod.parseApConfig(); err != nil {
+		t.Fatal(err)
+	}
+	mod.apRunning = true
+
+	sta, _ := net.ParseMAC("66:77:88:99:aa:bb")
+	mod.karmaProbe(nil, sta, "home")
+
+	lure := func(raw []byte) {
+		packet := gopacket.NewPacket(raw, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+		ok, radiotap, dot11 := packets.Dot11Parse(packet)
+		if !ok {
+			t.Fatal("not a 802.11 frame")
+		}
+		mod.karmaLure(radiotap, dot11, packet)
+	}
+
+	// authentication uses the SSID the station was answered with
+	if err, raw := packets.NewDot11Auth(sta, mod.apConfig.BSSID, 0); err != nil {
+		t.Fatal(err)
+	} else {
+		lure(raw)
+	}
+	// association confirms it and is not reported twice
+	if err, raw := packets.NewDot11AssociationRequest(sta, mod.apConfig.BSSID, "home", 1); err != nil {
+		t.Fatal(err)
+	} else {
+		lure(raw)
+	}
+
+	lured := 0
+	for _, e := range sess.Events.Sorted() {
+		if e.Tag == "wifi.client.lured" {
+			lured++
+			if event := e.Data.(LuredEvent); event.Addr != "66:77:88:99:aa:bb" || event.SSID != "home" {
+				t.Errorf("unexpected event %+v", event)
+			}
+		}
+	}
+	if lured != 1 {
+		t.Fatalf("expected 1 lured event, got %d", lured)
+	}
+
+	if pruned := mod.karma.prune(0); len(pruned) != 0 {
+		t.Errorf("lured stations should not be pruned, got %v", pruned)
+	} else if err := mod.showKarma(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,103 @@
+package wifi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/v2/modules/net_sniff"
+	"github.com/bettercap/bettercap/v2/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// setPSK adds the passphrase of a network to the decryption table.
+func (mod *WiFiModule) setPSK(essid string, passphrase string) error {
+	essid = strings.Trim(essid, `"`)
+	if essid == "" {
+		return fmt.Errorf("invalid ESSID")
+	} else if !isCrackCandidate(passphrase) {
+		return fmt.Errorf("WPA passphrases must be 8 to 63 characters long")
+	}
+
+	if derived := mod.decrypter.SetPSK(essid, passphrase); derived > 0 {
+		mod.Info("derived WPA keys of %d stations of %s from the handshakes captured so far", derived, essid)
+	}
+	return nil
+}
+
+func (mod *WiFiModule) showPSKs() error {
+	psks := mod.decrypter.PSKs()
+	if len(psks) == 0 {
+		mod.Info("no WPA passphrases set, use wifi.psk ESSID PASSPHRASE to add one")
+		return nil
+	}
+
+	essids := make([]string, 0, len(psks))
+	for essid := range psks {
+		essids = append(essids, essid)
+	}
+	sort.Strings(essids)
+
+	rows := make([][]string, 0, len(essids))
+	for _, essid := range essids {
+		stations := make([]string, 0)
+		for _, sta := range mod.decrypter.Stations(essid) {
+			stations = append(stations, sta.String())
+		}
+		sort.Strings(stations)
+
+		decrypting := tui.Dim("none")
+		if len(stations) > 0 {
+			decrypting = tui.Green(strings.Join(stations, ", "))
+		}
+		rows = append(rows, []string{essid, tui.Yellow(psks[essid]), decrypting})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, []string{"ESSID", "Passphrase", "Decrypting"}, rows)
+	return nil
+}
+
+// newDecryptStreams creates the TCP reassembler used for decrypted traffic
+// with the net.sniff settings.
+func (mod *WiFiModule) newDecryptStreams() *net_sniff.StreamAssembler {
+	timeout := net_sniff.DefaultStreamTimeout
+	if err, value := mod.Session.Env.GetInt("net.sniff.streams.timeout"); err == nil {
+		timeout = value
+	}
+
+	buffer := net_sniff.DefaultStreamBuffer
+	if err, value := mod.Session.Env.GetInt("net.sniff.streams.buffer"); err == nil {
+		buffer = value
+	}
+
+	mod.decryptVerbose = false
+	if found, value := mod.Session.Env.Get("net.sniff.verbose"); found {
+		mod.decryptVerbose, _ = strconv.ParseBool(value)
+	}
+
+	return net_sniff.NewStreamAssembler(time.Duration(timeout)*time.Second, buffer)
+}
+
+// decryptTraffic learns the keys of the stations from their handshakes and
+// passes the decrypted data frames to the net.sniff parsers.
+func (mod *WiFiModule) decryptTraffic(dot11 *layers.Dot11, packet gopacket.Packet) {
+	if derived, ap, sta := mod.decrypter.Learn(packet, dot11); derived {
+		mod.Info("derived WPA keys for %s <-> %s, decrypting their traffic", ap, sta)
+	}
+
+	if dot11.Type.MainType() != layers.Dot11TypeData || !dot11.Flags.WEP() || !mod.decrypter.HasPSKs() {
+		return
+	}
+
+	if decrypted, err := mod.decrypter.Decrypt(packet, dot11); err == nil {
+		net_sniff.ParsePacket(decrypted, mod.decryptVerbose, mod.decryptStreams)
+	} else if err != packets.ErrDot11NoKey {
+		mod.Debug("could not decrypt frame %s -> %s: %v", dot11.Address2, dot11.Address1, err)
+	}
+}
@@ -0,0 +1,67 @@
+package wifi
+
+import (
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func TestSetPSK(t *testing.T) {
+	mod := NewWiFiModule(createMockSession())
+
+	for _, c := range []struct {
+		essid      string
+		passphrase string
+		valid      bool
+	}{
+		{"home", "short", false},
+		{`""`, "long enough", false},
+		{"home", "long enough", true},
+		{`"my home"`, "with some spaces", true},
+	} {
+		if err := mod.setPSK(c.essid, c.passphrase); (err == nil) != c.valid {
+			t.Errorf("%s/%s: unexpected result %v", c.essid, c.passphrase, err)
+		}
+	}
+
+	psks := mod.decrypter.PSKs()
+	if len(psks) != 2 || psks["home"] != "long enough" || psks["my home"] != "with some spaces" {
+		t.Errorf("unexpected psks %v", psks)
+	} else if err := mod.showPSKs(); err != nil {
+		t.Error(err)
+	}
+
+	mod.decrypter.Clear()
+	if mod.decrypter.HasPSKs() {
+		t.Error("expected no psks after clear")
+	}
+}
+
+func TestSetPSKDerivesCapturedHandshakes(t *testing.T) {
+	mod := NewWiFiModule(createMockSession())
+
+	decode := func(frame []byte) (gopacket.Packet, *layers.Dot11) {
+		packet := gopacket.NewPacket(frame, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+		return packet, packet.Layer(layers.LayerTypeDot11).(*layers.Dot11)
+	}
+
+	// the beacon is needed to know the ESSID of the handshake
+	frames := [][]byte{testM1(t, nil), testM2(t, layers.EAPOLKeyDescriptorVersionAESHMACSHA1)}
+	for _, frame := range frames {
+		packet, dot11 := decode(frame)
+		mod.decryptTraffic(dot11, packet)
+	}
+
+	if err := mod.setPSK(testCrackESSID, testCrackPassword); err != nil {
+		t.Fatal(err)
+	} else if stations := mod.decrypter.Stations(testCrackESSID); len(stations) != 0 {
+		t.Fatalf("no keys expected without the ESSID, got %v", stations)
+	}
+
+	packet, dot11 := decode(testBeacon(t))
+	mod.decryptTraffic(dot11, packet)
+	if stations := mod.decrypter.Stations(testCrackESSID); len(stations) != 1 {
+		t.Fatalf("expected 1 station, got %v", stations)
+	}
+}
@@ -3,6 +3,7 @@ package wifi
 import (
 	"bytes"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/bettercap/bettercap/v2/network"
@@ -53,6 +54,14 @@ func (mod *WiFiModule) stationPruner() {
 				}
 			}
 		}
+		// loop every probing station
+		for _, sta := range mod.karma.prune(maxStaTTL) {
+			mod.Debug("probing station %s not seen in %s, removing.", sta, maxStaTTL)
+		}
+		// and every device clustered from randomized addresses
+		for _, id := range mod.Session.WiFi.Devices().Prune(time.Now()) {
+			mod.Debug("device %s not seen in %s, removing.", id, network.WiFiDeviceTTL)
+		}
 		time.Sleep(1 * time.Second)
 		// refresh
 		maxApTTL = time.Duration(mod.apTTL) * time.Second
@@ -122,6 +131,25 @@ func (mod *WiFiModule) startProbing(staMac net.HardwareAddr, ssid string) error
 	return nil
 }
 
+// probeSSID returns the SSID of a probe request, empty for broadcast ones.
+func probeSSID(req *layers.Dot11MgmtProbeReq) (string, bool) {
+	tot := len(req.Contents)
+	if tot < 3 {
+		return "", false
+	}
+
+	avail := uint32(tot - 2)
+	if avail < 2 {
+		return "", false
+	}
+	size := uint32(req.Contents[1])
+	if size > avail {
+		return "", false
+	}
+
+	return string(req.Contents[2 : 2+size]), true
+}
+
 func (mod *WiFiModule) discoverProbes(radiotap *layers.RadioTap, dot11 *layers.Dot11, packet gopacket.Packet) {
 	if dot11.Type != layers.Dot11TypeMgmtProbeReq {
 		return
@@ -142,34 +170,28 @@ func (mod *WiFiModule) discoverProbes(radiotap *layers.RadioTap, dot11 *layers.D
 		return
 	}
 
-	clientSTA := network.NormalizeMac(dot11.Address2.String())
-	if mod.filterProbeSTA != nil && !mod.filterProbeSTA.MatchString(clientSTA) {
+	apSSID, ok := probeSSID(req)
+	if !ok {
 		return
 	}
 
-	tot := len(req.Contents)
-	if tot < 3 {
-		return
-	}
+	// answer as the network the station is looking for if in karma mode
+	mod.karmaProbe(radiotap, dot11.Address2, apSSID)
+	mod.clusterDevice(dot11, packet)
 
-	avail := uint32(tot - 2)
-	if avail < 2 {
+	clientSTA := network.NormalizeMac(dot11.Address2.String())
+	if mod.filterProbeSTA != nil && !mod.filterProbeSTA.MatchString(clientSTA) {
 		return
-	}
-	size := uint32(req.Contents[1])
-	if size == 0 || size > avail {
+	} else if apSSID == "" {
 		return
-	}
-
-	apSSID := string(req.Contents[2 : 2+size])
-	if mod.filterProbeAP != nil && !mod.filterProbeAP.MatchString(apSSID) {
+	} else if mod.filterProbeAP != nil && !mod.filterProbeAP.MatchString(apSSID) {
 		return
 	}
 
 	mod.Session.Events.Add("wifi.client.probe", ProbeEvent{
 		FromAddr:   clientSTA,
 		FromVendor: network.ManufLookup(clientSTA),
-		FromAlias:  mod.Session.Lan.GetAlias(clientSTA),
+		FromAlias:  mod.Session.WiFi.DeviceAlias(clientSTA),
 		SSID:       apSSID,
 		RSSI:       radioTapValues(radiotap).DBMAntennaSignal,
 	})
@@ -186,6 +208,7 @@ func (mod *WiFiModule) discoverClients(radiotap *layers.RadioTap, dot11 *layers.
 			rssi := radio.DBMAntennaSignal
 
 			if station, isNew := ap.AddClientIfNew(bssid, freq, rssi); isNew {
+				mod.setStationDevice(station)
 				mod.Session.Events.Add("wifi.client.new", ClientEvent{
 					AP:     ap,
 					Client: station,
@@ -195,6 +218,37 @@ func (mod *WiFiModule) discoverClients(radiotap *layers.RadioTap, dot11 *layers.
 	})
 }
 
+// clusterDevice links a probing station to the logical device it belongs
+// to, so that its randomized MAC addresses can be correlated.
+func (mod *WiFiModule) clusterDevice(dot11 *layers.Dot11, packet gopacket.Packet) {
+	ok, fingerprint := packets.Dot11ProbeFingerprint(packet)
+	if !ok {
+		return
+	}
+
+	mac := network.NormalizeMac(dot11.Address2.String())
+	device, linked := mod.Session.WiFi.Devices().Observe(mac, fingerprint, dot11.SequenceNumber, time.Now())
+	if linked {
+		mod.Debug("station %s is device %s, also seen as %s", mac, device.ID, strings.Join(device.MACs[:len(device.MACs)-1], ", "))
+	}
+
+	if station, found := mod.Session.WiFi.GetClient(mac); found {
+		mod.setStationDevice(station)
+	}
+}
+
+// setStationDevice sets the device identifier of a client station and the
+// alias of its device if it has none.
+func (mod *WiFiModule) setStationDevice(station *network.Station) {
+	mac := station.BSSID()
+	if id := mod.Session.WiFi.Devices().ID(mac); id != "" {
+		station.SetDeviceID(id)
+		if alias := mod.Session.WiFi.DeviceAlias(mac); alias != "" {
+			station.SetAlias(alias)
+		}
+	}
+}
+
 func (mod *WiFiModule) discoverDeauths(radiotap *layers.RadioTap, dot11 *layers.Dot11, packet gopacket.Packet) {
 	if dot11.Type != layers.Dot11TypeMgmtDeauthentication {
 		return
@@ -0,0 +1,84 @@
+package wifi
+
+import (
+	"strings"
+
+	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// discoverEAP extracts identities, negotiated methods and crackable
+// challenge/response pairs from the 802.1X exchanges of enterprise networks.
+func (mod *WiFiModule) discoverEAP(radiotap *layers.RadioTap, dot11 *layers.Dot11, packet gopacket.Packet) {
+	if dot11.Type.MainType() != layers.Dot11TypeData || dot11.Flags.WEP() {
+		return
+	}
+
+	ok, info := mod.eapParser.Parse(packet, dot11)
+	if !ok {
+		return
+	}
+
+	apMac := network.NormalizeMac(info.AP.String())
+	staMac := network.NormalizeMac(info.Station.String())
+
+	essid := ""
+	station := (*network.Station)(nil)
+	if ap, found := mod.Session.WiFi.Get(apMac); found {
+		essid = ap.ESSID()
+		station, _ = ap.Get(staMac)
+	}
+
+	// only report what we didn't know yet about the station, methods are
+	// repeated by every fragment of a tunneled exchange so they can only be
+	// reported for known stations
+	newIdentity := info.Identity != ""
+	newMethods := []string{}
+	newHash := info.Hash != ""
+	if station != nil {
+		newIdentity = station.SetEAPIdentity(info.Identity)
+		newMethods = station.AddEAPMethods(info.Methods...)
+		newHash = station.AddEAPHash(info.Hash)
+	}
+
+	if !newIdentity && len(newMethods) == 0 && !newHash {
+		return
+	}
+
+	method := packets.EAPTypeName(info.Type)
+	if info.Identity == "" && station != nil {
+		info.Identity = station.Snapshot().EAP.Identity
+	}
+
+	if newHash {
+		mod.Session.Credentials.Add(session.Credential{
+			Protocol:    "eap-" + strings.ToLower(method),
+			Module:      mod.Name(),
+			Client:      staMac,
+			Host:        apMac,
+			User:        info.Identity,
+			Type:        session.CredentialHash,
+			Secret:      info.Hash,
+			HashcatMode: info.HashcatMode,
+		})
+	} else {
+		info.Hash = ""
+		info.HashcatMode = 0
+	}
+
+	mod.Session.Events.Add("wifi.client.eap", EAPEvent{
+		AP:          apMac,
+		ESSID:       essid,
+		Station:     staMac,
+		Identity:    info.Identity,
+		Method:      method,
+		Methods:     newMethods,
+		Hash:        info.Hash,
+		HashcatMode: info.HashcatMode,
+		RSSI:        radioTapValues(radiotap).DBMAntennaSignal,
+	})
+}
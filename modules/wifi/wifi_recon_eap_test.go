@@ -0,0 +1,107 @@
+package wifi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var (
+	testEAPAP  = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	testEAPSTA = net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+)
+
+func testEAPFrame(t *testing.T, code layers.EAPCode, id uint8, typ layers.EAPType, data []byte) (*layers.RadioTap, *layers.Dot11, gopacket.Packet) {
+	t.Helper()
+
+	dot11 := &layers.Dot11{Type: layers.Dot11TypeData}
+	if code == layers.EAPCodeResponse {
+		dot11.Flags = layers.Dot11FlagsToDS
+		dot11.Address1, dot11.Address2, dot11.Address3 = testEAPAP, testEAPSTA, testEAPAP
+	} else {
+		dot11.Flags = layers.Dot11FlagsFromDS
+		dot11.Address1, dot11.Address2, dot11.Address3 = testEAPSTA, testEAPAP, testEAPAP
+	}
+
+	eap := append([]byte{byte(code), id, 0, 0, byte(typ)}, data...)
+	binary.BigEndian.PutUint16(eap[2:], uint16(len(eap)))
+
+	err, raw := packets.Serialize(
+		&layers.RadioTap{},
+		dot11,
+		&layers.LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 3},
+		&layers.SNAP{OrganizationalCode: []byte{0, 0, 0}, Type: layers.EthernetTypeEAPOL},
+		&layers.EAPOL{Version: 1, Type: layers.EAPOLTypeEAP, Length: uint16(len(eap))},
+		gopacket.Payload(eap),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := gopacket.NewPacket(raw, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+	ok, radiotap, parsed := packets.Dot11Parse(packet)
+	if !ok {
+		t.Fatal("not a 802.11 frame")
+	}
+	return radiotap, parsed, packet
+}
+
+func TestDiscoverEAP(t *testing.T) {
+	sess := createMockSession()
+	sess.Credentials = session.NewCredentials(nil)
+	mod := NewWiFiModule(sess)
+
+	ap, _ := sess.WiFi.AddIfNew("corp", testEAPAP.String(), 2412, -42)
+	station, _ := ap.AddClientIfNew(testEAPSTA.String(), 2412, -55)
+
+	challenge := bytes.Repeat([]byte{0x11}, 8)
+	response := bytes.Repeat([]byte{0x22}, 24)
+	frames := []struct {
+		code layers.EAPCode
+		typ  layers.EAPType
+		data []byte
+	}{
+		{layers.EAPCodeResponse, layers.EAPTypeIdentity, []byte(`CORP\bob`)},
+		// repeated identities are not reported twice
+		{layers.EAPCodeResponse, layers.EAPTypeIdentity, []byte(`CORP\bob`)},
+		{layers.EAPCodeRequest, packets.EAPTypeLEAP, append(append([]byte{1, 0, 8}, challenge...), "bob"...)},
+		{layers.EAPCodeResponse, packets.EAPTypeLEAP, append(append([]byte{1, 0, 24}, response...), `CORP\bob`...)},
+	}
+	for i, frame := range frames {
+		mod.discoverEAP(testEAPFrame(t, frame.code, uint8(i), frame.typ, frame.data))
+	}
+
+	events := make([]EAPEvent, 0)
+	for _, e := range sess.Events.Sorted() {
+		if e.Tag == "wifi.client.eap" {
+			events = append(events, e.Data.(EAPEvent))
+		}
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", events)
+	} else if events[0].Identity != `CORP\bob` || events[0].ESSID != "corp" {
+		t.Errorf("unexpected identity event %+v", events[0])
+	} else if len(events[1].Methods) != 1 || events[1].Methods[0] != "LEAP" || events[1].Hash != "" {
+		t.Errorf("unexpected method event %+v", events[1])
+	} else if events[2].Hash == "" || events[2].HashcatMode != packets.EAPHashcatModeNetNTLMv1 {
+		t.Errorf("unexpected hash event %+v", events[2])
+	}
+
+	if eap := station.Snapshot().EAP; eap == nil || eap.Identity != `CORP\bob` || len(eap.Hashes) != 1 {
+		t.Errorf("unexpected station EAP %+v", eap)
+	}
+
+	creds := sess.Credentials.List()
+	if len(creds) != 1 {
+		t.Fatalf("expected 1 credential, got %+v", creds)
+	} else if creds[0].Protocol != "eap-leap" || creds[0].User != `CORP\bob` || creds[0].Secret != events[2].Hash {
+		t.Errorf("unexpected credential %+v", creds[0])
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/bettercap/bettercap/v2/network"
 
 	"github.com/bettercap/bettercap/v2/packets"
+	"github.com/bettercap/bettercap/v2/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -120,10 +121,11 @@ func (mod *WiFiModule) discoverHandshakes(radiotap *layers.RadioTap, dot11 *laye
 		doSave := numUnsaved > 0
 		if doSave && shakesFileName != "" {
 			mod.Debug("(aggregate %v) saving handshake frames to %s", mod.shakesAggregate, shakesFileName)
-			if err := mod.Session.WiFi.SaveHandshakesTo(shakesFileName, mod.handle.LinkType()); err != nil {
+			if err := mod.saveHandshakes(shakesFileName); err != nil {
 				mod.Error("error while saving handshake frames to %s: %s", shakesFileName, err)
 			}
 		}
+		shakesFileName = mod.handshakesFileFor(shakesFileName)
 
 		// ADDED: PMKID is only valid if it's not nil AND not all zeros
 		validPMKID := rawPMKID != nil && !allZeros(rawPMKID)
@@ -146,6 +148,16 @@ func (mod *WiFiModule) discoverHandshakes(radiotap *layers.RadioTap, dot11 *laye
 			// make sure the info that we have key material for this AP
 			// is persisted even after stations are pruned due to inactivity
 			ap.WithKeyMaterial(true)
+
+			mod.Session.Credentials.Add(session.Credential{
+				Protocol: "wpa",
+				Module:   mod.Name(),
+				Client:   staMac.String(),
+				Host:     apMac.String(),
+				User:     ap.ESSID(),
+				Type:     session.CredentialHandshake,
+				Secret:   shakesFileName,
+			})
 		}
 		// if we added ourselves as a client station but we didn't get any
 		// PMKID, just remove it from the list of clients of this AP.
@@ -201,7 +213,7 @@ func (mod *WiFiModule) discoverHandshakes(radiotap *layers.RadioTap, dot11 *laye
 			shakesFileName := mod.getHandshakeFileFor(targetAP)
 			if shakesFileName != "" {
 				mod.Debug("(aggregate %v) saving handshake frames to %s", mod.shakesAggregate, shakesFileName)
-				if err := mod.Session.WiFi.SaveHandshakesTo(shakesFileName, mod.handle.LinkType()); err != nil {
+				if err := mod.saveHandshakes(shakesFileName); err != nil {
 					mod.Error("error while saving handshake frames to %s: %s", shakesFileName, err)
 				}
 			}
@@ -0,0 +1,55 @@
+package wifi
+
+import (
+	"net"
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func TestClusterDevice(t *testing.T) {
+	sess := createMockSession()
+	mod := NewWiFiModule(sess)
+
+	ap, _ := sess.WiFi.AddIfNew("home", "00:11:22:33:44:55", 2412, -42)
+	station, _ := ap.AddClientIfNew("02:00:00:00:00:02", 2412, -55)
+	sess.Lan.Aliases().Set("02:00:00:00:00:01", "alice-phone")
+
+	// the same phone probing with two randomized addresses
+	for seq, mac := range []string{"02:00:00:00:00:01", "02:00:00:00:00:02"} {
+		hw, _ := net.ParseMAC(mac)
+		err, raw := packets.NewDot11ProbeRequest(hw, uint16(seq+10), "home", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet := gopacket.NewPacket(raw, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+		if ok, radiotap, dot11 := packets.Dot11Parse(packet); !ok {
+			t.Fatal("not a 802.11 frame")
+		} else {
+			mod.discoverProbes(radiotap, dot11, packet)
+		}
+	}
+
+	snapshot := station.Snapshot()
+	if id := sess.WiFi.Devices().ID("02:00:00:00:00:01"); id == "" || snapshot.DeviceID != id {
+		t.Errorf("expected device %q, got %q", id, snapshot.DeviceID)
+	} else if snapshot.Alias != "alice-phone" {
+		t.Errorf("expected the device alias, got %q", snapshot.Alias)
+	}
+
+	probes := 0
+	for _, e := range sess.Events.Sorted() {
+		if e.Tag == "wifi.client.probe" {
+			probes++
+			if probe := e.Data.(ProbeEvent); probe.FromAlias != "alice-phone" {
+				t.Errorf("unexpected probe alias %q", probe.FromAlias)
+			}
+		}
+	}
+	if probes != 2 {
+		t.Errorf("expected 2 probes, got %d", probes)
+	}
+}
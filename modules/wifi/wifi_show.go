@@ -9,6 +9,7 @@ import (
 
 	"github.com/bettercap/bettercap/v2/modules/net_recon"
 	"github.com/bettercap/bettercap/v2/network"
+	"github.com/bettercap/bettercap/v2/packets"
 	"github.com/bettercap/bettercap/v2/session"
 
 	"github.com/dustin/go-humanize"
@@ -62,6 +63,13 @@ func (mod *WiFiModule) getRow(station *network.Station) ([]string, bool) {
 		}
 	}
 
+	// management frame protection, deauth is futile when required
+	if snapshot.PMF == packets.Dot11PMFRequired {
+		encryption += " " + tui.Yellow("PMF")
+	} else if snapshot.PMF == packets.Dot11PMFCapable {
+		encryption += " " + tui.Dim("PMF optional")
+	}
+
 	sent := ops.Ternary(snapshot.Sent > 0, humanize.Bytes(snapshot.Sent), "").(string)
 	recvd := ops.Ternary(snapshot.Received > 0, humanize.Bytes(snapshot.Received), "").(string)
 
@@ -82,6 +90,11 @@ func (mod *WiFiModule) getRow(station *network.Station) ([]string, bool) {
 	}
 
 	if mod.isApSelected() {
+		// the logical device of stations rotating their MAC address
+		if snapshot.DeviceID != "" {
+			bssid = fmt.Sprintf("%s %s", bssid, tui.Dim(snapshot.DeviceID))
+		}
+
 		if mod.showManuf {
 			return []string{
 				rssi,
@@ -4,6 +4,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/bettercap/bettercap/v2/packets"
 )
 
 func TestWiFiShowRowContract(t *testing.T) {
@@ -42,6 +44,27 @@ func TestWiFiShowRowContract(t *testing.T) {
 	}
 }
 
+func TestWiFiShowRowPMF(t *testing.T) {
+	sess := createMockSession()
+	mod := NewWiFiModule(sess)
+	mod.frequencies = []int{2412}
+	mod.minRSSI = -100
+
+	ap, _ := sess.WiFi.AddIfNew("wpa3-network", "02:00:00:00:00:01", 2412, -42)
+	ap.SetEncryption("WPA3", "AES-CCM", "SAE")
+	ap.SetPMF(packets.Dot11PMFRequired)
+
+	row, _ := mod.getRow(ap.Station())
+	if !strings.Contains(row[3], "WPA3 (AES-CCM, SAE)") || !strings.Contains(row[3], "PMF") {
+		t.Errorf("unexpected encryption column %q", row[3])
+	}
+
+	ap.SetPMF(packets.Dot11PMFCapable)
+	if row, _ = mod.getRow(ap.Station()); !strings.Contains(row[3], "PMF optional") {
+		t.Errorf("unexpected encryption column %q", row[3])
+	}
+}
+
 func TestWiFiSelectionFilterAndSortContract(t *testing.T) {
 	sess := createMockSession()
 	mod := NewWiFiModule(sess)
@@ -103,6 +103,7 @@ func TestNewWiFiModule(t *testing.T) {
 		"wifi.txpower",
 		"wifi.handshakes.file",
 		"wifi.handshakes.aggregate",
+		"wifi.handshakes.format",
 		"wifi.ap.ssid",
 		"wifi.ap.bssid",
 		"wifi.ap.channel",
@@ -119,6 +120,7 @@ func TestNewWiFiModule(t *testing.T) {
 		"wifi.bruteforce.wide",
 		"wifi.bruteforce.stop_at_first",
 		"wifi.bruteforce.timeout",
+		"wifi.crack.workers",
 	}
 	for _, param := range params {
 		if !mod.Session.Env.Has(param) {
@@ -138,6 +140,7 @@ func TestNewWiFiModule(t *testing.T) {
 		"wifi.probe BSSID ESSID",
 		"wifi.assoc BSSID",
 		"wifi.ap",
+		"wifi.ap.show",
 		"wifi.show.wps BSSID",
 		"wifi.show",
 		"wifi.recon.channel CHANNEL",
@@ -147,6 +150,15 @@ func TestNewWiFiModule(t *testing.T) {
 		"wifi.fake_auth bssid client",
 		"wifi.bruteforce on",
 		"wifi.bruteforce off",
+		"wifi.crack off",
+		"wifi.crack FILE WORDLIST",
+		"wifi.psk ESSID PASSPHRASE",
+		"wifi.psk.show",
+		"wifi.psk.clear",
+		"wifi.export FORMAT FILE",
+		"wifi.export.stream FORMAT FILE",
+		"wifi.export.stream off",
+		"wifi.handshakes.convert FILE",
 	}
 
 	if len(handlers) != len(expectedHandlers) {
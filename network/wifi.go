@@ -78,6 +78,7 @@ type WiFi struct {
 
 	aliases *data.UnsortedKV
 	aps     map[string]*AccessPoint
+	devices *WiFiDevices
 	iface   *Endpoint
 	newCb   APNewCallback
 	lostCb  APLostCallback
@@ -90,6 +91,7 @@ type wifiJSON struct {
 func NewWiFi(iface *Endpoint, aliases *data.UnsortedKV, newcb APNewCallback, lostcb APLostCallback) *WiFi {
 	return &WiFi{
 		aps:     make(map[string]*AccessPoint),
+		devices: NewWiFiDevices(),
 		aliases: aliases,
 		iface:   iface,
 		newCb:   newcb,
@@ -215,6 +217,41 @@ func (w *WiFi) Clear() {
 	w.mu.Lock()
 	w.aps = make(map[string]*AccessPoint)
 	w.mu.Unlock()
+	w.Devices().Clear()
+}
+
+// Devices returns the logical devices the client stations were clustered in.
+func (w *WiFi) Devices() *WiFiDevices {
+	w.mu.RLock()
+	devices := w.devices
+	w.mu.RUnlock()
+	if devices != nil {
+		return devices
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.devices == nil {
+		w.devices = NewWiFiDevices()
+	}
+	return w.devices
+}
+
+// DeviceAlias returns the alias of mac or, if it has none, the one of the
+// first MAC address of the same device that has an alias.
+func (w *WiFi) DeviceAlias(mac string) string {
+	mac = NormalizeMac(mac)
+	if w.aliases == nil {
+		return ""
+	} else if alias := w.aliases.GetOr(mac, ""); alias != "" {
+		return alias
+	}
+	for _, sibling := range w.Devices().Siblings(mac) {
+		if alias := w.aliases.GetOr(sibling, ""); alias != "" {
+			return alias
+		}
+	}
+	return ""
 }
 
 func (w *WiFi) NumAPs() int {
@@ -237,7 +274,21 @@ func (w *WiFi) NumHandshakes() int {
 	return sum
 }
 
-func (w *WiFi) SaveHandshakesTo(fileName string, linkType layers.LinkType) error {
+// EachUnsavedHandshakePacket calls cb for every handshake frame captured
+// since the last save, the frames are then considered saved.
+func (w *WiFi) EachUnsavedHandshakePacket(cb func(gopacket.Packet)) {
+	for _, ap := range w.List() {
+		for _, station := range ap.Clients() {
+			if handshake := station.Handshake(); handshake.Any() {
+				handshake.EachUnsavedPacket(cb)
+			}
+		}
+	}
+}
+
+// SaveHandshakesTo appends the unsaved handshake frames to a pcapng file,
+// each frame is also passed to the optional onPacket callbacks.
+func (w *WiFi) SaveHandshakesTo(fileName string, linkType layers.LinkType, onPacket ...func(gopacket.Packet)) error {
 	// check if folder exists first
 	dirName := filepath.Dir(fileName)
 	if _, err := os.Stat(dirName); err != nil {
@@ -266,6 +317,9 @@ func (w *WiFi) SaveHandshakesTo(fileName string, linkType layers.LinkType) error
 			if handshake.Any() {
 				err = nil
 				handshake.EachUnsavedPacket(func(pkt gopacket.Packet) {
+					for _, cb := range onPacket {
+						cb(pkt)
+					}
 					if err == nil {
 						ci := pkt.Metadata().CaptureInfo
 						ci.InterfaceIndex = 0
@@ -70,6 +70,7 @@ func (ap *AccessPoint) SetEncryption(enc, cipher, auth string) {
 func (ap *AccessPoint) SetEncryptionIfOpen(enc, cipher, auth string) bool {
 	return ap.Station().SetEncryptionIfOpen(enc, cipher, auth)
 }
+func (ap *AccessPoint) SetPMF(pmf string)     { ap.Station().SetPMF(pmf) }
 func (ap *AccessPoint) Handshake() *Handshake { return ap.Station().Handshake() }
 
 func (ap *AccessPoint) MarshalJSON() ([]byte, error) {
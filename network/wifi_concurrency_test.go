@@ -146,6 +146,63 @@ func TestAccessPointJSONContractAndRoundTrip(t *testing.T) {
 	}
 }
 
+func TestAccessPointPMFJSON(t *testing.T) {
+	aliases, err := data.NewMemUnsortedKV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := NewAccessPoint("wpa3-network", "02:00:00:00:00:01", 2412, -42, aliases)
+	ap.SetEncryption("WPA3", "AES-CCM", "SAE")
+	ap.SetPMF("required")
+
+	raw, err := json.Marshal(ap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := decodeJSONObject(t, raw)
+	if doc["pmf"] != "required" || doc["encryption"] != "WPA3" {
+		t.Fatalf("unexpected AP JSON: %s", raw)
+	}
+
+	var roundTrip AccessPoint
+	if err := json.Unmarshal(raw, &roundTrip); err != nil {
+		t.Fatal(err)
+	} else if roundTrip.Snapshot().PMF != "required" {
+		t.Fatal("pmf was not restored during unmarshal")
+	}
+}
+
+func TestStationEAPJSON(t *testing.T) {
+	station := NewStation("", "02:00:00:00:00:02", 2412, -50)
+	if !station.SetEAPIdentity(`CORP\alice`) || station.SetEAPIdentity(`CORP\alice`) {
+		t.Fatal("expected the identity to change only once")
+	} else if added := station.AddEAPMethods("PEAP", "MSCHAPv2"); len(added) != 2 {
+		t.Fatalf("unexpected methods %v", added)
+	} else if added := station.AddEAPMethods("PEAP"); len(added) != 0 {
+		t.Fatalf("unexpected methods %v", added)
+	} else if !station.AddEAPHash("alice::::00:11") || station.AddEAPHash("alice::::00:11") {
+		t.Fatal("expected the hash to be added only once")
+	}
+
+	raw, err := json.Marshal(station)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roundTrip Station
+	if err := json.Unmarshal(raw, &roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	expected := &StationEAP{
+		Identity: `CORP\alice`,
+		Methods:  []string{"PEAP", "MSCHAPv2"},
+		Hashes:   []string{"alice::::00:11"},
+	}
+	if got := roundTrip.Snapshot().EAP; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %#v, want %#v", got, expected)
+	}
+}
+
 func TestZeroAccessPointJSONIsValid(t *testing.T) {
 	raw, err := json.Marshal(&AccessPoint{})
 	if err != nil {
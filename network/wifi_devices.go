@@ -0,0 +1,190 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// how many frames a station can send between the last probe of a MAC
+	// address and the first one of the next randomized address
+	WiFiDeviceSeqWindow = 256
+	// how long a device is considered for new randomized addresses
+	WiFiDeviceTTL = 10 * time.Minute
+)
+
+// WiFiDevice is a logical device seen with one or more (randomized) MAC
+// addresses, clustered by probe request fingerprint and sequence number
+// continuity.
+type WiFiDevice struct {
+	ID          string    `json:"id"`
+	Fingerprint string    `json:"fingerprint"`
+	MACs        []string  `json:"macs"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	lastSeq     uint16
+}
+
+func (d *WiFiDevice) copy() WiFiDevice {
+	cp := *d
+	cp.MACs = append([]string(nil), d.MACs...)
+	return cp
+}
+
+type WiFiDevices struct {
+	sync.RWMutex
+
+	devices map[string]*WiFiDevice
+	byMAC   map[string]*WiFiDevice
+	counts  map[string]int
+}
+
+func NewWiFiDevices() *WiFiDevices {
+	return &WiFiDevices{
+		devices: make(map[string]*WiFiDevice),
+		byMAC:   make(map[string]*WiFiDevice),
+		counts:  make(map[string]int),
+	}
+}
+
+// seqDelta returns how many frames b is ahead of a, sequence numbers are 12
+// bits and wrap around.
+func seqDelta(a, b uint16) int {
+	return int((b - a) & 0x0fff)
+}
+
+// Observe records a probe request of mac, with the given fingerprint and
+// sequence number, and returns the device it belongs to. A MAC address seen
+// for the first time joins the device with the same fingerprint whose last
+// sequence number it continues, otherwise a new device is created. The
+// boolean is true when mac was linked to an already known device.
+func (d *WiFiDevices) Observe(mac string, fingerprint string, seq uint16, seen time.Time) (WiFiDevice, bool) {
+	mac = NormalizeMac(mac)
+
+	d.Lock()
+	defer d.Unlock()
+
+	if device, found := d.byMAC[mac]; found {
+		device.lastSeq = seq
+		device.LastSeen = seen
+		return device.copy(), false
+	}
+
+	var best *WiFiDevice
+	bestDelta := WiFiDeviceSeqWindow + 1
+	for _, device := range d.devices {
+		if device.Fingerprint != fingerprint || seen.Sub(device.LastSeen) > WiFiDeviceTTL {
+			continue
+		}
+		if delta := seqDelta(device.lastSeq, seq); delta > 0 && delta < bestDelta {
+			best, bestDelta = device, delta
+		}
+	}
+
+	if best != nil {
+		best.MACs = append(best.MACs, mac)
+		best.lastSeq = seq
+		best.LastSeen = seen
+		d.byMAC[mac] = best
+		return best.copy(), true
+	}
+
+	d.counts[fingerprint]++
+	device := &WiFiDevice{
+		ID:          fmt.Sprintf("%.8s-%d", fingerprint, d.counts[fingerprint]),
+		Fingerprint: fingerprint,
+		MACs:        []string{mac},
+		FirstSeen:   seen,
+		LastSeen:    seen,
+		lastSeq:     seq,
+	}
+	d.devices[device.ID] = device
+	d.byMAC[mac] = device
+	return device.copy(), false
+}
+
+// Get returns the device mac belongs to.
+func (d *WiFiDevices) Get(mac string) (WiFiDevice, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	if device, found := d.byMAC[NormalizeMac(mac)]; found {
+		return device.copy(), true
+	}
+	return WiFiDevice{}, false
+}
+
+// ID returns the identifier of the device mac belongs to, or an empty string.
+func (d *WiFiDevices) ID(mac string) string {
+	d.RLock()
+	defer d.RUnlock()
+	if device, found := d.byMAC[NormalizeMac(mac)]; found {
+		return device.ID
+	}
+	return ""
+}
+
+// Siblings returns the other MAC addresses of the device mac belongs to.
+func (d *WiFiDevices) Siblings(mac string) []string {
+	mac = NormalizeMac(mac)
+	siblings := make([]string, 0)
+
+	d.RLock()
+	defer d.RUnlock()
+	if device, found := d.byMAC[mac]; found {
+		for _, other := range device.MACs {
+			if other != mac {
+				siblings = append(siblings, other)
+			}
+		}
+	}
+	return siblings
+}
+
+// List returns the devices sorted by first seen time.
+func (d *WiFiDevices) List() []WiFiDevice {
+	d.RLock()
+	defer d.RUnlock()
+
+	list := make([]WiFiDevice, 0, len(d.devices))
+	for _, device := range d.devices {
+		list = append(list, device.copy())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].FirstSeen.Equal(list[j].FirstSeen) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].FirstSeen.Before(list[j].FirstSeen)
+	})
+	return list
+}
+
+// Prune removes the devices not seen for more than WiFiDeviceTTL, which can't
+// be linked to new addresses anymore, and returns their identifiers. The
+// counters of their fingerprints are kept so that identifiers are never reused.
+func (d *WiFiDevices) Prune(now time.Time) []string {
+	d.Lock()
+	defer d.Unlock()
+
+	pruned := make([]string, 0)
+	for id, device := range d.devices {
+		if now.Sub(device.LastSeen) > WiFiDeviceTTL {
+			for _, mac := range device.MACs {
+				delete(d.byMAC, mac)
+			}
+			delete(d.devices, id)
+			pruned = append(pruned, id)
+		}
+	}
+
+	return pruned
+}
+
+func (d *WiFiDevices) Clear() {
+	d.Lock()
+	defer d.Unlock()
+	d.devices = make(map[string]*WiFiDevice)
+	d.byMAC = make(map[string]*WiFiDevice)
+	d.counts = make(map[string]int)
+}
@@ -0,0 +1,103 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/islazy/data"
+)
+
+func TestWiFiDevicesObserve(t *testing.T) {
+	devices := NewWiFiDevices()
+	now := time.Now()
+
+	first, linked := devices.Observe("02:00:00:00:00:01", "phone", 100, now)
+	if linked {
+		t.Fatal("the first address can't be linked")
+	}
+
+	// same fingerprint, continuing sequence numbers
+	if device, linked := devices.Observe("02:00:00:00:00:02", "phone", 104, now.Add(time.Minute)); !linked || device.ID != first.ID {
+		t.Fatalf("expected %s to be linked, got %+v", first.ID, device)
+	}
+	// the same address again
+	if device, linked := devices.Observe("02:00:00:00:00:02", "phone", 110, now.Add(time.Minute)); linked || device.ID != first.ID {
+		t.Fatalf("unexpected device %+v", device)
+	}
+	// sequence numbers wrapping around
+	devices.Observe("02:00:00:00:00:02", "phone", 4090, now.Add(time.Minute))
+	if device, _ := devices.Observe("02:00:00:00:00:03", "phone", 3, now.Add(time.Minute)); device.ID != first.ID {
+		t.Fatalf("expected wrapped sequence numbers to be linked, got %+v", device)
+	}
+
+	// same model but a different device
+	if device, linked := devices.Observe("02:00:00:00:00:04", "phone", 2000, now.Add(time.Minute)); linked || device.ID == first.ID {
+		t.Fatalf("unexpected device %+v", device)
+	}
+	// different model
+	if device, linked := devices.Observe("02:00:00:00:00:05", "laptop", 4, now.Add(time.Minute)); linked || device.ID == first.ID {
+		t.Fatalf("unexpected device %+v", device)
+	}
+	// not seen for too long
+	if device, linked := devices.Observe("02:00:00:00:00:06", "phone", 5, now.Add(time.Hour)); linked || device.ID == first.ID {
+		t.Fatalf("unexpected device %+v", device)
+	}
+
+	if siblings := devices.Siblings("02:00:00:00:00:02"); !reflect.DeepEqual(siblings, []string{"02:00:00:00:00:01", "02:00:00:00:00:03"}) {
+		t.Errorf("unexpected siblings %v", siblings)
+	} else if n := len(devices.List()); n != 4 {
+		t.Errorf("expected 4 devices, got %d", n)
+	} else if devices.ID("02:00:00:00:00:03") != first.ID || devices.ID("02:00:00:00:00:99") != "" {
+		t.Error("unexpected device identifiers")
+	}
+}
+
+func TestWiFiDevicesPrune(t *testing.T) {
+	devices := NewWiFiDevices()
+	now := time.Now()
+
+	old, _ := devices.Observe("02:00:00:00:00:01", "phone", 100, now)
+	devices.Observe("02:00:00:00:00:02", "phone", 104, now.Add(time.Minute))
+	recent, _ := devices.Observe("02:00:00:00:00:03", "laptop", 1, now.Add(WiFiDeviceTTL))
+
+	if pruned := devices.Prune(now.Add(WiFiDeviceTTL)); len(pruned) != 0 {
+		t.Fatalf("unexpected pruned devices %v", pruned)
+	}
+
+	pruned := devices.Prune(now.Add(WiFiDeviceTTL + 2*time.Minute))
+	if !reflect.DeepEqual(pruned, []string{old.ID}) {
+		t.Fatalf("expected %s to be pruned, got %v", old.ID, pruned)
+	} else if list := devices.List(); len(list) != 1 || list[0].ID != recent.ID {
+		t.Errorf("unexpected devices %+v", list)
+	} else if devices.ID("02:00:00:00:00:01") != "" || devices.ID("02:00:00:00:00:02") != "" {
+		t.Error("addresses of the pruned device still mapped")
+	}
+
+	// identifiers of pruned devices are not reused
+	if device, _ := devices.Observe("02:00:00:00:00:04", "phone", 3000, now.Add(WiFiDeviceTTL+3*time.Minute)); device.ID == old.ID {
+		t.Errorf("identifier %s reused", device.ID)
+	}
+}
+
+func TestWiFiDeviceAlias(t *testing.T) {
+	aliases, err := data.NewMemUnsortedKV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wifi := NewWiFi(buildExampleEndpoint(), aliases, func(ap *AccessPoint) {}, func(ap *AccessPoint) {})
+
+	now := time.Now()
+	wifi.Devices().Observe("02:00:00:00:00:01", "phone", 1, now)
+	wifi.Devices().Observe("02:00:00:00:00:02", "phone", 2, now)
+	aliases.Set("02:00:00:00:00:01", "alice-phone")
+
+	if alias := wifi.DeviceAlias("02:00:00:00:00:02"); alias != "alice-phone" {
+		t.Errorf("unexpected alias %q", alias)
+	}
+
+	wifi.Clear()
+	if alias := wifi.DeviceAlias("02:00:00:00:00:02"); alias != "" {
+		t.Errorf("unexpected alias %q after clear", alias)
+	}
+}
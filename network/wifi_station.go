@@ -37,9 +37,30 @@ type StationSnapshot struct {
 	Encryption     string            `json:"encryption"`
 	Cipher         string            `json:"cipher"`
 	Authentication string            `json:"authentication"`
+	PMF            string            `json:"pmf,omitempty"`
+	EAP            *StationEAP       `json:"eap,omitempty"`
+	DeviceID       string            `json:"device_id,omitempty"`
 	WPS            map[string]string `json:"wps"`
 }
 
+// StationEAP is what was captured of the 802.1X authentication of a station.
+type StationEAP struct {
+	Identity string   `json:"identity,omitempty"`
+	Methods  []string `json:"methods,omitempty"`
+	Hashes   []string `json:"hashes,omitempty"`
+}
+
+func (e *StationEAP) clone() *StationEAP {
+	if e == nil {
+		return nil
+	}
+	return &StationEAP{
+		Identity: e.Identity,
+		Methods:  append([]string(nil), e.Methods...),
+		Hashes:   append([]string(nil), e.Hashes...),
+	}
+}
+
 type Station struct {
 	mu sync.RWMutex
 
@@ -53,6 +74,9 @@ type Station struct {
 	encryption     string
 	cipher         string
 	authentication string
+	pmf            string
+	eap            *StationEAP
+	deviceID       string
 	wps            map[string]string
 	handshake      *Handshake
 }
@@ -69,6 +93,9 @@ type stationFieldsJSON struct {
 	Encryption     string            `json:"encryption"`
 	Cipher         string            `json:"cipher"`
 	Authentication string            `json:"authentication"`
+	PMF            string            `json:"pmf,omitempty"`
+	EAP            *StationEAP       `json:"eap,omitempty"`
+	DeviceID       string            `json:"device_id,omitempty"`
 	WPS            map[string]string `json:"wps"`
 }
 
@@ -82,6 +109,9 @@ func stationFieldsFromSnapshot(snapshot StationSnapshot) stationFieldsJSON {
 		Encryption:     snapshot.Encryption,
 		Cipher:         snapshot.Cipher,
 		Authentication: snapshot.Authentication,
+		PMF:            snapshot.PMF,
+		EAP:            snapshot.EAP,
+		DeviceID:       snapshot.DeviceID,
 		WPS:            snapshot.WPS,
 	}
 }
@@ -147,6 +177,9 @@ func stationFromSnapshot(snapshot StationSnapshot) *Station {
 		encryption:     snapshot.Encryption,
 		cipher:         snapshot.Cipher,
 		authentication: snapshot.Authentication,
+		pmf:            snapshot.PMF,
+		eap:            snapshot.EAP.clone(),
+		deviceID:       snapshot.DeviceID,
 		wps:            cloneWPS(snapshot.WPS),
 		handshake:      NewHandshake(),
 	}
@@ -195,6 +228,9 @@ func (s *Station) snapshotState() (StationSnapshot, bool) {
 		Encryption:     s.encryption,
 		Cipher:         s.cipher,
 		Authentication: s.authentication,
+		PMF:            s.pmf,
+		EAP:            s.eap.clone(),
+		DeviceID:       s.deviceID,
 		WPS:            cloneWPS(s.wps),
 	}, s.hasEndpoint
 }
@@ -323,6 +359,95 @@ func (s *Station) SetEncryptionIfOpen(enc, cipher, auth string) bool {
 	return true
 }
 
+// SetPMF sets the management frame protection support level advertised by
+// the station, either empty, "capable" or "required".
+func (s *Station) SetPMF(pmf string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.pmf = pmf
+	s.mu.Unlock()
+}
+
+// SetDeviceID sets the identifier of the logical device the station was
+// clustered in, see WiFiDevices.
+func (s *Station) SetDeviceID(id string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.deviceID = id
+	s.mu.Unlock()
+}
+
+func (s *Station) eapState() *StationEAP {
+	if s.eap == nil {
+		s.eap = &StationEAP{}
+	}
+	return s.eap
+}
+
+// SetEAPIdentity sets the 802.1X identity of the station, returns true if it
+// changed.
+func (s *Station) SetEAPIdentity(identity string) bool {
+	if s == nil || identity == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	eap := s.eapState()
+	if eap.Identity == identity {
+		return false
+	}
+	eap.Identity = identity
+	return true
+}
+
+// AddEAPMethods records the EAP methods negotiated by the station and returns
+// the ones that were not known yet.
+func (s *Station) AddEAPMethods(methods ...string) []string {
+	added := make([]string, 0)
+	if s == nil {
+		return added
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	eap := s.eapState()
+	for _, method := range methods {
+		known := false
+		for _, m := range eap.Methods {
+			if m == method {
+				known = true
+				break
+			}
+		}
+		if !known {
+			eap.Methods = append(eap.Methods, method)
+			added = append(added, method)
+		}
+	}
+	return added
+}
+
+// AddEAPHash records a crackable EAP challenge/response pair, returns true if
+// it's new.
+func (s *Station) AddEAPHash(hash string) bool {
+	if s == nil || hash == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	eap := s.eapState()
+	for _, h := range eap.Hashes {
+		if h == hash {
+			return false
+		}
+	}
+	eap.Hashes = append(eap.Hashes, hash)
+	return true
+}
+
 func (s *Station) Handshake() *Handshake {
 	if s == nil {
 		return nil
@@ -373,6 +498,9 @@ func (s *Station) UnmarshalJSON(raw []byte) error {
 	s.encryption = replacement.encryption
 	s.cipher = replacement.cipher
 	s.authentication = replacement.authentication
+	s.pmf = replacement.pmf
+	s.eap = replacement.eap
+	s.deviceID = replacement.deviceID
 	s.wps = replacement.wps
 	s.handshake = replacement.handshake
 	s.mu.Unlock()
@@ -0,0 +1,101 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/gopacket/gopacket/layers"
+)
+
+// Web Proxy Auto-Discovery, not defined by gopacket
+const DHCPOptWPAD layers.DHCPOpt = 252
+
+const dhcpBroadcastFlag = 0x8000
+
+// DHCPReplyFor creates the reply of a DHCPv4 server to a client request, the
+// message type and server identifier options are already set.
+func DHCPReplyFor(what layers.DHCPMsgType, to *layers.DHCPv4, server net.IP, yiaddr net.IP) *layers.DHCPv4 {
+	reply := &layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: to.HardwareType,
+		Xid:          to.Xid,
+		Flags:        to.Flags,
+		ClientIP:     net.IPv4zero,
+		YourClientIP: net.IPv4zero,
+		NextServerIP: net.IPv4zero,
+		RelayAgentIP: to.RelayAgentIP,
+		ClientHWAddr: to.ClientHWAddr,
+		Options: layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(what)}),
+			layers.NewDHCPOption(layers.DHCPOptServerID, server.To4()),
+		},
+	}
+
+	if what == layers.DHCPMsgTypeAck && to.ClientIP != nil && !to.ClientIP.IsUnspecified() {
+		reply.ClientIP = to.ClientIP
+	}
+	if yiaddr != nil {
+		reply.YourClientIP = yiaddr
+	}
+	if reply.RelayAgentIP == nil {
+		reply.RelayAgentIP = net.IPv4zero
+	}
+
+	return reply
+}
+
+// DHCPEncodeIPs encodes a list of addresses as the payload of a DHCPv4 option.
+func DHCPEncodeIPs(ips []net.IP) (encoded []byte) {
+	encoded = make([]byte, 0, len(ips)*4)
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			encoded = append(encoded, ip4...)
+		}
+	}
+	return
+}
+
+// DHCPEncodeDuration encodes a time value in seconds as the payload of a DHCPv4 option.
+func DHCPEncodeDuration(d time.Duration) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(d/time.Second))
+}
+
+// NewDHCPReply serializes a DHCPv4 server reply, addressed as described by
+// section 4.1 of RFC 2131.
+func NewDHCPReply(from net.IP, fromHW net.HardwareAddr, req *layers.DHCPv4, reply *layers.DHCPv4) (error, []byte) {
+	dstMAC := req.ClientHWAddr
+	dstIP := reply.YourClientIP
+	isNak := len(reply.Options) > 0 && reply.Options[0].Type == layers.DHCPOptMessageType &&
+		len(reply.Options[0].Data) == 1 && layers.DHCPMsgType(reply.Options[0].Data[0]) == layers.DHCPMsgTypeNak
+
+	if !isNak && req.ClientIP != nil && !req.ClientIP.IsUnspecified() {
+		dstIP = req.ClientIP
+	} else if isNak || req.Flags&dhcpBroadcastFlag != 0 || dstIP == nil || dstIP.IsUnspecified() {
+		dstMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+		dstIP = net.IPv4bcast
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       fromHW,
+		DstMAC:       dstMAC,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolUDP,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    from,
+		DstIP:    dstIP,
+	}
+
+	udp := layers.UDP{
+		SrcPort: 67,
+		DstPort: 68,
+	}
+
+	udp.SetNetworkLayerForChecksum(&ip4)
+
+	return Serialize(&eth, &ip4, &udp, reply)
+}
@@ -0,0 +1,101 @@
+package packets
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func TestDHCPOptWPAD(t *testing.T) {
+	if DHCPOptWPAD != 252 {
+		t.Fatalf("expected '252', got '%v'", DHCPOptWPAD)
+	}
+}
+
+func TestDHCPEncodeIPs(t *testing.T) {
+	got := DHCPEncodeIPs([]net.IP{net.ParseIP("192.168.1.1"), net.ParseIP("::1"), net.IPv4(8, 8, 8, 8)})
+	exp := []byte{192, 168, 1, 1, 8, 8, 8, 8}
+	if !bytes.Equal(got, exp) {
+		t.Fatalf("expected '%v', got '%v'", exp, got)
+	}
+}
+
+func TestDHCPEncodeDuration(t *testing.T) {
+	got := DHCPEncodeDuration(time.Hour)
+	exp := []byte{0x00, 0x00, 0x0e, 0x10}
+	if !bytes.Equal(got, exp) {
+		t.Fatalf("expected '%v', got '%v'", exp, got)
+	}
+}
+
+func TestNewDHCPReply(t *testing.T) {
+	server := net.ParseIP("192.168.1.100").To4()
+	serverHW, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	clientHW, _ := net.ParseMAC("00:11:22:33:44:55")
+	yiaddr := net.ParseIP("192.168.1.50").To4()
+
+	req := &layers.DHCPv4{
+		Operation:    layers.DHCPOpRequest,
+		HardwareType: layers.LinkTypeEthernet,
+		Xid:          0xdeadbeef,
+		ClientIP:     net.IPv4zero,
+		ClientHWAddr: clientHW,
+	}
+
+	tests := []struct {
+		name   string
+		what   layers.DHCPMsgType
+		flags  uint16
+		ciaddr net.IP
+		dstMAC string
+		dstIP  string
+	}{
+		{"offer unicast", layers.DHCPMsgTypeOffer, 0, net.IPv4zero, "00:11:22:33:44:55", "192.168.1.50"},
+		{"offer broadcast", layers.DHCPMsgTypeOffer, 0x8000, net.IPv4zero, "ff:ff:ff:ff:ff:ff", "255.255.255.255"},
+		{"renew", layers.DHCPMsgTypeAck, 0, net.ParseIP("192.168.1.50"), "00:11:22:33:44:55", "192.168.1.50"},
+		{"nak", layers.DHCPMsgTypeNak, 0, net.ParseIP("192.168.1.50"), "ff:ff:ff:ff:ff:ff", "255.255.255.255"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req.Flags = test.flags
+			req.ClientIP = test.ciaddr
+
+			ip := yiaddr
+			if test.what == layers.DHCPMsgTypeNak {
+				ip = nil
+			}
+
+			reply := DHCPReplyFor(test.what, req, server, ip)
+			err, raw := NewDHCPReply(server, serverHW, req, reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+			eth := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+			ip4 := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+			dhcp, ok := pkt.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4)
+			if !ok {
+				t.Fatal("expected a DHCPv4 layer")
+			}
+
+			if eth.DstMAC.String() != test.dstMAC || ip4.DstIP.String() != test.dstIP {
+				t.Errorf("unexpected destination %s %s", eth.DstMAC, ip4.DstIP)
+			}
+			if dhcp.Operation != layers.DHCPOpReply || dhcp.Xid != req.Xid || !bytes.Equal(dhcp.ClientHWAddr, clientHW) {
+				t.Errorf("unexpected reply %+v", dhcp)
+			}
+			if dhcp.Options[0].Type != layers.DHCPOptMessageType || layers.DHCPMsgType(dhcp.Options[0].Data[0]) != test.what {
+				t.Errorf("unexpected message type %v", dhcp.Options[0])
+			}
+			if dhcp.Options[1].Type != layers.DHCPOptServerID || !net.IP(dhcp.Options[1].Data).Equal(server) {
+				t.Errorf("unexpected server id %v", dhcp.Options[1])
+			}
+		})
+	}
+}
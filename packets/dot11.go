@@ -60,7 +60,9 @@ func emptyRadioTap() *layers.RadioTap {
 	}
 }
 
-func NewDot11Beacon(conf Dot11ApConfig, seq uint16, extendDot11Info ...*layers.Dot11InformationElement) (error, []byte) {
+// dot11ApElements returns the capability flags and the information elements
+// advertised by a fake access point in its beacons and probe responses.
+func dot11ApElements(conf Dot11ApConfig, extendDot11Info []*layers.Dot11InformationElement) (uint16, []gopacket.SerializableLayer) {
 	flags := openFlags
 	if conf.Encryption {
 		flags = wpaFlags
@@ -68,6 +70,26 @@ func NewDot11Beacon(conf Dot11ApConfig, seq uint16, extendDot11Info ...*layers.D
 	if conf.SpectrumManagement {
 		flags |= specManFlag
 	}
+	elements := []gopacket.SerializableLayer{
+		Dot11Info(layers.Dot11InformationElementIDSSID, []byte(conf.SSID)),
+		Dot11Info(layers.Dot11InformationElementIDRates, fakeApRates),
+		Dot11Info(layers.Dot11InformationElementIDDSSet, []byte{byte(conf.Channel & 0xff)}),
+	}
+	for _, v := range extendDot11Info {
+		elements = append(elements, v)
+	}
+	if conf.Encryption {
+		elements = append(elements, &layers.Dot11InformationElement{
+			ID:     layers.Dot11InformationElementIDRSNInfo,
+			Length: uint8(len(fakeApWpaRSN) & 0xff),
+			Info:   fakeApWpaRSN,
+		})
+	}
+	return uint16(flags), elements
+}
+
+func NewDot11Beacon(conf Dot11ApConfig, seq uint16, extendDot11Info ...*layers.Dot11InformationElement) (error, []byte) {
+	flags, elements := dot11ApElements(conf, extendDot11Info)
 	stack := []gopacket.SerializableLayer{
 		emptyRadioTap(),
 		&layers.Dot11{
@@ -78,25 +100,34 @@ func NewDot11Beacon(conf Dot11ApConfig, seq uint16, extendDot11Info ...*layers.D
 			SequenceNumber: seq,
 		},
 		&layers.Dot11MgmtBeacon{
-			Flags:    uint16(flags),
+			Flags:    flags,
 			Interval: 100,
 		},
-		Dot11Info(layers.Dot11InformationElementIDSSID, []byte(conf.SSID)),
-		Dot11Info(layers.Dot11InformationElementIDRates, fakeApRates),
-		Dot11Info(layers.Dot11InformationElementIDDSSet, []byte{byte(conf.Channel & 0xff)}),
-	}
-	for _, v := range extendDot11Info {
-		stack = append(stack, v)
 	}
-	if conf.Encryption {
-		stack = append(stack, &layers.Dot11InformationElement{
-			ID:     layers.Dot11InformationElementIDRSNInfo,
-			Length: uint8(len(fakeApWpaRSN) & 0xff),
-			Info:   fakeApWpaRSN,
-		})
+
+	return Serialize(append(stack, elements...)...)
+}
+
+// NewDot11ProbeResponse answers the probe request of the station dst as the
+// access point described by conf.
+func NewDot11ProbeResponse(conf Dot11ApConfig, dst net.HardwareAddr, seq uint16, extendDot11Info ...*layers.Dot11InformationElement) (error, []byte) {
+	flags, elements := dot11ApElements(conf, extendDot11Info)
+	stack := []gopacket.SerializableLayer{
+		emptyRadioTap(),
+		&layers.Dot11{
+			Address1:       dst,
+			Address2:       conf.BSSID,
+			Address3:       conf.BSSID,
+			Type:           layers.Dot11TypeMgmtProbeResp,
+			SequenceNumber: seq,
+		},
+		&layers.Dot11MgmtProbeResp{
+			Flags:    flags,
+			Interval: 100,
+		},
 	}
 
-	return Serialize(stack...)
+	return Serialize(append(stack, elements...)...)
 }
 
 func NewDot11ProbeRequest(staMac net.HardwareAddr, seq uint16, ssid string, channel int) (error, []byte) {
@@ -232,16 +263,81 @@ func Dot11ParseIDSSID(packet gopacket.Packet) (bool, string) {
 	return false, ""
 }
 
-func Dot11ParseEncryption(packet gopacket.Packet, dot11 *layers.Dot11) (bool, string, string, string) {
+// management frame protection (802.11w) support levels
+const (
+	Dot11PMFCapable  = "capable"
+	Dot11PMFRequired = "required"
+)
+
+// Dot11Security is the security configuration advertised by a station.
+type Dot11Security struct {
+	Encryption     string
+	Cipher         string
+	Authentication string
+	PMF            string
+}
+
+func isSAEAuth(auth Dot11AuthType) bool {
+	return auth == 8 || auth == 9 || auth == 24 || auth == 25
+}
+
+func isPSKAuth(auth Dot11AuthType) bool {
+	return auth == 2 || auth == 4 || auth == 6 || auth == 19 || auth == 20
+}
+
+// rsnSecurity classifies a RSN element as WPA2, WPA3 (SAE), WPA2/WPA3
+// transition mode or OWE.
+func rsnSecurity(rsn RSNInfo) (sec Dot11Security) {
 	var i uint16
-	enc := ""
-	cipher := ""
-	auth := ""
+	sec.Encryption = "WPA2"
+	for i = 0; i < rsn.Pairwise.Count; i++ {
+		sec.Cipher = rsn.Pairwise.Suites[i].Type.String()
+	}
+
+	sae, psk, owe := false, false, false
+	for i = 0; i < rsn.AuthKey.Count; i++ {
+		// https://balramdot11b.com/2020/11/08/wpa3-deep-dive/
+		auth := rsn.AuthKey.Suites[i].Type
+		if isSAEAuth(auth) {
+			sae = true
+		} else {
+			psk = psk || isPSKAuth(auth)
+			owe = owe || auth == 18
+			sec.Authentication = auth.String()
+		}
+	}
+
+	if sae && psk {
+		sec.Encryption = "WPA2/WPA3"
+		sec.Authentication = "SAE/PSK"
+	} else if sae {
+		sec.Encryption = "WPA3"
+		sec.Authentication = "SAE"
+	} else if owe {
+		sec.Encryption = "OWE"
+		sec.Authentication = "OWE"
+	}
+
+	if rsn.MFPRequired() {
+		sec.PMF = Dot11PMFRequired
+	} else if rsn.MFPCapable() {
+		sec.PMF = Dot11PMFCapable
+	}
+
+	return
+}
+
+// Dot11ParseSecurity parses the encryption, cipher, authentication and
+// management frame protection advertised by the information elements of a
+// frame.
+func Dot11ParseSecurity(packet gopacket.Packet, dot11 *layers.Dot11) (bool, Dot11Security) {
+	var i uint16
+	sec := Dot11Security{}
 	found := false
 
 	if dot11.Flags.WEP() {
 		found = true
-		enc = "WEP"
+		sec.Encryption = "WEP"
 	}
 
 	for _, layer := range packet.Layers() {
@@ -250,31 +346,19 @@ func Dot11ParseEncryption(packet gopacket.Packet, dot11 *layers.Dot11) (bool, st
 			if ok {
 				found = true
 				if info.ID == layers.Dot11InformationElementIDRSNInfo {
-					enc = "WPA2"
-					rsn, err := Dot11InformationElementRSNInfoDecode(info.Info)
-					if err == nil {
-						for i = 0; i < rsn.Pairwise.Count; i++ {
-							cipher = rsn.Pairwise.Suites[i].Type.String()
-						}
-						for i = 0; i < rsn.AuthKey.Count; i++ {
-							// https://balramdot11b.com/2020/11/08/wpa3-deep-dive/
-							if rsn.AuthKey.Suites[i].Type == 8 {
-								auth = "SAE"
-								enc = "WPA3"
-							} else {
-								auth = rsn.AuthKey.Suites[i].Type.String()
-							}
-						}
+					sec.Encryption = "WPA2"
+					if rsn, err := Dot11InformationElementRSNInfoDecode(info.Info); err == nil {
+						sec = rsnSecurity(rsn)
 					}
-				} else if enc == "" && info.ID == layers.Dot11InformationElementIDVendor && info.Length >= 8 && bytes.Equal(info.OUI, wpaSignatureBytes) && bytes.HasPrefix(info.Info, []byte{1, 0}) {
-					enc = "WPA"
+				} else if sec.Encryption == "" && info.ID == layers.Dot11InformationElementIDVendor && info.Length >= 8 && bytes.Equal(info.OUI, wpaSignatureBytes) && bytes.HasPrefix(info.Info, []byte{1, 0}) {
+					sec.Encryption = "WPA"
 					vendor, err := Dot11InformationElementVendorInfoDecode(info.Info)
 					if err == nil {
 						for i = 0; i < vendor.Unicast.Count; i++ {
-							cipher = vendor.Unicast.Suites[i].Type.String()
+							sec.Cipher = vendor.Unicast.Suites[i].Type.String()
 						}
 						for i = 0; i < vendor.AuthKey.Count; i++ {
-							auth = vendor.AuthKey.Suites[i].Type.String()
+							sec.Authentication = vendor.AuthKey.Suites[i].Type.String()
 						}
 					}
 				}
@@ -282,12 +366,16 @@ func Dot11ParseEncryption(packet gopacket.Packet, dot11 *layers.Dot11) (bool, st
 		}
 	}
 
-	if found && enc == "" {
-		enc = "OPEN"
+	if found && sec.Encryption == "" {
+		sec.Encryption = "OPEN"
 	}
 
-	return found, enc, cipher, auth
+	return found, sec
+}
 
+func Dot11ParseEncryption(packet gopacket.Packet, dot11 *layers.Dot11) (bool, string, string, string) {
+	found, sec := Dot11ParseSecurity(packet, dot11)
+	return found, sec.Encryption, sec.Cipher, sec.Authentication
 }
 
 func Dot11IsDataFor(dot11 *layers.Dot11, station net.HardwareAddr) bool {
@@ -0,0 +1,377 @@
+package packets
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var (
+	ErrDot11NotEncrypted = errors.New("not an encrypted data frame")
+	ErrDot11Unsupported  = errors.New("unsupported encryption")
+	ErrDot11NoKey        = errors.New("no key for this frame")
+	ErrDot11NotLLC       = errors.New("decrypted payload is not LLC/SNAP")
+
+	rsnOUI = []byte{0x00, 0x0f, 0xac}
+)
+
+// dot11Station holds the handshake state and the derived keys of a single
+// access point and station pair.
+type dot11Station struct {
+	ap      net.HardwareAddr
+	sta     net.HardwareAddr
+	anonces [][]byte
+	snonce  []byte
+	m2      []byte
+	m2MIC   []byte
+	version uint8
+	essid   string
+	ptk     []byte
+}
+
+// Dot11Decrypter derives the session keys of WPA/WPA2-PSK stations from the
+// 4-way handshakes it observes and uses them to decrypt CCMP and TKIP data
+// frames, it is safe for concurrent use.
+type Dot11Decrypter struct {
+	sync.Mutex
+
+	psks     map[string]string
+	pmks     map[string][]byte
+	essids   map[string]string
+	stations map[string]*dot11Station
+	gtks     map[string]map[uint8][]byte
+}
+
+func NewDot11Decrypter() *Dot11Decrypter {
+	return &Dot11Decrypter{
+		psks:     make(map[string]string),
+		pmks:     make(map[string][]byte),
+		essids:   make(map[string]string),
+		stations: make(map[string]*dot11Station),
+		gtks:     make(map[string]map[uint8][]byte),
+	}
+}
+
+// SetPSK sets the passphrase of a network and returns the number of stations
+// whose keys could be derived from handshakes already observed.
+func (d *Dot11Decrypter) SetPSK(essid string, passphrase string) int {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.psks[essid] != passphrase {
+		d.psks[essid] = passphrase
+		delete(d.pmks, essid)
+	}
+
+	derived := 0
+	for _, st := range d.stations {
+		if st.ptk == nil && d.derive(st) {
+			derived++
+		}
+	}
+	return derived
+}
+
+// PSKs returns a copy of the ESSID to passphrase table.
+func (d *Dot11Decrypter) PSKs() map[string]string {
+	d.Lock()
+	defer d.Unlock()
+
+	psks := make(map[string]string, len(d.psks))
+	for essid, passphrase := range d.psks {
+		psks[essid] = passphrase
+	}
+	return psks
+}
+
+// HasPSKs returns true if at least one passphrase is set.
+func (d *Dot11Decrypter) HasPSKs() bool {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.psks) > 0
+}
+
+// Clear removes every passphrase and derived key, observed handshakes are
+// kept so that keys can be derived again once a passphrase is set.
+func (d *Dot11Decrypter) Clear() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.psks = make(map[string]string)
+	d.pmks = make(map[string][]byte)
+	d.gtks = make(map[string]map[uint8][]byte)
+	for _, st := range d.stations {
+		st.ptk = nil
+	}
+}
+
+// Stations returns the stations of a network whose keys have been derived.
+func (d *Dot11Decrypter) Stations(essid string) []net.HardwareAddr {
+	d.Lock()
+	defer d.Unlock()
+
+	stations := make([]net.HardwareAddr, 0)
+	for _, st := range d.stations {
+		if st.ptk != nil && st.essid == essid {
+			stations = append(stations, st.sta)
+		}
+	}
+	return stations
+}
+
+func (d *Dot11Decrypter) pmkFor(essid string) []byte {
+	passphrase, found := d.psks[essid]
+	if !found {
+		return nil
+	}
+
+	pmk, found := d.pmks[essid]
+	if !found {
+		pmk = WPAPMK(passphrase, essid)
+		d.pmks[essid] = pmk
+	}
+	return pmk
+}
+
+// derive tries every ANonce observed for the station and keeps the PTK that
+// verifies the MIC of its M2.
+func (d *Dot11Decrypter) derive(st *dot11Station) bool {
+	if st.m2 == nil {
+		return false
+	}
+
+	essid := d.essids[st.ap.String()]
+	pmk := d.pmkFor(essid)
+	if pmk == nil {
+		return false
+	}
+
+	for _, anonce := range st.anonces {
+		ptk := WPAPTK(pmk, st.ap, st.sta, anonce, st.snonce, st.version)
+		if bytes.Equal(WPAMIC(ptk[:16], st.m2, st.version), st.m2MIC) {
+			st.ptk = ptk
+			st.essid = essid
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dot11Decrypter) station(ap net.HardwareAddr, sta net.HardwareAddr) *dot11Station {
+	key := ap.String() + sta.String()
+	st, found := d.stations[key]
+	if !found {
+		st = &dot11Station{ap: ap, sta: sta}
+		d.stations[key] = st
+	}
+	return st
+}
+
+func (st *dot11Station) addANonce(anonce []byte) {
+	for _, known := range st.anonces {
+		if bytes.Equal(known, anonce) {
+			return
+		}
+	}
+	st.anonces = append(st.anonces, append([]byte{}, anonce...))
+}
+
+// setGTK stores the group key carried by the encrypted key data of a M3 or of
+// a group key handshake message.
+func (d *Dot11Decrypter) setGTK(bssid net.HardwareAddr, kek []byte, key *layers.EAPOLKey) {
+	if !key.HasEncryptedKeyData || len(key.EncryptedKeyData) == 0 {
+		return
+	}
+
+	data, err := WPAUnwrapKeyData(kek, key.IV, key.EncryptedKeyData, uint8(key.KeyDescriptorVersion))
+	if err != nil {
+		return
+	}
+
+	gtks, found := d.gtks[bssid.String()]
+	if !found {
+		gtks = make(map[uint8][]byte)
+		d.gtks[bssid.String()] = gtks
+	}
+
+	// WPA1 group messages carry the raw key
+	if key.KeyDescriptorVersion == layers.EAPOLKeyDescriptorVersionRC4HMACMD5 {
+		if key.KeyType == layers.EAPOLKeyTypeGroupSMK && int(key.KeyLength) <= len(data) {
+			gtks[key.KeyIndex] = data[:key.KeyLength]
+		}
+		return
+	}
+
+	// look for the GTK KDE: dd LEN 00-0f-ac 01 KEYID RESERVED GTK
+	for i := 0; i+2 <= len(data); {
+		id, size := data[i], int(data[i+1])
+		if i+2+size > len(data) {
+			break
+		}
+		kde := data[i+2 : i+2+size]
+		if id == 0xdd && size > 6 && bytes.Equal(kde[:3], rsnOUI) && kde[3] == 0x01 {
+			gtks[kde[4]&0x03] = append([]byte{}, kde[6:]...)
+		}
+		i += 2 + size
+	}
+}
+
+// Learn parses a 802.11 frame for network names and EAPOL key messages, it
+// returns true along with the addresses of the pair if a PTK has been derived.
+func (d *Dot11Decrypter) Learn(packet gopacket.Packet, dot11 *layers.Dot11) (derived bool, ap net.HardwareAddr, sta net.HardwareAddr) {
+	switch dot11.Type {
+	case layers.Dot11TypeMgmtBeacon, layers.Dot11TypeMgmtProbeResp, layers.Dot11TypeMgmtAssociationReq, layers.Dot11TypeMgmtReassociationReq:
+		if found, ssid := Dot11ParseIDSSID(packet); found && ssid != "<hidden>" {
+			d.Lock()
+			defer d.Unlock()
+
+			bssid := dot11.Address3.String()
+			if d.essids[bssid] != ssid {
+				d.essids[bssid] = ssid
+				// handshakes seen before the network name
+				for _, st := range d.stations {
+					if st.ptk == nil && st.ap.String() == bssid && d.derive(st) {
+						return true, st.ap, st.sta
+					}
+				}
+			}
+		}
+		return
+	}
+
+	key, ok := packet.Layer(layers.LayerTypeEAPOLKey).(*layers.EAPOLKey)
+	if !ok {
+		return
+	}
+
+	if dot11.Flags.FromDS() {
+		ap, sta = dot11.Address2, dot11.Address1
+	} else if dot11.Flags.ToDS() {
+		ap, sta = dot11.Address1, dot11.Address2
+	} else {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	st := d.station(ap, sta)
+	if key.KeyType == layers.EAPOLKeyTypeGroupSMK {
+		if key.KeyACK && key.KeyMIC && st.ptk != nil {
+			d.setGTK(ap, st.ptk[16:32], key)
+		}
+		return false, ap, sta
+	}
+
+	if !key.Install && key.KeyACK && !key.KeyMIC {
+		// M1
+		st.addANonce(key.Nonce)
+	} else if !key.Install && !key.KeyACK && key.KeyMIC && !allZeros(key.Nonce) {
+		// M2, a new handshake invalidates the previous keys
+		if eapol, ok := packet.Layer(layers.LayerTypeEAPOL).(*layers.EAPOL); ok {
+			if raw := WPAZeroMIC(append(append([]byte{}, eapol.Contents...), eapol.Payload...)); raw != nil {
+				st.snonce = append([]byte{}, key.Nonce...)
+				st.m2 = raw
+				st.m2MIC = append([]byte{}, key.MIC...)
+				st.version = uint8(key.KeyDescriptorVersion)
+				st.ptk = nil
+				derived = d.derive(st)
+			}
+		}
+	} else if key.Install && key.KeyACK && key.KeyMIC {
+		// M3, its ANonce is the one of the M1 we might have missed
+		st.addANonce(key.Nonce)
+		if st.ptk == nil {
+			derived = d.derive(st)
+		}
+		if st.ptk != nil {
+			d.setGTK(ap, st.ptk[16:32], key)
+		}
+	}
+
+	return derived, ap, sta
+}
+
+func allZeros(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Decrypt decrypts a protected data frame with the keys derived so far and
+// returns its payload decoded as an Ethernet frame.
+func (d *Dot11Decrypter) Decrypt(packet gopacket.Packet, dot11 *layers.Dot11) (gopacket.Packet, error) {
+	if dot11.Type.MainType() != layers.Dot11TypeData || !dot11.Flags.WEP() {
+		return nil, ErrDot11NotEncrypted
+	} else if dot11.Flags.MF() || dot11.FragmentNumber != 0 {
+		return nil, ErrDot11Unsupported
+	}
+
+	body := dot11.Payload
+	if len(body) < 8 {
+		return nil, ErrWPAShortFrame
+	} else if body[3]&0x20 == 0 {
+		// no extended IV, WEP
+		return nil, ErrDot11Unsupported
+	}
+
+	var bssid, sta, dst, src net.HardwareAddr
+	switch {
+	case dot11.Flags.FromDS() && dot11.Flags.ToDS():
+		return nil, ErrDot11Unsupported
+	case dot11.Flags.FromDS():
+		bssid, sta, dst, src = dot11.Address2, dot11.Address1, dot11.Address1, dot11.Address3
+	case dot11.Flags.ToDS():
+		bssid, sta, dst, src = dot11.Address1, dot11.Address2, dot11.Address3, dot11.Address2
+	default:
+		return nil, ErrDot11Unsupported
+	}
+
+	var tk []byte
+	d.Lock()
+	if dot11.Address1[0]&0x01 != 0 {
+		if gtk := d.gtks[bssid.String()][body[3]>>6]; len(gtk) >= 16 {
+			tk = gtk[:16]
+		}
+	} else if st, found := d.stations[bssid.String()+sta.String()]; found && st.ptk != nil {
+		tk = st.ptk[32:48]
+	}
+	d.Unlock()
+
+	if tk == nil {
+		return nil, ErrDot11NoKey
+	}
+
+	plain, err := WPADecryptCCMP(tk, dot11.Contents, body)
+	if err != nil {
+		if plain, err = WPADecryptTKIP(tk, dot11.Address2, body); err != nil {
+			return nil, err
+		}
+	}
+
+	// LLC/SNAP header: aa aa 03 00 00 00 ETHERTYPE
+	if len(plain) < 8 || plain[0] != 0xaa || plain[1] != 0xaa || plain[2] != 0x03 {
+		return nil, ErrDot11NotLLC
+	}
+
+	frame := make([]byte, 0, 14+len(plain)-8)
+	frame = append(frame, dst...)
+	frame = append(frame, src...)
+	frame = append(frame, plain[6:]...)
+
+	decrypted := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default)
+	if meta := packet.Metadata(); meta != nil {
+		decrypted.Metadata().CaptureInfo = meta.CaptureInfo
+		decrypted.Metadata().CaptureLength = len(frame)
+		decrypted.Metadata().Length = len(frame)
+	}
+
+	return decrypted, nil
+}
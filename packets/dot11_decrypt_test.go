@@ -0,0 +1,265 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/bettercap/bettercap/v2/network"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+const (
+	testDecryptESSID    = "bettercap-test"
+	testDecryptPassword = "correct horse battery"
+)
+
+var (
+	testDecryptAP     = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	testDecryptSTA    = net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+	testDecryptANonce = bytes.Repeat([]byte{0xaa}, 32)
+	testDecryptSNonce = bytes.Repeat([]byte{0xbb}, 32)
+	testDecryptGTK    = bytes.Repeat([]byte{0x47}, 16)
+	testDecryptSrc    = net.HardwareAddr{0x02, 0xde, 0xad, 0xbe, 0xef, 0x01}
+)
+
+// aesWrap implements the AES key wrap algorithm of RFC 3394.
+func aesWrap(kek []byte, data []byte) []byte {
+	block, _ := aes.NewCipher(kek)
+	n := len(data) / 8
+	a := bytes.Repeat([]byte{0xa6}, 8)
+	r := append([]byte{}, data...)
+	buf := make([]byte, 16)
+	for j := 0; j <= 5; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, a)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Encrypt(buf, buf)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^uint64(n*j+i))
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+	return append(a, r...)
+}
+
+// ccmpEncrypt returns the CCMP header, the encrypted data and the MIC.
+func ccmpEncrypt(tk []byte, header []byte, pn []byte, keyID uint8, plain []byte) []byte {
+	block, _ := aes.NewCipher(tk)
+	aad, nonce := ccmpAAD(header, pn)
+
+	x := make([]byte, 16)
+	x[0] = 0x59
+	copy(x[1:14], nonce)
+	binary.BigEndian.PutUint16(x[14:], uint16(len(plain)))
+	block.Encrypt(x, x)
+	for _, data := range [][]byte{append([]byte{0, byte(len(aad))}, aad...), plain} {
+		for i := 0; i < len(data); i += 16 {
+			end := i + 16
+			if end > len(data) {
+				end = len(data)
+			}
+			xorBytes(x[:end-i], data[i:end])
+			block.Encrypt(x, x)
+		}
+	}
+
+	ctr := make([]byte, 16)
+	ctr[0] = 0x01
+	copy(ctr[1:14], nonce)
+	stream := make([]byte, 16)
+	body := []byte{pn[5], pn[4], 0, 0x20 | keyID<<6, pn[3], pn[2], pn[1], pn[0]}
+	for i := 0; i*16 < len(plain); i++ {
+		binary.BigEndian.PutUint16(ctr[14:], uint16(i+1))
+		block.Encrypt(stream, ctr)
+		for j := 0; j < 16 && i*16+j < len(plain); j++ {
+			body = append(body, plain[i*16+j]^stream[j])
+		}
+	}
+
+	binary.BigEndian.PutUint16(ctr[14:], 0)
+	block.Encrypt(stream, ctr)
+	xorBytes(stream[:8], x[:8])
+	return append(body, stream[:8]...)
+}
+
+func testDecryptPacket(t *testing.T, raw []byte) (gopacket.Packet, *layers.Dot11) {
+	t.Helper()
+	packet := gopacket.NewPacket(raw, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+	dot11, ok := packet.Layer(layers.LayerTypeDot11).(*layers.Dot11)
+	if !ok {
+		t.Fatal("not a 802.11 frame")
+	}
+	return packet, dot11
+}
+
+func testDecryptEAPOL(t *testing.T, fromAP bool, key *layers.EAPOLKey, kck []byte) []byte {
+	t.Helper()
+
+	dot11 := &layers.Dot11{Type: layers.Dot11TypeData}
+	if fromAP {
+		dot11.Flags = layers.Dot11FlagsFromDS
+		dot11.Address1, dot11.Address2, dot11.Address3 = testDecryptSTA, testDecryptAP, testDecryptAP
+	} else {
+		dot11.Flags = layers.Dot11FlagsToDS
+		dot11.Address1, dot11.Address2, dot11.Address3 = testDecryptAP, testDecryptSTA, testDecryptAP
+	}
+
+	key.KeyDescriptorType = layers.EAPOLKeyDescriptorTypeDot11
+	key.KeyDescriptorVersion = layers.EAPOLKeyDescriptorVersionAESHMACSHA1
+	key.KeyType = layers.EAPOLKeyTypePairwise
+	key.KeyDataLength = uint16(len(key.EncryptedKeyData))
+	key.IV = make([]byte, 16)
+	key.MIC = make([]byte, 16)
+
+	serialize := func() []byte {
+		err, raw := Serialize(
+			&layers.RadioTap{},
+			dot11,
+			&layers.LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 3},
+			&layers.SNAP{OrganizationalCode: []byte{0, 0, 0}, Type: layers.EthernetTypeEAPOL},
+			&layers.EAPOL{Version: 1, Type: layers.EAPOLTypeKey, Length: uint16(95 + len(key.EncryptedKeyData))},
+			key,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	raw := serialize()
+	if kck != nil {
+		packet, _ := testDecryptPacket(t, raw)
+		eapol := packet.Layer(layers.LayerTypeEAPOL).(*layers.EAPOL)
+		key.MIC = WPAMIC(kck, append(append([]byte{}, eapol.Contents...), eapol.Payload...), WPAKeyDescriptorHMACSHA1)
+		raw = serialize()
+	}
+	return raw
+}
+
+func testDecryptDataFrame(t *testing.T, tk []byte, dst net.HardwareAddr, keyID uint8, plain []byte) []byte {
+	t.Helper()
+	// data, FromDS | Protected, DA, BSSID, SA
+	header := []byte{0x08, 0x42, 0x00, 0x00}
+	header = append(header, dst...)
+	header = append(header, testDecryptAP...)
+	header = append(header, testDecryptSrc...)
+	header = append(header, 0x10, 0x00)
+
+	frame := []byte{0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}
+	frame = append(frame, header...)
+	return append(frame, ccmpEncrypt(tk, header, []byte{0, 0, 0, 0, 0, 1}, keyID, plain)...)
+}
+
+func testDecryptHandshake(t *testing.T, ptk []byte) [][]byte {
+	err, beacon := NewDot11Beacon(Dot11ApConfig{
+		SSID:       testDecryptESSID,
+		BSSID:      testDecryptAP,
+		Channel:    1,
+		Encryption: true,
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kde := append([]byte{0xdd, 0x16, 0x00, 0x0f, 0xac, 0x01, 0x01, 0x00}, testDecryptGTK...)
+	return [][]byte{
+		beacon,
+		testDecryptEAPOL(t, true, &layers.EAPOLKey{KeyACK: true, ReplayCounter: 1, Nonce: testDecryptANonce}, nil),
+		testDecryptEAPOL(t, false, &layers.EAPOLKey{KeyMIC: true, ReplayCounter: 1, Nonce: testDecryptSNonce}, ptk[:16]),
+		testDecryptEAPOL(t, true, &layers.EAPOLKey{
+			KeyACK:              true,
+			KeyMIC:              true,
+			Install:             true,
+			Secure:              true,
+			HasEncryptedKeyData: true,
+			ReplayCounter:       2,
+			Nonce:               testDecryptANonce,
+			EncryptedKeyData:    aesWrap(ptk[16:32], kde),
+		}, ptk[:16]),
+	}
+}
+
+func TestDot11Decrypter(t *testing.T) {
+	pmk := WPAPMK(testDecryptPassword, testDecryptESSID)
+	ptk := WPAPTK(pmk, testDecryptAP, testDecryptSTA, testDecryptANonce, testDecryptSNonce, WPAKeyDescriptorHMACSHA1)
+
+	err, arp := NewARPRequest(net.IP{10, 0, 0, 2}, testDecryptSrc, net.IP{10, 0, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := append([]byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00}, arp[12:]...)
+
+	d := NewDot11Decrypter()
+	d.SetPSK(testDecryptESSID, testDecryptPassword)
+
+	derived := 0
+	for _, raw := range testDecryptHandshake(t, ptk) {
+		packet, dot11 := testDecryptPacket(t, raw)
+		if ok, ap, sta := d.Learn(packet, dot11); ok {
+			derived++
+			if !bytes.Equal(ap, testDecryptAP) || !bytes.Equal(sta, testDecryptSTA) {
+				t.Errorf("unexpected addresses %s <-> %s", ap, sta)
+			}
+		}
+	}
+	if derived != 1 {
+		t.Fatalf("expected keys to be derived once, got %d", derived)
+	} else if stations := d.Stations(testDecryptESSID); len(stations) != 1 {
+		t.Fatalf("expected 1 station, got %v", stations)
+	}
+
+	cases := []struct {
+		tk    []byte
+		dst   net.HardwareAddr
+		keyID uint8
+	}{
+		{ptk[32:48], testDecryptSTA, 0},
+		{testDecryptGTK, network.BroadcastHw, 1},
+	}
+	for _, c := range cases {
+		packet, dot11 := testDecryptPacket(t, testDecryptDataFrame(t, c.tk, c.dst, c.keyID, plain))
+		decrypted, err := d.Decrypt(packet, dot11)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dst, err)
+		}
+
+		eth, ok := decrypted.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+		if !ok {
+			t.Fatalf("%s: no ethernet layer", c.dst)
+		} else if !bytes.Equal(eth.DstMAC, c.dst) || !bytes.Equal(eth.SrcMAC, testDecryptSrc) {
+			t.Errorf("%s: unexpected addresses %s -> %s", c.dst, eth.SrcMAC, eth.DstMAC)
+		} else if decrypted.Layer(layers.LayerTypeARP) == nil {
+			t.Errorf("%s: no ARP layer", c.dst)
+		}
+	}
+
+	d.Clear()
+	packet, dot11 := testDecryptPacket(t, testDecryptDataFrame(t, ptk[32:48], testDecryptSTA, 0, plain))
+	if _, err := d.Decrypt(packet, dot11); err != ErrDot11NoKey {
+		t.Errorf("expected %v, got %v", ErrDot11NoKey, err)
+	}
+}
+
+func TestDot11DecrypterLatePSK(t *testing.T) {
+	pmk := WPAPMK(testDecryptPassword, testDecryptESSID)
+	ptk := WPAPTK(pmk, testDecryptAP, testDecryptSTA, testDecryptANonce, testDecryptSNonce, WPAKeyDescriptorHMACSHA1)
+
+	d := NewDot11Decrypter()
+	for _, raw := range testDecryptHandshake(t, ptk) {
+		if ok, _, _ := d.Learn(testDecryptPacket(t, raw)); ok {
+			t.Fatal("no keys expected without a passphrase")
+		}
+	}
+
+	if n := d.SetPSK(testDecryptESSID, "wrong password"); n != 0 {
+		t.Errorf("expected no stations with a wrong passphrase, got %d", n)
+	} else if n := d.SetPSK(testDecryptESSID, testDecryptPassword); n != 1 {
+		t.Errorf("expected 1 station, got %d", n)
+	} else if psks := d.PSKs(); psks[testDecryptESSID] != testDecryptPassword {
+		t.Errorf("unexpected psks %v", psks)
+	}
+}
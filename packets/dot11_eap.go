@@ -0,0 +1,258 @@
+package packets
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// EAP methods not defined by gopacket, which also names type 4 OTP instead
+// of MD5-Challenge.
+const (
+	EAPTypeMD5      layers.EAPType = 4
+	EAPTypeOTP      layers.EAPType = 5
+	EAPTypeGTC      layers.EAPType = 6
+	EAPTypeTLS      layers.EAPType = 13
+	EAPTypeLEAP     layers.EAPType = 17
+	EAPTypeSIM      layers.EAPType = 18
+	EAPTypeTTLS     layers.EAPType = 21
+	EAPTypeAKA      layers.EAPType = 23
+	EAPTypePEAP     layers.EAPType = 25
+	EAPTypeMSCHAPv2 layers.EAPType = 26
+	EAPTypeFAST     layers.EAPType = 43
+	EAPTypePWD      layers.EAPType = 52
+)
+
+var eapTypeNames = map[layers.EAPType]string{
+	layers.EAPTypeIdentity:     "Identity",
+	layers.EAPTypeNotification: "Notification",
+	layers.EAPTypeNACK:         "NAK",
+	EAPTypeMD5:                 "MD5",
+	EAPTypeOTP:                 "OTP",
+	EAPTypeGTC:                 "GTC",
+	EAPTypeTLS:                 "TLS",
+	EAPTypeLEAP:                "LEAP",
+	EAPTypeSIM:                 "SIM",
+	EAPTypeTTLS:                "TTLS",
+	EAPTypeAKA:                 "AKA",
+	EAPTypePEAP:                "PEAP",
+	EAPTypeMSCHAPv2:            "MSCHAPv2",
+	EAPTypeFAST:                "FAST",
+	EAPTypePWD:                 "PWD",
+}
+
+// hashcat modes of the crackable EAP challenge/response pairs
+const (
+	EAPHashcatModeMD5       = 4800
+	EAPHashcatModeNetNTLMv1 = 5500
+)
+
+// EAPTypeName returns the name of an EAP method.
+func EAPTypeName(t layers.EAPType) string {
+	if name, found := eapTypeNames[t]; found {
+		return name
+	}
+	return fmt.Sprintf("EAP-%d", t)
+}
+
+// isEAPMethod returns true for the EAP types that are authentication methods.
+func isEAPMethod(t layers.EAPType) bool {
+	return t > layers.EAPTypeNACK
+}
+
+// EAPInfo is what was learned from an EAP frame exchanged between an access
+// point and one of its stations.
+type EAPInfo struct {
+	AP      net.HardwareAddr
+	Station net.HardwareAddr
+	Code    layers.EAPCode
+	Type    layers.EAPType
+	// username sent by the station
+	Identity string
+	// methods proposed by the access point or requested by the station
+	Methods []string
+	// crackable challenge/response pair in hashcat format
+	Hash        string
+	HashcatMode int
+}
+
+type eapChallenge struct {
+	md5      []byte
+	md5ID    uint8
+	leap     []byte
+	mschapv2 []byte
+	mschapID uint8
+}
+
+// EAPParser follows the 802.1X exchanges of the stations to pair the
+// challenges sent by the authenticator with their responses.
+type EAPParser struct {
+	sync.Mutex
+	challenges map[string]*eapChallenge
+}
+
+func NewEAPParser() *EAPParser {
+	return &EAPParser{
+		challenges: make(map[string]*eapChallenge),
+	}
+}
+
+func (p *EAPParser) challenge(ap net.HardwareAddr, sta net.HardwareAddr) *eapChallenge {
+	key := ap.String() + sta.String()
+	c, found := p.challenges[key]
+	if !found {
+		c = &eapChallenge{}
+		p.challenges[key] = c
+	}
+	return c
+}
+
+// eapUsername strips the domain from a DOMAIN\user identity.
+func eapUsername(name string) string {
+	if idx := strings.LastIndex(name, `\`); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// Parse decodes an EAP frame and returns the information it carries.
+func (p *EAPParser) Parse(packet gopacket.Packet, dot11 *layers.Dot11) (bool, EAPInfo) {
+	info := EAPInfo{}
+
+	eapLayer := packet.Layer(layers.LayerTypeEAP)
+	if eapLayer == nil {
+		return false, info
+	}
+	eap, ok := eapLayer.(*layers.EAP)
+	if !ok {
+		return false, info
+	}
+
+	// requests come from the authenticator, responses from the supplicant
+	if eap.Code == layers.EAPCodeResponse {
+		info.Station, info.AP = dot11.Address2, dot11.Address1
+	} else {
+		info.AP, info.Station = dot11.Address2, dot11.Address1
+	}
+	info.Code = eap.Code
+	info.Type = eap.Type
+
+	data := eap.TypeData
+	if eap.Length >= 5 && int(eap.Length)-5 <= len(data) {
+		data = data[:eap.Length-5]
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	c := p.challenge(info.AP, info.Station)
+
+	switch {
+	case eap.Code == layers.EAPCodeSuccess || eap.Code == layers.EAPCodeFailure:
+		delete(p.challenges, info.AP.String()+info.Station.String())
+
+	case eap.Type == layers.EAPTypeIdentity:
+		if eap.Code == layers.EAPCodeResponse {
+			info.Identity = strings.TrimRight(string(data), "\x00")
+		}
+
+	case eap.Type == layers.EAPTypeNACK:
+		for _, t := range data {
+			if isEAPMethod(layers.EAPType(t)) {
+				info.Methods = append(info.Methods, EAPTypeName(layers.EAPType(t)))
+			}
+		}
+
+	case eap.Type == EAPTypeMD5:
+		p.parseMD5(eap, data, c, &info)
+
+	case eap.Type == EAPTypeLEAP:
+		p.parseLEAP(eap, data, c, &info)
+
+	case eap.Type == EAPTypeMSCHAPv2:
+		p.parseMSCHAPv2(eap, data, c, &info)
+	}
+
+	if isEAPMethod(eap.Type) {
+		info.Methods = append(info.Methods, EAPTypeName(eap.Type))
+	}
+
+	return true, info
+}
+
+// parseMD5 handles EAP-MD5, where response = MD5(id | password | challenge).
+func (p *EAPParser) parseMD5(eap *layers.EAP, data []byte, c *eapChallenge, info *EAPInfo) {
+	if len(data) < 1 || len(data) < 1+int(data[0]) || data[0] != 16 {
+		return
+	}
+
+	value := data[1:17]
+	if eap.Code == layers.EAPCodeRequest {
+		c.md5 = append([]byte{}, value...)
+		c.md5ID = eap.Id
+	} else if c.md5 != nil && c.md5ID == eap.Id {
+		info.Identity = string(data[17:])
+		info.Hash = fmt.Sprintf("%x:%x:%02x", value, c.md5, eap.Id)
+		info.HashcatMode = EAPHashcatModeMD5
+		c.md5 = nil
+	}
+}
+
+// parseLEAP handles Cisco LEAP, whose response is a MSCHAPv1 one to the 8
+// bytes challenge of the access point.
+func (p *EAPParser) parseLEAP(eap *layers.EAP, data []byte, c *eapChallenge, info *EAPInfo) {
+	// version | reserved | count | data | name
+	if len(data) < 3 || data[0] != 1 || len(data) < 3+int(data[2]) {
+		return
+	}
+
+	count := int(data[2])
+	value := data[3 : 3+count]
+	name := string(data[3+count:])
+	if eap.Code == layers.EAPCodeRequest && count == 8 {
+		c.leap = append([]byte{}, value...)
+	} else if eap.Code == layers.EAPCodeResponse && count == 24 && c.leap != nil {
+		info.Identity = name
+		info.Hash = fmt.Sprintf("%s::::%x:%x", eapUsername(name), value, c.leap)
+		info.HashcatMode = EAPHashcatModeNetNTLMv1
+		c.leap = nil
+	}
+}
+
+// parseMSCHAPv2 handles EAP-MSCHAPv2, visible when not tunneled or when the
+// tunnel is terminated by us.
+func (p *EAPParser) parseMSCHAPv2(eap *layers.EAP, data []byte, c *eapChallenge, info *EAPInfo) {
+	// opcode | ms-chapv2 id | ms-length | value size | value | name
+	if len(data) < 5 || len(data) < 5+int(data[4]) {
+		return
+	}
+
+	opcode := data[0]
+	size := int(data[4])
+	value := data[5 : 5+size]
+	name := string(data[5+size:])
+	if eap.Code == layers.EAPCodeRequest && opcode == 1 && size == 16 {
+		c.mschapv2 = append([]byte{}, value...)
+		c.mschapID = data[1]
+	} else if eap.Code == layers.EAPCodeResponse && opcode == 2 && size == 49 && c.mschapv2 != nil && c.mschapID == data[1] {
+		// peer challenge | reserved | NT response | flags
+		peerChallenge := value[0:16]
+		ntResponse := value[24:48]
+		username := eapUsername(name)
+
+		hash := sha1.New()
+		hash.Write(peerChallenge)
+		hash.Write(c.mschapv2)
+		hash.Write([]byte(username))
+
+		info.Identity = name
+		info.Hash = fmt.Sprintf("%s::::%x:%x", username, ntResponse, hash.Sum(nil)[:8])
+		info.HashcatMode = EAPHashcatModeNetNTLMv1
+		c.mschapv2 = nil
+	}
+}
@@ -0,0 +1,138 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func testEAPFrame(t *testing.T, code layers.EAPCode, id uint8, typ layers.EAPType, data []byte) (gopacket.Packet, *layers.Dot11) {
+	t.Helper()
+
+	dot11 := &layers.Dot11{Type: layers.Dot11TypeData}
+	if code == layers.EAPCodeResponse {
+		dot11.Flags = layers.Dot11FlagsToDS
+		dot11.Address1, dot11.Address2, dot11.Address3 = testDecryptAP, testDecryptSTA, testDecryptAP
+	} else {
+		dot11.Flags = layers.Dot11FlagsFromDS
+		dot11.Address1, dot11.Address2, dot11.Address3 = testDecryptSTA, testDecryptAP, testDecryptAP
+	}
+
+	eap := []byte{byte(code), id, 0, 0, byte(typ)}
+	eap = append(eap, data...)
+	binary.BigEndian.PutUint16(eap[2:], uint16(len(eap)))
+
+	err, raw := Serialize(
+		&layers.RadioTap{},
+		dot11,
+		&layers.LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 3},
+		&layers.SNAP{OrganizationalCode: []byte{0, 0, 0}, Type: layers.EthernetTypeEAPOL},
+		&layers.EAPOL{Version: 1, Type: layers.EAPOLTypeEAP, Length: uint16(len(eap))},
+		gopacket.Payload(eap),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testDecryptPacket(t, raw)
+}
+
+func TestEAPParserIdentity(t *testing.T) {
+	p := NewEAPParser()
+
+	if ok, info := p.Parse(testEAPFrame(t, layers.EAPCodeResponse, 1, layers.EAPTypeIdentity, []byte("CORP\\alice"))); !ok {
+		t.Fatal("expected EAP frame")
+	} else if info.Identity != "CORP\\alice" {
+		t.Errorf("unexpected identity %q", info.Identity)
+	} else if !bytes.Equal(info.AP, testDecryptAP) || !bytes.Equal(info.Station, testDecryptSTA) {
+		t.Errorf("unexpected addresses %s <-> %s", info.AP, info.Station)
+	}
+
+	// the station refuses TLS and asks for PEAP or MSCHAPv2
+	if _, info := p.Parse(testEAPFrame(t, layers.EAPCodeRequest, 2, EAPTypeTLS, []byte{0x20})); !reflect.DeepEqual(info.Methods, []string{"TLS"}) {
+		t.Errorf("unexpected methods %v", info.Methods)
+	} else if !bytes.Equal(info.AP, testDecryptAP) || !bytes.Equal(info.Station, testDecryptSTA) {
+		t.Errorf("unexpected addresses %s <-> %s", info.AP, info.Station)
+	}
+	if _, info := p.Parse(testEAPFrame(t, layers.EAPCodeResponse, 2, layers.EAPTypeNACK, []byte{byte(EAPTypePEAP), byte(EAPTypeMSCHAPv2)})); !reflect.DeepEqual(info.Methods, []string{"PEAP", "MSCHAPv2"}) {
+		t.Errorf("unexpected methods %v", info.Methods)
+	}
+
+	packet := gopacket.NewPacket([]byte{0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+	if ok, _ := p.Parse(packet, &layers.Dot11{}); ok {
+		t.Error("expected no EAP frame")
+	}
+}
+
+func TestEAPParserMD5(t *testing.T) {
+	p := NewEAPParser()
+	challenge := bytes.Repeat([]byte{0x42}, 16)
+
+	p.Parse(testEAPFrame(t, layers.EAPCodeRequest, 7, EAPTypeMD5, append([]byte{16}, challenge...)))
+
+	h := md5.New()
+	h.Write([]byte{7})
+	h.Write([]byte("password"))
+	h.Write(challenge)
+	response := h.Sum(nil)
+
+	_, info := p.Parse(testEAPFrame(t, layers.EAPCodeResponse, 7, EAPTypeMD5, append([]byte{16}, response...)))
+	expected := fmt.Sprintf("%x:%x:07", response, challenge)
+	if info.Hash != expected || info.HashcatMode != EAPHashcatModeMD5 {
+		t.Errorf("unexpected hash %q (%d), expected %q", info.Hash, info.HashcatMode, expected)
+	}
+
+	// no challenge, no hash
+	if _, info := p.Parse(testEAPFrame(t, layers.EAPCodeResponse, 7, EAPTypeMD5, append([]byte{16}, response...))); info.Hash != "" {
+		t.Errorf("unexpected hash %q", info.Hash)
+	}
+}
+
+func TestEAPParserLEAP(t *testing.T) {
+	p := NewEAPParser()
+	challenge := bytes.Repeat([]byte{0x11}, 8)
+	response := bytes.Repeat([]byte{0x22}, 24)
+
+	p.Parse(testEAPFrame(t, layers.EAPCodeRequest, 3, EAPTypeLEAP, append(append([]byte{1, 0, 8}, challenge...), "bob"...)))
+	_, info := p.Parse(testEAPFrame(t, layers.EAPCodeResponse, 3, EAPTypeLEAP, append(append([]byte{1, 0, 24}, response...), "CORP\\bob"...)))
+
+	expected := fmt.Sprintf("bob::::%x:%x", response, challenge)
+	if info.Hash != expected || info.HashcatMode != EAPHashcatModeNetNTLMv1 {
+		t.Errorf("unexpected hash %q, expected %q", info.Hash, expected)
+	} else if info.Identity != "CORP\\bob" {
+		t.Errorf("unexpected identity %q", info.Identity)
+	}
+}
+
+func TestEAPParserMSCHAPv2(t *testing.T) {
+	// RFC 2759 section 9.2 test vector
+	authChallenge, _ := hex.DecodeString("5b5d7c7d7b3f2f3e3c2c602132262628")
+	peerChallenge, _ := hex.DecodeString("21402324255e262a28295f2b3a337c7e")
+	ntResponse, _ := hex.DecodeString("82309ecd8d708b5ea08faa3981cd83544233114a3d85d6df")
+
+	p := NewEAPParser()
+
+	request := []byte{1, 9, 0, 0, 16}
+	request = append(request, authChallenge...)
+	p.Parse(testEAPFrame(t, layers.EAPCodeRequest, 4, EAPTypeMSCHAPv2, append(request, "radius"...)))
+
+	response := []byte{2, 9, 0, 0, 49}
+	response = append(response, peerChallenge...)
+	response = append(response, make([]byte, 8)...)
+	response = append(response, ntResponse...)
+	response = append(response, 0)
+	_, info := p.Parse(testEAPFrame(t, layers.EAPCodeResponse, 4, EAPTypeMSCHAPv2, append(response, "DOMAIN\\User"...)))
+
+	expected := "User::::82309ecd8d708b5ea08faa3981cd83544233114a3d85d6df:d02e4386bce91226"
+	if info.Hash != expected || info.HashcatMode != EAPHashcatModeNetNTLMv1 {
+		t.Errorf("unexpected hash %q, expected %q", info.Hash, expected)
+	} else if !reflect.DeepEqual(info.Methods, []string{"MSCHAPv2"}) {
+		t.Errorf("unexpected methods %v", info.Methods)
+	}
+}
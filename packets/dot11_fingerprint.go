@@ -0,0 +1,71 @@
+package packets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// information elements of a probe request that depend on the hardware and
+// driver of the station rather than on the network it is looking for, their
+// content is part of the fingerprint
+var dot11FingerprintElements = map[layers.Dot11InformationElementID]bool{
+	layers.Dot11InformationElementIDRates:           true,
+	layers.Dot11InformationElementIDESRates:         true,
+	layers.Dot11InformationElementIDHTCapabilities:  true,
+	layers.Dot11InformationElementIDExtCapability:   true,
+	layers.Dot11InformationElementIDVHTCapabilities: true,
+	layers.Dot11InformationElementIDInterworking:    true,
+	// HE capabilities and the other extensions
+	0xff: true,
+}
+
+// Dot11ProbeFingerprint returns an identifier of the hardware and driver of
+// the station sending a probe request, which doesn't change when its MAC
+// address is randomized. It's built from the order of the information
+// elements, the content of the capability ones and the OUI and type of the
+// vendor specific ones, whose content may carry per-scan values.
+func Dot11ProbeFingerprint(packet gopacket.Packet) (bool, string) {
+	reqLayer := packet.Layer(layers.LayerTypeDot11MgmtProbeReq)
+	if reqLayer == nil {
+		return false, ""
+	}
+	req, ok := reqLayer.(*layers.Dot11MgmtProbeReq)
+	if !ok {
+		return false, ""
+	}
+
+	hash := sha256.New()
+	found := false
+
+	// the elements are not decoded as layers after a probe request
+	for data := req.Contents; len(data) >= 2; {
+		id := layers.Dot11InformationElementID(data[0])
+		size := int(data[1])
+		if len(data) < 2+size {
+			break
+		}
+		info := data[2 : 2+size]
+		data = data[2+size:]
+
+		if id == layers.Dot11InformationElementIDSSID || id == layers.Dot11InformationElementIDDSSet {
+			continue
+		}
+
+		found = true
+		hash.Write([]byte{byte(id)})
+		if dot11FingerprintElements[id] {
+			hash.Write([]byte{byte(size)})
+			hash.Write(info)
+		} else if id == layers.Dot11InformationElementIDVendor && size >= 4 {
+			hash.Write(info[:4])
+		}
+	}
+
+	if !found {
+		return false, ""
+	}
+	return true, hex.EncodeToString(hash.Sum(nil)[:8])
+}
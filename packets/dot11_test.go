@@ -118,6 +118,53 @@ func TestNewDot11Beacon(t *testing.T) {
 	}
 }
 
+func TestNewDot11ProbeResponse(t *testing.T) {
+	conf := BuildDot11ApConfig()
+	conf.SSID = "karma"
+	conf.BSSID, _ = net.ParseMAC("00:11:22:33:44:55")
+	dst, _ := net.ParseMAC("66:77:88:99:aa:bb")
+
+	err, raw := NewDot11ProbeResponse(conf, dst, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := gopacket.NewPacket(raw, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+	if ok, _, dot11 := Dot11Parse(packet); !ok {
+		t.Fatal("not a 802.11 frame")
+	} else if dot11.Type != layers.Dot11TypeMgmtProbeResp {
+		t.Errorf("unexpected type %v", dot11.Type)
+	} else if dot11.Address1.String() != dst.String() || dot11.Address2.String() != conf.BSSID.String() {
+		t.Errorf("unexpected addresses %s -> %s", dot11.Address2, dot11.Address1)
+	} else if ok, ssid := Dot11ParseIDSSID(packet); !ok || ssid != "karma" {
+		t.Errorf("unexpected ssid %q", ssid)
+	}
+}
+
+func TestDot11ProbeFingerprint(t *testing.T) {
+	fingerprint := func(mac string, ssid string, channel int) string {
+		hw, _ := net.ParseMAC(mac)
+		err, raw := NewDot11ProbeRequest(hw, 1, ssid, channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, id := Dot11ProbeFingerprint(gopacket.NewPacket(raw, layers.LinkTypeIEEE80211Radio, gopacket.Default))
+		if !ok {
+			t.Fatal("expected a fingerprint")
+		}
+		return id
+	}
+
+	a := fingerprint("02:00:00:00:00:01", "home", 1)
+	if b := fingerprint("06:00:00:00:00:02", "office", 6); a != b {
+		t.Errorf("expected the same fingerprint, got %s and %s", a, b)
+	}
+
+	if ok, _ := Dot11ProbeFingerprint(BuildDot11Packet()); ok {
+		t.Error("expected no fingerprint without information elements")
+	}
+}
+
 func TestNewDot11Deauth(t *testing.T) {
 	mac, _ := net.ParseMAC("00:00:00:00:00:00")
 	seq := uint16(0)
@@ -247,6 +294,45 @@ func TestDot11ParseEncryption(t *testing.T) {
 	}
 }
 
+func TestDot11ParseSecurity(t *testing.T) {
+	bssid, _ := net.ParseMAC("00:11:22:33:44:55")
+	rsn := func(akms []byte, caps uint16) []byte {
+		info := []byte{0x01, 0x00, 0x00, 0x0f, 0xac, 0x04, 0x01, 0x00, 0x00, 0x0f, 0xac, 0x04, byte(len(akms)), 0x00}
+		for _, akm := range akms {
+			info = append(info, 0x00, 0x0f, 0xac, akm)
+		}
+		return append(info, byte(caps), byte(caps>>8))
+	}
+
+	cases := []struct {
+		name string
+		info []byte
+		exp  Dot11Security
+	}{
+		{"wpa2", rsn([]byte{2}, 0), Dot11Security{"WPA2", "AES-CCM", "PSK", ""}},
+		{"wpa2 pmf", rsn([]byte{2, 6}, 0x0080), Dot11Security{"WPA2", "AES-CCM", "PSK (SHA256)", Dot11PMFCapable}},
+		{"wpa3", rsn([]byte{8}, 0x00c0), Dot11Security{"WPA3", "AES-CCM", "SAE", Dot11PMFRequired}},
+		{"transition", rsn([]byte{8, 2}, 0x0080), Dot11Security{"WPA2/WPA3", "AES-CCM", "SAE/PSK", Dot11PMFCapable}},
+		{"owe", rsn([]byte{18}, 0x00c0), Dot11Security{"OWE", "AES-CCM", "OWE", Dot11PMFRequired}},
+		{"no capabilities", rsn([]byte{2}, 0)[:18], Dot11Security{"WPA2", "AES-CCM", "PSK", ""}},
+	}
+
+	for _, c := range cases {
+		err, raw := NewDot11Beacon(Dot11ApConfig{SSID: c.name, BSSID: bssid, Channel: 1}, 0, Dot11Info(layers.Dot11InformationElementIDRSNInfo, c.info))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		packet := gopacket.NewPacket(raw, layers.LayerTypeRadioTap, gopacket.Default)
+		_, _, dot11 := Dot11Parse(packet)
+		if found, sec := Dot11ParseSecurity(packet, dot11); !found {
+			t.Errorf("%s: security not found", c.name)
+		} else if !reflect.DeepEqual(sec, c.exp) {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.exp, sec)
+		}
+	}
+}
+
 func TestDot11IsDataFor(t *testing.T) {
 	mac, _ := net.ParseMAC("00:00:00:00:00:00")
 	seq := uint16(0)
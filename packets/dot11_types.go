@@ -120,11 +120,29 @@ type AuthSuiteSelector struct {
 	Suites []AuthSuite
 }
 
+// RSN capabilities bits advertising management frame protection (802.11w).
+const (
+	RSNCapabilityMFPRequired = 0x0040
+	RSNCapabilityMFPCapable  = 0x0080
+)
+
 type RSNInfo struct {
-	Version  uint16
-	Group    CipherSuite
-	Pairwise CipherSuiteSelector
-	AuthKey  AuthSuiteSelector
+	Version         uint16
+	Group           CipherSuite
+	Pairwise        CipherSuiteSelector
+	AuthKey         AuthSuiteSelector
+	HasCapabilities bool
+	Capabilities    uint16
+}
+
+// MFPRequired returns true if the RSN requires management frame protection.
+func (rsn RSNInfo) MFPRequired() bool {
+	return rsn.HasCapabilities && rsn.Capabilities&RSNCapabilityMFPRequired != 0
+}
+
+// MFPCapable returns true if the RSN supports management frame protection.
+func (rsn RSNInfo) MFPCapable() bool {
+	return rsn.HasCapabilities && rsn.Capabilities&(RSNCapabilityMFPCapable|RSNCapabilityMFPRequired) != 0
 }
 
 type VendorInfo struct {
@@ -257,6 +275,13 @@ func Dot11InformationElementRSNInfoDecode(buf []byte) (rsn RSNInfo, err error) {
 		}
 	} else {
 		rsn.AuthKey.Count = 0
+		return
+	}
+
+	// capabilities are optional
+	if canParse("RSN.Capabilities", buf, 2) == nil {
+		rsn.HasCapabilities = true
+		rsn.Capabilities = binary.LittleEndian.Uint16(buf[0:2])
 	}
 
 	return
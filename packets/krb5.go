@@ -11,11 +11,13 @@ import (
 )
 
 const (
-	Krb5AsRequestType         = 10
-	Krb5Krb5PrincipalNameType = 1
-	Krb5CryptDesCbcMd4        = 2
-	Krb5CryptDescCbcMd5       = 3
-	Krb5CryptRc4Hmac          = 23
+	Krb5AsRequestType          = 10
+	Krb5Krb5PrincipalNameType  = 1
+	Krb5CryptDesCbcMd4         = 2
+	Krb5CryptDescCbcMd5        = 3
+	Krb5CryptRc4Hmac           = 23
+	Krb5CryptAes128CtsHmacSha1 = 17
+	Krb5CryptAes256CtsHmacSha1 = 18
 )
 
 var (
@@ -76,36 +78,81 @@ type Krb5Request struct {
 	ReqBody    Krb5ReqBody  `asn1:"explicit,tag:4"`
 }
 
-func (kdc Krb5Request) String() (string, error) {
-	var eType, cipher string
+// preauth returns the client name and its encrypted timestamp.
+func (kdc Krb5Request) preauth() (string, Krb5EncryptedData, error) {
+	var enc Krb5EncryptedData
+	found := false
 
 	if kdc.ReqBody.Cname.NameType != Krb5Krb5PrincipalNameType {
-		return "", ErrNoCrypt
+		return "", enc, ErrNoCrypt
 	}
 
-	realm := kdc.ReqBody.Realm
 	crypt := kdc.ReqBody.Cname.NameString
 
 	for _, pn := range kdc.Krb5PnData {
 		if pn.Krb5PnDataType == 2 {
-			enc, err := pn.getParsedValue()
+			parsed, err := pn.getParsedValue()
 			if err != nil {
-				return "", ErrReqData
+				return "", enc, ErrReqData
 			}
-			eType = strconv.Itoa(enc.Etype)
-			cipher = hex.EncodeToString(enc.Cipher)
+			enc = parsed
+			found = true
 		}
 	}
 
-	if eType == "" || cipher == "" {
-		return "", ErrNoCipher
+	if !found || len(enc.Cipher) == 0 {
+		return "", enc, ErrNoCipher
 	}
 
 	if len(crypt) == 0 {
-		return "", ErrNoCrypt
+		return "", enc, ErrNoCrypt
+	}
+
+	return crypt[0], enc, nil
+}
+
+func (kdc Krb5Request) String() (string, error) {
+	user, enc, err := kdc.preauth()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$krb5$%s$%s$%s$nodata$%s", strconv.Itoa(enc.Etype), user, kdc.ReqBody.Realm, hex.EncodeToString(enc.Cipher)), nil
+}
+
+// User returns the client principal name of the request.
+func (kdc Krb5Request) User() string {
+	if len(kdc.ReqBody.Cname.NameString) == 0 {
+		return ""
+	}
+	return kdc.ReqBody.Cname.NameString[0]
+}
+
+// HashcatString returns the pre-authentication timestamp in the format
+// expected by hashcat along with its mode.
+func (kdc Krb5Request) HashcatString() (string, int, error) {
+	user, enc, err := kdc.preauth()
+	if err != nil {
+		return "", 0, err
+	}
+
+	realm := kdc.ReqBody.Realm
+	switch enc.Etype {
+	case Krb5CryptRc4Hmac:
+		// the checksum precedes the encrypted timestamp
+		if len(enc.Cipher) < 16 {
+			return "", 0, ErrNoCipher
+		}
+		return fmt.Sprintf("$krb5pa$23$%s$%s$$%s%s", user, realm,
+			hex.EncodeToString(enc.Cipher[16:]),
+			hex.EncodeToString(enc.Cipher[:16])), 7500, nil
+	case Krb5CryptAes128CtsHmacSha1:
+		return fmt.Sprintf("$krb5pa$17$%s$%s$%s", user, realm, hex.EncodeToString(enc.Cipher)), 19800, nil
+	case Krb5CryptAes256CtsHmacSha1:
+		return fmt.Sprintf("$krb5pa$18$%s$%s$%s", user, realm, hex.EncodeToString(enc.Cipher)), 19900, nil
 	}
 
-	return fmt.Sprintf("$krb5$%s$%s$%s$nodata$%s", eType, crypt[0], realm, cipher), nil
+	return "", 0, fmt.Errorf("unsupported encryption type %d", enc.Etype)
 }
 
 func (pd Krb5PnData) getParsedValue() (Krb5EncryptedData, error) {
@@ -206,3 +206,52 @@ func TestKrb5Request(t *testing.T) {
 
 // TODO: add test for func (kdc Krb5Request) String()
 // TODO: add test for func (pd Krb5PnData) getParsedValue()
+
+func testKrb5Request(t *testing.T, etype int, cipher []byte) Krb5Request {
+	value, err := asn1.Marshal(Krb5EncryptedData{Etype: etype, Cipher: cipher})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Krb5Request{
+		Krb5PnData: []Krb5PnData{{Krb5PnDataType: 2, Krb5PnDataValue: value}},
+		ReqBody: Krb5ReqBody{
+			Cname: Krb5PrincipalName{NameType: Krb5Krb5PrincipalNameType, NameString: []string{"alice"}},
+			Realm: "CORP.LOCAL",
+		},
+	}
+}
+
+func TestKrb5RequestHashcatString(t *testing.T) {
+	cipher := make([]byte, 52)
+	for i := range cipher {
+		cipher[i] = byte(i)
+	}
+
+	req := testKrb5Request(t, Krb5CryptRc4Hmac, cipher)
+	if hash, mode, err := req.HashcatString(); err != nil {
+		t.Fatal(err)
+	} else if mode != 7500 {
+		t.Errorf("unexpected mode %d", mode)
+	} else if exp := "$krb5pa$23$alice$CORP.LOCAL$$" +
+		"101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f30313233" +
+		"000102030405060708090a0b0c0d0e0f"; hash != exp {
+		t.Errorf("expected %s, got %s", exp, hash)
+	}
+
+	req = testKrb5Request(t, Krb5CryptAes256CtsHmacSha1, []byte{0xde, 0xad, 0xbe, 0xef})
+	if hash, mode, err := req.HashcatString(); err != nil {
+		t.Fatal(err)
+	} else if mode != 19900 || hash != "$krb5pa$18$alice$CORP.LOCAL$deadbeef" {
+		t.Errorf("unexpected hash %s (mode %d)", hash, mode)
+	}
+
+	req = testKrb5Request(t, Krb5CryptDesCbcMd4, []byte{0x01})
+	if _, _, err := req.HashcatString(); err == nil {
+		t.Error("expected error for unsupported encryption type")
+	}
+
+	if req.User() != "alice" {
+		t.Errorf("unexpected user %s", req.User())
+	}
+}
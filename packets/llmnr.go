@@ -0,0 +1,12 @@
+package packets
+
+import (
+	"net"
+)
+
+const LLMNRPort = 5355
+
+var (
+	LLMNRDestIP  = net.ParseIP("224.0.0.252")
+	LLMNRDestIP6 = net.ParseIP("ff02::1:3")
+)
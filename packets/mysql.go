@@ -1,5 +1,12 @@
 package packets
 
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
 var (
 	MySQLGreeting = []byte{
 		0x5b, 0x00, 0x00, 0x00, 0x0a, 0x35, 0x2e, 0x36,
@@ -31,3 +38,59 @@ func MySQLGetFile(infile string) []byte {
 		0x00, 0x00, 0x01, 0xfb,
 	}, infile...)
 }
+
+const (
+	mysqlClientSecureConnection   = 0x00008000
+	mysqlClientPluginAuthLenEnc   = 0x00200000
+	mysqlHandshakeResponseUserPos = 36
+)
+
+// MySQLGreetingSalt returns the scramble sent to clients with MySQLGreeting.
+func MySQLGreetingSalt() []byte {
+	salt := append([]byte{}, MySQLGreeting[33:41]...)
+	return append(salt, MySQLGreeting[60:72]...)
+}
+
+// MySQLParseLogin extracts the user name and the mysql_native_password
+// response from a client handshake response packet.
+func MySQLParseLogin(msg []byte) (string, []byte, bool) {
+	if len(msg) <= mysqlHandshakeResponseUserPos {
+		return "", nil, false
+	}
+
+	capabilities := binary.LittleEndian.Uint32(msg[4:])
+
+	end := bytes.IndexByte(msg[mysqlHandshakeResponseUserPos:], 0)
+	if end < 0 {
+		return "", nil, false
+	}
+
+	user := string(msg[mysqlHandshakeResponseUserPos : mysqlHandshakeResponseUserPos+end])
+	pos := mysqlHandshakeResponseUserPos + end + 1
+	if pos >= len(msg) {
+		return user, nil, true
+	}
+
+	size := 0
+	if capabilities&(mysqlClientPluginAuthLenEnc|mysqlClientSecureConnection) != 0 {
+		// longer length encoded responses are not used by mysql_native_password
+		if size = int(msg[pos]); size >= 0xfb {
+			return user, nil, false
+		}
+		pos++
+	} else if size = bytes.IndexByte(msg[pos:], 0); size < 0 {
+		return user, nil, false
+	}
+
+	if pos+size > len(msg) {
+		return user, nil, false
+	}
+
+	return user, msg[pos : pos+size], true
+}
+
+// MySQLHashcatString returns a mysql_native_password response in the
+// format expected by hashcat (mode 11200).
+func MySQLHashcatString(salt []byte, response []byte) string {
+	return fmt.Sprintf("$mysqlna$%s*%s", hex.EncodeToString(salt), hex.EncodeToString(response))
+}
@@ -239,3 +239,38 @@ func BenchmarkMySQLGetFileLong(b *testing.B) {
 		_ = MySQLGetFile(filename)
 	}
 }
+
+func TestMySQLGreetingSalt(t *testing.T) {
+	salt := MySQLGreetingSalt()
+	exp := []byte("@?Y&K+4`hiY_R_cU`dSR")
+	if !bytes.Equal(salt, exp) {
+		t.Errorf("MySQLGreetingSalt() = %q, want %q", salt, exp)
+	}
+}
+
+func TestMySQLParseLogin(t *testing.T) {
+	response := bytes.Repeat([]byte{0xaa}, 20)
+
+	msg := []byte{0x00, 0x00, 0x00, 0x01}
+	// CLIENT_PROTOCOL_41 | CLIENT_SECURE_CONNECTION
+	msg = append(msg, 0x00, 0x82, 0x00, 0x00)
+	msg = append(msg, make([]byte, 28)...)
+	msg = append(msg, []byte("root\x00")...)
+	msg = append(msg, byte(len(response)))
+	msg = append(msg, response...)
+	msg = append(msg, []byte("mysql\x00")...)
+
+	user, got, ok := MySQLParseLogin(msg)
+	if !ok || user != "root" || !bytes.Equal(got, response) {
+		t.Fatalf("MySQLParseLogin() = %q, %x, %v", user, got, ok)
+	}
+
+	hash := MySQLHashcatString([]byte{0x01, 0x02}, got)
+	if hash != "$mysqlna$0102*aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" {
+		t.Errorf("MySQLHashcatString() = %s", hash)
+	}
+
+	if _, _, ok := MySQLParseLogin(msg[:30]); ok {
+		t.Error("expected short message to be rejected")
+	}
+}
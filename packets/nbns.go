@@ -1,7 +1,12 @@
 package packets
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
 	"strconv"
+	"strings"
 
 	"github.com/evilsocket/islazy/str"
 
@@ -39,3 +44,105 @@ func NBNSGetMeta(pkt gopacket.Packet) map[string]string {
 	}
 	return nil
 }
+
+const (
+	// name query request, name query response and the NB record type
+	nbnsOpcodeMask  = 0x7800
+	nbnsResponse    = 0x8000
+	nbnsTypeNB      = 0x0020
+	nbnsClassIN     = 0x0001
+	nbnsEncodedSize = 32
+)
+
+var (
+	ErrNBNSTruncated = errors.New("truncated NBNS packet")
+	ErrNBNSNotQuery  = errors.New("not a NBNS name query")
+)
+
+// NBNSQuery is a NetBIOS name query request.
+type NBNSQuery struct {
+	ID     uint16
+	Name   string
+	Suffix byte
+}
+
+// NBNSDecodeName decodes a first level encoded NetBIOS name (RFC 1001 section 14.1).
+func NBNSDecodeName(encoded []byte) (name string, suffix byte, err error) {
+	if len(encoded) != nbnsEncodedSize {
+		return "", 0, ErrNBNSTruncated
+	}
+
+	decoded := make([]byte, nbnsEncodedSize/2)
+	for i := range decoded {
+		hi, lo := encoded[i*2]-'A', encoded[i*2+1]-'A'
+		if hi > 0x0f || lo > 0x0f {
+			return "", 0, fmt.Errorf("invalid NetBIOS name encoding")
+		}
+		decoded[i] = hi<<4 | lo
+	}
+
+	return strings.TrimRight(string(decoded[:15]), " "), decoded[15], nil
+}
+
+// NBNSEncodeName encodes a NetBIOS name and its suffix with the first level encoding.
+func NBNSEncodeName(name string, suffix byte) []byte {
+	padded := make([]byte, 16)
+	copy(padded, fmt.Sprintf("%-15.15s", strings.ToUpper(name)))
+	padded[15] = suffix
+
+	encoded := make([]byte, 0, nbnsEncodedSize)
+	for _, b := range padded {
+		encoded = append(encoded, 'A'+(b>>4), 'A'+(b&0x0f))
+	}
+	return encoded
+}
+
+// NBNSParseQuery parses a NetBIOS name query request for a NB record.
+func NBNSParseQuery(data []byte) (*NBNSQuery, error) {
+	// header, length prefixed name, terminator, type and class
+	if len(data) < 12+1+nbnsEncodedSize+1+4 {
+		return nil, ErrNBNSTruncated
+	}
+
+	flags := binary.BigEndian.Uint16(data[2:4])
+	if flags&nbnsResponse != 0 || flags&nbnsOpcodeMask != 0 || binary.BigEndian.Uint16(data[4:6]) != 1 {
+		return nil, ErrNBNSNotQuery
+	} else if data[12] != nbnsEncodedSize || data[13+nbnsEncodedSize] != 0 {
+		return nil, ErrNBNSNotQuery
+	}
+
+	question := data[14+nbnsEncodedSize:]
+	if binary.BigEndian.Uint16(question[0:2]) != nbnsTypeNB || binary.BigEndian.Uint16(question[2:4]) != nbnsClassIN {
+		return nil, ErrNBNSNotQuery
+	}
+
+	name, suffix, err := NBNSDecodeName(data[13 : 13+nbnsEncodedSize])
+	if err != nil {
+		return nil, err
+	}
+
+	return &NBNSQuery{
+		ID:     binary.BigEndian.Uint16(data[0:2]),
+		Name:   name,
+		Suffix: suffix,
+	}, nil
+}
+
+// NBNSReply creates a positive name query response resolving the query to address.
+func NBNSReply(query *NBNSQuery, ttl uint32, address net.IP) []byte {
+	reply := make([]byte, 0, 62)
+	reply = binary.BigEndian.AppendUint16(reply, query.ID)
+	// response, authoritative answer, recursion desired
+	reply = binary.BigEndian.AppendUint16(reply, 0x8500)
+	reply = append(reply, 0, 0, 0, 1, 0, 0, 0, 0)
+	reply = append(reply, nbnsEncodedSize)
+	reply = append(reply, NBNSEncodeName(query.Name, query.Suffix)...)
+	reply = append(reply, 0)
+	reply = binary.BigEndian.AppendUint16(reply, nbnsTypeNB)
+	reply = binary.BigEndian.AppendUint16(reply, nbnsClassIN)
+	reply = binary.BigEndian.AppendUint32(reply, ttl)
+	reply = binary.BigEndian.AppendUint16(reply, 6)
+	// B-node, unique name
+	reply = append(reply, 0, 0)
+	return append(reply, address.To4()...)
+}
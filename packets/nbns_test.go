@@ -349,3 +349,56 @@ func BenchmarkNBNSGetMetaNonNBNS(b *testing.B) {
 		_ = NBNSGetMeta(packet)
 	}
 }
+
+func TestNBNSNameEncoding(t *testing.T) {
+	encoded := NBNSEncodeName("fileserver", 0x20)
+	if len(encoded) != 32 || string(encoded[:4]) != "EGEJ" {
+		t.Fatalf("unexpected encoding %s", encoded)
+	}
+
+	name, suffix, err := NBNSDecodeName(encoded)
+	if err != nil {
+		t.Fatal(err)
+	} else if name != "FILESERVER" || suffix != 0x20 {
+		t.Errorf("unexpected name %s<%02x>", name, suffix)
+	}
+
+	if _, _, err := NBNSDecodeName([]byte("short")); err == nil {
+		t.Error("expected error for truncated name")
+	}
+}
+
+func TestNBNSParseQuery(t *testing.T) {
+	query := []byte{0x13, 0x37, 0x01, 0x10, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20}
+	query = append(query, NBNSEncodeName("WPAD", 0x00)...)
+	query = append(query, 0x00, 0x00, 0x20, 0x00, 0x01)
+
+	q, err := NBNSParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	} else if q.ID != 0x1337 || q.Name != "WPAD" || q.Suffix != 0x00 {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	// NBSTAT queries are not name queries
+	nbstat := append([]byte{}, query...)
+	nbstat[len(nbstat)-3] = 0x21
+	if _, err := NBNSParseQuery(nbstat); err != ErrNBNSNotQuery {
+		t.Errorf("expected ErrNBNSNotQuery, got %v", err)
+	}
+
+	if _, err := NBNSParseQuery(query[:20]); err != ErrNBNSTruncated {
+		t.Errorf("expected ErrNBNSTruncated, got %v", err)
+	}
+
+	reply := NBNSReply(q, 30, net.ParseIP("192.168.1.100"))
+	if len(reply) != 62 {
+		t.Fatalf("unexpected reply length %d", len(reply))
+	}
+	if !bytes.Equal(reply[0:4], []byte{0x13, 0x37, 0x85, 0x00}) || !bytes.Equal(reply[58:], []byte{192, 168, 1, 100}) {
+		t.Errorf("unexpected reply %x", reply)
+	}
+	if !bytes.Equal(reply[13:45], NBNSEncodeName("WPAD", 0x00)) {
+		t.Errorf("unexpected reply name %s", reply[13:45])
+	}
+}
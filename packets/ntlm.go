@@ -181,7 +181,8 @@ func (sr NTLMChallengeResponse) ParsedNtLMv1() (NTLMChallengeResponseParsed, err
 	b := sr.getResponseBytes()
 	if int(r.UserOffset)+int(r.UserLen) > len(b) ||
 		int(r.DomainOffset)+int(r.DomainLen) > len(b) ||
-		int(r.LmOffset)+int(r.LmLen) > len(b) {
+		int(r.LmOffset)+int(r.LmLen) > len(b) ||
+		int(r.NtOffset)+int(r.NtLen) > len(b) {
 		return NTLMChallengeResponseParsed{}, errors.New("response too short")
 	}
 	return NTLMChallengeResponseParsed{
@@ -190,6 +191,7 @@ func (sr NTLMChallengeResponse) ParsedNtLMv1() (NTLMChallengeResponseParsed, err
 		User:            strings.Replace(string(b[r.UserOffset:r.UserOffset+r.UserLen]), "\x00", "", -1),
 		Domain:          strings.Replace(string(b[r.DomainOffset:r.DomainOffset+r.DomainLen]), "\x00", "", -1),
 		LmHash:          hex.EncodeToString(b[r.LmOffset : r.LmOffset+r.LmLen]),
+		NtHashOne:       hex.EncodeToString(b[r.NtOffset : r.NtOffset+r.NtLen]),
 	}, nil
 }
 
@@ -248,3 +250,12 @@ func (data NTLMChallengeResponseParsed) LcString() string {
 	}
 	return data.User + "::" + data.Domain + ":" + data.ServerChallenge + ":" + data.NtHashOne + ":" + data.NtHashTwo + "\n"
 }
+
+// HashcatString returns the response in the format of hashcat modes 5500
+// (NetNTLMv1) and 5600 (NetNTLMv2).
+func (data NTLMChallengeResponseParsed) HashcatString() string {
+	if data.Type == NtlmV1 {
+		return data.User + "::" + data.Domain + ":" + data.LmHash + ":" + data.NtHashOne + ":" + data.ServerChallenge
+	}
+	return data.User + "::" + data.Domain + ":" + data.ServerChallenge + ":" + data.NtHashOne + ":" + data.NtHashTwo
+}
@@ -0,0 +1,136 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	NTLM_NEGOTIATE_UNICODE             = 0x00000001
+	NTLM_REQUEST_TARGET                = 0x00000004
+	NTLM_NEGOTIATE_SIGN                = 0x00000010
+	NTLM_NEGOTIATE_SEAL                = 0x00000020
+	NTLM_NEGOTIATE_NTLM                = 0x00000200
+	NTLM_NEGOTIATE_ALWAYS_SIGN         = 0x00008000
+	NTLM_TARGET_TYPE_DOMAIN            = 0x00010000
+	NTLM_NEGOTIATE_EXTENDED_SESSIONSEC = 0x00080000
+	NTLM_NEGOTIATE_TARGET_INFO         = 0x00800000
+	NTLM_NEGOTIATE_VERSION             = 0x02000000
+	NTLM_NEGOTIATE_128                 = 0x20000000
+	NTLM_NEGOTIATE_KEY_EXCH            = 0x40000000
+	NTLM_NEGOTIATE_56                  = 0x80000000
+
+	NTLM_NEGOTIATE    = 1
+	NTLM_CHALLENGE    = 2
+	NTLM_AUTHENTICATE = 3
+)
+
+var NTLMSignature = []byte("NTLMSSP\x00")
+
+// flags the server echoes back if requested by the client
+const ntlmMirroredFlags = NTLM_NEGOTIATE_SIGN | NTLM_NEGOTIATE_SEAL | NTLM_NEGOTIATE_EXTENDED_SESSIONSEC |
+	NTLM_NEGOTIATE_128 | NTLM_NEGOTIATE_KEY_EXCH | NTLM_NEGOTIATE_56
+
+// target info AV pair identifiers
+const (
+	ntlmAvEOL             = 0
+	ntlmAvNbComputerName  = 1
+	ntlmAvNbDomainName    = 2
+	ntlmAvDnsComputerName = 3
+	ntlmAvDnsDomainName   = 4
+	ntlmAvTimestamp       = 7
+)
+
+// seconds between 1601-01-01 and 1970-01-01
+const filetimeEpochDelta = 11644473600
+
+// NTLMFind returns the NTLMSSP message embedded in a security blob, for
+// instance a SPNEGO token, or nil if there's none.
+func NTLMFind(blob []byte) []byte {
+	if idx := bytes.Index(blob, NTLMSignature); idx >= 0 && len(blob)-idx >= 12 {
+		return blob[idx:]
+	}
+	return nil
+}
+
+// NTLMMessageType returns the type of a NTLMSSP message.
+func NTLMMessageType(msg []byte) uint32 {
+	if len(msg) < 12 || !bytes.HasPrefix(msg, NTLMSignature) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(msg[NTLM_TYPE_OFFSET:])
+}
+
+// NTLMNegotiateFlags returns the flags of a NTLMSSP NEGOTIATE message.
+func NTLMNegotiateFlags(msg []byte) uint32 {
+	if NTLMMessageType(msg) != NTLM_NEGOTIATE || len(msg) < NTLM_TYPE1_FLAGS_OFFSET+4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(msg[NTLM_TYPE1_FLAGS_OFFSET:])
+}
+
+// FileTime converts a time to a Windows FILETIME value.
+func FileTime(t time.Time) uint64 {
+	return uint64(t.Unix()+filetimeEpochDelta)*10000000 + uint64(t.Nanosecond()/100)
+}
+
+func ntlmUnicode(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	raw := make([]byte, len(encoded)*2)
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(raw[i*2:], c)
+	}
+	return raw
+}
+
+func ntlmAvPair(id uint16, value []byte) []byte {
+	pair := binary.LittleEndian.AppendUint16(nil, id)
+	pair = binary.LittleEndian.AppendUint16(pair, uint16(len(value)))
+	return append(pair, value...)
+}
+
+// NewNTLMChallenge creates the NTLMSSP CHALLENGE message of a server answering
+// a NEGOTIATE message with the given flags. If ess is false the extended
+// session security is never negotiated, so that NTLMv1 clients will send plain
+// NetNTLMv1 responses.
+func NewNTLMChallenge(challenge []byte, clientFlags uint32, ess bool, domain string, computer string) []byte {
+	flags := uint32(NTLM_NEGOTIATE_UNICODE | NTLM_REQUEST_TARGET | NTLM_NEGOTIATE_NTLM | NTLM_NEGOTIATE_ALWAYS_SIGN |
+		NTLM_TARGET_TYPE_DOMAIN | NTLM_NEGOTIATE_TARGET_INFO | NTLM_NEGOTIATE_VERSION)
+	flags |= clientFlags & ntlmMirroredFlags
+	if !ess {
+		flags &^= NTLM_NEGOTIATE_EXTENDED_SESSIONSEC
+	}
+
+	dnsDomain := strings.ToLower(domain) + ".local"
+	dnsComputer := strings.ToLower(computer) + "." + dnsDomain
+
+	target := ntlmUnicode(domain)
+	info := ntlmAvPair(ntlmAvNbDomainName, target)
+	info = append(info, ntlmAvPair(ntlmAvNbComputerName, ntlmUnicode(computer))...)
+	info = append(info, ntlmAvPair(ntlmAvDnsDomainName, ntlmUnicode(dnsDomain))...)
+	info = append(info, ntlmAvPair(ntlmAvDnsComputerName, ntlmUnicode(dnsComputer))...)
+	info = append(info, ntlmAvPair(ntlmAvTimestamp, binary.LittleEndian.AppendUint64(nil, FileTime(time.Now())))...)
+	info = append(info, ntlmAvPair(ntlmAvEOL, nil)...)
+
+	// header with the version field
+	headerSize := NTLM_TYPE2_DATA_OFFSET + 8
+
+	msg := append([]byte{}, NTLMSignature...)
+	msg = binary.LittleEndian.AppendUint32(msg, NTLM_CHALLENGE)
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(target)))
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(target)))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(headerSize))
+	msg = binary.LittleEndian.AppendUint32(msg, flags)
+	msg = append(msg, challenge[:8]...)
+	msg = append(msg, make([]byte, 8)...)
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(info)))
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(info)))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(headerSize+len(target)))
+	// Windows 6.1 build 7601, NTLM revision 15
+	msg = append(msg, 6, 1, 0xb1, 0x1d, 0, 0, 0, 0x0f)
+	msg = append(msg, target...)
+	return append(msg, info...)
+}
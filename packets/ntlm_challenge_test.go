@@ -0,0 +1,108 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+// testNTLMAuthenticate builds a NTLMSSP AUTHENTICATE message.
+func testNTLMAuthenticate(domain, user string, lm, nt []byte) []byte {
+	fields := [][]byte{lm, nt, ntlmUnicode(domain), ntlmUnicode(user), ntlmUnicode("WS01"), nil}
+
+	msg := append([]byte{}, NTLMSignature...)
+	msg = binary.LittleEndian.AppendUint32(msg, NTLM_AUTHENTICATE)
+
+	offset := NTLM_TYPE3_DATA_OFFSET
+	payload := []byte{}
+	for _, field := range fields {
+		msg = binary.LittleEndian.AppendUint16(msg, uint16(len(field)))
+		msg = binary.LittleEndian.AppendUint16(msg, uint16(len(field)))
+		msg = binary.LittleEndian.AppendUint32(msg, uint32(offset))
+		offset += len(field)
+		payload = append(payload, field...)
+	}
+	msg = binary.LittleEndian.AppendUint32(msg, 0)
+
+	return append(msg, payload...)
+}
+
+func TestNTLMFind(t *testing.T) {
+	msg := append([]byte{}, NTLMSignature...)
+	msg = binary.LittleEndian.AppendUint32(msg, NTLM_NEGOTIATE)
+	msg = binary.LittleEndian.AppendUint32(msg, NTLM_NEGOTIATE_UNICODE|NTLM_NEGOTIATE_EXTENDED_SESSIONSEC)
+
+	blob := append([]byte{0x60, 0x48, 0x06, 0x06}, msg...)
+	if found := NTLMFind(blob); !bytes.Equal(found, msg) {
+		t.Fatalf("unexpected message %x", found)
+	}
+	if NTLMMessageType(msg) != NTLM_NEGOTIATE {
+		t.Errorf("unexpected type %d", NTLMMessageType(msg))
+	}
+	if NTLMNegotiateFlags(msg) != NTLM_NEGOTIATE_UNICODE|NTLM_NEGOTIATE_EXTENDED_SESSIONSEC {
+		t.Errorf("unexpected flags %x", NTLMNegotiateFlags(msg))
+	}
+	if NTLMFind([]byte("nothing here")) != nil {
+		t.Error("expected no message")
+	}
+}
+
+func TestNewNTLMChallenge(t *testing.T) {
+	challenge := []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}
+
+	msg := NewNTLMChallenge(challenge, NTLM_NEGOTIATE_EXTENDED_SESSIONSEC|NTLM_NEGOTIATE_128, true, "CORP", "FS01")
+	if NTLMMessageType(msg) != NTLM_CHALLENGE {
+		t.Fatalf("unexpected type %d", NTLMMessageType(msg))
+	}
+	if !bytes.Equal(msg[NTLM_TYPE2_CHALLENGE_OFFSET:NTLM_TYPE2_CHALLENGE_OFFSET+8], challenge) {
+		t.Errorf("unexpected challenge %x", msg[NTLM_TYPE2_CHALLENGE_OFFSET:])
+	}
+
+	flags := binary.LittleEndian.Uint32(msg[NTLM_TYPE2_FLAGS_OFFSET:])
+	if flags&NTLM_NEGOTIATE_EXTENDED_SESSIONSEC == 0 || flags&NTLM_NEGOTIATE_128 == 0 || flags&NTLM_NEGOTIATE_56 != 0 {
+		t.Errorf("unexpected flags %x", flags)
+	}
+
+	targetLen := binary.LittleEndian.Uint16(msg[NTLM_TYPE2_TARGET_OFFSET:])
+	targetOffset := binary.LittleEndian.Uint32(msg[NTLM_TYPE2_TARGET_OFFSET+4:])
+	if !bytes.Equal(msg[targetOffset:targetOffset+uint32(targetLen)], ntlmUnicode("CORP")) {
+		t.Errorf("unexpected target name")
+	}
+
+	infoLen := binary.LittleEndian.Uint16(msg[NTLM_TYPE2_TARGETINFO_OFFSET:])
+	infoOffset := binary.LittleEndian.Uint32(msg[NTLM_TYPE2_TARGETINFO_OFFSET+4:])
+	if int(infoOffset)+int(infoLen) != len(msg) || !bytes.HasSuffix(msg, []byte{0, 0, 0, 0}) {
+		t.Errorf("unexpected target info")
+	}
+
+	downgraded := NewNTLMChallenge(challenge, NTLM_NEGOTIATE_EXTENDED_SESSIONSEC, false, "CORP", "FS01")
+	if binary.LittleEndian.Uint32(downgraded[NTLM_TYPE2_FLAGS_OFFSET:])&NTLM_NEGOTIATE_EXTENDED_SESSIONSEC != 0 {
+		t.Error("expected extended session security to be disabled")
+	}
+}
+
+func TestNTLMHashcatString(t *testing.T) {
+	challenge := NewNTLMChallenge([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}, 0, true, "CORP", "FS01")
+
+	ntv2 := append(bytes.Repeat([]byte{0xaa}, 16), 0x01, 0x01, 0x00, 0x00)
+	pair := NTLMChallengeResponse{
+		Challenge: base64.StdEncoding.EncodeToString(challenge),
+		Response:  base64.StdEncoding.EncodeToString(testNTLMAuthenticate("CORP", "alice", make([]byte, 24), ntv2)),
+	}
+
+	if parsed, err := pair.Parsed(); err != nil {
+		t.Fatal(err)
+	} else if got := parsed.HashcatString(); got != "alice::CORP:1122334455667788:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa:01010000" {
+		t.Errorf("unexpected NetNTLMv2 line %s", got)
+	}
+
+	pair.Response = base64.StdEncoding.EncodeToString(testNTLMAuthenticate("CORP", "bob", bytes.Repeat([]byte{0x01}, 24), bytes.Repeat([]byte{0x02}, 24)))
+	if parsed, err := pair.Parsed(); err != nil {
+		t.Fatal(err)
+	} else if got := parsed.HashcatString(); got != "bob::CORP:"+
+		"010101010101010101010101010101010101010101010101:"+
+		"020202020202020202020202020202020202020202020202:1122334455667788" {
+		t.Errorf("unexpected NetNTLMv1 line %s", got)
+	}
+}
@@ -0,0 +1,202 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"net"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// EAPOL key descriptor versions, see IEEE 802.11-2016 12.7.2
+const (
+	WPAKeyDescriptorHMACMD5  = 1
+	WPAKeyDescriptorHMACSHA1 = 2
+	WPAKeyDescriptorAESCMAC  = 3
+)
+
+// offset and size of the MIC field inside a raw EAPOL-Key frame (including
+// the 4 bytes EAPOL header).
+const (
+	WPAEAPOLMICOffset = 81
+	WPAEAPOLMICSize   = 16
+)
+
+var (
+	wpaPTKLabel   = []byte("Pairwise key expansion")
+	wpaPMKIDLabel = []byte("PMK Name")
+)
+
+// WPAPMK derives the 256 bits pairwise master key from a WPA passphrase and
+// the network ESSID.
+func WPAPMK(passphrase string, essid string) []byte {
+	return pbkdf2.Key([]byte(passphrase), []byte(essid), 4096, 32, sha1.New)
+}
+
+// WPAPMKID computes the PMKID of an AP and station pair as HMAC-SHA1-128(PMK, "PMK Name" | AA | SPA).
+func WPAPMKID(pmk []byte, ap net.HardwareAddr, sta net.HardwareAddr) []byte {
+	mac := hmac.New(sha1.New, pmk)
+	mac.Write(wpaPMKIDLabel)
+	mac.Write(ap)
+	mac.Write(sta)
+	return mac.Sum(nil)[:16]
+}
+
+// wpaKeyData returns min(AA,SPA) | max(AA,SPA) | min(ANonce,SNonce) | max(ANonce,SNonce)
+func wpaKeyData(ap net.HardwareAddr, sta net.HardwareAddr, anonce []byte, snonce []byte) []byte {
+	data := make([]byte, 0, 76)
+	if bytes.Compare(ap, sta) < 0 {
+		data = append(append(data, ap...), sta...)
+	} else {
+		data = append(append(data, sta...), ap...)
+	}
+	if bytes.Compare(anonce, snonce) < 0 {
+		data = append(append(data, anonce...), snonce...)
+	} else {
+		data = append(append(data, snonce...), anonce...)
+	}
+	return data
+}
+
+// wpaPRF is the IEEE 802.11 SHA1 based pseudo random function, it returns
+// the first size bytes of its output.
+func wpaPRF(key []byte, label []byte, data []byte, size int) []byte {
+	out := make([]byte, 0, size+sha1.Size)
+	for i := byte(0); len(out) < size; i++ {
+		mac := hmac.New(sha1.New, key)
+		mac.Write(label)
+		mac.Write([]byte{0})
+		mac.Write(data)
+		mac.Write([]byte{i})
+		out = mac.Sum(out)
+	}
+	return out[:size]
+}
+
+// wpaKDF is the IEEE 802.11 SHA256 based key derivation function, bits is
+// the length of the derived key while only its first size bytes are returned.
+func wpaKDF(key []byte, label []byte, data []byte, bits uint16, size int) []byte {
+	out := make([]byte, 0, size+sha256.Size)
+	for i := uint16(1); len(out) < size; i++ {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte{byte(i), byte(i >> 8)})
+		mac.Write(label)
+		mac.Write(data)
+		mac.Write([]byte{byte(bits), byte(bits >> 8)})
+		out = mac.Sum(out)
+	}
+	return out[:size]
+}
+
+// WPAKCK derives the 128 bits key confirmation key, which is the first part
+// of the PTK and the only one needed to verify the MIC of a handshake.
+func WPAKCK(pmk []byte, ap net.HardwareAddr, sta net.HardwareAddr, anonce []byte, snonce []byte, version uint8) []byte {
+	data := wpaKeyData(ap, sta, anonce, snonce)
+	if version == WPAKeyDescriptorAESCMAC {
+		return wpaKDF(pmk, wpaPTKLabel, data, 384, 16)
+	}
+	return wpaPRF(pmk, wpaPTKLabel, data, 16)
+}
+
+// WPAMIC computes the MIC of a raw EAPOL-Key frame, the MIC field of the
+// frame is expected to be zeroed.
+func WPAMIC(kck []byte, eapol []byte, version uint8) []byte {
+	var mac hash.Hash
+
+	switch version {
+	case WPAKeyDescriptorHMACMD5:
+		mac = hmac.New(md5.New, kck)
+	case WPAKeyDescriptorAESCMAC:
+		return aesCMAC(kck, eapol)
+	default:
+		mac = hmac.New(sha1.New, kck)
+	}
+
+	mac.Write(eapol)
+	return mac.Sum(nil)[:16]
+}
+
+// WPAZeroMIC returns a copy of the raw EAPOL-Key frame with the MIC field
+// zeroed and truncated to the length declared in its header, or nil if the
+// frame is too short.
+func WPAZeroMIC(eapol []byte) []byte {
+	if len(eapol) < WPAEAPOLMICOffset+WPAEAPOLMICSize {
+		return nil
+	}
+
+	size := 4 + int(binary.BigEndian.Uint16(eapol[2:4]))
+	if size > len(eapol) || size < WPAEAPOLMICOffset+WPAEAPOLMICSize {
+		size = len(eapol)
+	}
+
+	frame := make([]byte, size)
+	copy(frame, eapol)
+	for i := 0; i < WPAEAPOLMICSize; i++ {
+		frame[WPAEAPOLMICOffset+i] = 0
+	}
+	return frame
+}
+
+// aesCMAC implements AES-128-CMAC as defined in RFC 4493.
+func aesCMAC(key []byte, msg []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil
+	}
+
+	shift := func(in []byte) []byte {
+		out := make([]byte, 16)
+		for i := 0; i < 15; i++ {
+			out[i] = in[i]<<1 | in[i+1]>>7
+		}
+		out[15] = in[15] << 1
+		if in[0]&0x80 != 0 {
+			out[15] ^= 0x87
+		}
+		return out
+	}
+
+	k1 := make([]byte, 16)
+	block.Encrypt(k1, k1)
+	k1 = shift(k1)
+	k2 := shift(k1)
+
+	n := (len(msg) + 15) / 16
+	last := make([]byte, 16)
+	if n == 0 {
+		n = 1
+		last[0] = 0x80
+		xorBytes(last, k2)
+	} else if len(msg)%16 == 0 {
+		copy(last, msg[(n-1)*16:])
+		xorBytes(last, k1)
+	} else {
+		rest := msg[(n-1)*16:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xorBytes(last, k2)
+	}
+
+	x := make([]byte, 16)
+	for i := 0; i < n-1; i++ {
+		xorBytes(x, msg[i*16:(i+1)*16])
+		block.Encrypt(x, x)
+	}
+	xorBytes(x, last)
+	block.Encrypt(x, x)
+
+	return x
+}
+
+// xorBytes xors src into dst.
+func xorBytes(dst []byte, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
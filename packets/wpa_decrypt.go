@@ -0,0 +1,292 @@
+package packets
+
+import (
+	"crypto/aes"
+	"crypto/rc4"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"net"
+)
+
+var (
+	ErrWPAShortFrame = errors.New("frame too short")
+	ErrWPAMIC        = errors.New("MIC verification failed")
+	ErrWPAICV        = errors.New("ICV verification failed")
+	ErrWPAUnwrap     = errors.New("key unwrap integrity check failed")
+)
+
+// WPAPTK derives the pairwise transient key, KCK | KEK | TK for CCMP plus the
+// two TKIP MIC keys for the HMAC descriptor versions.
+func WPAPTK(pmk []byte, ap net.HardwareAddr, sta net.HardwareAddr, anonce []byte, snonce []byte, version uint8) []byte {
+	data := wpaKeyData(ap, sta, anonce, snonce)
+	if version == WPAKeyDescriptorAESCMAC {
+		return wpaKDF(pmk, wpaPTKLabel, data, 384, 48)
+	}
+	return wpaPRF(pmk, wpaPTKLabel, data, 64)
+}
+
+// WPAUnwrapKeyData decrypts the key data of an EAPOL-Key frame with the KEK,
+// using RC4 for descriptor version 1 and AES key wrap otherwise.
+func WPAUnwrapKeyData(kek []byte, iv []byte, data []byte, version uint8) ([]byte, error) {
+	if version == WPAKeyDescriptorHMACMD5 {
+		cipher, err := rc4.NewCipher(append(append([]byte{}, iv...), kek...))
+		if err != nil {
+			return nil, err
+		}
+		discard := make([]byte, 256)
+		cipher.XORKeyStream(discard, discard)
+		plain := make([]byte, len(data))
+		cipher.XORKeyStream(plain, data)
+		return plain, nil
+	}
+	return aesUnwrap(kek, data)
+}
+
+// aesUnwrap implements the AES key unwrap algorithm of RFC 3394.
+func aesUnwrap(kek []byte, data []byte) ([]byte, error) {
+	if len(data) < 24 || len(data)%8 != 0 {
+		return nil, ErrWPAShortFrame
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(data)/8 - 1
+	a := make([]byte, 8)
+	copy(a, data[:8])
+	r := make([]byte, n*8)
+	copy(r, data[8:])
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	for _, b := range a {
+		if b != 0xa6 {
+			return nil, ErrWPAUnwrap
+		}
+	}
+
+	return r, nil
+}
+
+// ccmpAAD builds the additional authentication data and the nonce of a CCMP
+// protected frame from its raw 802.11 header.
+func ccmpAAD(header []byte, pn []byte) (aad []byte, nonce []byte) {
+	isQoS := header[0]&0x8c == 0x88
+	hasA4 := header[1]&0x03 == 0x03
+
+	aad = make([]byte, 0, 30)
+	// mask subtype bits of data frames, retry, power management and more data
+	aad = append(aad, header[0]&0x8f, (header[1]&0xc7)|0x40)
+	if isQoS {
+		aad[1] &= 0x7f
+	}
+	aad = append(aad, header[4:22]...)
+	// sequence number masked, fragment number kept
+	aad = append(aad, header[22]&0x0f, 0)
+
+	offset := 24
+	if hasA4 {
+		aad = append(aad, header[24:30]...)
+		offset = 30
+	}
+
+	priority := byte(0)
+	if isQoS {
+		priority = header[offset] & 0x0f
+		aad = append(aad, priority, 0)
+	}
+
+	nonce = make([]byte, 0, 13)
+	nonce = append(nonce, priority)
+	nonce = append(nonce, header[10:16]...)
+	nonce = append(nonce, pn...)
+
+	return aad, nonce
+}
+
+// WPADecryptCCMP decrypts the body of a CCMP protected frame given its raw
+// 802.11 header and the temporal key.
+func WPADecryptCCMP(tk []byte, header []byte, body []byte) ([]byte, error) {
+	if len(header) < 24 || len(body) < 16 {
+		return nil, ErrWPAShortFrame
+	}
+
+	block, err := aes.NewCipher(tk[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	// PN5 | PN4 | PN3 | PN2 | PN1 | PN0
+	pn := []byte{body[7], body[6], body[5], body[4], body[1], body[0]}
+	aad, nonce := ccmpAAD(header, pn)
+
+	cipherText := body[8 : len(body)-8]
+	mic := body[len(body)-8:]
+
+	// counter mode, A_i = flags | nonce | i
+	ctr := make([]byte, 16)
+	ctr[0] = 0x01
+	copy(ctr[1:14], nonce)
+	stream := make([]byte, 16)
+
+	plain := make([]byte, len(cipherText))
+	for i := 0; i*16 < len(cipherText); i++ {
+		binary.BigEndian.PutUint16(ctr[14:], uint16(i+1))
+		block.Encrypt(stream, ctr)
+		for j := 0; j < 16 && i*16+j < len(cipherText); j++ {
+			plain[i*16+j] = cipherText[i*16+j] ^ stream[j]
+		}
+	}
+
+	// CBC-MAC over B_0, the AAD and the plaintext
+	x := make([]byte, 16)
+	x[0] = 0x59
+	copy(x[1:14], nonce)
+	binary.BigEndian.PutUint16(x[14:], uint16(len(plain)))
+	block.Encrypt(x, x)
+
+	mac := func(data []byte) {
+		for i := 0; i < len(data); i += 16 {
+			end := i + 16
+			if end > len(data) {
+				end = len(data)
+			}
+			xorBytes(x[:end-i], data[i:end])
+			block.Encrypt(x, x)
+		}
+	}
+	mac(append([]byte{byte(len(aad) >> 8), byte(len(aad))}, aad...))
+	mac(plain)
+
+	binary.BigEndian.PutUint16(ctr[14:], 0)
+	block.Encrypt(stream, ctr)
+	xorBytes(stream[:8], mic)
+
+	if subtle.ConstantTimeCompare(stream[:8], x[:8]) != 1 {
+		return nil, ErrWPAMIC
+	}
+
+	return plain, nil
+}
+
+// tkipSbox is the TKIP S-box, each entry is 2*S[i] | 3*S[i] of the AES S-box.
+var tkipSbox = func() (sbox [256]uint16) {
+	// build the AES S-box from multiplicative inverses in GF(2^8)
+	mul := func(a, b byte) (p byte) {
+		for ; b > 0; b >>= 1 {
+			if b&1 != 0 {
+				p ^= a
+			}
+			hi := a & 0x80
+			a <<= 1
+			if hi != 0 {
+				a ^= 0x1b
+			}
+		}
+		return
+	}
+
+	for i := 0; i < 256; i++ {
+		inv := byte(0)
+		if i != 0 {
+			for inv = 1; mul(byte(i), inv) != 1; inv++ {
+			}
+		}
+		s := inv ^ (inv<<1 | inv>>7) ^ (inv<<2 | inv>>6) ^ (inv<<3 | inv>>5) ^ (inv<<4 | inv>>4) ^ 0x63
+		sbox[i] = uint16(mul(s, 2))<<8 | uint16(mul(s, 3))
+	}
+	return
+}()
+
+func tkipS(v uint16) uint16 {
+	hi := tkipSbox[v>>8]
+	return tkipSbox[v&0xff] ^ (hi<<8 | hi>>8)
+}
+
+func ror16(v uint16) uint16 {
+	return v>>1 | v<<15
+}
+
+// tkipMix computes the per packet RC4 key from the temporal key, the
+// transmitter address and the TKIP sequence counter.
+func tkipMix(tk []byte, ta net.HardwareAddr, iv32 uint32, iv16 uint16) []byte {
+	le16 := func(b []byte) uint16 { return binary.LittleEndian.Uint16(b) }
+
+	// phase 1
+	p1k := [5]uint16{uint16(iv32), uint16(iv32 >> 16), le16(ta[0:]), le16(ta[2:]), le16(ta[4:])}
+	for i := 0; i < 8; i++ {
+		j := 2 * (i & 1)
+		p1k[0] += tkipS(p1k[4] ^ le16(tk[0+j:]))
+		p1k[1] += tkipS(p1k[0] ^ le16(tk[4+j:]))
+		p1k[2] += tkipS(p1k[1] ^ le16(tk[8+j:]))
+		p1k[3] += tkipS(p1k[2] ^ le16(tk[12+j:]))
+		p1k[4] += tkipS(p1k[3]^le16(tk[0+j:])) + uint16(i)
+	}
+
+	// phase 2
+	ppk := [6]uint16{p1k[0], p1k[1], p1k[2], p1k[3], p1k[4], p1k[4] + iv16}
+	ppk[0] += tkipS(ppk[5] ^ le16(tk[0:]))
+	ppk[1] += tkipS(ppk[0] ^ le16(tk[2:]))
+	ppk[2] += tkipS(ppk[1] ^ le16(tk[4:]))
+	ppk[3] += tkipS(ppk[2] ^ le16(tk[6:]))
+	ppk[4] += tkipS(ppk[3] ^ le16(tk[8:]))
+	ppk[5] += tkipS(ppk[4] ^ le16(tk[10:]))
+	ppk[0] += ror16(ppk[5] ^ le16(tk[12:]))
+	ppk[1] += ror16(ppk[0] ^ le16(tk[14:]))
+	ppk[2] += ror16(ppk[1])
+	ppk[3] += ror16(ppk[2])
+	ppk[4] += ror16(ppk[3])
+	ppk[5] += ror16(ppk[4])
+
+	key := make([]byte, 16)
+	key[0] = byte(iv16 >> 8)
+	key[1] = (byte(iv16>>8) | 0x20) & 0x7f
+	key[2] = byte(iv16)
+	key[3] = byte((ppk[5] ^ le16(tk[0:])) >> 1)
+	for i := 0; i < 6; i++ {
+		binary.LittleEndian.PutUint16(key[4+2*i:], ppk[i])
+	}
+	return key
+}
+
+// WPADecryptTKIP decrypts the body of a TKIP protected frame sent by the
+// transmitter address ta, the Michael MIC is stripped but not verified.
+func WPADecryptTKIP(tk []byte, ta net.HardwareAddr, body []byte) ([]byte, error) {
+	if len(tk) < 16 || len(body) < 8+8+4 {
+		return nil, ErrWPAShortFrame
+	}
+
+	iv16 := uint16(body[0])<<8 | uint16(body[2])
+	iv32 := binary.LittleEndian.Uint32(body[4:8])
+
+	cipher, err := rc4.NewCipher(tkipMix(tk, ta, iv32, iv16))
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]byte, len(body)-8)
+	cipher.XORKeyStream(plain, body[8:])
+
+	icv := binary.LittleEndian.Uint32(plain[len(plain)-4:])
+	plain = plain[:len(plain)-4]
+	if crc32.ChecksumIEEE(plain) != icv {
+		return nil, ErrWPAICV
+	}
+
+	// strip the Michael MIC
+	return plain[:len(plain)-8], nil
+}
@@ -0,0 +1,110 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/rc4"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+)
+
+func TestAESUnwrap(t *testing.T) {
+	// RFC 3394 4.1
+	kek := unhex(t, "000102030405060708090a0b0c0d0e0f")
+	wrapped := unhex(t, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5")
+	expected := unhex(t, "00112233445566778899aabbccddeeff")
+
+	if got, err := aesUnwrap(kek, wrapped); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, expected) {
+		t.Errorf("expected %x, got %x", expected, got)
+	}
+
+	wrapped[0] ^= 1
+	if _, err := aesUnwrap(kek, wrapped); err != ErrWPAUnwrap {
+		t.Errorf("expected %v, got %v", ErrWPAUnwrap, err)
+	}
+}
+
+func TestWPAPTK(t *testing.T) {
+	ap, _ := net.ParseMAC("00:11:22:33:44:55")
+	sta, _ := net.ParseMAC("66:77:88:99:aa:bb")
+	pmk := WPAPMK("password", "IEEE")
+	anonce := bytes.Repeat([]byte{0xaa}, 32)
+	snonce := bytes.Repeat([]byte{0xbb}, 32)
+
+	for _, version := range []uint8{WPAKeyDescriptorHMACMD5, WPAKeyDescriptorHMACSHA1, WPAKeyDescriptorAESCMAC} {
+		ptk := WPAPTK(pmk, ap, sta, anonce, snonce, version)
+		if !bytes.Equal(ptk[:16], WPAKCK(pmk, ap, sta, anonce, snonce, version)) {
+			t.Errorf("version %d: the PTK does not start with the KCK", version)
+		} else if version == WPAKeyDescriptorAESCMAC && len(ptk) != 48 {
+			t.Errorf("version %d: expected 48 bytes, got %d", version, len(ptk))
+		} else if version != WPAKeyDescriptorAESCMAC && len(ptk) != 64 {
+			t.Errorf("version %d: expected 64 bytes, got %d", version, len(ptk))
+		}
+	}
+}
+
+func TestWPADecryptCCMP(t *testing.T) {
+	// IEEE 802.11-2016 J.6.4
+	tk := unhex(t, "c97c1f67ce371185514a8a19f2bdd52f")
+	header := unhex(t, "0848c32c0fd2e128a57c5030f1844408abaea5b8fcba8033")
+	body := unhex(t, "0ce70020769703b5f3d0a2fe9a3dbf2342a643e43246e80c3c04d0197845ce0b16f97623")
+	expected := unhex(t, "f8ba1a55d02f85ae967bb62fb6cda8eb7e78a050")
+
+	if got, err := WPADecryptCCMP(tk, header, body); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, expected) {
+		t.Errorf("expected %x, got %x", expected, got)
+	}
+
+	body[len(body)-1] ^= 1
+	if _, err := WPADecryptCCMP(tk, header, body); err != ErrWPAMIC {
+		t.Errorf("expected %v, got %v", ErrWPAMIC, err)
+	}
+}
+
+func TestTKIPMix(t *testing.T) {
+	// IEEE 802.11i TKIP key mixing test vectors
+	tk := unhex(t, "000102030405060708090a0b0c0d0e0f")
+	ta := net.HardwareAddr{0x10, 0x22, 0x33, 0x44, 0x55, 0x66}
+
+	cases := map[uint16]string{
+		0: "00200033ea8d2f60ca6d1374234a660b",
+		1: "00200190ffdc314389a9d9d074fd20aa",
+	}
+	for iv16, expected := range cases {
+		if got := tkipMix(tk, ta, 0, iv16); !bytes.Equal(got, unhex(t, expected)) {
+			t.Errorf("iv16 %d: expected %s, got %x", iv16, expected, got)
+		}
+	}
+}
+
+func TestWPADecryptTKIP(t *testing.T) {
+	tk := unhex(t, "000102030405060708090a0b0c0d0e0f")
+	ta := net.HardwareAddr{0x10, 0x22, 0x33, 0x44, 0x55, 0x66}
+	plain := []byte("aaaa03000000080045000014")
+	michael := bytes.Repeat([]byte{0x42}, 8)
+
+	// TSC1 | WEPSeed | TSC0 | KeyID + ExtIV | TSC2..TSC5
+	body := []byte{0x00, 0x20, 0x01, 0x20, 0x00, 0x00, 0x00, 0x00}
+	data := append(append([]byte{}, plain...), michael...)
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+
+	cipher, _ := rc4.NewCipher(tkipMix(tk, ta, 0, 1))
+	encrypted := make([]byte, len(data))
+	cipher.XORKeyStream(encrypted, data)
+	body = append(body, encrypted...)
+
+	if got, err := WPADecryptTKIP(tk, ta, body); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, plain) {
+		t.Errorf("expected %x, got %x", plain, got)
+	}
+
+	body[len(body)-1] ^= 1
+	if _, err := WPADecryptTKIP(tk, ta, body); err != ErrWPAICV {
+		t.Errorf("expected %v, got %v", ErrWPAICV, err)
+	}
+}
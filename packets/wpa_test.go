@@ -0,0 +1,139 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex %s: %v", s, err)
+	}
+	return b
+}
+
+func TestWPAPMK(t *testing.T) {
+	// IEEE 802.11i-2004 H.4.1
+	pmk := WPAPMK("password", "IEEE")
+	expected := "f42c6fc52df0ebef9ebb4b90b38a5f902e83fe1b135a70e23aed762e9710a12e"
+	if got := hex.EncodeToString(pmk); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestWPAPMKID(t *testing.T) {
+	// hashcat 22000 example hash, password hashcat!
+	ap, _ := net.ParseMAC("fc:69:0c:15:82:64")
+	sta, _ := net.ParseMAC("f4:74:7f:87:f9:f4")
+	pmk := WPAPMK("hashcat!", "hashcat-essid")
+	expected := "4d4fe7aac3a2cecab195321ceb99a7d0"
+	if got := hex.EncodeToString(WPAPMKID(pmk, ap, sta)); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestWPAKeyData(t *testing.T) {
+	ap := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	sta := net.HardwareAddr{1, 0, 0, 0, 0, 1}
+	anonce := bytes.Repeat([]byte{1}, 32)
+	snonce := bytes.Repeat([]byte{2}, 32)
+
+	data := wpaKeyData(ap, sta, anonce, snonce)
+	if len(data) != 76 {
+		t.Fatalf("expected 76 bytes, got %d", len(data))
+	} else if !bytes.Equal(data[:6], sta) || !bytes.Equal(data[6:12], ap) {
+		t.Errorf("addresses not sorted: %x", data[:12])
+	} else if !bytes.Equal(data[12:44], anonce) || !bytes.Equal(data[44:], snonce) {
+		t.Errorf("nonces not sorted: %x", data[12:])
+	}
+}
+
+func TestAESCMAC(t *testing.T) {
+	// RFC 4493 4. Test Vectors
+	key := unhex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	msg := unhex(t, "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411")
+	cases := []struct {
+		size     int
+		expected string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+	}
+
+	for _, c := range cases {
+		if got := hex.EncodeToString(aesCMAC(key, msg[:c.size])); got != c.expected {
+			t.Errorf("size %d: expected %s, got %s", c.size, c.expected, got)
+		}
+	}
+}
+
+func TestWPAZeroMIC(t *testing.T) {
+	frame := make([]byte, 121)
+	frame[3] = 95
+	for i := range frame[WPAEAPOLMICOffset : WPAEAPOLMICOffset+WPAEAPOLMICSize] {
+		frame[WPAEAPOLMICOffset+i] = 0xff
+	}
+
+	zeroed := WPAZeroMIC(frame)
+	if len(zeroed) != 99 {
+		t.Fatalf("expected frame truncated to 99 bytes, got %d", len(zeroed))
+	} else if !bytes.Equal(zeroed[WPAEAPOLMICOffset:WPAEAPOLMICOffset+WPAEAPOLMICSize], make([]byte, WPAEAPOLMICSize)) {
+		t.Errorf("MIC not zeroed: %x", zeroed)
+	} else if frame[WPAEAPOLMICOffset] != 0xff {
+		t.Error("original frame modified")
+	}
+
+	if WPAZeroMIC(frame[:50]) != nil {
+		t.Error("expected nil for a short frame")
+	}
+}
+
+func TestWPAMIC(t *testing.T) {
+	ap := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	sta := net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+	anonce := bytes.Repeat([]byte{0xaa}, 32)
+	snonce := bytes.Repeat([]byte{0xbb}, 32)
+	pmk := WPAPMK("correct horse", "test")
+
+	frame := make([]byte, 99)
+	frame[1], frame[3] = 3, 95
+
+	for _, version := range []uint8{WPAKeyDescriptorHMACMD5, WPAKeyDescriptorHMACSHA1, WPAKeyDescriptorAESCMAC} {
+		kck := WPAKCK(pmk, ap, sta, anonce, snonce, version)
+		if len(kck) != 16 {
+			t.Fatalf("version %d: unexpected KCK size %d", version, len(kck))
+		}
+
+		mic := WPAMIC(kck, frame, version)
+		if len(mic) != 16 {
+			t.Fatalf("version %d: unexpected MIC size %d", version, len(mic))
+		}
+
+		// same inputs must verify, a different passphrase must not
+		other := WPAKCK(WPAPMK("wrong horse", "test"), ap, sta, anonce, snonce, version)
+		if !bytes.Equal(mic, WPAMIC(kck, frame, version)) {
+			t.Errorf("version %d: MIC is not deterministic", version)
+		} else if bytes.Equal(mic, WPAMIC(other, frame, version)) {
+			t.Errorf("version %d: MIC matched with the wrong key", version)
+		}
+	}
+}
+
+func TestWPAPRF(t *testing.T) {
+	// IEEE 802.11i-2004 H.3 PRF test vectors
+	key := bytes.Repeat([]byte{0x0b}, 20)
+	expected := "bcd4c650b30b9684951829e0d75f9d54b862175ed9f00606e17d8da35402ffee75df78c3d31e0f889f012120c0862beb67753e7439ae242edb8373698356cf5a"
+	if got := hex.EncodeToString(wpaPRF(key, []byte("prefix"), []byte("Hi There"), 64)); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	// shorter outputs are a prefix of the longer ones
+	if got := hex.EncodeToString(wpaPRF(key, []byte("prefix"), []byte("Hi There"), 16)); got != expected[:32] {
+		t.Errorf("expected %s, got %s", expected[:32], got)
+	}
+}
@@ -0,0 +1,203 @@
+package session
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CredentialPassword  = "password"
+	CredentialHash      = "hash"
+	CredentialToken     = "token"
+	CredentialHandshake = "handshake"
+)
+
+// Credential is a secret captured by a module.
+type Credential struct {
+	Protocol    string    `json:"protocol"`
+	Module      string    `json:"module"`
+	Client      string    `json:"client"`
+	Host        string    `json:"host"`
+	User        string    `json:"user"`
+	Type        string    `json:"type"`
+	Secret      string    `json:"secret"`
+	HashcatMode int       `json:"hashcat_mode,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Seen        int       `json:"seen"`
+}
+
+// Key is what credentials are deduplicated by.
+func (c Credential) Key() string {
+	return strings.ToLower(c.Protocol + "|" + c.Host + "|" + c.User)
+}
+
+type CredentialNewCallback func(c Credential)
+
+// Credentials is the session wide store of captured credentials, every
+// module can push into it and reporting tools can consume it.
+type Credentials struct {
+	sync.RWMutex
+	items map[string]*Credential
+	newCb CredentialNewCallback
+}
+
+var credentialsCSVHeader = []string{
+	"first_seen", "last_seen", "seen", "module", "protocol", "client", "host", "user", "type", "secret", "hashcat_mode",
+}
+
+func NewCredentials(newCb CredentialNewCallback) *Credentials {
+	return &Credentials{
+		items: make(map[string]*Credential),
+		newCb: newCb,
+	}
+}
+
+func (c *Credentials) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.List())
+}
+
+// Add stores a credential, if one with the same protocol, host and user is
+// already known its secret is updated and it's not reported as new.
+func (c *Credentials) Add(cred Credential) bool {
+	c.Lock()
+
+	now := time.Now()
+	if cred.LastSeen.IsZero() {
+		cred.LastSeen = now
+	}
+
+	key := cred.Key()
+	if known, found := c.items[key]; found {
+		known.LastSeen = cred.LastSeen
+		known.Seen++
+		if cred.Secret != "" {
+			known.Module = cred.Module
+			known.Client = cred.Client
+			known.Type = cred.Type
+			known.Secret = cred.Secret
+			known.HashcatMode = cred.HashcatMode
+		}
+		c.Unlock()
+		return false
+	}
+
+	if cred.FirstSeen.IsZero() {
+		cred.FirstSeen = cred.LastSeen
+	}
+	cred.Seen = 1
+	c.items[key] = &cred
+	c.Unlock()
+
+	if c.newCb != nil {
+		c.newCb(cred)
+	}
+	return true
+}
+
+// List returns a copy of the stored credentials sorted by first seen time.
+func (c *Credentials) List() []Credential {
+	c.RLock()
+	defer c.RUnlock()
+
+	list := make([]Credential, 0, len(c.items))
+	for _, cred := range c.items {
+		list = append(list, *cred)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].FirstSeen.Equal(list[j].FirstSeen) {
+			return list[i].Key() < list[j].Key()
+		}
+		return list[i].FirstSeen.Before(list[j].FirstSeen)
+	})
+
+	return list
+}
+
+func (c *Credentials) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.items)
+}
+
+func (c *Credentials) Clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.items = make(map[string]*Credential)
+}
+
+// HashcatModes returns the sorted list of hashcat modes of the stored hashes.
+func (c *Credentials) HashcatModes() []int {
+	modes := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, cred := range c.List() {
+		if cred.HashcatMode > 0 && !seen[cred.HashcatMode] {
+			seen[cred.HashcatMode] = true
+			modes = append(modes, cred.HashcatMode)
+		}
+	}
+	sort.Ints(modes)
+	return modes
+}
+
+func (c *Credentials) ExportJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c.List())
+}
+
+func (c *Credentials) ExportCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(credentialsCSVHeader); err != nil {
+		return err
+	}
+
+	for _, cred := range c.List() {
+		mode := ""
+		if cred.HashcatMode > 0 {
+			mode = strconv.Itoa(cred.HashcatMode)
+		}
+
+		if err := writer.Write([]string{
+			cred.FirstSeen.Format(time.RFC3339),
+			cred.LastSeen.Format(time.RFC3339),
+			strconv.Itoa(cred.Seen),
+			cred.Module,
+			cred.Protocol,
+			cred.Client,
+			cred.Host,
+			cred.User,
+			cred.Type,
+			cred.Secret,
+			mode,
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportHashcat writes the hashes of the given hashcat mode one per line,
+// ready to be cracked. It returns the number of hashes written.
+func (c *Credentials) ExportHashcat(w io.Writer, mode int) (int, error) {
+	num := 0
+	for _, cred := range c.List() {
+		if cred.HashcatMode == mode && cred.Secret != "" {
+			if _, err := fmt.Fprintln(w, cred.Secret); err != nil {
+				return num, err
+			}
+			num++
+		}
+	}
+	return num, nil
+}
@@ -0,0 +1,90 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialsAdd(t *testing.T) {
+	added := make([]Credential, 0)
+	creds := NewCredentials(func(c Credential) {
+		added = append(added, c)
+	})
+
+	first := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	if !creds.Add(Credential{Protocol: "ftp", Host: "10.0.0.1", User: "bob", Type: CredentialPassword, Secret: "s3cr3t", LastSeen: first}) {
+		t.Fatal("expected a new credential")
+	}
+	// same protocol, host and user regardless of the case
+	if creds.Add(Credential{Protocol: "FTP", Host: "10.0.0.1", User: "Bob", Type: CredentialPassword, Secret: "changed", LastSeen: first.Add(time.Minute)}) {
+		t.Fatal("expected a duplicate credential")
+	}
+	if !creds.Add(Credential{Protocol: "ftp", Host: "10.0.0.2", User: "bob", Type: CredentialPassword, Secret: "other", LastSeen: first.Add(-time.Minute)}) {
+		t.Fatal("expected a new credential")
+	}
+
+	if len(added) != 2 {
+		t.Fatalf("expected 2 new credentials, got %d", len(added))
+	} else if creds.Len() != 2 {
+		t.Fatalf("expected 2 credentials, got %d", creds.Len())
+	}
+
+	list := creds.List()
+	if list[0].Host != "10.0.0.2" {
+		t.Errorf("expected credentials sorted by first seen time, got %v", list)
+	}
+
+	bob := list[1]
+	if bob.Seen != 2 || bob.Secret != "changed" || !bob.FirstSeen.Equal(first) || !bob.LastSeen.Equal(first.Add(time.Minute)) {
+		t.Errorf("unexpected credential %+v", bob)
+	}
+
+	creds.Clear()
+	if creds.Len() != 0 {
+		t.Errorf("expected no credentials after clear")
+	}
+}
+
+func TestCredentialsExport(t *testing.T) {
+	creds := NewCredentials(nil)
+	creds.Add(Credential{Protocol: "http", Module: "net.sniff", Host: "example.com", User: "alice", Type: CredentialPassword, Secret: "pa,ss"})
+	creds.Add(Credential{Protocol: "ntlm", Host: "10.0.0.1", User: "CORP\\alice", Type: CredentialHash, Secret: "alice::CORP:v2", HashcatMode: 5600})
+	creds.Add(Credential{Protocol: "smb", Host: "10.0.0.2", User: "CORP\\bob", Type: CredentialHash, Secret: "bob::CORP:v1", HashcatMode: 5500})
+	creds.Add(Credential{Protocol: "ntlm", Host: "10.0.0.3", User: "CORP\\carol", Type: CredentialHash, Secret: "carol::CORP:v2", HashcatMode: 5600})
+
+	if modes := creds.HashcatModes(); len(modes) != 2 || modes[0] != 5500 || modes[1] != 5600 {
+		t.Errorf("unexpected modes %v", modes)
+	}
+
+	buf := bytes.Buffer{}
+	if num, err := creds.ExportHashcat(&buf, 5600); err != nil {
+		t.Fatal(err)
+	} else if num != 2 || buf.String() != "alice::CORP:v2\ncarol::CORP:v2\n" {
+		t.Errorf("unexpected hashcat export (%d): %q", num, buf.String())
+	}
+
+	buf.Reset()
+	if err := creds.ExportCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "first_seen,") {
+		t.Fatalf("unexpected csv export %q", buf.String())
+	} else if !strings.Contains(lines[1], `,net.sniff,http,,example.com,alice,password,"pa,ss",`) {
+		t.Errorf("unexpected csv row %q", lines[1])
+	}
+
+	buf.Reset()
+	if err := creds.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Credential
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	} else if len(decoded) != 4 || decoded[1].HashcatMode != 5600 {
+		t.Errorf("unexpected json export %v", decoded)
+	}
+}
@@ -86,6 +86,8 @@ type Session struct {
 	Modules   ModuleList
 	Aliases   *data.UnsortedKV
 
+	Credentials *Credentials
+
 	Input            *readline.Instance
 	Prompt           Prompt
 	CoreHandlers     []CommandHandler
@@ -141,6 +143,9 @@ func WithOptions(opts core.Options) (s *Session, err error) {
 	}
 
 	s.Events = NewEventPool(s.Options.Debug, s.Options.Silent)
+	s.Credentials = NewCredentials(func(c Credential) {
+		s.Events.Add("creds.new", c)
+	})
 
 	s.registerCoreHandlers()
 
@@ -278,7 +283,7 @@ func (s *Session) Start() error {
 		go s.routeMon()
 	}
 
-	s.Firewall = firewall.Make(s.Interface)
+	s.Firewall = firewall.Make(s.Interface, s.Options.Firewall)
 
 	s.CAN = network.NewCAN(s.Aliases, func(dev *network.CANDevice) {
 		s.Events.Add("can.device.new", dev)
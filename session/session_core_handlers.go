@@ -325,6 +325,13 @@ func (s *Session) propagateAlias(mac, alias string) {
 		sta.SetAlias(alias)
 	}
 
+	// the other randomized addresses of the same device
+	for _, sibling := range s.WiFi.Devices().Siblings(mac) {
+		if sta, found := s.WiFi.GetClient(sibling); found {
+			sta.SetAlias(alias)
+		}
+	}
+
 	if host, found := s.Lan.Get(mac); found {
 		host.Alias = alias
 	}
@@ -66,6 +66,7 @@ type SessionJSON struct {
 	Active     bool              `json:"active"`
 	GPS        GPS               `json:"gps"`
 	Modules    ModuleList        `json:"modules"`
+	Creds      *Credentials      `json:"credentials"`
 	Caplets    []*caplets.Caplet `json:"caplets"`
 }
 
@@ -103,6 +104,7 @@ func (s *Session) MarshalJSON() ([]byte, error) {
 		Active:     s.Active,
 		GPS:        s.GPS,
 		Modules:    s.Modules,
+		Creds:      s.Credentials,
 		Caplets:    caplets.List(),
 	}
 